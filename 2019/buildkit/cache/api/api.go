@@ -2,11 +2,13 @@ package api
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"bitbucket.org/okteto/okteto/backend/app"
+	"bitbucket.org/okteto/okteto/backend/config"
 	"bitbucket.org/okteto/okteto/backend/logger"
 
 	"bitbucket.org/okteto/okteto/backend/model"
@@ -29,6 +31,10 @@ func Init(s *app.Server) http.Handler {
 
 func newAPI(s *app.Server) *API {
 	a := &API{app: s}
+	if config.GetGithubWebhookSecret() == "" {
+		log.Printf("the github webhook secret is not configured, github events will be rejected")
+	}
+
 	a.initRouter()
 	a.registerServices()
 	return a
@@ -47,9 +53,14 @@ func (a *API) registerServices() {
 	a.Container.Add(a.registerProjectsAPI())
 	a.Container.Add(a.registerEventsAPI())
 	a.Container.Add(a.registerUsersAPI())
+	a.Container.Add(a.registerUnsubscribeAPI())
 	a.Container.Add(a.registerAuthAPI())
 	a.Container.Add(a.registerGithubAPI())
+	a.Container.Add(a.registerGitlabAPI())
+	a.Container.Add(a.registerBitbucketAPI())
 	a.Container.Add(a.registerConfigAPI())
+	a.Container.Add(a.registerSchemasAPI())
+	a.Container.Add(a.registerManifestsAPI())
 
 	cors := restful.CrossOriginResourceSharing{
 		ExposeHeaders:  []string{"x-okteto"},
@@ -89,6 +100,39 @@ func (a *API) registerConfigAPI() *restful.WebService {
 	return ws
 }
 
+func (a *API) registerSchemasAPI() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/api/v1/schemas").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	ws.Route(ws.GET("/{version}/{schema}").To(a.getSchema).
+		Param(ws.PathParameter("version", "version of the schema").DataType("string")).
+		Param(ws.PathParameter("schema", "service.json or project-settings.json").DataType("string")).
+		Returns(http.StatusOK, "OK", model.JSONSchema{}).
+		Returns(http.StatusNotFound, "Not Found", nil))
+
+	return ws
+}
+
+func (a *API) registerManifestsAPI() *restful.WebService {
+	ws := new(restful.WebService).Filter(a.apiTokenAuthentication)
+
+	ws.Path("/api/v1/manifests").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	ws.Route(ws.POST("/validate").To(a.validateManifest).
+		Reads(model.ManifestValidation{}).
+		Writes(model.ManifestValidationResult{}).
+		Returns(http.StatusOK, "OK", model.ManifestValidationResult{}).
+		Returns(http.StatusBadRequest, "Bad Request", nil).
+		Returns(http.StatusNotFound, "Not Found", nil))
+
+	return ws
+}
+
 func (a *API) registerProjectsAPI() *restful.WebService {
 	ws := new(restful.WebService).Filter(a.apiTokenAuthentication)
 
@@ -136,9 +180,64 @@ func (a *API) registerProjectsAPI() *restful.WebService {
 
 	ws.Route(ws.GET("/{project-id}/github/repositories").To(a.getGHRepositories).
 		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Param(ws.QueryParameter("provider", "github, gitlab or bitbucket, github by default").DataType("string")).
 		Returns(200, "OK", model.Project{}).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(ws.GET("/{project-id}/github/deliveries").To(a.getGHDeliveries).
+		Writes([]model.GHDelivery{}).
+		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Param(ws.QueryParameter("status", "pending, processing, processed or failed").DataType("string")).
+		Returns(200, "OK", []model.GHDelivery{}).
+		Returns(400, "Bad Request", nil).
+		Returns(403, "Forbidden", nil))
+
+	ws.Route(ws.POST("/{project-id}/github/deliveries/{delivery-id}/replay").To(a.replayGHDelivery).
+		Writes(model.GHDelivery{}).
+		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Param(ws.PathParameter("delivery-id", "identifier of the github delivery").DataType("string")).
+		Returns(200, "OK", model.GHDelivery{}).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Conflict", nil))
+
+	ws.Route(ws.GET("/{project-id}/webhooks").To(a.getProjectWebhooks).
+		Writes([]model.ProjectWebhook{}).
+		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Returns(200, "OK", []model.ProjectWebhook{}).
+		Returns(403, "Forbidden", nil))
+
+	ws.Route(ws.POST("/{project-id}/webhooks").To(a.createProjectWebhook).
+		Reads(model.ProjectWebhook{}).
+		Writes(model.ProjectWebhook{}).
+		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Returns(201, "Created", model.ProjectWebhook{}).
+		Returns(400, "Bad Request", nil).
+		Returns(403, "Forbidden", nil))
+
+	ws.Route(ws.DELETE("/{project-id}/webhooks/{webhook-id}").To(a.deleteProjectWebhook).
+		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Param(ws.PathParameter("webhook-id", "identifier of the webhook").DataType("string")).
+		Returns(200, "OK", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{project-id}/webhooks/{webhook-id}/deliveries").To(a.getWebhookDeliveries).
+		Writes([]model.WebhookDelivery{}).
+		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Param(ws.PathParameter("webhook-id", "identifier of the webhook").DataType("string")).
+		Returns(200, "OK", []model.WebhookDelivery{}).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/{project-id}/webhooks/{webhook-id}/ping").To(a.pingProjectWebhook).
+		Writes(model.WebhookDelivery{}).
+		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
+		Param(ws.PathParameter("webhook-id", "identifier of the webhook").DataType("string")).
+		Returns(200, "OK", model.WebhookDelivery{}).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
 	ws.Route(ws.GET("/{project-id}/services/{service-id}").To(a.getService).
 		Writes(model.Service{}).
 		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
@@ -180,9 +279,10 @@ func (a *API) registerProjectsAPI() *restful.WebService {
 
 	ws.Route(ws.PUT("/{project-id}/services/{service-id}/github").To(a.linkGHRepositoryToService).
 		Reads(GHRepository{}).
+		Writes(GHRepository{}).
 		Param(ws.PathParameter("project-id", "identifier of the project").DataType("string")).
 		Param(ws.PathParameter("service-id", "identifier of the service").DataType("string")).
-		Returns(204, "OK", nil).
+		Returns(200, "OK", GHRepository{}).
 		Returns(404, "Not Found", nil))
 
 	ws.Route(ws.DELETE("/{project-id}/services/{service-id}/github").To(a.unlinkGHRepositoryToService).
@@ -281,6 +381,41 @@ func (a *API) registerUsersAPI() *restful.WebService {
 	ws.Route(ws.DELETE("").To(a.deleteUser).
 		Returns(204, "OK", nil))
 
+	ws.Route(ws.GET("/notifications").To(a.getEmailPreferences).
+		Writes(model.EmailPreferences{}).
+		Returns(200, "OK", model.EmailPreferences{}))
+
+	ws.Route(ws.PUT("/notifications").To(a.updateEmailPreferences).
+		Reads(model.EmailPreferences{}).
+		Writes(model.EmailPreferences{}).
+		Returns(200, "OK", model.EmailPreferences{}).
+		Returns(400, "Bad Request", nil))
+
+	return ws
+}
+
+func (a *API) registerUnsubscribeAPI() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/api/v1/unsubscribe").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	// the confirmation form of the unsubscribe page is posted by the browser
+	ws.Route(ws.POST("/{key}").To(a.unsubscribe).
+		Consumes(restful.MIME_JSON, formContentType).
+		Produces(restful.MIME_JSON, "text/html").
+		Param(ws.PathParameter("key", "unsubscribe key included in the emails").DataType("string")).
+		Returns(204, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	// the link of the emails, it's opened by the browser and only shows the confirmation form
+	ws.Route(ws.GET("/{key}").To(a.unsubscribeFromLink).
+		Produces(restful.MIME_JSON, "text/html").
+		Param(ws.PathParameter("key", "unsubscribe key included in the emails").DataType("string")).
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
 	return ws
 }
 
@@ -298,6 +433,36 @@ func (a *API) registerGithubAPI() *restful.WebService {
 	return ws
 }
 
+func (a *API) registerGitlabAPI() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/api/v1/gitlab").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	ws.Route(ws.POST("/events").To(a.gitlabWebhook).
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(401, "Unauthorized", nil))
+
+	return ws
+}
+
+func (a *API) registerBitbucketAPI() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/api/v1/bitbucket").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	ws.Route(ws.POST("/events").To(a.bitbucketWebhook).
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(401, "Unauthorized", nil))
+
+	return ws
+}
+
 func durationFilter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 	start := time.Now()
 	chain.ProcessFilter(req, resp)
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 
 	"bitbucket.org/okteto/okteto/backend/app"
 	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
 	"github.com/pkg/errors"
 
 	restful "github.com/emicklei/go-restful"
@@ -13,36 +17,147 @@ import (
 
 const (
 	// integration_installation is the legacy event. It was superceded by `installation`
-	githubEventHeader = "X-Github-Event"
+	githubEventHeader    = "X-Github-Event"
+	githubDeliveryHeader = "X-Github-Delivery"
+
+	acceptedDelivery    = "accepted"
+	rejectedDelivery    = "rejected"
+	duplicateDelivery   = "duplicate"
+	unsupportedDelivery = "unsupported"
+	invalidDelivery     = "invalid"
+
+	// maxWebhookSize is the largest delivery accepted, github caps the payloads of its webhooks at 25MB
+	maxWebhookSize = 25 << 20
 )
 
-// GHRepository contains info about a github repo. It can be used both to send info and read link requests
+// GHRepository contains info about a github, gitlab or bitbucket repo. It can be used both to send info and read link requests
 type GHRepository struct {
 	Owner    string `json:"owner,omitempty"`
 	Name     string `json:"name,omitempty"`
 	Branch   string `json:"branch,omitempty"`
+	Tag      string `json:"tag,omitempty"`
 	Manifest string `json:"manifest,omitempty"`
+	Overlay  string `json:"overlay,omitempty"`
 	URL      string `json:"url,omitempty"`
+
+	// Provider is the source control provider of the repository, github by default
+	Provider model.SCMProviderType `json:"provider,omitempty"`
+
+	// AutoDeploy deploys the service on every push to the branch
+	AutoDeploy bool `json:"auto_deploy,omitempty"`
+
+	// Paths are the globs of the files that affect the service, every push updates the service if it's empty
+	Paths []string `json:"paths,omitempty"`
+
+	// WebhookURL and WebhookSecret configure the webhook of a linked gitlab or bitbucket repository
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
 }
 
 func (a *API) ghWebhook(request *restful.Request, response *restful.Response) {
-	event := request.HeaderParameter("X-Github-Event")
-	logger.Info("ghWebhook called on %s for event '%s'", request.Request.RequestURI, event)
+	event := request.HeaderParameter(githubEventHeader)
+	delivery := request.HeaderParameter(githubDeliveryHeader)
+	logger.Info("ghWebhook called on %s for event '%s' delivery '%s'", request.Request.RequestURI, event, delivery)
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(response, request.Request.Body, maxWebhookSize))
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to read the github webhook"))
+		githubWebhooks.WithLabelValues(invalidDelivery).Inc()
+		response.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !a.app.VerifyGithubWebhook(request.Request.Header, body) {
+		log.Printf("rejected github delivery '%s' for event '%s': invalid signature", delivery, event)
+		githubWebhooks.WithLabelValues(rejectedDelivery).Inc()
+		response.WriteHeader(http.StatusUnauthorized)
+		return
+	}
 
 	if !app.IsGithubEventSupported(event) {
 		log.Printf("unknown github event %s", event)
+		githubWebhooks.WithLabelValues(unsupportedDelivery).Inc()
+		return
+	}
+
+	if delivery == "" {
+		logger.Error(fmt.Errorf("github delivery for event '%s' didn't have the %s header", event, githubDeliveryHeader))
+		githubWebhooks.WithLabelValues(invalidDelivery).Inc()
+		response.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	payload := &app.GHWebhookPayload{}
-	err := request.ReadEntity(&payload)
-	if err != nil {
-		logger.Error(errors.Wrap(err, "failed to load json from github webhook"))
+	if err := json.Unmarshal(body, payload); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load json from github delivery '%s'", delivery))
+		githubWebhooks.WithLabelValues(invalidDelivery).Inc()
 		response.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	payload.Event = event
-	a.app.QueueGithubEvent(payload)
+	isNew, err := a.app.QueueGithubEvent(delivery, payload, body)
+	if err != nil {
+		logger.Error(err)
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !isNew {
+		log.Printf("github delivery '%s' was already received", delivery)
+		githubWebhooks.WithLabelValues(duplicateDelivery).Inc()
+		return
+	}
 
+	githubWebhooks.WithLabelValues(acceptedDelivery).Inc()
+}
+
+func (a *API) getGHDeliveries(request *restful.Request, response *restful.Response) {
+	u := getAuthenticatedUser(request)
+	p := getRequestedProject(request)
+
+	if !p.LoadedSettings.IsAdmin(&u.Email) {
+		response.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	deliveries, err := a.app.GetGHDeliveries(p, model.GHDeliveryStatus(request.QueryParameter("status")))
+	if err != nil {
+		writeGHDeliveryError(response, p, err)
+		return
+	}
+
+	response.WriteEntity(deliveries)
+}
+
+func (a *API) replayGHDelivery(request *restful.Request, response *restful.Response) {
+	u := getAuthenticatedUser(request)
+	p := getRequestedProject(request)
+
+	if !p.LoadedSettings.IsAdmin(&u.Email) {
+		response.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	delivery, err := a.app.ReplayGHDelivery(p, request.PathParameter("delivery-id"))
+	if err != nil {
+		writeGHDeliveryError(response, p, err)
+		return
+	}
+
+	response.WriteEntity(delivery)
+}
+
+func writeGHDeliveryError(response *restful.Response, p *model.Project, err error) {
+	switch {
+	case err == model.ErrProjectNotLinkedToGithub:
+		response.WriteHeader(http.StatusBadRequest)
+	case err == model.ErrDeliveryNotFailed:
+		response.WriteHeader(http.StatusConflict)
+	case isNotFoundErr(err):
+		response.WriteHeader(http.StatusNotFound)
+	default:
+		logger.Error(errors.Wrapf(err, "failed to handle the github deliveries of project-%s", p.ID))
+		response.WriteHeader(http.StatusInternalServerError)
+	}
 }
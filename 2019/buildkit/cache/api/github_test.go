@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/app"
+	"bitbucket.org/okteto/okteto/backend/store"
+	restful "github.com/emicklei/go-restful"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/viper"
+)
+
+func sign(h func() hash.Hash, secret, body string) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func countDeliveries(t *testing.T, result string) float64 {
+	m := &dto.Metric{}
+	if err := githubWebhooks.WithLabelValues(result).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+func Test_ghWebhook(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	viper.Set("github.webhooksecret", "secret")
+	defer viper.Set("github.webhooksecret", "")
+
+	a := &API{app: &app.Server{DB: db}}
+	body := `{"action":"deleted","installation":{"id":1}}`
+
+	tests := []struct {
+		name      string
+		event     string
+		delivery  string
+		header    string
+		signature string
+		body      string
+		expected  int
+		result    string
+	}{
+		{
+			name:      "sha256",
+			event:     "installation",
+			delivery:  "a1",
+			header:    "X-Hub-Signature-256",
+			signature: "sha256=" + sign(sha256.New, "secret", body),
+			expected:  http.StatusOK,
+			result:    acceptedDelivery,
+		},
+		{
+			name:      "sha1",
+			event:     "installation",
+			delivery:  "b2",
+			header:    "X-Hub-Signature",
+			signature: "sha1=" + sign(sha1.New, "secret", body),
+			expected:  http.StatusOK,
+			result:    acceptedDelivery,
+		},
+		{
+			name:      "duplicate",
+			event:     "installation",
+			delivery:  "a1",
+			header:    "X-Hub-Signature",
+			signature: "sha1=" + sign(sha1.New, "secret", body),
+			expected:  http.StatusOK,
+			result:    duplicateDelivery,
+		},
+		{
+			name:     "missing-signature",
+			event:    "installation",
+			delivery: "c3",
+			expected: http.StatusUnauthorized,
+			result:   rejectedDelivery,
+		},
+		{
+			name:      "wrong-secret",
+			event:     "installation",
+			delivery:  "d4",
+			header:    "X-Hub-Signature-256",
+			signature: "sha256=" + sign(sha256.New, "other", body),
+			expected:  http.StatusUnauthorized,
+			result:    rejectedDelivery,
+		},
+		{
+			name:      "modified-body",
+			event:     "installation",
+			delivery:  "e5",
+			header:    "X-Hub-Signature-256",
+			signature: "sha256=" + sign(sha256.New, "secret", body),
+			body:      `{"action":"created","installation":{"id":1}}`,
+			expected:  http.StatusUnauthorized,
+			result:    rejectedDelivery,
+		},
+		{
+			name:      "unsupported",
+			event:     "issues",
+			delivery:  "f6",
+			header:    "X-Hub-Signature-256",
+			signature: "sha256=" + sign(sha256.New, "secret", body),
+			expected:  http.StatusOK,
+			result:    unsupportedDelivery,
+		},
+		{
+			name:      "invalid-json",
+			event:     "installation",
+			delivery:  "g7",
+			header:    "X-Hub-Signature-256",
+			signature: "sha256=" + sign(sha256.New, "secret", "{"),
+			body:      "{",
+			expected:  http.StatusBadRequest,
+			result:    invalidDelivery,
+		},
+		{
+			name:      "missing-delivery",
+			event:     "installation",
+			header:    "X-Hub-Signature-256",
+			signature: "sha256=" + sign(sha256.New, "secret", body),
+			expected:  http.StatusBadRequest,
+			result:    invalidDelivery,
+		},
+		{
+			name:     "too-large",
+			event:    "installation",
+			delivery: "h8",
+			body:     strings.Repeat(" ", maxWebhookSize+1),
+			expected: http.StatusBadRequest,
+			result:   invalidDelivery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := body
+			if tt.body != "" {
+				content = tt.body
+			}
+
+			httpReq, _ := http.NewRequest("POST", "http://localhost/api/v1/github/events", bytes.NewBufferString(content))
+			httpReq.Header.Add("Content-Type", "application/json")
+			httpReq.Header.Add(githubEventHeader, tt.event)
+			httpReq.Header.Add(githubDeliveryHeader, tt.delivery)
+			if tt.header != "" {
+				httpReq.Header.Add(tt.header, tt.signature)
+			}
+
+			before := countDeliveries(t, tt.result)
+			recorder := httptest.NewRecorder()
+			a.ghWebhook(restful.NewRequest(httpReq), restful.NewResponse(recorder))
+
+			if recorder.Code != tt.expected {
+				t.Errorf("expected %d got %d", tt.expected, recorder.Code)
+			}
+
+			if countDeliveries(t, tt.result) != before+1 {
+				t.Errorf("the delivery wasn't counted as %s", tt.result)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	restful "github.com/emicklei/go-restful"
+	"github.com/pkg/errors"
+)
+
+func (a *API) validateManifest(request *restful.Request, response *restful.Response) {
+	v := &model.ManifestValidation{}
+	if err := request.ReadEntity(v); err != nil {
+		appErr := &model.AppError{Status: http.StatusBadRequest, Code: model.InvalidJSON}
+		response.WriteHeaderAndEntity(appErr.Status, appErr)
+		return
+	}
+
+	u := getAuthenticatedUser(request)
+	result, appErr := a.app.ValidateManifest(v, u)
+	if appErr != nil {
+		logger.Error(errors.Wrapf(appErr, "failed to validate manifest"))
+		response.WriteHeaderAndEntity(appErr.Status, appErr)
+		return
+	}
+
+	response.WriteEntity(result)
+}
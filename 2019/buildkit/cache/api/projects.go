@@ -207,17 +207,18 @@ func (a *API) startWebsocket(request *restful.Request, response *restful.Respons
 
 	projectID := request.PathParameters()["project-id"]
 
-	ws := a.app.Hub.StartNewClient(conn, projectID, a.app.GetUserByToken)
+	ws := a.app.Hub.StartNewClient(conn, projectID, a.app.GetUserByToken, a.app.CanReadActivityLogs, a.app.GetActivityLogsFrom)
 	log.Printf("starting ws-%s", ws)
 }
 
 func (a *API) getGHRepositories(request *restful.Request, response *restful.Response) {
 	p := getRequestedProject(request)
+	provider := getSCMProviderType(request.QueryParameter("provider"))
 
-	repositories, err := a.app.GetGHRepositories(p)
+	repositories, err := a.app.GetSCMRepositories(p, provider)
 	if err != nil {
-		if err == model.ErrProjectNotLinkedToGithub {
-			logger.Error(errors.Wrapf(err, "project-%s is not linked with gh", p.ID))
+		if err == model.ErrProjectNotLinkedToGithub || err == model.ErrProjectNotLinkedToSCM || errors.Cause(err) == model.ErrUnknownSCMProvider {
+			logger.Error(errors.Wrapf(err, "project-%s is not linked with %s", p.ID, provider))
 			response.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -237,15 +238,25 @@ func (a *API) getGHRepositories(request *restful.Request, response *restful.Resp
 	out := make([]GHRepository, len(repositories))
 	for i := range repositories {
 		out[i] = GHRepository{
-			Name:  repositories[i].GetName(),
-			Owner: repositories[i].GetOwner().GetLogin(),
-			URL:   repositories[i].GetURL(),
+			Name:     repositories[i].Name,
+			Owner:    repositories[i].Owner,
+			URL:      repositories[i].URL,
+			Provider: provider,
 		}
 	}
 
 	response.WriteEntity(out)
 }
 
+// getSCMProviderType returns the provider of a request, github by default
+func getSCMProviderType(provider string) model.SCMProviderType {
+	if provider == "" {
+		return model.GithubProvider
+	}
+
+	return model.SCMProviderType(provider)
+}
+
 func isNotFoundErr(err error) bool {
 	cause := errors.Cause(err)
 	return cause == model.ErrNotFound
@@ -15,6 +15,20 @@ var (
 			Buckets: []float64{.25, .5, 1, 2.5, 5, 10},
 		},
 		[]string{"status", "route"})
+
+	githubWebhooks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "okteto_github_webhooks_total",
+			Help: "A counter for the github webhook deliveries by result.",
+		},
+		[]string{"result"})
+
+	scmWebhooks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "okteto_scm_webhooks_total",
+			Help: "A counter for the gitlab and bitbucket webhook deliveries by provider and result.",
+		},
+		[]string{"provider", "result"})
 )
 
 func buildMetricsHandler(handler http.Handler) http.Handler {
@@ -42,7 +56,7 @@ func buildMetricsHandler(handler http.Handler) http.Handler {
 		[]string{},
 	)
 
-	prometheus.MustRegister(inFlightGauge, counter, duration, responseSize)
+	prometheus.MustRegister(inFlightGauge, counter, duration, responseSize, githubWebhooks, scmWebhooks)
 
 	metricsChain := promhttp.InstrumentHandlerInFlight(inFlightGauge,
 		promhttp.InstrumentHandlerCounter(counter,
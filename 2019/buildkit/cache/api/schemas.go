@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"bitbucket.org/okteto/okteto/backend/config"
+	"bitbucket.org/okteto/okteto/backend/model"
+	restful "github.com/emicklei/go-restful"
+)
+
+const (
+	serviceSchema         = "service.json"
+	projectSettingsSchema = "project-settings.json"
+)
+
+// getSchemaURL returns the public URL of a schema, it's used as the $id of the schema
+func getSchemaURL(schema string) string {
+	return fmt.Sprintf("%s/schemas/%s/%s", config.GetAPIURL(), model.SchemaVersion, schema)
+}
+
+func (a *API) getSchema(request *restful.Request, response *restful.Response) {
+	if request.PathParameter("version") != model.SchemaVersion {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	name := request.PathParameter("schema")
+	switch name {
+	case serviceSchema:
+		response.WriteEntity(model.GetServiceSchema(getSchemaURL(name)))
+	case projectSettingsSchema:
+		response.WriteEntity(model.GetProjectSettingsSchema(getSchemaURL(name)))
+	default:
+		response.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"bitbucket.org/okteto/okteto/backend/app"
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+const (
+	gitlabEventHeader    = "X-Gitlab-Event"
+	gitlabDeliveryHeader = "X-Gitlab-Event-UUID"
+
+	bitbucketEventHeader    = "X-Event-Key"
+	bitbucketDeliveryHeader = "X-Request-UUID"
+)
+
+func (a *API) gitlabWebhook(request *restful.Request, response *restful.Response) {
+	event := request.HeaderParameter(gitlabEventHeader)
+	delivery := request.HeaderParameter(gitlabDeliveryHeader)
+	a.scmWebhook(model.GitlabProvider, event, delivery, request, response)
+}
+
+func (a *API) bitbucketWebhook(request *restful.Request, response *restful.Response) {
+	event := request.HeaderParameter(bitbucketEventHeader)
+	delivery := request.HeaderParameter(bitbucketDeliveryHeader)
+	a.scmWebhook(model.BitbucketProvider, event, delivery, request, response)
+}
+
+// scmWebhook queues the deliveries of the gitlab and bitbucket webhooks
+func (a *API) scmWebhook(provider model.SCMProviderType, event, delivery string, request *restful.Request, response *restful.Response) {
+	logger.Info("%s webhook called on %s for event '%s' delivery '%s'", provider, request.Request.RequestURI, event, delivery)
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(response, request.Request.Body, maxWebhookSize))
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to read the %s webhook", provider))
+		scmWebhooks.WithLabelValues(string(provider), invalidDelivery).Inc()
+		response.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the deliveries are verified with the secret of the project that linked the repository of the payload
+	if !json.Valid(body) {
+		log.Printf("%s delivery '%s' is not valid json", provider, delivery)
+		scmWebhooks.WithLabelValues(string(provider), invalidDelivery).Inc()
+		response.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	projectID, ok := a.app.VerifySCMWebhook(provider, request.Request.Header, body)
+	if !ok {
+		log.Printf("rejected %s delivery '%s' for event '%s': invalid secret", provider, delivery, event)
+		scmWebhooks.WithLabelValues(string(provider), rejectedDelivery).Inc()
+		response.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !app.IsSCMEventSupported(provider, event) {
+		log.Printf("unknown %s event %s", provider, event)
+		scmWebhooks.WithLabelValues(string(provider), unsupportedDelivery).Inc()
+		return
+	}
+
+	// old gitlab versions don't send the ID of the delivery, the pushes are identified by their project and content instead
+	if delivery == "" {
+		h := sha256.Sum256(append([]byte(projectID), body...))
+		delivery = hex.EncodeToString(h[:])
+	}
+
+	isNew, err := a.app.QueueSCMEvent(provider, projectID, delivery, event, body)
+	if err != nil {
+		logger.Error(err)
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !isNew {
+		log.Printf("%s delivery '%s' was already received", provider, delivery)
+		scmWebhooks.WithLabelValues(string(provider), duplicateDelivery).Inc()
+		return
+	}
+
+	scmWebhooks.WithLabelValues(string(provider), acceptedDelivery).Inc()
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/app"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+	restful "github.com/emicklei/go-restful"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func countSCMDeliveries(t *testing.T, provider, result string) float64 {
+	m := &dto.Metric{}
+	if err := scmWebhooks.WithLabelValues(provider, result).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+func Test_scmWebhook(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	links := []*model.GHRepoLink{
+		{Provider: model.GitlabProvider, Repository: "okteto/app", Branch: "refs/heads/master", ProjectID: "p1", WebhookSecret: "secret"},
+		{Provider: model.BitbucketProvider, Repository: "okteto/app", Branch: "refs/heads/master", ProjectID: "p1", WebhookSecret: "secret"},
+	}
+	for _, l := range links {
+		if err := db.Create(l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := &API{app: &app.Server{DB: db}}
+	body := `{"ref":"refs/heads/master","project":{"path_with_namespace":"okteto/app"},"repository":{"full_name":"okteto/app"}}`
+
+	tests := []struct {
+		name     string
+		provider string
+		headers  map[string]string
+		body     string
+		expected int
+		result   string
+	}{
+		{
+			name:     "gitlab",
+			provider: "gitlab",
+			headers:  map[string]string{gitlabEventHeader: "Push Hook", gitlabDeliveryHeader: "a1", "X-Gitlab-Token": "secret"},
+			expected: http.StatusOK,
+			result:   acceptedDelivery,
+		},
+		{
+			name:     "gitlab-without-delivery",
+			provider: "gitlab",
+			headers:  map[string]string{gitlabEventHeader: "Push Hook", "X-Gitlab-Token": "secret"},
+			expected: http.StatusOK,
+			result:   acceptedDelivery,
+		},
+		{
+			name:     "gitlab-duplicate",
+			provider: "gitlab",
+			headers:  map[string]string{gitlabEventHeader: "Push Hook", gitlabDeliveryHeader: "a1", "X-Gitlab-Token": "secret"},
+			expected: http.StatusOK,
+			result:   duplicateDelivery,
+		},
+		{
+			name:     "gitlab-wrong-token",
+			provider: "gitlab",
+			headers:  map[string]string{gitlabEventHeader: "Push Hook", gitlabDeliveryHeader: "b2", "X-Gitlab-Token": "other"},
+			expected: http.StatusUnauthorized,
+			result:   rejectedDelivery,
+		},
+		{
+			name:     "gitlab-unsupported",
+			provider: "gitlab",
+			headers:  map[string]string{gitlabEventHeader: "Issue Hook", gitlabDeliveryHeader: "c3", "X-Gitlab-Token": "secret"},
+			expected: http.StatusOK,
+			result:   unsupportedDelivery,
+		},
+		{
+			name:     "bitbucket",
+			provider: "bitbucket",
+			headers:  map[string]string{bitbucketEventHeader: "repo:push", bitbucketDeliveryHeader: "d4", "X-Hub-Signature": "sha256=" + sign(sha256.New, "secret", body)},
+			expected: http.StatusOK,
+			result:   acceptedDelivery,
+		},
+		{
+			name:     "bitbucket-unknown-repository",
+			provider: "bitbucket",
+			headers:  map[string]string{bitbucketEventHeader: "repo:push", bitbucketDeliveryHeader: "f6", "X-Hub-Signature": "sha256=" + sign(sha256.New, "secret", `{"repository":{"full_name":"okteto/other"}}`)},
+			body:     `{"repository":{"full_name":"okteto/other"}}`,
+			expected: http.StatusUnauthorized,
+			result:   rejectedDelivery,
+		},
+		{
+			name:     "bitbucket-invalid-json",
+			provider: "bitbucket",
+			headers:  map[string]string{bitbucketEventHeader: "repo:push", bitbucketDeliveryHeader: "e5", "X-Hub-Signature": "sha256=" + sign(sha256.New, "secret", "{")},
+			body:     "{",
+			expected: http.StatusBadRequest,
+			result:   invalidDelivery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := body
+			if tt.body != "" {
+				content = tt.body
+			}
+
+			httpReq, _ := http.NewRequest("POST", "http://localhost/api/v1/"+tt.provider+"/events", bytes.NewBufferString(content))
+			httpReq.Header.Add("Content-Type", "application/json")
+			for k, v := range tt.headers {
+				httpReq.Header.Add(k, v)
+			}
+
+			before := countSCMDeliveries(t, tt.provider, tt.result)
+			recorder := httptest.NewRecorder()
+			if tt.provider == "gitlab" {
+				a.gitlabWebhook(restful.NewRequest(httpReq), restful.NewResponse(recorder))
+			} else {
+				a.bitbucketWebhook(restful.NewRequest(httpReq), restful.NewResponse(recorder))
+			}
+
+			if recorder.Code != tt.expected {
+				t.Errorf("expected %d got %d", tt.expected, recorder.Code)
+			}
+
+			if countSCMDeliveries(t, tt.provider, tt.result) != before+1 {
+				t.Errorf("the delivery wasn't counted as %s", tt.result)
+			}
+		})
+	}
+}
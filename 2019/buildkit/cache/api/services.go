@@ -5,14 +5,23 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/emicklei/go-restful"
 	"github.com/pkg/errors"
 
+	"bitbucket.org/okteto/okteto/backend/config"
 	"bitbucket.org/okteto/okteto/backend/logger"
 	"bitbucket.org/okteto/okteto/backend/model"
 )
 
+//UpdateServiceRequest is the request to update the manifest of a service. A missing overlay keeps the overlay of the
+//service, an empty one removes it
+type UpdateServiceRequest struct {
+	Manifest string  `json:"manifest"`
+	Overlay  *string `json:"overlay"`
+}
+
 func (a *API) getService(request *restful.Request, response *restful.Response) {
 	serviceID := request.PathParameter("service-id")
 	project := getRequestedProject(request)
@@ -24,6 +33,7 @@ func (a *API) getService(request *restful.Request, response *restful.Response) {
 		return
 	}
 
+	a.app.LoadReplicaStatus(service, project)
 	response.WriteEntity(service)
 }
 
@@ -136,7 +146,7 @@ func (a *API) updateService(request *restful.Request, response *restful.Response
 	serviceID := request.PathParameter("service-id")
 	project := getRequestedProject(request)
 	user := getAuthenticatedUser(request)
-	d := &model.Service{}
+	d := &UpdateServiceRequest{}
 	err := request.ReadEntity(&d)
 	if err != nil {
 		appErr := &model.AppError{Status: http.StatusBadRequest, Code: model.InvalidJSON}
@@ -144,8 +154,9 @@ func (a *API) updateService(request *restful.Request, response *restful.Response
 		return
 	}
 
-	d.ID = serviceID
-	appErr := a.app.UpdateManifest(project.ID, serviceID, d.Manifest, user.ID, "Manifest updated manually")
+	// the current overlay is kept when the request doesn't set one
+	update := &model.Service{Manifest: d.Manifest}
+	appErr := a.app.UpdateManifest(project.ID, serviceID, update, d.Overlay, user.ID, "Manifest updated manually")
 	if appErr != nil {
 		logger.Error(errors.Wrapf(appErr, "failed to update service-%s", serviceID))
 		response.WriteHeaderAndEntity(appErr.Status, appErr)
@@ -201,12 +212,6 @@ func (a *API) linkGHRepositoryToService(request *restful.Request, response *rest
 	serviceID := request.PathParameter("service-id")
 	project := getRequestedProject(request)
 
-	if project.GHInstallationID == "" {
-		response.WriteHeader(http.StatusBadRequest)
-		logger.Error(fmt.Errorf("project-%s is not linked to github", project.ID))
-		return
-	}
-
 	r := &GHRepository{}
 	err := request.ReadEntity(&r)
 	if err != nil {
@@ -215,10 +220,32 @@ func (a *API) linkGHRepositoryToService(request *restful.Request, response *rest
 		return
 	}
 
-	err = a.app.LinkGHRepositoryToService(project, serviceID, r.Owner, r.Name, r.Branch, r.Manifest)
+	provider := getSCMProviderType(string(r.Provider))
+	if provider == model.GithubProvider && project.GHInstallationID == "" {
+		response.WriteHeader(http.StatusBadRequest)
+		logger.Error(fmt.Errorf("project-%s is not linked to github", project.ID))
+		return
+	}
+
+	// the branch and the tag can be glob patterns, the tags are linked by their full ref
+	branch := r.Branch
+	if r.Tag != "" {
+		branch = model.TagRefPrefix + strings.TrimPrefix(r.Tag, model.TagRefPrefix)
+	}
+
+	link, err := a.app.LinkGHRepositoryToService(project, serviceID, provider, r.Owner, r.Name, branch, r.Manifest, r.Overlay, r.Paths, r.AutoDeploy)
 	if err != nil {
 		logger.Error(errors.Wrapf(err, "failed to link service-%s", serviceID))
+		return
+	}
+
+	// the gitlab and bitbucket webhooks are added to the repository with the secret of the project
+	if provider != model.GithubProvider {
+		r.WebhookURL = fmt.Sprintf("%s/%s/events", config.GetAPIURL(), provider)
+		r.WebhookSecret = link.WebhookSecret
 	}
+
+	response.WriteEntity(r)
 }
 
 func (a *API) unlinkGHRepositoryToService(request *restful.Request, response *restful.Response) {
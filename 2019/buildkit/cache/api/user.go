@@ -1,7 +1,9 @@
 package api
 
 import (
+	"html/template"
 	"net/http"
+	"strings"
 
 	"bitbucket.org/okteto/okteto/backend/logger"
 	"bitbucket.org/okteto/okteto/backend/model"
@@ -109,3 +111,112 @@ func (a *API) claimGHInstallation(request *restful.Request, response *restful.Re
 
 	response.WriteHeader(http.StatusOK)
 }
+
+func (a *API) getEmailPreferences(request *restful.Request, response *restful.Response) {
+	u := getAuthenticatedUser(request)
+	prefs, err := a.app.GetEmailPreferences(u)
+	if err != nil {
+		logger.Error(err)
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteEntity(prefs)
+}
+
+func (a *API) updateEmailPreferences(request *restful.Request, response *restful.Response) {
+	u := getAuthenticatedUser(request)
+	update := model.EmailPreferences{}
+	if err := request.ReadEntity(&update); err != nil {
+		appErr := &model.AppError{Status: http.StatusBadRequest, Code: model.InvalidJSON}
+		response.WriteHeaderAndEntity(appErr.Status, appErr)
+		return
+	}
+
+	prefs, err := a.app.UpdateEmailPreferences(u, &update)
+	if err != nil {
+		logger.Error(err)
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteEntity(prefs)
+}
+
+// formContentType is the content type of the forms posted by the browser
+const formContentType = "application/x-www-form-urlencoded"
+
+// unsubscribePage is the page opened by the unsubscribe link of the emails. The link only shows the page, the user
+// is unsubscribed when the form is posted, so the email clients and scanners that open links don't unsubscribe them
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Okteto</title></head>
+<body>
+<p>{{.Message}}</p>
+{{if .Confirm}}<form method="post"><button type="submit">Unsubscribe</button></form>{{end}}
+</body>
+</html>
+`))
+
+func (a *API) unsubscribe(request *restful.Request, response *restful.Response) {
+	// the confirmation form of the unsubscribe page is posted by the browser
+	fromPage := strings.HasPrefix(request.Request.Header.Get("Content-Type"), formContentType)
+
+	err := a.app.Unsubscribe(request.PathParameter("key"))
+	if err != nil {
+		if isNotFoundErr(err) {
+			if fromPage {
+				writeUnsubscribePage(response, http.StatusNotFound, "The unsubscribe link is not valid.", false)
+				return
+			}
+
+			response.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		logger.Error(err)
+		if fromPage {
+			writeUnsubscribePage(response, http.StatusInternalServerError, "Something went wrong, please try again later.", false)
+			return
+		}
+
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if fromPage {
+		writeUnsubscribePage(response, http.StatusOK, "You have been unsubscribed, Okteto won't email you anymore.", false)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) unsubscribeFromLink(request *restful.Request, response *restful.Response) {
+	err := a.app.CheckUnsubscribeKey(request.PathParameter("key"))
+	if err != nil {
+		if isNotFoundErr(err) {
+			writeUnsubscribePage(response, http.StatusNotFound, "The unsubscribe link is not valid.", false)
+			return
+		}
+
+		logger.Error(err)
+		writeUnsubscribePage(response, http.StatusInternalServerError, "Something went wrong, please try again later.", false)
+		return
+	}
+
+	writeUnsubscribePage(response, http.StatusOK, "Do you want to stop receiving emails from Okteto?", true)
+}
+
+func writeUnsubscribePage(response *restful.Response, status int, message string, confirm bool) {
+	response.AddHeader("Content-Type", "text/html; charset=utf-8")
+	response.WriteHeader(status)
+	data := struct {
+		Message string
+		Confirm bool
+	}{message, confirm}
+
+	if err := unsubscribePage.Execute(response, data); err != nil {
+		logger.Error(errors.Wrap(err, "failed to render the unsubscribe page"))
+	}
+}
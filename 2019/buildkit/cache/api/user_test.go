@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/app"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+	restful "github.com/emicklei/go-restful"
+)
+
+func Test_unsubscribeFromLink(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	prefs := model.NewEmailPreferences("user")
+	if err := db.Create(prefs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	a := &API{app: &app.Server{DB: db}}
+	container := restful.NewContainer()
+	container.Add(a.registerUnsubscribeAPI())
+
+	tests := []struct {
+		name     string
+		method   string
+		key      string
+		expected int
+	}{
+		{name: "wrong-key", method: http.MethodGet, key: "wrong", expected: http.StatusNotFound},
+		{name: "key", method: http.MethodGet, key: prefs.UnsubscribeKey, expected: http.StatusOK},
+		{name: "confirm-wrong-key", method: http.MethodPost, key: "wrong", expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/unsubscribe/"+tt.key, nil)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+			if tt.method == http.MethodPost {
+				req.Header.Set("Content-Type", formContentType)
+			}
+
+			recorder := httptest.NewRecorder()
+			container.ServeHTTP(recorder, req)
+			if recorder.Code != tt.expected {
+				t.Errorf("expected %d got %d: %s", tt.expected, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+
+	saved := &model.EmailPreferences{}
+	if err := db.Where("user_id = ?", "user").First(saved).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.Unsubscribed {
+		t.Fatalf("opening the link unsubscribed the user")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/unsubscribe/"+prefs.UnsubscribeKey, strings.NewReader(""))
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	req.Header.Set("Content-Type", formContentType)
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+
+	if err := db.Where("user_id = ?", "user").First(saved).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if !saved.Unsubscribed {
+		t.Errorf("the confirmation didn't unsubscribe the user")
+	}
+}
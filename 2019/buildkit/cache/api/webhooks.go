@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/pkg/errors"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+)
+
+func (a *API) getProjectWebhooks(request *restful.Request, response *restful.Response) {
+	p, ok := getAdministeredProject(request, response)
+	if !ok {
+		return
+	}
+
+	webhooks, err := a.app.GetProjectWebhooks(p)
+	if err != nil {
+		writeWebhookError(response, p, err)
+		return
+	}
+
+	response.WriteEntity(webhooks)
+}
+
+func (a *API) createProjectWebhook(request *restful.Request, response *restful.Response) {
+	p, ok := getAdministeredProject(request, response)
+	if !ok {
+		return
+	}
+
+	w := &model.ProjectWebhook{}
+	if err := request.ReadEntity(w); err != nil {
+		appErr := &model.AppError{Status: http.StatusBadRequest, Code: model.InvalidJSON}
+		response.WriteHeaderAndEntity(appErr.Status, appErr)
+		return
+	}
+
+	w, appErr := a.app.CreateProjectWebhook(p, w)
+	if appErr != nil {
+		logger.Error(errors.Wrapf(appErr, "failed to create a webhook for project-%s", p.ID))
+		response.WriteHeaderAndEntity(appErr.Status, appErr)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusCreated, w)
+}
+
+func (a *API) deleteProjectWebhook(request *restful.Request, response *restful.Response) {
+	p, ok := getAdministeredProject(request, response)
+	if !ok {
+		return
+	}
+
+	if err := a.app.DeleteProjectWebhook(p, request.PathParameter("webhook-id")); err != nil {
+		writeWebhookError(response, p, err)
+	}
+}
+
+func (a *API) getWebhookDeliveries(request *restful.Request, response *restful.Response) {
+	p, ok := getAdministeredProject(request, response)
+	if !ok {
+		return
+	}
+
+	deliveries, err := a.app.GetWebhookDeliveries(p, request.PathParameter("webhook-id"))
+	if err != nil {
+		writeWebhookError(response, p, err)
+		return
+	}
+
+	response.WriteEntity(deliveries)
+}
+
+func (a *API) pingProjectWebhook(request *restful.Request, response *restful.Response) {
+	p, ok := getAdministeredProject(request, response)
+	if !ok {
+		return
+	}
+
+	delivery, err := a.app.PingProjectWebhook(p, request.PathParameter("webhook-id"))
+	if err != nil {
+		writeWebhookError(response, p, err)
+		return
+	}
+
+	response.WriteEntity(delivery)
+}
+
+// getAdministeredProject returns the requested project if the authenticated user is one of its administrators
+func getAdministeredProject(request *restful.Request, response *restful.Response) (*model.Project, bool) {
+	u := getAuthenticatedUser(request)
+	p := getRequestedProject(request)
+	if !p.LoadedSettings.IsAdmin(&u.Email) {
+		response.WriteHeader(http.StatusForbidden)
+		return nil, false
+	}
+
+	return p, true
+}
+
+func writeWebhookError(response *restful.Response, p *model.Project, err error) {
+	if isNotFoundErr(err) {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	logger.Error(errors.Wrapf(err, "failed to handle the webhooks of project-%s", p.ID))
+	response.WriteHeader(http.StatusInternalServerError)
+}
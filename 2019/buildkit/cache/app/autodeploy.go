@@ -0,0 +1,128 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+// autoDeployLease is how long a replica owns a pending auto deploy while it starts it. If the replica stops before
+// the deployment starts, the auto deploy is run again once the lease expires
+const autoDeployLease = 5 * time.Minute
+
+// autoDeployDelay is how long a service waits for more pushes before it's deployed
+var autoDeployDelay = 10 * time.Second
+
+// scheduleAutoDeploy saves commit as the pending auto deploy of the service, it's deployed after autoDeployDelay. If
+// another push arrives before, the deployment is delayed again, so a burst of pushes only deploys the manifest of the
+// newest commit. The pending auto deploy is saved before returning, the deliveries are retried if it fails
+func (s *Server) scheduleAutoDeploy(serviceID, commit string) error {
+	at := time.Now().UTC().Add(autoDeployDelay)
+	r := s.DB.Model(&model.Service{}).Where("id = ?", serviceID).
+		UpdateColumns(map[string]interface{}{"auto_deploy_commit": commit, "auto_deploy_at": at})
+	if r.Error != nil {
+		return errors.Wrapf(r.Error, "failed to schedule the auto deploy of commit %s of service-%s", commit, serviceID)
+	}
+
+	time.AfterFunc(autoDeployDelay, s.runAutoDeploys)
+	return nil
+}
+
+// cancelAutoDeploy discards the pending auto deploy of the service, if any
+func (s *Server) cancelAutoDeploy(serviceID string) error {
+	r := s.DB.Model(&model.Service{}).Where("id = ?", serviceID).
+		UpdateColumns(map[string]interface{}{"auto_deploy_commit": "", "auto_deploy_at": nil})
+	if r.Error != nil {
+		return errors.Wrapf(r.Error, "failed to cancel the auto deploy of service-%s", serviceID)
+	}
+
+	return nil
+}
+
+// runAutoDeploys starts the pending auto deploys that are due. It runs after the delay of every push, when an activity
+// finishes, since it might have blocked an auto deploy, and periodically to run the ones of other replicas
+func (s *Server) runAutoDeploys() {
+	var services []model.Service
+	if err := s.DB.Where("auto_deploy_at <= ?", time.Now().UTC()).Order("auto_deploy_at").Find(&services).Error; err != nil {
+		logger.Error(errors.Wrap(err, "failed to get the pending auto deploys"))
+		return
+	}
+
+	for i := range services {
+		s.runAutoDeploy(&services[i])
+	}
+}
+
+func (s *Server) runAutoDeploy(service *model.Service) {
+	commit := service.AutoDeployCommit
+
+	// the lease makes the other replicas skip the auto deploy until it finishes or the lease expires
+	r := s.DB.Model(&model.Service{}).Where("id = ? AND auto_deploy_commit = ? AND auto_deploy_at <= ?", service.ID, commit, time.Now().UTC()).
+		UpdateColumn("auto_deploy_at", time.Now().UTC().Add(autoDeployLease))
+	if r.Error != nil {
+		logger.Error(errors.Wrapf(r.Error, "failed to claim the auto deploy of service-%s", service.ID))
+		return
+	}
+
+	if r.RowsAffected != 1 {
+		return
+	}
+
+	wait, err := s.startAutoDeploy(service, commit)
+	if err != nil {
+		logger.Error(err)
+	}
+
+	values := map[string]interface{}{"auto_deploy_commit": "", "auto_deploy_at": nil}
+	if wait {
+		// the activity that blocks the service runs the auto deploy again when it finishes
+		values = map[string]interface{}{"auto_deploy_at": time.Now().UTC()}
+	}
+
+	// a newer push replaces the commit, the auto deploy is kept for it
+	r = s.DB.Model(&model.Service{}).Where("id = ? AND auto_deploy_commit = ?", service.ID, commit).UpdateColumns(values)
+	if r.Error != nil {
+		logger.Error(errors.Wrapf(r.Error, "failed to save the auto deploy of service-%s", service.ID))
+	}
+}
+
+// startAutoDeploy deploys commit, the manifest of the service is already updated. It returns true if another
+// operation of the service is in progress and the auto deploy must wait for it
+func (s *Server) startAutoDeploy(service *model.Service, commit string) (bool, error) {
+	project, err := s.getProjectByID(service.ProjectID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to load project-%s to auto deploy service-%s", service.ProjectID, service.ID)
+	}
+
+	if project == nil {
+		log.Printf("project-%s doesn't exist, service-%s won't be auto deployed", service.ProjectID, service.ID)
+		return false, nil
+	}
+
+	if service.IsDestroyed() {
+		log.Printf("service-%s was destroyed, commit %s won't be auto deployed", service.ID, commit)
+		return false, nil
+	}
+
+	// deploying would disable the dev mode of the developer
+	if service.Dev {
+		log.Printf("service-%s is in dev mode, commit %s won't be auto deployed", service.ID, commit)
+		return false, nil
+	}
+
+	appErr := s.StartService(project, service.ID, githubUser())
+	if appErr == nil {
+		log.Printf("auto deploying commit %s of service-%s", commit, service.ID)
+		return false, nil
+	}
+
+	if appErr.Code == model.InvalidServiceStatus {
+		log.Printf("service-%s is busy, commit %s will be auto deployed when it finishes", service.ID, commit)
+		return true, nil
+	}
+
+	return false, errors.Wrapf(appErr, "failed to auto deploy commit %s of service-%s", commit, service.ID)
+}
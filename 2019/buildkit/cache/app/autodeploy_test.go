@@ -0,0 +1,202 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+func countActivities(t *testing.T, s *Server, serviceID string, activityType model.ActivityType) int {
+	count := 0
+	if err := s.DB.Model(&model.Activity{}).Where("service_id = ? AND type = ?", serviceID, activityType).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestAutoDeployDebounce(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	autoDeployDelay = 50 * time.Millisecond
+	defer func() { autoDeployDelay = 10 * time.Second }()
+
+	s := Server{DB: db}
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	svc := &model.Service{Manifest: httpsService, Name: "service"}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	for _, commit := range []string{"a1", "b2", "c3"} {
+		if err := s.scheduleAutoDeploy(svc.ID, commit); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.waitUntil(p, svc.ID, model.DeployedService); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * autoDeployDelay)
+	if count := countActivities(t, &s, svc.ID, model.Deployed); count != 1 {
+		t.Errorf("expected 1 deployment, got %d", count)
+	}
+
+	var activity model.Activity
+	if err := db.Where("service_id = ? AND type = ?", svc.ID, model.Deployed).First(&activity).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if activity.ActorID != githubActorID {
+		t.Errorf("the deployment wasn't done by the github actor: %s", activity.ActorID)
+	}
+}
+
+func TestAutoDeployDevMode(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	autoDeployDelay = 10 * time.Millisecond
+	defer func() { autoDeployDelay = 10 * time.Second }()
+
+	s := Server{DB: db}
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	svc := &model.Service{Manifest: httpsService, Name: "service"}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	if err := db.Model(svc).Update("dev", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.scheduleAutoDeploy(svc.ID, "a1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * autoDeployDelay)
+
+	if count := countActivities(t, &s, svc.ID, model.Deployed); count != 0 {
+		t.Errorf("a service in dev mode was deployed %d times", count)
+	}
+}
+
+func TestAutoDeployWaitsForTheBlockingActivity(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	autoDeployDelay = 10 * time.Millisecond
+	defer func() { autoDeployDelay = 10 * time.Second }()
+
+	s := Server{DB: db}
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	svc := &model.Service{Manifest: httpsService, Name: "service"}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	blocking := &model.Activity{ServiceID: svc.ID, ActorID: u.ID, Type: model.Deployed, Status: model.InProgress}
+	if err := db.Create(blocking).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Model(svc).Update("status", model.DeployingService).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.scheduleAutoDeploy(svc.ID, "a1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fixed number of attempts would give up while the activity is in progress
+	for i := 0; i < 50; i++ {
+		s.runAutoDeploys()
+		time.Sleep(autoDeployDelay / 5)
+	}
+
+	if count := countActivities(t, &s, svc.ID, model.Deployed); count != 1 {
+		t.Fatalf("the service was deployed while it was busy: %d", count)
+	}
+
+	if err := s.updateServiceActivity(p, svc.ID, blocking.ID, model.Completed, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.waitUntil(p, svc.ID, model.DeployedService); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countActivities(t, &s, svc.ID, model.Deployed); count != 2 {
+		t.Errorf("the auto deploy didn't run when the activity finished: %d", count)
+	}
+}
+
+func TestAutoDeployIsKeptAcrossRestarts(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	autoDeployDelay = time.Hour
+	defer func() { autoDeployDelay = 10 * time.Second }()
+
+	s := Server{DB: db}
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	svc := &model.Service{Manifest: httpsService, Name: "service"}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	if err := s.scheduleAutoDeploy(svc.ID, "a1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the timer of the first server never fires, the pending auto deploy is due after a restart
+	if err := db.Model(svc).UpdateColumn("auto_deploy_at", time.Now().UTC().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := Server{DB: db}
+	restarted.runAutoDeploys()
+	if err := restarted.waitUntil(p, svc.ID, model.DeployedService); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved model.Service
+	if err := db.Where("id = ?", svc.ID).First(&saved).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.AutoDeployCommit != "" || saved.AutoDeployAt != nil {
+		t.Errorf("the auto deploy is still pending: %s %v", saved.AutoDeployCommit, saved.AutoDeployAt)
+	}
+}
@@ -0,0 +1,279 @@
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	bitbucketSignatureHeader = "X-Hub-Signature"
+	bitbucketPushEvent       = "repo:push"
+
+	// bitbucket limits the key of the commit statuses to 40 characters
+	maxBitbucketStatusKey = 40
+)
+
+var (
+	bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+	bitbucketURL    = "https://bitbucket.org"
+
+	// bitbucketStates are the commit status states of bitbucket for every github state
+	bitbucketStates = map[string]string{
+		ghPendingState: "INPROGRESS",
+		ghSuccessState: "SUCCESSFUL",
+		ghFailureState: "FAILED",
+	}
+)
+
+// BitbucketSCM is the bitbucket cloud implementation of SCMProvider, it authenticates with the app password of the
+// project settings
+type BitbucketSCM struct {
+	s *Server
+}
+
+type bitbucketRepository struct {
+	Slug       string `json:"slug"`
+	FullName   string `json:"full_name"`
+	MainBranch *struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+// bitbucketPushPayload is the payload of a bitbucket push event
+type bitbucketPushPayload struct {
+	Actor struct {
+		Nickname string `json:"nickname"`
+	} `json:"actor"`
+	Repository *bitbucketRepository `json:"repository"`
+	Push       struct {
+		Changes []struct {
+			New *struct {
+				Type   string `json:"type"`
+				Name   string `json:"name"`
+				Target struct {
+					Hash string `json:"hash"`
+				} `json:"target"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+}
+
+// ListRepositories returns the bitbucket repositories where the user of p is a member
+func (b *BitbucketSCM) ListRepositories(p *model.Project) ([]*SCMRepository, error) {
+	credentials, err := getSCMCredentials(p, model.BitbucketProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newBitbucketRequest(credentials, http.MethodGet, bitbucketAPIURL+"/repositories?role=member&pagelen=100", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var page struct {
+		Values []bitbucketRepository `json:"values"`
+	}
+
+	if err := decodeSCMResponse(req, &page); err != nil {
+		return nil, errors.Wrapf(err, "failed to get bitbucket repositories for project-%s", p.ID)
+	}
+
+	result := make([]*SCMRepository, len(page.Values))
+	for i := range page.Values {
+		result[i] = page.Values[i].toSCMRepository()
+	}
+
+	return result, nil
+}
+
+// GetRepository returns the bitbucket repository owner/name, where owner is the workspace
+func (b *BitbucketSCM) GetRepository(p *model.Project, owner, name string) (*SCMRepository, error) {
+	credentials, err := getSCMCredentials(p, model.BitbucketProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/repositories/%s/%s", bitbucketAPIURL, url.PathEscape(owner), url.PathEscape(name))
+	req, err := newBitbucketRequest(credentials, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo bitbucketRepository
+	if err := decodeSCMResponse(req, &repo); err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s/%s via the bitbucket api", owner, name)
+	}
+
+	return repo.toSCMRepository(), nil
+}
+
+// GetFile returns the raw content of a file of the linked repository at ref
+func (b *BitbucketSCM) GetFile(link *model.GHRepoLink, path, ref string) (string, error) {
+	credentials, err := b.s.getLinkCredentials(link)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/repositories/%s/src/%s/%s", bitbucketAPIURL, link.Repository, url.PathEscape(getShortRefName(ref)), strings.TrimPrefix(path, "/"))
+	req, err := newBitbucketRequest(credentials, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := sendSCMRequest(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get %s of %s at %s", path, link.Repository, ref)
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s of %s at %s", path, link.Repository, ref)
+	}
+
+	return string(content), nil
+}
+
+// DownloadRepository extracts the archive of the linked repository at commit into dir
+func (b *BitbucketSCM) DownloadRepository(link *model.GHRepoLink, commit, dir string) error {
+	credentials, err := b.s.getLinkCredentials(link)
+	if err != nil {
+		return err
+	}
+
+	req, err := newBitbucketRequest(credentials, http.MethodGet, fmt.Sprintf("%s/%s/get/%s.tar.gz", bitbucketURL, link.Repository, url.PathEscape(commit)), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := downloadSCMArchive(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to download the archive of %s at %s", link.Repository, commit)
+	}
+	defer res.Body.Close()
+
+	return extractTarball(res.Body, dir)
+}
+
+// VerifyWebhook returns true if the delivery is signed with the secret of the bitbucket webhook
+func (b *BitbucketSCM) VerifyWebhook(header http.Header, body []byte, secret string) bool {
+	return validHubSignature([]byte(secret), body, header.Get(bitbucketSignatureHeader))
+}
+
+// CreateStatus creates a build status in the commit of the linked repository
+func (b *BitbucketSCM) CreateStatus(link *model.GHRepoLink, commit string, status *SCMStatus) error {
+	credentials, err := b.s.getLinkCredentials(link)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]string{
+		"key":         getBitbucketStatusKey(status.Context),
+		"state":       bitbucketStates[status.State],
+		"name":        status.Context,
+		"url":         status.TargetURL,
+		"description": status.Description,
+	}
+
+	endpoint := fmt.Sprintf("%s/repositories/%s/commit/%s/statuses/build", bitbucketAPIURL, link.Repository, commit)
+	req, err := newBitbucketRequest(credentials, http.MethodPost, endpoint, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := sendSCMRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (s *Server) handleBitbucketEvent(projectID, event string, body []byte) error {
+	if event != bitbucketPushEvent {
+		return fmt.Errorf("unknown bitbucket event queued: %s", event)
+	}
+
+	payload := &bitbucketPushPayload{}
+	if err := json.Unmarshal(body, payload); err != nil {
+		return errors.Wrap(err, "failed to load the bitbucket push")
+	}
+
+	if payload.Repository == nil {
+		return errors.New("bitbucket push didn't have a repository")
+	}
+
+	// a push can update several branches and tags, the changes that delete them don't have a new state
+	for _, c := range payload.Push.Changes {
+		if c.New == nil {
+			continue
+		}
+
+		var ref string
+		switch c.New.Type {
+		case "branch":
+			ref = getCanonicalBranchName(c.New.Name)
+		case "tag", "annotated_tag":
+			ref = model.TagRefPrefix + c.New.Name
+		default:
+			continue
+		}
+
+		// bitbucket pushes don't have the changed files, every linked service is updated
+		if err := s.syncManifestFromSCM(model.BitbucketProvider, projectID, payload.Repository.FullName, ref, c.New.Target.Hash, payload.Actor.Nickname, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newBitbucketRequest(credentials *model.SCMCredentials, method, endpoint string, body interface{}) (*http.Request, error) {
+	req, err := newSCMRequest(method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(credentials.Username, credentials.Token)
+	return req, nil
+}
+
+// getBitbucketStatusKey returns the key of a commit status, the contexts that are too long are hashed
+func getBitbucketStatusKey(context string) string {
+	if len(context) <= maxBitbucketStatusKey {
+		return context
+	}
+
+	h := sha1.Sum([]byte(context))
+	return hex.EncodeToString(h[:])
+}
+
+func (r *bitbucketRepository) toSCMRepository() *SCMRepository {
+	owner, _ := splitRepository(r.FullName)
+	repo := &SCMRepository{
+		Owner:    owner,
+		Name:     r.Slug,
+		FullName: r.FullName,
+		URL:      r.Links.HTML.Href,
+	}
+
+	if r.MainBranch != nil {
+		repo.DefaultBranch = r.MainBranch.Name
+	}
+
+	return repo
+}
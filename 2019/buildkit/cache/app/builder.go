@@ -0,0 +1,395 @@
+package app
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	buildctlBinary       = "buildctl"
+	buildkitDigestKey    = "containerimage.digest"
+	dockerHubCredentials = "https://index.docker.io/v1/"
+
+	// archiveTimeout is the maximum time to download the archive of a repository
+	archiveTimeout = 5 * time.Minute
+)
+
+// archiveClient downloads the archives of the repositories to build
+var archiveClient = &http.Client{Timeout: archiveTimeout}
+
+// BuildRequest is an image to build from the source code of a repository and push to a registry
+type BuildRequest struct {
+	// Source is the directory with the source code of the repository
+	Source string
+
+	// Context and Dockerfile are relative to Source and Context
+	Context    string
+	Dockerfile string
+	Args       map[string]string
+	Target     string
+
+	// Image is where the image is pushed, Registry has its credentials if they are part of the project settings
+	Image    string
+	Registry *model.Registry
+}
+
+// Builder is an interface used to build and push the images of the containers. It returns the digest of the pushed image
+type Builder interface {
+	build(r *BuildRequest, l *log.Logger) (string, error)
+}
+
+// BuildKit builds the images with the buildctl client of a buildkitd daemon, implements the Builder interface
+type BuildKit struct {
+	Address string
+}
+
+// NewBuildKitBuilder returns a builder that uses the buildkitd daemon listening in address, e.g. tcp://buildkitd:1234
+func NewBuildKitBuilder(address string) Builder {
+	return &BuildKit{Address: address}
+}
+
+func (b *BuildKit) build(r *BuildRequest, l *log.Logger) (string, error) {
+	dir, err := ioutil.TempDir("", "buildctl-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeDockerConfig(dir, r.Image, r.Registry); err != nil {
+		return "", errors.Wrap(err, "failed to write the registry credentials")
+	}
+
+	metadata := filepath.Join(dir, "metadata.json")
+	context := filepath.Join(r.Source, r.Context)
+	dockerfile := filepath.Join(context, r.Dockerfile)
+	args := []string{
+		"--addr", b.Address, "build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + context,
+		"--local", "dockerfile=" + filepath.Dir(dockerfile),
+		"--opt", "filename=" + filepath.Base(dockerfile),
+		"--output", fmt.Sprintf("type=image,name=%s,push=true", r.Image),
+		"--progress", "plain",
+		"--metadata-file", metadata,
+	}
+
+	if r.Target != "" {
+		args = append(args, "--opt", "target="+r.Target)
+	}
+
+	keys := []string{}
+	for k := range r.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--opt", fmt.Sprintf("build-arg:%s=%s", k, r.Args[k]))
+	}
+
+	cmd := exec.Command(buildctlBinary, args...)
+	cmd.Env = append(os.Environ(), "DOCKER_CONFIG="+dir)
+
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return "", errors.Wrap(err, "failed to start buildctl")
+	}
+
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		l.Println(scanner.Text())
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return "", fmt.Errorf("the build of '%s' failed: %s", r.Image, err)
+	}
+
+	content, err := ioutil.ReadFile(metadata)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the build metadata")
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return "", errors.Wrap(err, "failed to decode the build metadata")
+	}
+
+	digest, _ := result[buildkitDigestKey].(string)
+	if digest == "" {
+		return "", fmt.Errorf("buildctl didn't return the digest of '%s'", r.Image)
+	}
+
+	return digest, nil
+}
+
+// writeDockerConfig saves the credentials of registry in dir/config.json, the file read by buildctl to push the images
+func writeDockerConfig(dir, image string, registry *model.Registry) error {
+	if registry == nil {
+		return nil
+	}
+
+	ref, err := model.ParseImageReference(image)
+	if err != nil {
+		return err
+	}
+
+	server := ref.Registry
+	if server == model.DockerHub {
+		server = dockerHubCredentials
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", registry.Username, registry.Password)))
+	config := map[string]interface{}{
+		"auths": map[string]interface{}{
+			server: map[string]string{"auth": auth},
+		},
+	}
+
+	content, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "config.json"), content, 0600)
+}
+
+// extractTarball extracts the gzipped tarball of a repository in dir. The archives of github have every file inside a
+// directory named after the repository and the commit, it is removed. Entries are never written through a symbolic
+// link and the links that don't resolve to a file of the repository are removed
+func extractTarball(r io.Reader, dir string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "the archive is not gzipped")
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return removeEscapingLinks(root)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read the archive")
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(h.Name, "./"), "/", 2)
+		if len(parts) < 2 {
+			continue
+		}
+
+		name := path.Clean(parts[1])
+		if name == "." {
+			continue
+		}
+
+		if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return fmt.Errorf("the archive contains the invalid path '%s'", h.Name)
+		}
+
+		// symbolic links are replaced by the entry, the rest must be created in the directories of the archive
+		checked := name
+		if h.Typeflag == tar.TypeSymlink {
+			checked = path.Dir(name)
+		}
+
+		inLink, err := hasSymlink(root, checked)
+		if err != nil {
+			return err
+		}
+		if inLink {
+			return fmt.Errorf("the archive contains the path '%s' inside of a symbolic link", h.Name)
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(name))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := writeFile(target, tr, os.FileMode(h.Mode)&0755); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// links outside of the repository would add files of the server to the build context
+			linked := path.Clean(path.Join(path.Dir(name), h.Linkname))
+			if path.IsAbs(h.Linkname) || linked == ".." || strings.HasPrefix(linked, "../") {
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(h.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// hasSymlink returns true if an existing element of the path name, relative to root, is a symbolic link
+func hasSymlink(root, name string) (bool, error) {
+	current := root
+	for _, e := range strings.Split(name, "/") {
+		if e == "." {
+			continue
+		}
+
+		current = filepath.Join(current, e)
+		fi, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// removeEscapingLinks removes the symbolic links of root that resolve outside of it, e.g. through other links, or that
+// don't resolve at all
+func removeEscapingLinks(root string) error {
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil && (resolved == root || strings.HasPrefix(resolved, root+string(filepath.Separator))) {
+			return nil
+		}
+
+		return os.Remove(p)
+	})
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// buildImages builds the images of the containers of service that have a build section from the linked repository at
+// the commit of d, pushes them and replaces the image of the containers by the pushed digest
+func (s *Server) buildImages(service *model.Service, d *model.Service, project *model.Project, activityID string) error {
+	if !service.HasBuilds() {
+		return nil
+	}
+
+	if s.Builder == nil {
+		return fmt.Errorf("Service '%s' builds its images but image builds are not enabled", service.Name)
+	}
+
+	if d.GHRepoLinkID == "" {
+		return fmt.Errorf("Service '%s' builds its images but it is not linked to a repository", service.Name)
+	}
+
+	link, err := s.getRepoLink(d)
+	if err != nil {
+		logger.Error(err)
+		return fmt.Errorf("The linked repository of service '%s' couldn't be read", service.Name)
+	}
+
+	provider, err := s.getLinkProvider(link)
+	if err != nil {
+		logger.Error(err)
+		return fmt.Errorf("The linked repository of service '%s' couldn't be read", service.Name)
+	}
+
+	dir, err := ioutil.TempDir("", "okteto-build-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create the build directory")
+	}
+	defer os.RemoveAll(dir)
+
+	commit := getServiceRef(link, d)
+	if err := fetchRepository(provider, link, commit, dir); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to download the repository of service-%s", d.ID))
+		return fmt.Errorf("The linked repository couldn't be downloaded at %s", commit)
+	}
+
+	l, stop := s.startLogs(activityID)
+	defer stop()
+
+	return forEachContainer(service, func(nC string, c *model.Container) error {
+		if c.Build == nil {
+			return nil
+		}
+
+		ref, err := model.ParseImageReference(c.Image)
+		if err != nil {
+			return err
+		}
+
+		var registry *model.Registry
+		if project.LoadedSettings != nil && isRegistryOf(project.LoadedSettings.Registry, ref) {
+			registry = project.LoadedSettings.Registry
+		}
+
+		l.Printf("Building the image '%s' of container '%s' at %s...", c.Image, nC, commit)
+		digest, err := s.Builder.build(&BuildRequest{
+			Source:     dir,
+			Context:    c.Build.GetContext(),
+			Dockerfile: c.Build.GetDockerfile(),
+			Args:       c.Build.Args,
+			Target:     c.Build.Target,
+			Image:      c.Image,
+			Registry:   registry,
+		}, l)
+		if err != nil {
+			return errors.Wrapf(err, "failed to build the image of container '%s'", nC)
+		}
+
+		l.Printf("Pushed the image '%s' with digest %s", c.Image, digest)
+		c.Image = fmt.Sprintf("%s@%s", c.Image, digest)
+		return nil
+	})
+}
@@ -0,0 +1,281 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+// fakeBuilder records the build requests and returns a digest computed from the Dockerfile and the image
+type fakeBuilder struct {
+	Builds []BuildRequest
+	mu     sync.Mutex
+}
+
+func (f *fakeBuilder) build(r *BuildRequest, l *log.Logger) (string, error) {
+	dockerfile, err := os.Open(filepath.Join(r.Source, r.Context, r.Dockerfile))
+	if err != nil {
+		return "", fmt.Errorf("the Dockerfile '%s' doesn't exist", filepath.Join(r.Context, r.Dockerfile))
+	}
+	defer dockerfile.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, dockerfile); err != nil {
+		return "", err
+	}
+	io.WriteString(h, r.Image)
+
+	f.mu.Lock()
+	f.Builds = append(f.Builds, *r)
+	f.mu.Unlock()
+
+	l.Printf("would've built %s with %s", r.Image, strings.TrimPrefix(dockerfile.Name(), r.Source+"/"))
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+
+type tarEntry struct {
+	name     string
+	content  string
+	linkname string
+}
+
+func newTarball(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	b := &bytes.Buffer{}
+	gz := gzip.NewWriter(b)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(e.name, "/") {
+			h.Typeflag = tar.TypeDir
+			h.Mode = 0755
+		}
+		if e.linkname != "" {
+			h.Typeflag = tar.TypeSymlink
+			h.Linkname = e.linkname
+		}
+
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tw.Close()
+	gz.Close()
+	return b
+}
+
+func Test_extractTarball(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tarball := newTarball(t, []tarEntry{
+		{name: "okteto-api-a1b2c3/"},
+		{name: "okteto-api-a1b2c3/README.md", content: "api"},
+		{name: "okteto-api-a1b2c3/api/Dockerfile", content: "FROM golang"},
+		{name: "okteto-api-a1b2c3/api/docs", linkname: "../README.md"},
+		{name: "okteto-api-a1b2c3/api/passwd", linkname: "/etc/passwd"},
+		{name: "okteto-api-a1b2c3/api/parent", linkname: "../../"},
+	})
+
+	if err := extractTarball(tarball, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "api", "Dockerfile"))
+	if err != nil || string(content) != "FROM golang" {
+		t.Errorf("the Dockerfile wasn't extracted: %s", err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, "api", "docs")); err != nil {
+		t.Errorf("the link inside the repository wasn't extracted: %s", err)
+	}
+
+	for _, l := range []string{"passwd", "parent"} {
+		if _, err := os.Lstat(filepath.Join(dir, "api", l)); !os.IsNotExist(err) {
+			t.Errorf("the link %s outside of the repository was extracted", l)
+		}
+	}
+
+	invalid := newTarball(t, []tarEntry{{name: "okteto-api-a1b2c3/../../etc/cron.d/job", content: "* * * * *"}})
+	if err := extractTarball(invalid, dir); err == nil {
+		t.Errorf("didn't fail with a path outside of the repository")
+	}
+}
+
+func Test_extractTarballWithSymlinkChains(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(parent)
+
+	dir := filepath.Join(parent, "repository")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := newTarball(t, []tarEntry{
+		{name: "okteto-api-a1b2c3/a", linkname: "."},
+		{name: "okteto-api-a1b2c3/a/b", linkname: ".."},
+		{name: "okteto-api-a1b2c3/a/b/job", content: "* * * * *"},
+	})
+	if err := extractTarball(chain, dir); err == nil {
+		t.Errorf("didn't fail with a path inside of a symbolic link")
+	}
+
+	if _, err := os.Lstat(filepath.Join(parent, "b")); !os.IsNotExist(err) {
+		t.Errorf("the archive created a file outside of the repository")
+	}
+
+	os.RemoveAll(dir)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	escaping := newTarball(t, []tarEntry{
+		{name: "okteto-api-a1b2c3/c", linkname: "a/.."},
+		{name: "okteto-api-a1b2c3/a", linkname: "."},
+		{name: "okteto-api-a1b2c3/docs", linkname: "a"},
+	})
+	if err := extractTarball(escaping, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, "c")); !os.IsNotExist(err) {
+		t.Errorf("the link resolved outside of the repository through another link wasn't removed")
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, "docs")); err != nil {
+		t.Errorf("the link inside the repository was removed: %s", err)
+	}
+}
+
+func Test_writeDockerConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeDockerConfig(dir, "okteto/api:1.0", &model.Registry{Username: "okteto", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Auths map[string]struct{ Auth string }
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Auths[dockerHubCredentials].Auth != "b2t0ZXRvOnNlY3JldA==" {
+		t.Errorf("wrong docker config: %s", string(content))
+	}
+}
+
+func Test_buildImages(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Branch: "refs/heads/master"}
+	if err := db.Create(link).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := ""
+	defaultFetchRepository := fetchRepository
+	fetchRepository = func(provider SCMProvider, l *model.GHRepoLink, commit, dir string) error {
+		fetched = commit
+		if err := os.MkdirAll(filepath.Join(dir, "api", "build"), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, "api", "build", "Dockerfile"), []byte("FROM golang"), 0644)
+	}
+	defer func() { fetchRepository = defaultFetchRepository }()
+
+	builder := &fakeBuilder{}
+	s := Server{DB: db, Builder: builder}
+	registry := &model.Registry{Server: "registry.okteto.net", Username: "okteto", Password: "secret"}
+	p := &model.Project{LoadedSettings: &model.ProjectSettings{Registry: registry}}
+	d := &model.Service{GHRepoLinkID: link.ID, Commit: "a1b2c3"}
+	service := &model.Service{
+		Name: "api",
+		Containers: map[string]*model.Container{
+			"api": &model.Container{
+				Image: "registry.okteto.net/okteto/api:a1b2c3",
+				Build: &model.Build{Context: "api", Dockerfile: "build/Dockerfile", Args: map[string]string{"DEBUG": "false"}},
+			},
+			"nginx": &model.Container{Image: "nginx:alpine"},
+		},
+	}
+
+	if err := s.buildImages(service, d, p, "activity-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if fetched != "a1b2c3" {
+		t.Errorf("the repository was fetched at '%s'", fetched)
+	}
+
+	if len(builder.Builds) != 1 {
+		t.Fatalf("expected one build, got %+v", builder.Builds)
+	}
+
+	b := builder.Builds[0]
+	if b.Context != "api" || b.Dockerfile != "build/Dockerfile" || b.Args["DEBUG"] != "false" || b.Registry != registry {
+		t.Errorf("wrong build request: %+v", b)
+	}
+
+	ref, err := model.ParseImageReference(service.Containers["api"].Image)
+	if err != nil || !ref.IsPinned() || ref.Tag != "a1b2c3" {
+		t.Errorf("the built image wasn't pinned: %s", service.Containers["api"].Image)
+	}
+
+	if service.Containers["nginx"].Image != "nginx:alpine" {
+		t.Errorf("an image without build was changed: %s", service.Containers["nginx"].Image)
+	}
+
+	logs, err := s.getActivityLogs("activity-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 3 || !strings.Contains(logs[1].Log, "would've built") {
+		t.Errorf("the build logs weren't saved: %+v", logs)
+	}
+
+	service.Containers["api"].Build.Dockerfile = "Dockerfile"
+	if err := s.buildImages(service, d, p, "activity-2"); err == nil {
+		t.Errorf("didn't fail with a missing Dockerfile")
+	}
+
+	if err := s.buildImages(service, &model.Service{}, p, "activity-3"); err == nil {
+		t.Errorf("didn't fail without a linked repository")
+	}
+}
@@ -9,6 +9,18 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// demoServiceLifetime is the time after which the demo services are destroyed
+	demoServiceLifetime = 60 * time.Minute
+
+	// demoExpirationNotice is how long before their destruction the creators of demo services are notified. It must be
+	// longer than the cleanup interval
+	demoExpirationNotice = 20 * time.Minute
+)
+
+// expirableStatuses are the statuses of the demo services that are destroyed when they expire
+var expirableStatuses = []string{"created", "failed", "deployed"}
+
 func (s *Server) cleanExpiredServices() {
 	for {
 		s.clean()
@@ -18,12 +30,14 @@ func (s *Server) cleanExpiredServices() {
 
 func (s *Server) clean() {
 	var u model.User
-	r := s.DB.Where("email = ?", "bot@okteto.com").First(&u)
+	r := s.DB.Where("email = ?", botEmail).First(&u)
 	if r.Error != nil {
 		logger.Error(errors.Wrap(r.Error, "failed to get bot user"))
 		return
 	}
 
+	s.notifyExpiringServices()
+
 	services := s.expiredServices()
 	for _, svc := range services {
 		var p model.Project
@@ -50,11 +64,11 @@ func (s *Server) clean() {
 }
 
 func (s *Server) expiredServices() []model.Service {
-	expiredPeriod := time.Now().Add(-60 * time.Minute).UTC()
+	expiredPeriod := time.Now().Add(-demoServiceLifetime).UTC()
 	var services []model.Service
 	r := s.DB.Where(
 		"status in (?) AND created_at < ? AND is_demo = ?",
-		[]string{"created", "failed", "deployed"}, expiredPeriod, true).Find(&services)
+		expirableStatuses, expiredPeriod, true).Find(&services)
 	if r.Error != nil {
 		logger.Error(errors.Wrap(r.Error, "failed to get expired demo services"))
 		return services
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
 )
 
 //Notification represents the notifications raised from the DB
@@ -14,6 +15,9 @@ type Notification struct {
 	Action  string
 	ID      string
 	Project string
+
+	// Activity is the activity of the notifications of the activity_logs table
+	Activity string
 }
 
 func (s *Server) processEvents() {
@@ -27,8 +31,11 @@ func (s *Server) processEvents() {
 				continue
 			}
 
-			if notification.Table == "services" {
+			switch notification.Table {
+			case "services":
 				s.fireHubNotification(notification.Project, notification.ID)
+			case "activity_logs":
+				s.Hub.SendLog(notification.Activity)
 			}
 
 		case <-time.After(90 * time.Second):
@@ -53,3 +60,47 @@ func (s *Server) fireHubNotification(projectID string, serviceID string) {
 
 	s.Hub.SendNotification(d)
 }
+
+// CanReadActivityLogs returns an error if the activity doesn't belong to a service of a project that the user can access
+func (s *Server) CanReadActivityLogs(userID, projectID, activityID string) error {
+	p, appErr := s.GetProject(projectID, userID)
+	if appErr != nil {
+		return appErr
+	}
+
+	var activity model.Activity
+	r := s.DB.Where("id = ?", activityID).First(&activity)
+	if r.Error != nil {
+		if r.RecordNotFound() {
+			return errors.Wrapf(model.ErrNotFound, "activity-%s not found", activityID)
+		}
+
+		return errors.Wrapf(r.Error, "failed to get activity-%s", activityID)
+	}
+
+	if _, appErr := s.getService(p, activity.ServiceID); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// GetActivityLogsFrom returns up to limit lines of the logs of an activity written after the line after. If after is
+// nil, the first offset lines are skipped. It's used to stream the logs to the websocket clients
+func (s *Server) GetActivityLogsFrom(activityID string, after *model.ActivityLog, offset, limit int) ([]model.ActivityLog, error) {
+	query := s.DB.Where("activity_id = ?", activityID)
+	if after != nil {
+		// the lines are paged by the last line sent, the offset is only used when a client resumes a subscription
+		query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", after.CreatedAt, after.CreatedAt, after.ID)
+	} else {
+		query = query.Offset(offset)
+	}
+
+	var logs []model.ActivityLog
+	r := query.Order("created_at ASC, id ASC").Limit(limit).Find(&logs)
+	if r.Error != nil {
+		return nil, errors.Wrapf(r.Error, "failed to get the logs of activity-%s", activityID)
+	}
+
+	return logs, nil
+}
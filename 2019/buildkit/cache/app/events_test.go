@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+func TestActivityLogsStream(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	u, _ := s.createUser("user@example.com", true, "")
+	other, _ := s.createUser("other@example.com", true, "")
+	projectID, appErr := s.CreateProject(&model.Project{Name: "project1", Settings: demoProject}, u)
+	if appErr != nil {
+		t.Fatalf("%+v", appErr)
+	}
+
+	svc := &model.Service{Name: "api", ProjectID: projectID, Manifest: httpsService}
+	if err := db.Create(svc).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	activity := &model.Activity{Type: model.Deployed, Status: model.InProgress, ServiceID: svc.ID}
+	if err := db.Create(activity).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		s.addLog(activity.ID, fmt.Sprintf("line %d", i))
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.CanReadActivityLogs(u.ID, projectID, activity.ID); err != nil {
+		t.Errorf("the member of the project can't read the logs: %s", err)
+	}
+
+	if err := s.CanReadActivityLogs(other.ID, projectID, activity.ID); err == nil {
+		t.Errorf("a user that isn't a member of the project can read the logs")
+	}
+
+	if err := s.CanReadActivityLogs(u.ID, projectID, "missing"); err == nil {
+		t.Errorf("the logs of a missing activity can be read")
+	}
+
+	logs, err := s.GetActivityLogsFrom(activity.ID, nil, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 3 || logs[0].Log != "line 1" || logs[2].Log != "line 3" {
+		t.Fatalf("wrong logs: %+v", logs)
+	}
+
+	// the offset is ignored once the client has a line
+	logs, err = s.GetActivityLogsFrom(activity.ID, &logs[2], 100, 3)
+	if err != nil || len(logs) != 1 || logs[0].Log != "line 4" {
+		t.Fatalf("wrong logs after the last line sent: %+v %s", logs, err)
+	}
+
+	logs, err = s.GetActivityLogsFrom(activity.ID, &logs[0], 0, 3)
+	if err != nil || len(logs) != 0 {
+		t.Errorf("expected no logs after the last line, got %+v %s", logs, err)
+	}
+
+	logs, err = s.GetActivityLogsFrom(activity.ID, nil, 5, 3)
+	if err != nil || len(logs) != 0 {
+		t.Errorf("expected no logs after the last line, got %+v %s", logs, err)
+	}
+}
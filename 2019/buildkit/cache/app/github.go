@@ -3,8 +3,11 @@ package app
 import (
 	"encoding/base64"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"bitbucket.org/okteto/okteto/backend/logger"
 
@@ -20,12 +23,31 @@ const (
 	defaultManifestPath = "okteto.yaml"
 	githubActorID       = "799273b1-b067-4f84-b632-864c543c4dc5"
 
+	githubSignatureHeader    = "X-Hub-Signature"
+	githubSignature256Header = "X-Hub-Signature-256"
+
 	installationEvent   = "installation"
 	pushEvent           = "push"
+	pullRequestEvent    = "pull_request"
 	createdInstallation = "created"
 	deletedInstallation = "deleted"
+
+	// ghRepositoryTTL is how long the repositories read by getGHRepository are cached
+	ghRepositoryTTL = 10 * time.Minute
+)
+
+var (
+	ghRepositories      = map[string]*cachedGHRepository{}
+	ghRepositoriesMutex sync.Mutex
 )
 
+// cachedGHRepository is a repository read with the client of its installation
+type cachedGHRepository struct {
+	client     *github.Client
+	repo       *github.Repository
+	expiration time.Time
+}
+
 // GHWebhookPayload is the payload of a github event
 type GHWebhookPayload struct {
 	Event        string
@@ -35,7 +57,11 @@ type GHWebhookPayload struct {
 	Repository   *GHRepo
 	Repositories []GHRepo
 	Ref          string
-	Commit       string `json:"after"`
+	Deleted      bool
+	Commit       string         `json:"after"`
+	HeadCommit   *GHCommit      `json:"head_commit"`
+	PullRequest  *GHPullRequest `json:"pull_request"`
+	Commits      []SCMCommit
 
 	// Sender is the account that triggered the action
 	Sender *GHAccount
@@ -58,33 +84,73 @@ type GHRepo struct {
 	Owner    *GHAccount
 }
 
+// GHPullRequest is the payload of a pull request event
+type GHPullRequest struct {
+	Number int
+	Merged bool
+	Head   GHBranch
+	Base   GHBranch
+
+	// AuthorAssociation is the relation of the author of the pull request with the repository, e.g. MEMBER
+	AuthorAssociation string `json:"author_association"`
+}
+
+// GHCommit is the head commit of a push event. The pushes of annotated tags have the SHA of the tag object in
+// the after field, the commit is only in the head commit
+type GHCommit struct {
+	ID string
+}
+
+// GHBranch is the branch and the commit of one side of a pull request. Repo is nil if the fork of the head was deleted
+type GHBranch struct {
+	Ref  string
+	SHA  string `json:"sha"`
+	Repo *GHRepo
+}
+
 // GHAccount is the payload of a github account and/or organization
 type GHAccount struct {
 	ID    int
 	Login string
 }
 
-var githubEvents = make(chan *GHWebhookPayload, 10)
+// getFileFromRepo returns the content of a file of a repository at commit
+var getFileFromRepo = downloadFileFromGH
 
-func getManifestFromRepo(installationID int, owner, name, manifest, commit string) (string, error) {
+func downloadFileFromGH(installationID int, owner, name, path, commit string) (string, error) {
 	client, err := newGithubClient(installationID)
 	if err != nil {
 		return "", err
 	}
 
-	content, _, _, err := client.Repositories.GetContents(context.Background(), owner, name, manifest, &github.RepositoryContentGetOptions{Ref: commit})
+	content, _, _, err := client.Repositories.GetContents(context.Background(), owner, name, path, &github.RepositoryContentGetOptions{Ref: commit})
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to get contents of %s/%s for ghinstallation-%d", owner, name, installationID)
 	}
 
 	contentStr, err := content.GetContent()
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to get content of %s %s/%s for ghinstallation-%d", manifest, owner, name, installationID)
+		return "", errors.Wrapf(err, "failed to get content of %s %s/%s for ghinstallation-%d", path, owner, name, installationID)
 	}
 
 	return contentStr, nil
 }
 
+// getFileFromRepoLink returns the content of a file of a linked repository at ref
+func getFileFromRepoLink(link *model.GHRepoLink, path, ref string) (string, error) {
+	if link.Repository != "" {
+		owner, name := splitRepository(link.Repository)
+		return getFileFromRepo(link.InstallationID, owner, name, path, ref)
+	}
+
+	_, repo, err := getGHRepository(link)
+	if err != nil {
+		return "", err
+	}
+
+	return getFileFromRepo(link.InstallationID, repo.GetOwner().GetLogin(), repo.GetName(), path, ref)
+}
+
 // newGithubClient returns a github client configured to authenticate as the okteto github app
 func newGithubClient(installationID int) (*github.Client, error) {
 	ghAppID, ghPrivateKey, err := config.GetGithubApp()
@@ -102,6 +168,37 @@ func newGithubClient(installationID int) (*github.Client, error) {
 	return github.NewClient(&http.Client{Transport: itr}), nil
 }
 
+// getGHRepository returns a client of the installation of link and its repository
+// getGHRepository returns a client of the installation of link and its repository. They are cached for
+// ghRepositoryTTL, so a renamed repository is read again
+func getGHRepository(link *model.GHRepoLink) (*github.Client, *github.Repository, error) {
+	key := fmt.Sprintf("%d/%d", link.InstallationID, link.RepositoryID)
+	ghRepositoriesMutex.Lock()
+	cached, ok := ghRepositories[key]
+	ghRepositoriesMutex.Unlock()
+	if ok && time.Now().Before(cached.expiration) {
+		return cached.client, cached.repo, nil
+	}
+
+	client, err := newGithubClient(link.InstallationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ghAPITimeout)
+	defer cancel()
+
+	repo, _, err := client.Repositories.GetByID(ctx, int64(link.RepositoryID))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get repository-%d via the api", link.RepositoryID)
+	}
+
+	ghRepositoriesMutex.Lock()
+	ghRepositories[key] = &cachedGHRepository{client: client, repo: repo, expiration: time.Now().Add(ghRepositoryTTL)}
+	ghRepositoriesMutex.Unlock()
+	return client, repo, nil
+}
+
 func (s *Server) getGHInstallation(p *model.Project) (*model.GHInstallation, error) {
 	if p.GHInstallationID == "" {
 		return nil, model.ErrProjectNotLinkedToGithub
@@ -227,20 +324,40 @@ func (s *Server) ClaimGHInstallation(userID string, installationID int) error {
 	return nil
 }
 
-// syncManifestFromGH updates the manifest of all the services that are linked to this repo/branch combination
-func (s *Server) syncManifestFromGH(installationID, repositoryID int, repositoryOwner, repositoryName, branch, commit, author string) error {
-	link := model.GHRepoLink{InstallationID: installationID, RepositoryID: repositoryID, Branch: branch}
-	r := s.DB.Where(link).First(&link)
-	if r.Error != nil {
-		if r.RecordNotFound() {
-			return errors.Wrapf(model.ErrNotFound, "ghinstallation-%d repository-%d branch-%s not found in the DB", installationID, repositoryID, branch)
+// syncManifestFromGH updates the manifest of the services linked to this repo/branch combination that are affected by the
+// changed files, and deploys them if their link has auto deploy enabled
+func (s *Server) syncManifestFromGH(installationID, repositoryID int, repositoryOwner, repositoryName, branch, commit, author string, files []string) error {
+	links, err := s.getRefLinks(model.GHRepoLink{InstallationID: installationID, RepositoryID: repositoryID}, branch)
+	if err != nil {
+		return errors.Wrap(err, "failed to query for GHRepoLink")
+	}
+
+	if len(links) == 0 {
+		return errors.Wrapf(model.ErrNotFound, "ghinstallation-%d repository-%d branch-%s not found in the DB", installationID, repositoryID, branch)
+	}
+
+	failed := 0
+	for i := range links {
+		if err := s.syncLinkedServices(&links[i], repositoryOwner, repositoryName, branch, commit, author, files); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to sync the services of ghrepolink-%s", links[i].ID))
+			failed++
 		}
+	}
 
-		return errors.Wrap(r.Error, "failed to query for GHRepoLink")
+	// the delivery is retried, the auto deploys are only acknowledged once they are saved
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %d of %d links", failed, len(links))
 	}
 
+	return nil
+}
+
+// syncLinkedServices updates the services of link affected by files. A nil files updates every service of the link
+func (s *Server) syncLinkedServices(link *model.GHRepoLink, repositoryOwner, repositoryName, branch, commit, author string, files []string) error {
 	var services []model.Service
-	r = s.DB.Where(model.Service{GHRepoLinkID: link.ID}).Find(&services)
+
+	// the previews of pull requests are updated by their own events
+	r := s.DB.Where(model.Service{GHRepoLinkID: link.ID}).Where("preview_id = ''").Find(&services)
 	if r.Error != nil {
 		if r.RecordNotFound() {
 			return nil
@@ -249,18 +366,38 @@ func (s *Server) syncManifestFromGH(installationID, repositoryID int, repository
 		return errors.Wrap(r.Error, "failed to query for github linked services")
 	}
 
-	if len(services) > 0 {
-		content, err := getManifestFromRepo(installationID, repositoryOwner, repositoryName, link.Manifest, commit)
+	if len(services) == 0 {
+		return nil
+	}
+
+	manifests, err := s.getLinkManifests(link)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]*model.Service{}
+	logMessage := fmt.Sprintf("Updated manifest due to commit #%s by %s", commit, author)
+	for _, svc := range services {
+		m := getServiceManifest(link, manifests, svc.ID)
+		if !m.IsAffectedBy(files) {
+			log.Printf("service-%s is not affected by commit #%s", svc.ID, commit)
+			continue
+		}
+
+		update, err := s.getManifestUpdate(updates, link, m, repositoryOwner, repositoryName, branch, commit)
 		if err != nil {
-			return err
+			logger.Error(errors.Wrapf(err, "failed to read the manifest of service-%s", svc.ID))
+			continue
+		}
+
+		if err := s.UpdateManifest(svc.ProjectID, svc.ID, update, getOverlayUpdate(m, update), githubActorID, logMessage); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to update manifest of service-%s", svc.ID))
+			continue
 		}
 
-		encodedManifest := base64.StdEncoding.EncodeToString([]byte(content))
-		logMessage := fmt.Sprintf("Updated manifest due to commit #%s by %s", commit, author)
-		for _, svc := range services {
-			err := s.UpdateManifest(svc.ProjectID, svc.ID, encodedManifest, githubActorID, logMessage)
-			if err != nil {
-				logger.Error(errors.Wrapf(err, "failed to update manifest of service-%s", svc.ID))
+		if link.AutoDeploy {
+			if err := s.scheduleAutoDeploy(svc.ID, commit); err != nil {
+				return err
 			}
 		}
 	}
@@ -268,27 +405,215 @@ func (s *Server) syncManifestFromGH(installationID, repositoryID int, repository
 	return nil
 }
 
-// LinkGHRepositoryToService links a gh repository to a existing okteto service
-func (s *Server) LinkGHRepositoryToService(p *model.Project, serviceID string, owner, name, branch, manifest string) error {
+// getLinkManifests returns the manifests of the services of link, by service
+func (s *Server) getLinkManifests(link *model.GHRepoLink) (map[string]*model.GHLinkManifest, error) {
+	var manifests []model.GHLinkManifest
+	if err := s.DB.Where(model.GHLinkManifest{GHRepoLinkID: link.ID}).Find(&manifests).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to get the manifests of ghrepolink-%s", link.ID)
+	}
 
-	i, err := s.getGHInstallation(p)
+	result := map[string]*model.GHLinkManifest{}
+	for i := range manifests {
+		result[manifests[i].ServiceID] = &manifests[i]
+	}
+
+	return result, nil
+}
+
+// getServiceManifest returns the manifest of a service of link. The services linked before the manifests were stored by
+// service read the manifest of the link
+func getServiceManifest(link *model.GHRepoLink, manifests map[string]*model.GHLinkManifest, serviceID string) *model.GHLinkManifest {
+	if m, ok := manifests[serviceID]; ok {
+		return m
+	}
+
+	return &model.GHLinkManifest{
+		GHRepoLinkID: link.ID,
+		ServiceID:    serviceID,
+		Manifest:     orDefault(link.Manifest, defaultManifestPath),
+		Overlay:      link.Overlay,
+	}
+}
+
+// getManifestUpdate returns the manifest and the overlay of m at commit. updates has the manifests already read by
+// this commit, so the services that share a manifest read it once
+func (s *Server) getManifestUpdate(updates map[string]*model.Service, link *model.GHRepoLink, m *model.GHLinkManifest, repositoryOwner, repositoryName, branch, commit string) (*model.Service, error) {
+	key := getManifestKey(m)
+	if update, ok := updates[key]; ok {
+		return update, nil
+	}
+
+	update, err := s.getManifestFromRepo(link, m, repositoryOwner, repositoryName, branch, commit)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	client, err := newGithubClient(i.InstallationID)
+	updates[key] = update
+	return update, nil
+}
+
+// getOverlayUpdate returns the overlay of update when the manifest m of the link defines one. The services synced from a
+// manifest without an overlay keep the overlay set in the API
+func getOverlayUpdate(m *model.GHLinkManifest, update *model.Service) *string {
+	if m.Overlay == "" {
+		return nil
+	}
+
+	return &update.Overlay
+}
+
+func getManifestKey(m *model.GHLinkManifest) string {
+	return fmt.Sprintf("%s:%s", m.Manifest, m.Overlay)
+}
+
+// getManifestFromRepo returns the manifest and the overlay of a linked repository at commit
+func (s *Server) getManifestFromRepo(link *model.GHRepoLink, m *model.GHLinkManifest, repositoryOwner, repositoryName, branch, commit string) (*model.Service, error) {
+	content, err := s.getFileFromLink(link, repositoryOwner, repositoryName, m.Manifest, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &model.Service{
+		Manifest: base64.StdEncoding.EncodeToString([]byte(content)),
+		Branch:   branch,
+		Commit:   commit,
+	}
+
+	if m.Overlay != "" {
+		overlay, err := s.getFileFromLink(link, repositoryOwner, repositoryName, m.Overlay, commit)
+		if err != nil {
+			return nil, err
+		}
+
+		update.Overlay = base64.StdEncoding.EncodeToString([]byte(overlay))
+	}
+
+	return update, nil
+}
+
+// getFileFromLink returns the content of a file of a linked repository at ref. The github files are read with the
+// owner and name of the repository received in the webhook
+func (s *Server) getFileFromLink(link *model.GHRepoLink, repositoryOwner, repositoryName, path, ref string) (string, error) {
+	if link.GetProvider() == model.GithubProvider {
+		return getFileFromRepo(link.InstallationID, repositoryOwner, repositoryName, path, ref)
+	}
+
+	provider, err := s.getLinkProvider(link)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.GetFile(link, path, ref)
+}
+
+// loadConfigFiles sets the content of the service configs that are read from a file of the linked repository, at the
+// commit the manifest of d was synced from
+func (s *Server) loadConfigFiles(service *model.Service, d *model.Service) error {
+	var link *model.GHRepoLink
+	var provider SCMProvider
+	for _, c := range service.Configs {
+		if c.File == "" {
+			continue
+		}
+
+		if d.GHRepoLinkID == "" {
+			return fmt.Errorf("Config '%s' reads the file '%s' but the service is not linked to a repository", c.Name, c.File)
+		}
+
+		if link == nil {
+			var err error
+			if link, err = s.getRepoLink(d); err != nil {
+				logger.Error(err)
+				return fmt.Errorf("Config '%s' couldn't be read from the linked repository", c.Name)
+			}
+
+			if provider, err = s.getLinkProvider(link); err != nil {
+				logger.Error(err)
+				return fmt.Errorf("Config '%s' couldn't be read from the linked repository", c.Name)
+			}
+		}
+
+		content, err := provider.GetFile(link, c.File, getServiceRef(link, d))
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to read config %s of service-%s", c.Name, d.ID))
+			return fmt.Errorf("Config '%s' couldn't read the file '%s' from the linked repository", c.Name, c.File)
+		}
+
+		c.Content = content
+	}
+
+	return nil
+}
+
+// getRepoLink returns the repository linked to d
+func (s *Server) getRepoLink(d *model.Service) (*model.GHRepoLink, error) {
+	link := &model.GHRepoLink{}
+	if err := s.DB.Where(model.GHRepoLink{Model: model.Model{ID: d.GHRepoLinkID}}).First(link).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to get ghrepolink-%s of service-%s", d.GHRepoLinkID, d.ID)
+	}
+	return link, nil
+}
+
+// getServiceRef returns the commit of the linked repository that d was updated to. The services that were never updated
+// use the branch of the link, or the last ref pushed if the link is a pattern
+func getServiceRef(link *model.GHRepoLink, d *model.Service) string {
+	if d.Commit != "" {
+		return d.Commit
+	}
+
+	if link.IsPattern() {
+		return d.Branch
+	}
+
+	return link.Branch
+}
+
+// fetchRepository downloads the linked repository at commit into dir
+var fetchRepository = func(provider SCMProvider, link *model.GHRepoLink, commit, dir string) error {
+	return provider.DownloadRepository(link, commit, dir)
+}
+
+func downloadRepositoryFromGH(link *model.GHRepoLink, commit, dir string) error {
+	client, repo, err := getGHRepository(link)
 	if err != nil {
 		return err
 	}
 
-	repo, _, err := client.Repositories.Get(context.Background(), owner, name)
+	archive, _, err := client.Repositories.GetArchiveLink(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), github.Tarball, &github.RepositoryContentGetOptions{Ref: commit})
 	if err != nil {
-		return errors.Wrapf(err, "failed to get %s/%s via the api", owner, name)
+		return errors.Wrapf(err, "failed to get the archive of repository-%d at %s", link.RepositoryID, commit)
 	}
 
-	svc, appErr := s.getService(p, serviceID)
+	res, err := archiveClient.Get(archive.String())
 	if err != nil {
-		return errors.Wrapf(appErr, "failed to get service-%s", serviceID)
+		return errors.Wrapf(err, "failed to download the archive of repository-%d", link.RepositoryID)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download the archive of repository-%d: %s", link.RepositoryID, res.Status)
+	}
+
+	return extractTarball(res.Body, dir)
+}
+
+// LinkGHRepositoryToService links a repository of a source control provider to a existing okteto service, and returns
+// the link. The services linked to the same branch share the link, and each of them reads its own manifest. paths are
+// the globs of the files that affect the service
+func (s *Server) LinkGHRepositoryToService(p *model.Project, serviceID string, provider model.SCMProviderType, owner, name, branch, manifest, overlay string, paths []string, autoDeploy bool) (*model.GHRepoLink, error) {
+	scm, err := s.GetSCMProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := scm.GetRepository(p, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, appErr := s.getService(p, serviceID)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get service-%s", serviceID)
 	}
 
 	if manifest == "" {
@@ -296,43 +621,97 @@ func (s *Server) LinkGHRepositoryToService(p *model.Project, serviceID string, o
 	}
 
 	if branch == "" {
-		branch = repo.GetDefaultBranch()
+		branch = repo.DefaultBranch
 	}
 
 	// get canonical form of the branch name
 	branch = getCanonicalBranchName(branch)
 
 	repoLink := model.GHRepoLink{
-		InstallationID: i.InstallationID,
-		RepositoryID:   int(repo.GetID()),
-		Branch:         branch,
-		Manifest:       manifest,
+		RepositoryID: repo.ID,
+		Branch:       branch,
+		Provider:     provider,
+		Repository:   repo.FullName,
+	}
+
+	// github repositories are read by the installation, the other providers use the credentials of the project and
+	// the webhook secret of the project
+	var secret string
+	if provider == model.GithubProvider {
+		i, err := s.getGHInstallation(p)
+		if err != nil {
+			return nil, err
+		}
+
+		repoLink.InstallationID = i.InstallationID
+	} else {
+		repoLink.ProjectID = p.ID
+		secret, err = s.getSCMWebhookSecret(p, provider, repo.FullName)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	tx := s.DB.Begin()
 	if tx.Error != nil {
-		return errors.Wrap(tx.Error, "failed to start transaction")
+		return nil, errors.Wrap(tx.Error, "failed to start transaction")
 	}
 
-	err = tx.Where(repoLink).FirstOrCreate(&repoLink).Error
+	err = tx.Where(repoLink).Attrs(model.GHRepoLink{AutoDeploy: autoDeploy, WebhookSecret: secret}).FirstOrCreate(&repoLink).Error
 	if err != nil {
 		tx.Rollback()
-		return errors.Wrapf(err, "failed to create or find repo link for service-%s", serviceID)
+		return nil, errors.Wrapf(err, "failed to create or find repo link for service-%s", serviceID)
+	}
+
+	// auto deploy is a setting of the link, the services linked to the same branch share it
+	if repoLink.AutoDeploy != autoDeploy {
+		err = tx.Model(&repoLink).Update("auto_deploy", autoDeploy).Error
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "failed to update the auto deploy of repo link-%s", repoLink.ID)
+		}
+	}
+
+	// the links created before the gitlab and bitbucket webhooks had a secret by project don't have it
+	if repoLink.WebhookSecret != secret {
+		err = tx.Model(&repoLink).Update("webhook_secret", secret).Error
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "failed to update the webhook secret of repo link-%s", repoLink.ID)
+		}
 	}
 
 	err = tx.Model(&svc).Updates(&model.Service{GHRepoLinkID: repoLink.ID}).Error
 	if err != nil {
 		tx.Rollback()
-		return errors.Wrapf(err, "failed to update github link ID for service-%s", serviceID)
+		return nil, errors.Wrapf(err, "failed to update github link ID for service-%s", serviceID)
+	}
+
+	err = tx.Unscoped().Where(model.GHLinkManifest{ServiceID: svc.ID}).Delete(model.GHLinkManifest{}).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to delete the previous manifest of service-%s", serviceID)
+	}
+
+	err = tx.Create(&model.GHLinkManifest{
+		GHRepoLinkID: repoLink.ID,
+		ServiceID:    svc.ID,
+		Manifest:     strings.TrimPrefix(manifest, "/"),
+		Overlay:      strings.TrimPrefix(overlay, "/"),
+		Paths:        strings.Join(paths, ","),
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to save the manifest of service-%s", serviceID)
 	}
 
 	err = tx.Commit().Error
 	if err != nil {
 		tx.Rollback()
-		return errors.Wrapf(err, "failed to commit transaction when linking service-%s", serviceID)
+		return nil, errors.Wrapf(err, "failed to commit transaction when linking service-%s", serviceID)
 	}
 
-	return nil
+	return &repoLink, nil
 }
 
 //UnlinkGHRepositoryToService removes the github link to a service
@@ -350,12 +729,22 @@ func (s *Server) UnlinkGHRepositoryToService(p *model.Project, serviceID string)
 		return errors.Wrapf(err, "failed to unlink service-%s", serviceID)
 	}
 
+	err = s.DB.Unscoped().Where(model.GHLinkManifest{ServiceID: svc.ID}).Delete(model.GHLinkManifest{}).Error
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete the manifest of service-%s", serviceID)
+	}
+
 	return nil
 }
 
-// GetGHRepositories returns all repositories p can see
-func (s *Server) GetGHRepositories(p *model.Project) ([]*github.Repository, error) {
-	gi, err := s.getGHInstallation(p)
+// GithubSCM is the github implementation of SCMProvider, it authenticates as an installation of the okteto github app
+type GithubSCM struct {
+	s *Server
+}
+
+// ListRepositories returns all repositories the github installation of p can see
+func (g *GithubSCM) ListRepositories(p *model.Project) ([]*SCMRepository, error) {
+	gi, err := g.s.getGHInstallation(p)
 	if err != nil {
 		return nil, err
 	}
@@ -375,81 +764,162 @@ func (s *Server) GetGHRepositories(p *model.Project) ([]*github.Repository, erro
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get repositories for project-%s", p.ID)
 	}
-	return repositories, nil
+
+	result := make([]*SCMRepository, len(repositories))
+	for i := range repositories {
+		result[i] = toSCMRepository(repositories[i])
+	}
+
+	return result, nil
 }
 
-func (s *Server) handleInstallation(payload *GHWebhookPayload) {
+// GetRepository returns a repository that the github installation of p can see
+func (g *GithubSCM) GetRepository(p *model.Project, owner, name string) (*SCMRepository, error) {
+	i, err := g.s.getGHInstallation(p)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newGithubClient(i.InstallationID)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, _, err := client.Repositories.Get(context.Background(), owner, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s/%s via the api", owner, name)
+	}
+
+	return toSCMRepository(repo), nil
+}
+
+// GetFile returns the content of a file of the linked repository at ref
+func (g *GithubSCM) GetFile(link *model.GHRepoLink, path, ref string) (string, error) {
+	return getFileFromRepoLink(link, path, ref)
+}
+
+// DownloadRepository extracts the tarball of the linked repository at commit into dir
+func (g *GithubSCM) DownloadRepository(link *model.GHRepoLink, commit, dir string) error {
+	return downloadRepositoryFromGH(link, commit, dir)
+}
+
+// VerifyWebhook returns true if the delivery is signed with secret, the webhook secret of the github app
+func (g *GithubSCM) VerifyWebhook(header http.Header, body []byte, secret string) bool {
+	signature := header.Get(githubSignature256Header)
+	if signature == "" {
+		signature = header.Get(githubSignatureHeader)
+	}
+
+	return validHubSignature([]byte(secret), body, signature)
+}
+
+// CreateStatus creates a commit status in the linked repository
+func (g *GithubSCM) CreateStatus(link *model.GHRepoLink, commit string, status *SCMStatus) error {
+	return githubReporter.createStatus(link, commit, &github.RepoStatus{
+		State:       github.String(status.State),
+		TargetURL:   github.String(status.TargetURL),
+		Description: github.String(status.Description),
+		Context:     github.String(status.Context),
+	})
+}
+
+func toSCMRepository(repo *github.Repository) *SCMRepository {
+	return &SCMRepository{
+		ID:            int(repo.GetID()),
+		Owner:         repo.GetOwner().GetLogin(),
+		Name:          repo.GetName(),
+		FullName:      repo.GetFullName(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		URL:           repo.GetURL(),
+	}
+}
+
+func (s *Server) handleInstallation(payload *GHWebhookPayload) error {
+	if payload.Installation == nil {
+		return errors.New("webhook didn't have an installation")
+	}
+
 	if payload.Action == createdInstallation {
 		err := s.createGHInstallation(payload.Installation.ID, payload.Sender.ID, payload.Installation.Account.Login, model.GHScope(payload.Installation.TargetType))
 		if err != nil {
-			logger.Error(errors.Wrap(err, "failed to create ghinstallation"))
-			return
+			return errors.Wrap(err, "failed to create ghinstallation")
 		}
 	} else if payload.Action == deletedInstallation {
 		err := s.deleteGHInstallation(payload.Installation.ID)
 		if err != nil {
-			logger.Error(errors.Wrap(err, "failed to delete ghinstallation"))
-			return
+			return errors.Wrap(err, "failed to delete ghinstallation")
 		}
 	}
+
+	return nil
 }
 
-func (s *Server) handlePush(webhook *GHWebhookPayload) {
+func (s *Server) handlePush(webhook *GHWebhookPayload) error {
 	if webhook.Ref == "" {
-		logger.Error(errors.New("webhook didn't have a ref"))
-		return
+		return errors.New("webhook didn't have a ref")
 	}
 
 	if webhook.Commit == "" {
-		logger.Error(errors.New("webhook didn't have a commit"))
-		return
+		return errors.New("webhook didn't have a commit")
+	}
+
+	// the pushes that delete a branch or a tag don't have a commit to sync
+	if webhook.Deleted || !isBranchOrTag(webhook.Ref) {
+		return nil
+	}
+
+	commit := webhook.Commit
+	if webhook.HeadCommit != nil && webhook.HeadCommit.ID != "" {
+		commit = webhook.HeadCommit.ID
 	}
 
-	err := s.syncManifestFromGH(webhook.Installation.ID, webhook.Repository.ID, webhook.Repository.Owner.Login, webhook.Repository.Name, webhook.Ref, webhook.Commit, webhook.Sender.Login)
+	err := s.syncManifestFromGH(webhook.Installation.ID, webhook.Repository.ID, webhook.Repository.Owner.Login, webhook.Repository.Name, webhook.Ref, commit, webhook.Sender.Login, getChangedFiles(webhook.Commits, len(webhook.Commits)))
 	if err != nil {
 		if err == model.ErrSHAMismatch {
-			return
+			return nil
 		}
 
 		if err == model.ErrRefMismatch {
-			return
+			return nil
 		}
 
-		logger.Error(errors.Wrap(err, "sync from gh failed"))
-	}
-}
-
-func (s *Server) processGithubEvents() {
-	for {
-		select {
-		case ev := <-githubEvents:
-			switch ev.Event {
-			case installationEvent:
-				s.handleInstallation(ev)
-			case pushEvent:
-				s.handlePush(ev)
-			default:
-				logger.Error(fmt.Errorf("unknown github event queued: %s", ev.Event))
-			}
+		// the pushes to branches that are not linked are ignored
+		if errors.Cause(err) == model.ErrNotFound {
+			return nil
 		}
+
+		return errors.Wrap(err, "sync from gh failed")
 	}
+
+	return nil
 }
 
-// QueueGithubEvent adds a github event to be processed
-func (s *Server) QueueGithubEvent(w *GHWebhookPayload) {
-	githubEvents <- w
+// VerifyGithubWebhook returns true if the delivery is signed with the webhook secret of the github app
+func (s *Server) VerifyGithubWebhook(header http.Header, body []byte) bool {
+	g := &GithubSCM{s: s}
+	return g.VerifyWebhook(header, body, config.GetGithubWebhookSecret())
 }
 
 //IsGithubEventSupported returns true if the event is supported
 func IsGithubEventSupported(event string) bool {
-	if event == installationEvent || event == pushEvent {
+	if event == installationEvent || event == pushEvent || event == pullRequestEvent {
 		return true
 	}
 
 	return false
 }
 
+// githubUser is the actor of the operations triggered by github events
+func githubUser() *model.User {
+	return &model.User{Model: model.Model{ID: githubActorID}, Email: "github"}
+}
+
+// getCanonicalBranchName returns the ref of a branch. Tag refs are returned as they are
 func getCanonicalBranchName(branch string) string {
-	branch = strings.TrimPrefix(branch, "refs/heads/")
-	return fmt.Sprintf("refs/heads/%s", branch)
+	if strings.HasPrefix(branch, model.TagRefPrefix) {
+		return branch
+	}
+
+	branch = strings.TrimPrefix(branch, model.BranchRefPrefix)
+	return fmt.Sprintf("%s%s", model.BranchRefPrefix, branch)
 }
@@ -0,0 +1,301 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// github redelivers failed deliveries on demand, the processed and failed deliveries are kept for a week
+	ghDeliveryRetention = 7 * 24 * time.Hour
+
+	ghDeliveryMaxAttempts = 5
+
+	// ghDeliveryStuckPeriod is the time after which a delivery that is still processing is considered lost, e.g. if
+	// the server was restarted while processing it
+	ghDeliveryStuckPeriod = 15 * time.Minute
+)
+
+var (
+	// ghDeliveryBackoff is the delay before the second attempt of a delivery, it's doubled on every failed attempt
+	ghDeliveryBackoff = 30 * time.Second
+
+	// ghDeliveries with the same key are processed in order by every replica, so the pushes to a ref and the events of
+	// a pull request are synced in order. A delivery waits for the older ones of its key until they succeed or fail
+	ghDeliveries = &outbox{
+		name:         "github",
+		record:       func() interface{} { return &model.GHDelivery{} },
+		pending:      string(model.PendingDelivery),
+		processing:   string(model.ProcessingDelivery),
+		processed:    string(model.ProcessedDelivery),
+		failed:       string(model.FailedDelivery),
+		key:          "key",
+		ordered:      true,
+		workers:      1,
+		maxAttempts:  ghDeliveryMaxAttempts,
+		backoff:      getGHDeliveryBackoff,
+		stuckPeriod:  ghDeliveryStuckPeriod,
+		retention:    ghDeliveryRetention,
+		pollInterval: 5 * time.Second,
+		events:       make(chan struct{}, 1),
+	}
+)
+
+// QueueGithubEvent saves a webhook delivery to be processed by the worker. It returns false if the delivery was
+// already received
+func (s *Server) QueueGithubEvent(deliveryID string, payload *GHWebhookPayload, body []byte) (bool, error) {
+	delivery := &model.GHDelivery{
+		DeliveryID: deliveryID,
+		Provider:   model.GithubProvider,
+		Event:      payload.Event,
+		Action:     payload.Action,
+		Payload:    string(body),
+	}
+
+	if payload.Installation != nil {
+		delivery.InstallationID = payload.Installation.ID
+	}
+
+	if payload.Repository != nil {
+		switch payload.Event {
+		case pushEvent:
+			delivery.Key = fmt.Sprintf("github/%d/%s", payload.Repository.ID, payload.Ref)
+		case pullRequestEvent:
+			delivery.Key = fmt.Sprintf("github/%d#%d", payload.Repository.ID, payload.Number)
+		}
+	}
+
+	return s.queueDelivery(delivery)
+}
+
+// QueueSCMEvent saves a webhook delivery of gitlab or bitbucket sent by the webhook of projectID to be processed by the
+// worker. It returns false if the delivery was already received
+func (s *Server) QueueSCMEvent(provider model.SCMProviderType, projectID, deliveryID, event string, body []byte) (bool, error) {
+	repository := getSCMEventRepository(provider, body)
+	return s.queueDelivery(&model.GHDelivery{
+		DeliveryID: deliveryID,
+		Provider:   provider,
+		Event:      event,
+		Repository: repository,
+		ProjectID:  projectID,
+		Key:        getSCMDeliveryKey(provider, projectID, repository, body),
+		Payload:    string(body),
+	})
+}
+
+func (s *Server) queueDelivery(delivery *model.GHDelivery) (bool, error) {
+	delivery.Status = model.PendingDelivery
+	delivery.NextAttemptAt = time.Now().UTC()
+	if err := s.DB.Create(delivery).Error; err != nil {
+		if checkForUniqueDNSError(err) {
+			return false, nil
+		}
+
+		return false, errors.Wrapf(err, "failed to save %s delivery %s", delivery.Provider, delivery.DeliveryID)
+	}
+
+	wakeUpGHDeliveryWorker()
+
+	return true, nil
+}
+
+// wakeUpGHDeliveryWorker notifies the worker that a delivery was queued
+func wakeUpGHDeliveryWorker() {
+	ghDeliveries.wakeUp()
+}
+
+func (s *Server) processGithubEvents() {
+	ghDeliveries.run(s.DB, s.processGHDelivery)
+}
+
+// processNextGHDelivery processes the oldest pending delivery. It returns false if there wasn't any delivery to process
+func (s *Server) processNextGHDelivery() bool {
+	id, err := ghDeliveries.claim(s.DB)
+	if err != nil {
+		logger.Error(err)
+		return false
+	}
+
+	if id == "" {
+		return false
+	}
+
+	s.processGHDelivery(id)
+	return true
+}
+
+// processGHDelivery processes a claimed delivery and saves the result of the attempt
+func (s *Server) processGHDelivery(id string) {
+	var delivery model.GHDelivery
+	if err := s.DB.Where("id = ?", id).First(&delivery).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get github delivery %s, it will be retried when it times out", id))
+		return
+	}
+
+	outdated, err := s.isGHDeliveryOutdated(&delivery)
+	if err == nil && outdated {
+		log.Printf("%s delivery %s was skipped, a newer delivery of %s was processed", delivery.Provider, delivery.DeliveryID, delivery.Key)
+	} else if err == nil {
+		err = s.handleGHDelivery(&delivery)
+	}
+
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "attempt %d of %s delivery %s failed", delivery.Attempts, delivery.Provider, delivery.DeliveryID))
+	}
+
+	ghDeliveries.complete(s.DB, delivery.ID, delivery.Attempts, nil, err)
+}
+
+// isGHDeliveryOutdated returns true if a newer delivery with the key of delivery was processed, e.g. when an old push
+// is replayed. Processing it would sync the services back to an older commit
+func (s *Server) isGHDeliveryOutdated(delivery *model.GHDelivery) (bool, error) {
+	if delivery.Key == "" {
+		return false, nil
+	}
+
+	count := 0
+	r := s.DB.Model(&model.GHDelivery{}).Where("key = ? AND status = ? AND created_at > ?", delivery.Key, model.ProcessedDelivery, delivery.CreatedAt).Count(&count)
+	if r.Error != nil {
+		return false, errors.Wrapf(r.Error, "failed to get the deliveries of %s", delivery.Key)
+	}
+
+	return count > 0, nil
+}
+
+func (s *Server) handleGHDelivery(delivery *model.GHDelivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while processing the delivery: %v", r)
+		}
+	}()
+
+	switch delivery.Provider {
+	case model.GitlabProvider:
+		return s.handleGitlabEvent(delivery.ProjectID, delivery.Event, []byte(delivery.Payload))
+	case model.BitbucketProvider:
+		return s.handleBitbucketEvent(delivery.ProjectID, delivery.Event, []byte(delivery.Payload))
+	}
+
+	payload := &GHWebhookPayload{}
+	if err := json.Unmarshal([]byte(delivery.Payload), payload); err != nil {
+		return errors.Wrap(err, "failed to load the payload")
+	}
+
+	payload.Event = delivery.Event
+	switch payload.Event {
+	case installationEvent:
+		return s.handleInstallation(payload)
+	case pushEvent:
+		return s.handlePush(payload)
+	case pullRequestEvent:
+		return s.handlePullRequest(payload)
+	default:
+		return fmt.Errorf("unknown github event queued: %s", payload.Event)
+	}
+}
+
+func getGHDeliveryBackoff(attempts int) time.Duration {
+	return ghDeliveryBackoff * time.Duration(1<<uint(attempts-1))
+}
+
+// syncGHDeliveries retries the deliveries that were lost while processing, and removes the deliveries that are older
+// than ghDeliveryRetention
+func (s *Server) syncGHDeliveries() {
+	ghDeliveries.sync(s.DB)
+}
+
+// GetGHDeliveries returns the deliveries received for the github installation and the gitlab and bitbucket repositories
+// of p, filtered by status if it's set
+func (s *Server) GetGHDeliveries(p *model.Project, status model.GHDeliveryStatus) ([]model.GHDelivery, error) {
+	query, err := s.getProjectDeliveries(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	deliveries := []model.GHDelivery{}
+	if err := query.Order("created_at desc").Find(&deliveries).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to get the deliveries of project-%s", p.ID)
+	}
+
+	return deliveries, nil
+}
+
+// ReplayGHDelivery queues a failed delivery of the github installation or the gitlab and bitbucket repositories of p
+// to be processed again
+func (s *Server) ReplayGHDelivery(p *model.Project, deliveryID string) (*model.GHDelivery, error) {
+	query, err := s.getProjectDeliveries(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var delivery model.GHDelivery
+	r := query.Where("id = ?", deliveryID).First(&delivery)
+	if r.Error != nil {
+		if r.RecordNotFound() {
+			return nil, errors.Wrapf(model.ErrNotFound, "delivery %s not found", deliveryID)
+		}
+
+		return nil, errors.Wrapf(r.Error, "failed to get delivery %s", deliveryID)
+	}
+
+	r = s.DB.Model(&delivery).Where("status = ?", model.FailedDelivery).
+		Updates(map[string]interface{}{"status": model.PendingDelivery, "attempts": 0, "last_error": "", "next_attempt_at": time.Now().UTC()})
+	if r.Error != nil {
+		return nil, errors.Wrapf(r.Error, "failed to replay delivery %s", deliveryID)
+	}
+
+	if r.RowsAffected == 0 {
+		return nil, model.ErrDeliveryNotFailed
+	}
+
+	log.Printf("%s delivery %s of project-%s was replayed", delivery.Provider, delivery.DeliveryID, p.ID)
+	wakeUpGHDeliveryWorker()
+
+	return &delivery, nil
+}
+
+// getProjectDeliveries returns the query of the deliveries of p: the deliveries of its github installation, and the
+// deliveries sent by the webhooks of the gitlab and bitbucket repositories linked by p
+func (s *Server) getProjectDeliveries(p *model.Project) (*gorm.DB, error) {
+	scopes := []string{}
+	values := []interface{}{}
+	if p.GHInstallationID != "" {
+		gi, err := s.getGHInstallation(p)
+		if err != nil {
+			return nil, err
+		}
+
+		scopes = append(scopes, "installation_id = ?")
+		values = append(values, gi.InstallationID)
+	}
+
+	count := 0
+	providers := []model.SCMProviderType{model.GitlabProvider, model.BitbucketProvider}
+	if err := s.DB.Model(&model.GHRepoLink{}).Where("project_id = ? AND provider in (?)", p.ID, providers).Count(&count).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to get the repositories linked by project-%s", p.ID)
+	}
+
+	// the gitlab and bitbucket deliveries don't have an installation, they have the project of the webhook instead
+	if count > 0 {
+		scopes = append(scopes, "project_id = ?")
+		values = append(values, p.ID)
+	}
+
+	if len(scopes) == 0 {
+		return nil, model.ErrProjectNotLinkedToGithub
+	}
+
+	return s.DB.Where(strings.Join(scopes, " OR "), values...), nil
+}
@@ -0,0 +1,311 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+	"github.com/pkg/errors"
+)
+
+func queueTestDelivery(t *testing.T, s *Server, deliveryID, event, body string) *model.GHDelivery {
+	payload := &GHWebhookPayload{Event: event, Installation: &GHInstallation{ID: 42}}
+	isNew, err := s.QueueGithubEvent(deliveryID, payload, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isNew {
+		t.Fatalf("delivery %s was considered a duplicate", deliveryID)
+	}
+
+	var d model.GHDelivery
+	if err := s.DB.Where("delivery_id = ?", deliveryID).First(&d).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return &d
+}
+
+func TestGHDeliveryProcessing(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	ghDeliveryBackoff = 0
+	defer func() { ghDeliveryBackoff = 30 * time.Second }()
+
+	body := `{"action":"created","installation":{"id":42,"account":{"login":"okteto"},"target_type":"Organization"},"sender":{"id":7}}`
+	processed := queueTestDelivery(t, &s, "a1", installationEvent, body)
+	failed := queueTestDelivery(t, &s, "b2", pushEvent, `{"installation":{"id":42}}`)
+
+	payload := &GHWebhookPayload{Event: installationEvent}
+	if isNew, err := s.QueueGithubEvent("a1", payload, []byte(body)); err != nil || isNew {
+		t.Errorf("a duplicated delivery was queued: %t %v", isNew, err)
+	}
+
+	for i := 0; s.processNextGHDelivery(); i++ {
+		if i > 2*ghDeliveryMaxAttempts {
+			t.Fatal("the deliveries were processed too many times")
+		}
+	}
+
+	if err := db.First(processed, "id = ?", processed.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if processed.Status != model.ProcessedDelivery || processed.Attempts != 1 {
+		t.Errorf("the installation wasn't processed: %+v", processed)
+	}
+
+	var i model.GHInstallation
+	if err := db.Where("installation_id = ?", 42).First(&i).Error; err != nil {
+		t.Errorf("the installation wasn't created: %s", err)
+	}
+
+	if err := db.First(failed, "id = ?", failed.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if failed.Status != model.FailedDelivery || failed.Attempts != ghDeliveryMaxAttempts {
+		t.Errorf("the push wasn't retried until it failed: %+v", failed)
+	}
+
+	if !strings.Contains(failed.LastError, "didn't have a ref") {
+		t.Errorf("the error of the push wasn't saved: %s", failed.LastError)
+	}
+
+	p := &model.Project{Name: "testproject", DNSName: "testproject", GHInstallationID: i.ID}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := s.GetGHDeliveries(p, model.FailedDelivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].ID != failed.ID {
+		t.Fatalf("expected only the failed delivery, got %+v", deliveries)
+	}
+
+	replayed, err := s.ReplayGHDelivery(p, failed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replayed.Status != model.PendingDelivery || replayed.Attempts != 0 || replayed.LastError != "" {
+		t.Errorf("the delivery wasn't reset: %+v", replayed)
+	}
+
+	if _, err := s.ReplayGHDelivery(p, failed.ID); err != model.ErrDeliveryNotFailed {
+		t.Errorf("a pending delivery was replayed: %v", err)
+	}
+
+	other := queueTestDelivery(t, &s, "c3", pushEvent, `{}`)
+	db.Model(other).Update("installation_id", 43)
+	if _, err := s.ReplayGHDelivery(p, other.ID); err == nil {
+		t.Errorf("a delivery of another installation was replayed")
+	}
+
+	if _, err := s.ReplayGHDelivery(&model.Project{}, failed.ID); err != model.ErrProjectNotLinkedToGithub {
+		t.Errorf("a delivery was replayed from a project without github: %v", err)
+	}
+}
+
+func TestSCMDeliveriesOfProjectWebhooks(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	p := &model.Project{Name: "testproject", DNSName: "testproject"}
+	other := &model.Project{Name: "otherproject", DNSName: "otherproject"}
+	for _, project := range []*model.Project{p, other} {
+		if err := db.Create(project).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := []*model.GHRepoLink{
+		{Provider: model.GitlabProvider, Repository: "okteto/app", Branch: "refs/heads/master", ProjectID: p.ID},
+		{Provider: model.GitlabProvider, Repository: "okteto/app", Branch: "refs/heads/develop", ProjectID: p.ID},
+		{Provider: model.BitbucketProvider, Repository: "okteto/other", Branch: "refs/heads/master", ProjectID: other.ID},
+		{Provider: model.GitlabProvider, Repository: "okteto/app", Branch: "refs/heads/master", ProjectID: other.ID},
+	}
+	for _, l := range links {
+		if err := db.Create(l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queue := func(provider model.SCMProviderType, projectID, deliveryID, event, body string) {
+		if _, err := s.QueueSCMEvent(provider, projectID, deliveryID, event, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queue(model.GitlabProvider, p.ID, "a1", gitlabPushEvent, `{"project":{"path_with_namespace":"okteto/app"}}`)
+	queue(model.BitbucketProvider, other.ID, "b2", bitbucketPushEvent, `{"repository":{"full_name":"okteto/other"}}`)
+	queue(model.GitlabProvider, other.ID, "c3", gitlabPushEvent, `{"project":{"path_with_namespace":"okteto/app"}}`)
+
+	deliveries, err := s.GetGHDeliveries(p, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].DeliveryID != "a1" || deliveries[0].Repository != "okteto/app" {
+		t.Fatalf("expected only the gitlab delivery of the webhook of the project, got %+v", deliveries)
+	}
+
+	var foreign model.GHDelivery
+	if err := db.Where("delivery_id = ?", "b2").First(&foreign).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	db.Model(&foreign).Update("status", model.FailedDelivery)
+	if _, err := s.ReplayGHDelivery(p, foreign.ID); errors.Cause(err) != model.ErrNotFound {
+		t.Errorf("a delivery of another project was replayed: %v", err)
+	}
+
+	if _, err := s.ReplayGHDelivery(other, foreign.ID); err != nil {
+		t.Errorf("the bitbucket delivery wasn't replayed: %v", err)
+	}
+}
+
+func TestGHDeliveriesOfARefAreProcessedInOrder(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	payload := &GHWebhookPayload{Event: pushEvent, Ref: "refs/heads/master", Repository: &GHRepo{ID: 7}}
+	if _, err := s.QueueGithubEvent("a1", payload, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	var first model.GHDelivery
+	if err := db.Where("delivery_id = ?", "a1").First(&first).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Key != "github/7/refs/heads/master" {
+		t.Fatalf("wrong key: %s", first.Key)
+	}
+
+	// the first push failed and it's waiting for a retry
+	if err := db.Model(&first).Updates(map[string]interface{}{"attempts": 1, "next_attempt_at": time.Now().Add(time.Hour).UTC()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond)
+	if _, err := s.QueueGithubEvent("b2", payload, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &GHWebhookPayload{Event: pushEvent, Ref: "refs/heads/other", Repository: &GHRepo{ID: 7}}
+	if _, err := s.QueueGithubEvent("c3", other, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := ghDeliveries.claim(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var third model.GHDelivery
+	if err := db.Where("delivery_id = ?", "c3").First(&third).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed != third.ID {
+		t.Fatalf("expected the push to the other branch to be claimed, got %s", claimed)
+	}
+
+	if claimed, err := ghDeliveries.claim(db); err != nil || claimed != "" {
+		t.Fatalf("a push was claimed before the older push to its branch: %s %v", claimed, err)
+	}
+
+	if err := db.Model(&first).Update("status", model.FailedDelivery).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var second model.GHDelivery
+	if err := db.Where("delivery_id = ?", "b2").First(&second).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed, err := ghDeliveries.claim(db); err != nil || claimed != second.ID {
+		t.Fatalf("the push wasn't claimed after the older push failed: %s %v", claimed, err)
+	}
+
+	ghDeliveries.complete(db, second.ID, 1, nil, nil)
+
+	// the replayed push is older than the processed one
+	if outdated, err := s.isGHDeliveryOutdated(&first); err != nil || !outdated {
+		t.Errorf("the replay of an old push isn't outdated: %t %v", outdated, err)
+	}
+
+	if outdated, err := s.isGHDeliveryOutdated(&third); err != nil || outdated {
+		t.Errorf("the push to the other branch is outdated: %t %v", outdated, err)
+	}
+}
+
+func TestSyncGHDeliveries(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	stuck := queueTestDelivery(t, &s, "a1", pushEvent, `{}`)
+	exhausted := queueTestDelivery(t, &s, "b2", pushEvent, `{}`)
+	old := queueTestDelivery(t, &s, "c3", pushEvent, `{}`)
+	pending := queueTestDelivery(t, &s, "d4", pushEvent, `{}`)
+
+	past := time.Now().UTC().Add(-ghDeliveryRetention - time.Hour)
+	db.Model(stuck).UpdateColumns(map[string]interface{}{"status": model.ProcessingDelivery, "attempts": 1, "updated_at": past})
+	db.Model(exhausted).UpdateColumns(map[string]interface{}{"status": model.ProcessingDelivery, "attempts": ghDeliveryMaxAttempts, "updated_at": past})
+	db.Model(old).UpdateColumns(map[string]interface{}{"status": model.ProcessedDelivery, "created_at": past})
+	db.Model(pending).UpdateColumns(map[string]interface{}{"created_at": past})
+
+	s.syncGHDeliveries()
+
+	expected := map[string]model.GHDeliveryStatus{
+		stuck.ID:     model.PendingDelivery,
+		exhausted.ID: model.FailedDelivery,
+		pending.ID:   model.PendingDelivery,
+	}
+
+	for id, status := range expected {
+		var d model.GHDelivery
+		if err := db.First(&d, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Status != status {
+			t.Errorf("delivery %s: expected %s got %s", d.DeliveryID, status, d.Status)
+		}
+	}
+
+	count := 0
+	db.Unscoped().Model(&model.GHDelivery{}).Where("id = ?", old.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("the old delivery wasn't deleted")
+	}
+}
+
+func Test_getGHDeliveryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 30 * time.Second},
+		{attempts: 2, expected: time.Minute},
+		{attempts: 4, expected: 4 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := getGHDeliveryBackoff(tt.attempts); got != tt.expected {
+			t.Errorf("attempt %d: expected %s got %s", tt.attempts, tt.expected, got)
+		}
+	}
+}
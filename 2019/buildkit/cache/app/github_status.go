@@ -0,0 +1,265 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	ghPendingState = "pending"
+	ghSuccessState = "success"
+	ghFailureState = "failure"
+
+	// ghAPITimeout is the maximum time of the requests to the github api
+	ghAPITimeout = 30 * time.Second
+)
+
+// GHReporter is an interface used to report the activities of the services linked to github as commit statuses
+// and deployments of the repository
+type GHReporter interface {
+	createStatus(link *model.GHRepoLink, commit string, status *github.RepoStatus) error
+	createDeployment(link *model.GHRepoLink, request *github.DeploymentRequest) (int64, error)
+	createDeploymentStatus(link *model.GHRepoLink, deploymentID int64, request *github.DeploymentStatusRequest) error
+}
+
+// GHAPIReporter reports the activities with the github api, implements the GHReporter interface
+type GHAPIReporter struct{}
+
+// githubReporter reports the activities of the services linked to github
+var githubReporter GHReporter = &GHAPIReporter{}
+
+// reportActivity creates the commit status of the activity in the linked repository in the background. The deployments
+// of github repositories also create a github deployment, and update its status as the activity changes state. The
+// reports of an activity are sent in order, with the status that the activity has when it's reported
+func (s *Server) reportActivity(activityID string) {
+	var activity model.Activity
+	if err := s.DB.Where("id = ?", activityID).First(&activity).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load activity-%s to report it to github", activityID))
+		return
+	}
+
+	if activity.Commit == "" || (activity.Type != model.Deployed && activity.Type != model.Updated) {
+		return
+	}
+
+	s.queueReport(activityID, func() { s.sendActivityReport(activityID, activity.Status) })
+}
+
+// queueReport runs send after the reports of the activity queued before it. Each activity has its own queue, so a slow
+// repository doesn't delay the reports of the rest
+func (s *Server) queueReport(activityID string, send func()) {
+	s.reportsMutex.Lock()
+	if s.reports == nil {
+		s.reports = map[string][]func(){}
+	}
+
+	queue, running := s.reports[activityID]
+	s.reports[activityID] = append(queue, send)
+	s.reportsMutex.Unlock()
+
+	if running {
+		return
+	}
+
+	s.pendingOperations.Add(1)
+	go func() {
+		defer s.pendingOperations.Done()
+		for {
+			s.reportsMutex.Lock()
+			queue := s.reports[activityID]
+			if len(queue) == 0 {
+				delete(s.reports, activityID)
+				s.reportsMutex.Unlock()
+				return
+			}
+
+			s.reports[activityID] = queue[1:]
+			s.reportsMutex.Unlock()
+			queue[0]()
+		}
+	}()
+}
+
+// sendActivityReport reports the activity with status. The rest of the activity is loaded again, it has the github
+// deployment created by the previous reports
+func (s *Server) sendActivityReport(activityID string, status model.ActivityStatus) {
+	var activity model.Activity
+	if err := s.DB.Where("id = ?", activityID).First(&activity).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load activity-%s to report it to github", activityID))
+		return
+	}
+
+	activity.Status = status
+	service, appErr := s.GetServiceByID(activity.ServiceID)
+	if appErr != nil {
+		logger.Error(errors.Wrapf(appErr, "failed to load service-%s to report activity-%s", activity.ServiceID, activityID))
+		return
+	}
+
+	if service.GHRepoLinkID == "" {
+		return
+	}
+
+	project, err := s.getProjectByID(service.ProjectID)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load project-%s to report activity-%s", service.ProjectID, activityID))
+		return
+	}
+
+	if project == nil {
+		log.Printf("project-%s doesn't exist, activity-%s won't be reported", service.ProjectID, activityID)
+		return
+	}
+
+	link, err := s.getRepoLink(service)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	if err := s.reportActivityToSCM(project, service, &activity, link); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to report activity-%s of service-%s to %s", activityID, service.ID, link.GetProvider()))
+	}
+}
+
+func (s *Server) reportActivityToSCM(project *model.Project, service *model.Service, activity *model.Activity, link *model.GHRepoLink) error {
+	environment := fmt.Sprintf("%s/%s", getServiceProject(project, service).DNSName, service.Name)
+	state := getGHState(activity.Status)
+	logs := getActivityPageURL(service.ProjectID, service.ID, activity.ID)
+	description := getGHDescription(service, activity)
+
+	provider, err := s.getLinkProvider(link)
+	if err != nil {
+		return err
+	}
+
+	err = provider.CreateStatus(link, activity.Commit, &SCMStatus{
+		State:       state,
+		TargetURL:   logs,
+		Description: description,
+		Context:     fmt.Sprintf("okteto/%s", environment),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create the commit status")
+	}
+
+	if activity.Type != model.Deployed || link.GetProvider() != model.GithubProvider {
+		return nil
+	}
+
+	if activity.GHDeploymentID == 0 {
+		id, err := githubReporter.createDeployment(link, &github.DeploymentRequest{
+			Ref:                  github.String(activity.Commit),
+			Environment:          github.String(environment),
+			Description:          github.String(description),
+			AutoMerge:            github.Bool(false),
+			RequiredContexts:     &[]string{},
+			TransientEnvironment: github.Bool(service.PreviewID != ""),
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to create the deployment")
+		}
+
+		activity.GHDeploymentID = id
+		if err := s.DB.Model(activity).Update("gh_deployment_id", id).Error; err != nil {
+			return errors.Wrap(err, "failed to save the deployment")
+		}
+	}
+
+	request := &github.DeploymentStatusRequest{
+		State:       github.String(state),
+		LogURL:      github.String(logs),
+		Description: github.String(description),
+	}
+
+	if state == ghSuccessState && project.LoadedSettings != nil {
+		if m, appErr := buildService(service, project, false, nil); appErr == nil {
+			endpoints := s.buildServiceEndpoints(m, getServiceProject(project, service), service.DNS)
+			if len(endpoints) > 0 {
+				request.EnvironmentURL = github.String(endpoints[0])
+			}
+		}
+	}
+
+	if err := githubReporter.createDeploymentStatus(link, activity.GHDeploymentID, request); err != nil {
+		return errors.Wrap(err, "failed to create the deployment status")
+	}
+
+	return nil
+}
+
+func getGHState(status model.ActivityStatus) string {
+	switch status {
+	case model.Completed:
+		return ghSuccessState
+	case model.Failed:
+		return ghFailureState
+	default:
+		return ghPendingState
+	}
+}
+
+func getGHDescription(service *model.Service, activity *model.Activity) string {
+	if activity.Type == model.Updated {
+		return fmt.Sprintf("The manifest of '%s' was updated", service.Name)
+	}
+
+	switch activity.Status {
+	case model.Completed:
+		return fmt.Sprintf("'%s' was deployed", service.Name)
+	case model.Failed:
+		return fmt.Sprintf("'%s' failed to deploy", service.Name)
+	default:
+		return fmt.Sprintf("'%s' is being deployed", service.Name)
+	}
+}
+
+func (r *GHAPIReporter) createStatus(link *model.GHRepoLink, commit string, status *github.RepoStatus) error {
+	client, repo, err := getGHRepository(link)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ghAPITimeout)
+	defer cancel()
+
+	_, _, err = client.Repositories.CreateStatus(ctx, repo.GetOwner().GetLogin(), repo.GetName(), commit, status)
+	return err
+}
+
+func (r *GHAPIReporter) createDeployment(link *model.GHRepoLink, request *github.DeploymentRequest) (int64, error) {
+	client, repo, err := getGHRepository(link)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ghAPITimeout)
+	defer cancel()
+
+	deployment, _, err := client.Repositories.CreateDeployment(ctx, repo.GetOwner().GetLogin(), repo.GetName(), request)
+	if err != nil {
+		return 0, err
+	}
+
+	return deployment.GetID(), nil
+}
+
+func (r *GHAPIReporter) createDeploymentStatus(link *model.GHRepoLink, deploymentID int64, request *github.DeploymentStatusRequest) error {
+	client, repo, err := getGHRepository(link)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ghAPITimeout)
+	defer cancel()
+
+	_, _, err = client.Repositories.CreateDeploymentStatus(ctx, repo.GetOwner().GetLogin(), repo.GetName(), deploymentID, request)
+	return err
+}
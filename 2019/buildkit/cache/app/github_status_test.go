@@ -0,0 +1,198 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+	"github.com/google/go-github/github"
+)
+
+type fakeReporter struct {
+	statuses           []string
+	deployments        []*github.DeploymentRequest
+	deploymentStatuses []string
+	mu                 sync.Mutex
+}
+
+func (f *fakeReporter) createStatus(link *model.GHRepoLink, commit string, status *github.RepoStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses = append(f.statuses, fmt.Sprintf("%s %s %s", commit, status.GetContext(), status.GetState()))
+	return nil
+}
+
+func (f *fakeReporter) createDeployment(link *model.GHRepoLink, request *github.DeploymentRequest) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deployments = append(f.deployments, request)
+	return int64(len(f.deployments)), nil
+}
+
+func (f *fakeReporter) createDeploymentStatus(link *model.GHRepoLink, deploymentID int64, request *github.DeploymentStatusRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deploymentStatuses = append(f.deploymentStatuses, fmt.Sprintf("%d %s", deploymentID, request.GetState()))
+	return nil
+}
+
+func (f *fakeReporter) waitForStatuses(count int) ([]string, error) {
+	for i := 0; i < 100; i++ {
+		f.mu.Lock()
+		statuses := append([]string{}, f.statuses...)
+		f.mu.Unlock()
+		if len(statuses) >= count {
+			return statuses, nil
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return nil, fmt.Errorf("expected %d statuses, got %+v", count, f.statuses)
+}
+
+func TestReportActivity(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	reporter := &fakeReporter{}
+	githubReporter = reporter
+	defer func() { githubReporter = &GHAPIReporter{} }()
+
+	s := Server{DB: db}
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Branch: "refs/heads/master"}
+	if err := db.Create(link).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &model.Service{Manifest: httpsService, Name: "service", GHRepoLinkID: link.ID}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	update := &model.Service{Manifest: httpsService, Commit: "a1b2c3"}
+	if appErr := s.UpdateManifest(p.ID, svc.ID, update, nil, githubActorID, "updated"); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	statuses, err := reporter.waitForStatuses(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statuses[0] != "a1b2c3 okteto/testproject/oktetotest success" {
+		t.Errorf("wrong status of the manifest update: %s", statuses[0])
+	}
+
+	project, err := s.getProjectByID(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if appErr := s.StartService(project, svc.ID, u); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	if err := s.waitUntil(p, svc.ID, model.DeployedService); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err = reporter.waitForStatuses(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a1b2c3 okteto/testproject/oktetotest pending", "a1b2c3 okteto/testproject/oktetotest success"}
+	if statuses[1] != expected[0] || statuses[2] != expected[1] {
+		t.Errorf("wrong statuses of the deployment: %+v", statuses)
+	}
+
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	if len(reporter.deployments) != 1 || reporter.deployments[0].GetRef() != "a1b2c3" || reporter.deployments[0].GetEnvironment() != "testproject/oktetotest" {
+		t.Errorf("wrong deployments: %+v", reporter.deployments)
+	}
+
+	if len(reporter.deploymentStatuses) != 2 || reporter.deploymentStatuses[0] != "1 pending" || reporter.deploymentStatuses[1] != "1 success" {
+		t.Errorf("wrong deployment statuses: %+v", reporter.deploymentStatuses)
+	}
+
+	var activity model.Activity
+	if err := db.Where("service_id = ? AND type = ?", svc.ID, model.Deployed).First(&activity).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if activity.Commit != "a1b2c3" || activity.GHDeploymentID != 1 {
+		t.Errorf("the deployment wasn't saved in the activity: %+v", activity)
+	}
+}
+
+func Test_getGHState(t *testing.T) {
+	tests := []struct {
+		status model.ActivityStatus
+		want   string
+	}{
+		{status: model.InProgress, want: ghPendingState},
+		{status: model.Completed, want: ghSuccessState},
+		{status: model.Failed, want: ghFailureState},
+	}
+
+	for _, tt := range tests {
+		if got := getGHState(tt.status); got != tt.want {
+			t.Errorf("getGHState(%s) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+}
+
+func Test_getGHRepositoryIsCached(t *testing.T) {
+	link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2}
+	cached := &cachedGHRepository{client: github.NewClient(nil), repo: &github.Repository{Name: github.String("app")}, expiration: time.Now().Add(time.Minute)}
+	ghRepositoriesMutex.Lock()
+	ghRepositories["1/2"] = cached
+	ghRepositoriesMutex.Unlock()
+	defer func() {
+		ghRepositoriesMutex.Lock()
+		delete(ghRepositories, "1/2")
+		ghRepositoriesMutex.Unlock()
+	}()
+
+	client, repo, err := getGHRepository(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client != cached.client || repo.GetName() != "app" {
+		t.Errorf("the cached repository wasn't returned: %+v", repo)
+	}
+}
+
+func Test_queueReportKeepsTheOrder(t *testing.T) {
+	s := Server{}
+	sent := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		i := i
+		s.queueReport("activity-1", func() { sent <- i })
+	}
+
+	for i := 0; i < 10; i++ {
+		select {
+		case got := <-sent:
+			if got != i {
+				t.Fatalf("report %d was sent in position %d", got, i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("report %d wasn't sent", i)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package app
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	"bitbucket.org/okteto/okteto/backend/model"
@@ -85,6 +88,9 @@ func TestUnlinkGHRepositoryToService(t *testing.T) {
 	pID, _ := s.CreateProject(&model.Project{Name: "test"}, u)
 	p, _ := s.GetProject(pID, u.ID)
 	svc, _ := s.CreateService(p, &model.Service{Manifest: httpService, GHRepoLinkID: "12345"}, u)
+	if err := db.Create(&model.GHLinkManifest{GHRepoLinkID: "12345", ServiceID: svc.ID, Manifest: "okteto.yml"}).Error; err != nil {
+		t.Fatal(err)
+	}
 
 	svc, appErr := s.GetServiceByID(svc.ID)
 	if appErr != nil {
@@ -108,6 +114,10 @@ func TestUnlinkGHRepositoryToService(t *testing.T) {
 	if svc.GHRepoLinkID != "" {
 		t.Fatalf("service wasn't unlinked: '%s'", svc.GHRepoLinkID)
 	}
+
+	if err := db.Create(&model.GHLinkManifest{GHRepoLinkID: "67890", ServiceID: svc.ID, Manifest: "okteto.yml"}).Error; err != nil {
+		t.Errorf("the service can't be linked again: %s", err)
+	}
 }
 
 func Test_getCanonicalBranchName(t *testing.T) {
@@ -126,6 +136,16 @@ func Test_getCanonicalBranchName(t *testing.T) {
 			branch: "refs/heads/master",
 			want:   "refs/heads/master",
 		},
+		{
+			name:   "tag",
+			branch: "refs/tags/v1.0",
+			want:   "refs/tags/v1.0",
+		},
+		{
+			name:   "pattern",
+			branch: "release/*",
+			want:   "refs/heads/release/*",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -135,3 +155,345 @@ func Test_getCanonicalBranchName(t *testing.T) {
 		})
 	}
 }
+
+func TestMonorepoPush(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	manifest := getTestManifest(t)
+	fetched := map[string]string{}
+	getFileFromRepo = func(installationID int, owner, name, path, commit string) (string, error) {
+		fetched[path] = commit
+		return manifest, nil
+	}
+	defer func() { getFileFromRepo = downloadFileFromGH }()
+
+	link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Branch: "refs/heads/master"}
+	if err := db.Create(link).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	services := map[string]*model.Service{}
+	for _, m := range []model.GHLinkManifest{
+		{Manifest: "frontend/okteto.yml", Paths: "frontend/**,shared/*.json"},
+		{Manifest: "backend/okteto.yml", Paths: "backend/"},
+	} {
+		name := strings.Split(m.Manifest, "/")[0]
+		p := &model.Project{Name: name, DNSName: name, Settings: demoProject}
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		svc := &model.Service{Manifest: httpsService, Name: "service", GHRepoLinkID: link.ID}
+		if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+			t.Fatalf("Create failed %+v", appErr)
+		}
+
+		m.GHRepoLinkID = link.ID
+		m.ServiceID = svc.ID
+		if err := db.Create(&m).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		services[name] = svc
+	}
+
+	push := func(commit string, commits []SCMCommit) {
+		err := s.handlePush(&GHWebhookPayload{
+			Installation: &GHInstallation{ID: 1},
+			Repository:   &GHRepo{ID: 2, Name: "app", Owner: &GHAccount{Login: "okteto"}},
+			Sender:       &GHAccount{Login: "developer"},
+			Ref:          "refs/heads/master",
+			Commit:       commit,
+			Commits:      commits,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		commit   string
+		commits  []SCMCommit
+		frontend string
+		backend  string
+	}{
+		{
+			name:     "frontend",
+			commit:   "a1",
+			commits:  []SCMCommit{{Modified: []string{"frontend/src/app.js"}}, {Added: []string{"README.md"}}},
+			frontend: "a1",
+		},
+		{
+			name:     "shared",
+			commit:   "b2",
+			commits:  []SCMCommit{{Removed: []string{"shared/config.json"}}},
+			frontend: "b2",
+		},
+		{
+			name:     "backend",
+			commit:   "c3",
+			commits:  []SCMCommit{{Modified: []string{"backend/main.go"}}},
+			frontend: "b2",
+			backend:  "c3",
+		},
+		{
+			name:     "unrelated",
+			commit:   "d4",
+			commits:  []SCMCommit{{Modified: []string{"docs/index.md"}}},
+			frontend: "b2",
+			backend:  "c3",
+		},
+		{
+			name:     "unknown-files",
+			commit:   "e5",
+			frontend: "e5",
+			backend:  "e5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			push(tt.commit, tt.commits)
+
+			for name, expected := range map[string]string{"frontend": tt.frontend, "backend": tt.backend} {
+				svc, appErr := s.GetServiceByID(services[name].ID)
+				if appErr != nil {
+					t.Fatal(appErr)
+				}
+
+				if svc.Commit != expected {
+					t.Errorf("expected the %s service at '%s', got '%s'", name, expected, svc.Commit)
+				}
+
+				if expected != "" && fetched[name+"/okteto.yml"] != expected {
+					t.Errorf("the %s manifest was fetched at '%s'", name, fetched[name+"/okteto.yml"])
+				}
+			}
+		})
+	}
+}
+
+func TestPatternPush(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	manifest := getTestManifest(t)
+	getFileFromRepo = func(installationID int, owner, name, path, commit string) (string, error) {
+		return manifest, nil
+	}
+	defer func() { getFileFromRepo = downloadFileFromGH }()
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	services := map[string]*model.Service{}
+	for _, branch := range []string{"refs/tags/v*", "refs/heads/release/*"} {
+		link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Branch: branch}
+		if err := db.Create(link).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		name := fmt.Sprintf("project%d", len(services))
+		p := &model.Project{Name: name, DNSName: name, Settings: demoProject}
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		svc := &model.Service{Manifest: httpsService, Name: "service", GHRepoLinkID: link.ID}
+		if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+			t.Fatalf("Create failed %+v", appErr)
+		}
+
+		services[branch] = svc
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		tag     string
+		release string
+	}{
+		{
+			name:    "annotated-tag",
+			payload: `{"ref":"refs/tags/v1.2.0","after":"t1","head_commit":{"id":"a1"}}`,
+			tag:     "a1:refs/tags/v1.2.0",
+		},
+		{
+			name:    "release-branch",
+			payload: `{"ref":"refs/heads/release/1.0","after":"b2","head_commit":{"id":"b2"}}`,
+			tag:     "a1:refs/tags/v1.2.0",
+			release: "b2:refs/heads/release/1.0",
+		},
+		{
+			name:    "other-branch",
+			payload: `{"ref":"refs/heads/master","after":"c3","head_commit":{"id":"c3"}}`,
+			tag:     "a1:refs/tags/v1.2.0",
+			release: "b2:refs/heads/release/1.0",
+		},
+		{
+			name:    "deleted-tag",
+			payload: `{"ref":"refs/tags/v1.3.0","after":"0000000000000000000000000000000000000000","deleted":true}`,
+			tag:     "a1:refs/tags/v1.2.0",
+			release: "b2:refs/heads/release/1.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &GHWebhookPayload{}
+			if err := json.Unmarshal([]byte(tt.payload), payload); err != nil {
+				t.Fatal(err)
+			}
+
+			payload.Installation = &GHInstallation{ID: 1}
+			payload.Repository = &GHRepo{ID: 2, Name: "app", Owner: &GHAccount{Login: "okteto"}}
+			payload.Sender = &GHAccount{Login: "developer"}
+			if err := s.handlePush(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			for branch, expected := range map[string]string{"refs/tags/v*": tt.tag, "refs/heads/release/*": tt.release} {
+				svc, appErr := s.GetServiceByID(services[branch].ID)
+				if appErr != nil {
+					t.Fatal(appErr)
+				}
+
+				got := ""
+				if svc.Commit != "" {
+					got = svc.Commit + ":" + svc.Branch
+				}
+
+				if got != expected {
+					t.Errorf("expected the service linked to %s at '%s', got '%s'", branch, expected, got)
+				}
+			}
+		})
+	}
+
+	svc, _ := s.GetServiceByID(services["refs/tags/v*"].ID)
+	vars := getManifestVariables(svc, &model.Project{Name: "project0"})
+	if vars[model.RefVariable] != "v1.2.0" {
+		t.Errorf("wrong ref variable: '%s'", vars[model.RefVariable])
+	}
+}
+
+func TestPushKeepsTheOverlaySetManually(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	manifest := getTestManifest(t)
+	getFileFromRepo = func(installationID int, owner, name, path, commit string) (string, error) {
+		if path == "overlay.yml" {
+			return "replicas: 3", nil
+		}
+
+		return manifest, nil
+	}
+	defer func() { getFileFromRepo = downloadFileFromGH }()
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	manual := base64.StdEncoding.EncodeToString([]byte("replicas: 2"))
+	services := map[string]*model.Service{}
+	for i, overlay := range []string{"", "overlay.yml"} {
+		link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Branch: "refs/heads/master", Overlay: overlay}
+		if err := db.Create(link).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		name := fmt.Sprintf("project%d", i)
+		p := &model.Project{Name: name, DNSName: name, Settings: demoProject}
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		svc := &model.Service{Manifest: httpsService, Overlay: manual, Name: "service", GHRepoLinkID: link.ID}
+		if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+			t.Fatalf("Create failed %+v", appErr)
+		}
+
+		services[overlay] = svc
+	}
+
+	payload := &GHWebhookPayload{}
+	if err := json.Unmarshal([]byte(`{"ref":"refs/heads/master","after":"a1","head_commit":{"id":"a1"}}`), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	payload.Installation = &GHInstallation{ID: 1}
+	payload.Repository = &GHRepo{ID: 2, Name: "app", Owner: &GHAccount{Login: "okteto"}}
+	payload.Sender = &GHAccount{Login: "developer"}
+	if err := s.handlePush(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	for overlay, expected := range map[string]string{"": "replicas: 2", "overlay.yml": "replicas: 3"} {
+		svc, appErr := s.GetServiceByID(services[overlay].ID)
+		if appErr != nil {
+			t.Fatal(appErr)
+		}
+
+		if svc.Commit != "a1" {
+			t.Errorf("the service linked with the overlay '%s' wasn't synced: '%s'", overlay, svc.Commit)
+		}
+
+		got, _ := base64.StdEncoding.DecodeString(svc.Overlay)
+		if string(got) != expected {
+			t.Errorf("expected the overlay '%s' for the link with the overlay '%s', got '%s'", expected, overlay, got)
+		}
+	}
+}
+
+func TestLoadConfigFilesAtSyncedCommit(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	fetched := map[string]string{}
+	getFileFromRepo = func(installationID int, owner, name, path, commit string) (string, error) {
+		fetched[path] = commit
+		return "content of " + path, nil
+	}
+	defer func() { getFileFromRepo = downloadFileFromGH }()
+
+	link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Repository: "okteto/app", Branch: "refs/heads/master"}
+	if err := db.Create(link).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		commit   string
+		expected string
+	}{
+		{name: "synced", commit: "a1b2c3", expected: "a1b2c3"},
+		{name: "never-synced", expected: "refs/heads/master"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &model.Service{Model: model.Model{ID: "service"}, GHRepoLinkID: link.ID, Commit: tt.commit}
+			svc := &model.Service{Configs: map[string]*model.Config{"settings": {Name: "settings", File: "config/settings.yml"}}}
+			if err := s.loadConfigFiles(svc, d); err != nil {
+				t.Fatal(err)
+			}
+
+			if fetched["config/settings.yml"] != tt.expected {
+				t.Errorf("expected the config at '%s', got '%s'", tt.expected, fetched["config/settings.yml"])
+			}
+
+			if svc.Configs["settings"].Content != "content of config/settings.yml" {
+				t.Errorf("wrong content: %s", svc.Configs["settings"].Content)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultGitlabURL = "https://gitlab.com"
+
+	gitlabTokenHeader = "X-Gitlab-Token"
+	gitlabPushEvent   = "Push Hook"
+	gitlabTagEvent    = "Tag Push Hook"
+)
+
+// gitlabStates are the commit status states of gitlab for every github state
+var gitlabStates = map[string]string{
+	ghPendingState: "running",
+	ghSuccessState: "success",
+	ghFailureState: "failed",
+}
+
+// GitlabSCM is the gitlab implementation of SCMProvider, it authenticates with the access token of the project settings
+type GitlabSCM struct {
+	s *Server
+}
+
+type gitlabProject struct {
+	ID                int    `json:"id"`
+	Path              string `json:"path"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	DefaultBranch     string `json:"default_branch"`
+	WebURL            string `json:"web_url"`
+}
+
+// gitlabPushPayload is the payload of a gitlab push event
+type gitlabPushPayload struct {
+	Ref          string         `json:"ref"`
+	CheckoutSHA  string         `json:"checkout_sha"`
+	UserUsername string         `json:"user_username"`
+	Project      *gitlabProject `json:"project"`
+	Commits      []SCMCommit    `json:"commits"`
+
+	// TotalCommitsCount is the number of commits of the push, the payload only has the last 20
+	TotalCommitsCount int `json:"total_commits_count"`
+}
+
+// ListRepositories returns the gitlab projects where the token of p is a member
+func (g *GitlabSCM) ListRepositories(p *model.Project) ([]*SCMRepository, error) {
+	credentials, err := getSCMCredentials(p, model.GitlabProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newGitlabRequest(credentials, http.MethodGet, "/projects?membership=true&simple=true&per_page=100", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []gitlabProject
+	if err := decodeSCMResponse(req, &projects); err != nil {
+		return nil, errors.Wrapf(err, "failed to get gitlab repositories for project-%s", p.ID)
+	}
+
+	result := make([]*SCMRepository, len(projects))
+	for i := range projects {
+		result[i] = projects[i].toSCMRepository()
+	}
+
+	return result, nil
+}
+
+// GetRepository returns the gitlab project owner/name, the owner can include subgroups
+func (g *GitlabSCM) GetRepository(p *model.Project, owner, name string) (*SCMRepository, error) {
+	credentials, err := getSCMCredentials(p, model.GitlabProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newGitlabRequest(credentials, http.MethodGet, "/projects/"+url.PathEscape(owner+"/"+name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var project gitlabProject
+	if err := decodeSCMResponse(req, &project); err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s/%s via the gitlab api", owner, name)
+	}
+
+	return project.toSCMRepository(), nil
+}
+
+// GetFile returns the raw content of a file of the linked repository at ref
+func (g *GitlabSCM) GetFile(link *model.GHRepoLink, path, ref string) (string, error) {
+	credentials, err := g.s.getLinkCredentials(link)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("/projects/%s/repository/files/%s/raw?ref=%s", getGitlabProjectID(link), url.PathEscape(path), url.QueryEscape(getShortRefName(ref)))
+	req, err := newGitlabRequest(credentials, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := sendSCMRequest(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get %s of %s at %s", path, link.Repository, ref)
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s of %s at %s", path, link.Repository, ref)
+	}
+
+	return string(content), nil
+}
+
+// DownloadRepository extracts the archive of the linked repository at commit into dir
+func (g *GitlabSCM) DownloadRepository(link *model.GHRepoLink, commit, dir string) error {
+	credentials, err := g.s.getLinkCredentials(link)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("/projects/%s/repository/archive.tar.gz?sha=%s", getGitlabProjectID(link), url.QueryEscape(commit))
+	req, err := newGitlabRequest(credentials, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := downloadSCMArchive(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to download the archive of %s at %s", link.Repository, commit)
+	}
+	defer res.Body.Close()
+
+	return extractTarball(res.Body, dir)
+}
+
+// VerifyWebhook returns true if the delivery includes the secret token of the gitlab webhook
+func (g *GitlabSCM) VerifyWebhook(header http.Header, body []byte, secret string) bool {
+	if secret == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(header.Get(gitlabTokenHeader)), []byte(secret)) == 1
+}
+
+// CreateStatus creates a commit status in the linked repository
+func (g *GitlabSCM) CreateStatus(link *model.GHRepoLink, commit string, status *SCMStatus) error {
+	credentials, err := g.s.getLinkCredentials(link)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]string{
+		"state":       gitlabStates[status.State],
+		"target_url":  status.TargetURL,
+		"description": status.Description,
+		"name":        status.Context,
+	}
+
+	req, err := newGitlabRequest(credentials, http.MethodPost, fmt.Sprintf("/projects/%s/statuses/%s", getGitlabProjectID(link), commit), body)
+	if err != nil {
+		return err
+	}
+
+	res, err := sendSCMRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (s *Server) handleGitlabEvent(projectID, event string, body []byte) error {
+	if event != gitlabPushEvent && event != gitlabTagEvent {
+		return fmt.Errorf("unknown gitlab event queued: %s", event)
+	}
+
+	payload := &gitlabPushPayload{}
+	if err := json.Unmarshal(body, payload); err != nil {
+		return errors.Wrap(err, "failed to load the gitlab push")
+	}
+
+	if payload.Project == nil || payload.Ref == "" {
+		return errors.New("gitlab push didn't have a project or a ref")
+	}
+
+	// the pushes that delete a branch or a tag don't have a commit
+	if payload.CheckoutSHA == "" || !isBranchOrTag(payload.Ref) {
+		return nil
+	}
+
+	return s.syncManifestFromSCM(model.GitlabProvider, projectID, payload.Project.PathWithNamespace, payload.Ref, payload.CheckoutSHA, payload.UserUsername, getChangedFiles(payload.Commits, payload.TotalCommitsCount))
+}
+
+func newGitlabRequest(credentials *model.SCMCredentials, method, endpoint string, body interface{}) (*http.Request, error) {
+	base := strings.TrimSuffix(orDefault(credentials.URL, defaultGitlabURL), "/")
+	req, err := newSCMRequest(method, base+"/api/v4"+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("PRIVATE-TOKEN", credentials.Token)
+	return req, nil
+}
+
+// getGitlabProjectID returns the ID of the linked gitlab project, or its escaped path if the ID is not known
+func getGitlabProjectID(link *model.GHRepoLink) string {
+	if link.RepositoryID != 0 {
+		return strconv.Itoa(link.RepositoryID)
+	}
+
+	return url.PathEscape(link.Repository)
+}
+
+func (p *gitlabProject) toSCMRepository() *SCMRepository {
+	owner, _ := splitRepository(p.PathWithNamespace)
+	return &SCMRepository{
+		ID:            p.ID,
+		Owner:         owner,
+		Name:          p.Path,
+		FullName:      p.PathWithNamespace,
+		DefaultBranch: p.DefaultBranch,
+		URL:           p.WebURL,
+	}
+}
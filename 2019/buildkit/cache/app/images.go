@@ -0,0 +1,334 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	dockerHubAPI         = "registry-1.docker.io"
+	registryTimeout      = 30 * time.Second
+	contentDigestHeader  = "Docker-Content-Digest"
+	authenticateHeader   = "WWW-Authenticate"
+	maxManifestSizeBytes = 4 * 1024 * 1024
+)
+
+var (
+	manifestMediaTypes = []string{
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.oci.image.manifest.v1+json",
+	}
+
+	challengeParameter = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// ImageResolver is an interface used to resolve the tags of the container images to the digests they reference
+type ImageResolver interface {
+	resolve(ref *model.ImageReference, registry *model.Registry) (string, error)
+}
+
+// RegistryResolver resolves the tags through the Docker Registry HTTP API V2, implements the ImageResolver interface.
+// The credentials of the project registry are used for the images hosted in it
+type RegistryResolver struct {
+	Client *http.Client
+}
+
+// NewRegistryResolver returns a RegistryResolver with the default timeout. The registries and their authentication
+// servers are set by the manifests, so like the webhooks it only connects to public addresses
+func NewRegistryResolver() ImageResolver {
+	return &RegistryResolver{Client: &http.Client{
+		Timeout: registryTimeout,
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: registryTimeout, Control: checkWebhookAddress}).DialContext,
+			TLSHandshakeTimeout: registryTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}}
+}
+
+func (r *RegistryResolver) resolve(ref *model.ImageReference, registry *model.Registry) (string, error) {
+	host := ref.Registry
+	if host == model.DockerHub {
+		host = dockerHubAPI
+	}
+
+	if !isRegistryOf(registry, ref) {
+		registry = nil
+	}
+
+	manifest := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, ref.Repository, ref.Reference())
+	res, err := r.request(http.MethodHead, manifest, registry)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+
+	if digest := res.Header.Get(contentDigestHeader); digest != "" {
+		return digest, nil
+	}
+
+	// the header is optional, the digest of a manifest is the hash of its content
+	res, err = r.request(http.MethodGet, manifest, registry)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if digest := res.Header.Get(contentDigestHeader); digest != "" {
+		return digest, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, io.LimitReader(res.Body, maxManifestSizeBytes)); err != nil {
+		return "", errors.Wrapf(err, "failed to read the manifest of %s", ref.Name)
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// request sends a request to the registry API, authenticating with the token requested by the registry if needed
+func (r *RegistryResolver) request(method, u string, registry *model.Registry) (*http.Response, error) {
+	res, err := r.do(method, u, registry, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		challenge := res.Header.Get(authenticateHeader)
+		res.Body.Close()
+		if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return nil, fmt.Errorf("the registry requires an authentication method that is not supported")
+		}
+
+		token, err := r.getToken(challenge, registry)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err = r.do(method, u, nil, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		if res.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("the image doesn't exist")
+		}
+
+		// the errors are shown to the users, the responses of the hosts set in the manifests are only logged
+		log.Printf("%s %s returned %d", method, u, res.StatusCode)
+		return nil, fmt.Errorf("the registry rejected the request")
+	}
+
+	return res, nil
+}
+
+func (r *RegistryResolver) do(method, u string, registry *model.Registry, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if registry != nil {
+		req.SetBasicAuth(registry.Username, registry.Password)
+	}
+
+	return r.Client.Do(req)
+}
+
+// getToken requests a token to the authorization server of a Bearer challenge, e.g.
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func (r *RegistryResolver) getToken(challenge string, registry *model.Registry) (string, error) {
+	params := map[string]string{}
+	for _, m := range challengeParameter.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+
+	// the credentials of the registry are sent to the realm
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme != "https" || realm.Host == "" {
+		return "", fmt.Errorf("the authentication realm '%s' is not a https url", params["realm"])
+	}
+
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if registry != nil {
+		req.SetBasicAuth(registry.Username, registry.Password)
+	}
+
+	res, err := r.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		log.Printf("the authentication server %s returned %d", realm.Host, res.StatusCode)
+		return "", fmt.Errorf("the authentication server rejected the request")
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxManifestSizeBytes))
+	if err != nil {
+		return "", err
+	}
+
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return "", errors.Wrap(err, "failed to decode the authentication token")
+	}
+
+	return orDefault(t.Token, t.AccessToken), nil
+}
+
+// isRegistryOf returns true if the credentials of registry are for the registry of ref. The credentials without
+// a server are for docker hub
+func isRegistryOf(registry *model.Registry, ref *model.ImageReference) bool {
+	if registry == nil || registry.Username == "" || registry.Password == "" {
+		return false
+	}
+
+	server := registry.Server
+	if i := strings.Index(server, "://"); i >= 0 {
+		server = server[i+3:]
+	}
+	server = strings.Split(server, "/")[0]
+
+	switch server {
+	case "", "index.docker.io", dockerHubAPI:
+		server = model.DockerHub
+	}
+
+	return server == ref.Registry
+}
+
+// resolveImages replaces the image of every container of service by the digest it references now, so the deployment
+// doesn't change if a tag is pushed again. The digests are stored in the activity
+func (s *Server) resolveImages(service *model.Service, registry *model.Registry, activityID string) error {
+	if s.Images == nil {
+		return nil
+	}
+
+	images := []*model.ActivityImage{}
+	resolve := func(nC string, c *model.Container) error {
+		ref, err := model.ParseImageReference(c.Image)
+		if err != nil {
+			return err
+		}
+
+		digest := ref.Digest
+		if digest == "" {
+			digest, err = s.Images.resolve(ref, registry)
+			if err != nil {
+				return errors.Wrapf(err, "failed to resolve the image '%s' of container '%s'", c.Image, nC)
+			}
+
+			s.addLog(activityID, fmt.Sprintf("resolved the image '%s' of container '%s' to %s", c.Image, nC, digest))
+		}
+
+		images = append(images, &model.ActivityImage{ActivityID: activityID, Container: nC, Image: c.Image, Digest: digest})
+		c.Image = ref.Pin(digest)
+		return nil
+	}
+
+	if err := forEachContainer(service, resolve); err != nil {
+		return err
+	}
+
+	for _, i := range images {
+		if result := s.DB.Create(i); result.Error != nil {
+			return errors.Wrapf(result.Error, "failed to save the images of activity-%s", activityID)
+		}
+	}
+
+	return nil
+}
+
+// forEachContainer calls f with the init containers of service in order and its containers sorted by name, until f
+// returns an error
+func forEachContainer(service *model.Service, f func(nC string, c *model.Container) error) error {
+	for _, c := range service.InitContainers {
+		if c == nil {
+			continue
+		}
+
+		if err := f(c.Name, &c.Container); err != nil {
+			return err
+		}
+	}
+
+	names := []string{}
+	for nC := range service.Containers {
+		names = append(names, nC)
+	}
+	sort.Strings(names)
+
+	for _, nC := range names {
+		if service.Containers[nC] == nil {
+			continue
+		}
+
+		if err := f(nC, service.Containers[nC]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getActivityImages returns the images deployed by activities grouped by activity
+func (s *Server) getActivityImages(activities []model.Activity) (map[string][]model.ActivityImage, error) {
+	result := map[string][]model.ActivityImage{}
+	if len(activities) == 0 {
+		return result, nil
+	}
+
+	ids := []string{}
+	for _, a := range activities {
+		ids = append(ids, a.ID)
+	}
+
+	var images []model.ActivityImage
+	if err := s.DB.Where("activity_id IN (?)", ids).Order("container ASC").Find(&images).Error; err != nil {
+		return nil, err
+	}
+
+	for _, i := range images {
+		result[i.ActivityID] = append(result[i.ActivityID], i)
+	}
+	return result, nil
+}
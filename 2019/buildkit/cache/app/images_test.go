@@ -0,0 +1,231 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+const testDigest = "sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"
+
+type fakeResolver struct {
+	digests map[string]string
+}
+
+func (f *fakeResolver) resolve(ref *model.ImageReference, registry *model.Registry) (string, error) {
+	digest, ok := f.digests[ref.Name+":"+ref.Tag]
+	if !ok {
+		return "", fmt.Errorf("the image doesn't exist")
+	}
+	return digest, nil
+}
+
+func TestRegistryResolver(t *testing.T) {
+	var registry *httptest.Server
+	registry = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if user, pass, _ := r.BasicAuth(); user != "okteto" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if r.URL.Query().Get("scope") != "repository:private/api:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			fmt.Fprint(w, `{"token": "t0k3n"}`)
+		case "/v2/library/public/manifests/latest":
+			w.Header().Set(contentDigestHeader, testDigest)
+		case "/v2/private/api/manifests/1.0":
+			if r.Header.Get("Authorization") != "Bearer t0k3n" {
+				w.Header().Set(authenticateHeader, fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:private/api:pull"`, registry.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if !strings.Contains(r.Header.Get("Accept"), "application/vnd.docker.distribution.manifest.v2+json") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, `{"schemaVersion": 2}`)
+			}
+		case "/v2/insecure/api/manifests/latest":
+			w.Header().Set(authenticateHeader, `Bearer realm="http://auth.example.com/token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/v2/broken/api/manifests/latest":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+
+	host := strings.TrimPrefix(registry.URL, "https://")
+	credentials := &model.Registry{Server: registry.URL, Username: "okteto", Password: "secret"}
+	tests := []struct {
+		name     string
+		image    string
+		registry *model.Registry
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:  "digest-header",
+			image: host + "/library/public",
+			want:  testDigest,
+		},
+		{
+			name:     "token-and-content-digest",
+			image:    host + "/private/api:1.0",
+			registry: credentials,
+			want:     "sha256:c5d902c53b4afcf32ad746fd9d696431650d3fbe8f7b10ca10519543fefd772c",
+		},
+		{
+			name:    "missing-credentials",
+			image:   host + "/private/api:1.0",
+			wantErr: true,
+		},
+		{
+			name:     "credentials-of-another-registry",
+			image:    host + "/private/api:1.0",
+			registry: &model.Registry{Server: "https://index.docker.io/v1/", Username: "okteto", Password: "secret"},
+			wantErr:  true,
+		},
+		{
+			name:    "missing-image",
+			image:   host + "/library/missing",
+			wantErr: true,
+		},
+		{
+			name:     "http-realm",
+			image:    host + "/insecure/api",
+			registry: credentials,
+			wantErr:  true,
+		},
+		{
+			name:    "registry-error",
+			image:   host + "/broken/api",
+			wantErr: true,
+		},
+	}
+
+	r := &RegistryResolver{Client: registry.Client()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := model.ParseImageReference(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := r.resolve(ref, tt.registry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolve() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("resolve() = %s, want %s", got, tt.want)
+			}
+
+			if err != nil && strings.Contains(err.Error(), "503") {
+				t.Errorf("the error has the response of the registry: %s", err)
+			}
+		})
+	}
+}
+
+func TestRegistryResolverOnlyReachesPublicAddresses(t *testing.T) {
+	reached := false
+	registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+	defer registry.Close()
+
+	ref, err := model.ParseImageReference(strings.TrimPrefix(registry.URL, "https://") + "/library/public")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewRegistryResolver().resolve(ref, nil); err == nil || !strings.Contains(err.Error(), "private address") || reached {
+		t.Errorf("the resolver connected to a loopback address: %v", err)
+	}
+}
+
+func Test_isRegistryOf(t *testing.T) {
+	tests := []struct {
+		server string
+		image  string
+		want   bool
+	}{
+		{server: "", image: "nginx", want: true},
+		{server: "https://index.docker.io/v1/", image: "okteto/api", want: true},
+		{server: "https://index.docker.io/v1/", image: "gcr.io/okteto/api", want: false},
+		{server: "gcr.io", image: "gcr.io/okteto/api", want: true},
+		{server: "https://registry:5000", image: "registry:5000/api", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server+"-"+tt.image, func(t *testing.T) {
+			ref, err := model.ParseImageReference(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := &model.Registry{Server: tt.server, Username: "okteto", Password: "secret"}
+			if got := isRegistryOf(r, ref); got != tt.want {
+				t.Errorf("isRegistryOf() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveImages(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	s := Server{DB: db, Images: &fakeResolver{digests: map[string]string{"nginx:alpine": testDigest, "busybox:latest": testDigest}}}
+	service := &model.Service{
+		Name: "test",
+		Containers: map[string]*model.Container{
+			"web":     &model.Container{Image: "nginx:alpine"},
+			"sidecar": &model.Container{Image: "envoy@" + testDigest},
+		},
+		InitContainers: []*model.InitContainer{
+			&model.InitContainer{Name: "init", Container: model.Container{Image: "busybox"}},
+		},
+	}
+
+	if err := s.resolveImages(service, nil, "activity-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if service.Containers["web"].Image != "nginx@"+testDigest || service.InitContainers[0].Image != "busybox@"+testDigest {
+		t.Errorf("the images weren't pinned: %s %s", service.Containers["web"].Image, service.InitContainers[0].Image)
+	}
+
+	images, err := s.getActivityImages([]model.Activity{model.Activity{Model: model.Model{ID: "activity-1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images["activity-1"]) != 3 {
+		t.Fatalf("expected 3 images, got %+v", images)
+	}
+
+	for _, i := range images["activity-1"] {
+		if i.Digest != testDigest {
+			t.Errorf("wrong digest for %s: %s", i.Container, i.Digest)
+		}
+	}
+
+	service.Containers["web"].Image = "nginx:missing"
+	if err := s.resolveImages(service, nil, "activity-2"); err == nil {
+		t.Errorf("didn't fail with a missing image")
+	}
+}
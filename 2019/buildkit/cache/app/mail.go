@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"log"
+	texttemplate "text/template"
 
 	"bitbucket.org/okteto/okteto/backend/model"
 	"github.com/pkg/errors"
@@ -64,17 +65,119 @@ The okteto team`
 <p>Thanks,</p>
 <p>The okteto team</p>
 </body>
+</html>`
+
+	failedActivityEmailTitle = "Failed to %s %s in the %s project"
+
+	failedActivityEmailBody = `Hi,
+
+Okteto failed to {{.Operation}} the service "{{.Service}}" in the "{{.Project}}" project. These are the last lines of its logs:
+
+{{.Logs}}
+
+The full logs are available at {{.LogsURL}}
+
+Thanks, 
+The okteto team
+
+To stop receiving these emails update your notification settings or unsubscribe by clicking on the link below: 
+{{.Unsubscribe}}`
+
+	failedActivityEmailBodyHTML = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<title>Failed to {{.Operation}} {{.Service}}</title> 
+	</head>
+	<body>
+<p>Okteto failed to {{.Operation}} the service <b>{{.Service}}</b> in the <b>{{.Project}}</b> project. These are the last lines of its logs:</p>
+
+<pre>{{.Logs}}</pre>
+
+<p>The full logs are available <a href="{{.LogsURL}}">here</a>.</p>
+
+<p>Thanks,</p>
+<p>The okteto team</p>
+
+<p>To stop receiving these emails update your notification settings or <a href="{{.Unsubscribe}}">unsubscribe here</a>.</p>
+</body>
+</html>`
+
+	expiringServicesEmailTitle = "Your demo services will be destroyed soon"
+
+	expiringServicesEmailBody = `Hi,
+
+Demo services are destroyed after {{.Lifetime}}. These services will be destroyed at {{.Expiration}}:
+{{range .Services}}
+- {{.Name}} in the "{{.Project}}" project{{end}}
+
+Configure a cloud provider in your projects at {{.URL}} to keep your services running.
+
+Thanks, 
+The okteto team
+
+To stop receiving these emails update your notification settings or unsubscribe by clicking on the link below: 
+{{.Unsubscribe}}`
+
+	expiringServicesEmailBodyHTML = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<title>Your demo services will be destroyed soon</title> 
+	</head>
+	<body>
+<p>Demo services are destroyed after {{.Lifetime}}. These services will be destroyed at {{.Expiration}}:</p>
+
+<ul>{{range .Services}}
+<li><b>{{.Name}}</b> in the <b>{{.Project}}</b> project</li>{{end}}
+</ul>
+
+<p><a href="{{.URL}}">Configure a cloud provider</a> in your projects to keep your services running.</p>
+
+<p>Thanks,</p>
+<p>The okteto team</p>
+
+<p>To stop receiving these emails update your notification settings or <a href="{{.Unsubscribe}}">unsubscribe here</a>.</p>
+</body>
 </html>`
 )
 
 // EmailProvider manages sending emails and the templates
 type EmailProvider struct {
-	FromEmail         string
-	projectInvite     *template.Template
-	projectInviteHTML *template.Template
-	userInvite        *template.Template
-	userInviteHTML    *template.Template
-	Sender            EmailSender
+	FromEmail            string
+	projectInvite        *template.Template
+	projectInviteHTML    *template.Template
+	userInvite           *template.Template
+	userInviteHTML       *template.Template
+	failedActivity       *texttemplate.Template
+	failedActivityHTML   *template.Template
+	expiringServices     *texttemplate.Template
+	expiringServicesHTML *template.Template
+	Sender               EmailSender
+}
+
+// failedActivityEmail is the data of the email sent when an activity fails
+type failedActivityEmail struct {
+	Project     string
+	Service     string
+	Operation   string
+	Logs        string
+	LogsURL     string
+	Unsubscribe string
+}
+
+// expiringServicesEmail is the data of the email sent before the demo services of a user are destroyed
+type expiringServicesEmail struct {
+	Services    []expiringService
+	Lifetime    string
+	Expiration  string
+	URL         string
+	Unsubscribe string
+}
+
+type expiringService struct {
+	Name    string
+	Project string
 }
 
 // EmailSender is an interface used to send in-app emails
@@ -108,6 +211,10 @@ func NewMail(fromEmail string, sender EmailSender) *EmailProvider {
 	e.userInviteHTML = template.Must(template.New("userInviteHTML").Parse(inviteEmailBodyHTML))
 	e.projectInvite = template.Must(template.New("projectInvite").Parse(projectInviteEmailBody))
 	e.projectInviteHTML = template.Must(template.New("projectInviteHTML").Parse(projectInviteEmailBodyHTML))
+	e.failedActivity = texttemplate.Must(texttemplate.New("failedActivity").Parse(failedActivityEmailBody))
+	e.failedActivityHTML = template.Must(template.New("failedActivityHTML").Parse(failedActivityEmailBodyHTML))
+	e.expiringServices = texttemplate.Must(texttemplate.New("expiringServices").Parse(expiringServicesEmailBody))
+	e.expiringServicesHTML = template.Must(template.New("expiringServicesHTML").Parse(expiringServicesEmailBodyHTML))
 
 	return &e
 }
@@ -187,6 +294,45 @@ func (e *EmailProvider) sendProjectInviteEmail(email string, projectName string,
 	return e.send(e.FromEmail, title, buf.String(), htmlBuf.String(), email)
 }
 
+// sendFailedActivityEmail sends the failed activity email template to the user
+func (e *EmailProvider) sendFailedActivityEmail(email string, data *failedActivityEmail) error {
+	if email == "" {
+		return errors.New(string(model.InvalidEmail))
+	}
+
+	buf := new(bytes.Buffer)
+	if err := e.failedActivity.Execute(buf, data); err != nil {
+		return err
+	}
+
+	htmlBuf := new(bytes.Buffer)
+	if err := e.failedActivityHTML.Execute(htmlBuf, data); err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf(failedActivityEmailTitle, data.Operation, data.Service, data.Project)
+	return e.send(e.FromEmail, title, buf.String(), htmlBuf.String(), email)
+}
+
+// sendExpiringServicesEmail sends the expiring services email template to the user
+func (e *EmailProvider) sendExpiringServicesEmail(email string, data *expiringServicesEmail) error {
+	if email == "" {
+		return errors.New(string(model.InvalidEmail))
+	}
+
+	buf := new(bytes.Buffer)
+	if err := e.expiringServices.Execute(buf, data); err != nil {
+		return err
+	}
+
+	htmlBuf := new(bytes.Buffer)
+	if err := e.expiringServicesHTML.Execute(htmlBuf, data); err != nil {
+		return err
+	}
+
+	return e.send(e.FromEmail, expiringServicesEmailTitle, buf.String(), htmlBuf.String(), email)
+}
+
 // Send sends an email using mailgun
 func (m *Mailgun) send(from string, title string, body string, bodyHTML string, to ...string) error {
 	message := m.Client.NewMessage(from, title, body, to...)
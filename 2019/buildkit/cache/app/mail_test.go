@@ -39,6 +39,14 @@ func TestNewMail(t *testing.T) {
 			if m.userInviteHTML == nil {
 				t.Errorf("userInviteHTML template not created")
 			}
+
+			if m.failedActivity == nil || m.failedActivityHTML == nil {
+				t.Errorf("failedActivity templates not created")
+			}
+
+			if m.expiringServices == nil || m.expiringServicesHTML == nil {
+				t.Errorf("expiringServices templates not created")
+			}
 		})
 	}
 }
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPSender sends emails through an SMTP server, implements the EmailSender interface
+type SMTPSender struct {
+	// Address is the host:port of the server
+	Address  string
+	Username string
+	Password string
+
+	// StartTLS requires the server to support STARTTLS, the credentials are never sent in plain text
+	StartTLS bool
+}
+
+// FileMailSender writes the emails to a maildir instead of sending them, implements the EmailSender interface
+type FileMailSender struct {
+	Directory string
+}
+
+// NewSMTPSender returns a sender for the SMTP server on address. The server must support AUTH if username is set
+func NewSMTPSender(address, username, password string, startTLS bool) EmailSender {
+	return &SMTPSender{Address: address, Username: username, Password: password, StartTLS: startTLS}
+}
+
+// NewFileMailSender returns a sender that writes the emails to the new folder of the maildir in directory. The
+// maildir is created if it doesn't exist
+func NewFileMailSender(directory string) (EmailSender, error) {
+	for _, d := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(directory, d), 0700); err != nil {
+			return nil, errors.Wrapf(err, "failed to create the maildir %s", directory)
+		}
+	}
+
+	return &FileMailSender{Directory: directory}, nil
+}
+
+func (m *SMTPSender) send(from string, title string, body string, bodyHTML string, to ...string) error {
+	message, err := buildMailMessage(from, title, body, bodyHTML, to...)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(m.Address)
+	if err != nil {
+		return errors.Wrapf(err, "invalid smtp address %s", m.Address)
+	}
+
+	conn, err := net.DialTimeout("tcp", m.Address, smtpTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to %s", m.Address)
+	}
+
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrapf(err, "failed to start the smtp session with %s", m.Address)
+	}
+	defer c.Close()
+
+	if m.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s doesn't support STARTTLS", m.Address)
+		}
+
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return errors.Wrap(err, "failed to start tls")
+		}
+	}
+
+	if m.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("%s doesn't support AUTH", m.Address)
+		}
+
+		// PlainAuth refuses to send the credentials without tls unless the server is local
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, host)); err != nil {
+			return errors.Wrap(err, "failed to authenticate")
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return errors.Wrapf(err, "the sender %s was rejected", from)
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return errors.Wrapf(err, "the recipient %s was rejected", rcpt)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "failed to start the message")
+	}
+
+	if _, err := w.Write(message); err != nil {
+		return errors.Wrap(err, "failed to write the message")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "the message was rejected")
+	}
+
+	return c.Quit()
+}
+
+func (f *FileMailSender) send(from string, title string, body string, bodyHTML string, to ...string) error {
+	message, err := buildMailMessage(from, title, body, bodyHTML, to...)
+	if err != nil {
+		return err
+	}
+
+	// the message is moved to new once it's complete, as the maildir format requires
+	name := fmt.Sprintf("%d.%s.okteto", time.Now().UnixNano(), model.GenerateRandomString(8))
+	tmp := filepath.Join(f.Directory, "tmp", name)
+	if err := ioutil.WriteFile(tmp, message, 0600); err != nil {
+		return errors.Wrap(err, "failed to write the email")
+	}
+
+	path := filepath.Join(f.Directory, "new", name)
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrap(err, "failed to deliver the email")
+	}
+
+	log.Printf("email to=%s with title=%s written to %s", strings.Join(to, ","), title, path)
+	return nil
+}
+
+// buildMailMessage returns a multipart/alternative message with the text and the html bodies
+func buildMailMessage(from string, title string, body string, bodyHTML string, to ...string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "Message-ID: <%s@okteto>\r\n", model.GenerateRandomString(20))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", body},
+		{"text/html; charset=utf-8", bodyHTML},
+	}
+
+	for _, p := range parts {
+		if p.content == "" {
+			continue
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", p.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the email")
+		}
+
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(p.content)); err != nil {
+			return nil, errors.Wrap(err, "failed to encode the email")
+		}
+
+		if err := qp.Close(); err != nil {
+			return nil, errors.Wrap(err, "failed to encode the email")
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to create the email")
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"bufio"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts a single session and records the commands and the message
+type fakeSMTPServer struct {
+	listener   net.Listener
+	extensions []string
+	commands   []string
+	message    string
+	done       chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T, extensions ...string) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeSMTPServer{listener: l, extensions: extensions, done: make(chan struct{})}
+	go f.serve()
+	return f
+}
+
+func (f *fakeSMTPServer) serve() {
+	defer close(f.done)
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		f.commands = append(f.commands, command)
+		switch command {
+		case "EHLO":
+			reply("250-localhost")
+			for _, e := range f.extensions {
+				reply("250-" + e)
+			}
+			reply("250 8BITMIME")
+		case "AUTH":
+			reply("235 2.7.0 Authentication successful")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			message := []string{}
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+
+				message = append(message, l)
+			}
+
+			f.message = strings.Join(message, "")
+			reply("250 2.0.0 Ok")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("250 2.0.0 Ok")
+		}
+	}
+}
+
+func (f *fakeSMTPServer) wait() {
+	f.listener.Close()
+	<-f.done
+}
+
+func TestSMTPSender(t *testing.T) {
+	server := newFakeSMTPServer(t, "AUTH PLAIN")
+	sender := NewSMTPSender(server.listener.Addr().String(), "user", "password", false)
+	if err := sender.send("hello@okteto.com", "Hi", "body", "<p>body</p>", "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.wait()
+	expected := "EHLO,AUTH,MAIL,RCPT,DATA,QUIT"
+	if got := strings.Join(server.commands, ","); got != expected {
+		t.Errorf("expected the commands %s, got %s", expected, got)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(server.message))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Header.Get("To") != "user@example.com" || m.Header.Get("Subject") != "Hi" {
+		t.Errorf("wrong headers: %+v", m.Header)
+	}
+}
+
+func TestSMTPSenderRequiresStartTLS(t *testing.T) {
+	server := newFakeSMTPServer(t, "AUTH PLAIN")
+	sender := NewSMTPSender(server.listener.Addr().String(), "user", "password", true)
+	err := sender.send("hello@okteto.com", "Hi", "body", "<p>body</p>", "user@example.com")
+	server.wait()
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("the email was sent without STARTTLS: %v", err)
+	}
+
+	for _, c := range server.commands {
+		if c == "AUTH" || c == "MAIL" {
+			t.Errorf("the session continued without STARTTLS: %+v", server.commands)
+		}
+	}
+}
+
+func TestFileMailSender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maildir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sender, err := NewFileMailSender(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewMail("hello@okteto.com", sender)
+	if err := e.sendProjectInviteEmail("user@example.com", "project-ñ", "https://okteto.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "new", "*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a single email in the maildir, got %+v %s", files, err)
+	}
+
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "tmp", "*")); len(tmp) != 0 {
+		t.Errorf("the email wasn't moved out of tmp: %+v", tmp)
+	}
+
+	content, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(string(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil || subject != "You've been invited to collaborate in the project-ñ project" {
+		t.Errorf("wrong subject: %s %s", subject, err)
+	}
+
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := []string{}
+	r := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+
+		body, _ := ioutil.ReadAll(p)
+		if !strings.Contains(string(body), "project-ñ") {
+			t.Errorf("the %s part doesn't include the project: %s", p.Header.Get("Content-Type"), body)
+		}
+
+		types = append(types, p.Header.Get("Content-Type"))
+	}
+
+	if strings.Join(types, ",") != "text/plain; charset=utf-8,text/html; charset=utf-8" {
+		t.Errorf("wrong parts: %+v", types)
+	}
+}
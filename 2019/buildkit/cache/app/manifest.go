@@ -0,0 +1,134 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+// ValidateManifest runs the checks of CreateService and UpdateManifest on v without storing anything.
+// The project is optional, without it the project variables, policies and secrets are not checked
+func (s *Server) ValidateManifest(v *model.ManifestValidation, user *model.User) (*model.ManifestValidationResult, *model.AppError) {
+	if v.Manifest == "" {
+		return nil, &model.AppError{Status: http.StatusBadRequest, Code: model.MissingManifest}
+	}
+
+	project := &model.Project{}
+	if v.Project != "" {
+		p, appErr := s.GetProject(v.Project, user.ID)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		project = p
+	}
+
+	return s.validateManifest(v, project), nil
+}
+
+func (s *Server) validateManifest(v *model.ManifestValidation, project *model.Project) *model.ManifestValidationResult {
+	result := model.NewManifestValidationResult()
+
+	d := &model.Service{
+		ProjectID: project.ID,
+		Manifest:  encodeManifest(v.Manifest),
+		Overlay:   encodeManifest(v.Overlay),
+		Branch:    v.Branch,
+		Commit:    v.Commit,
+	}
+
+	m, appErr := buildService(d, project, true, project.LoadedSettings)
+	if appErr != nil {
+		result.AddError(appErr)
+		return result
+	}
+
+	if project.LoadedSettings == nil {
+		result.AddErrors(m.ValidateIngressHostnames(nil))
+		return result
+	}
+
+	result.Warnings = append(result.Warnings, m.ValidateSecretReferences(project.LoadedSettings.Secrets)...)
+
+	e := s.buildEnvironment(project)
+	e.Provider.LoadDefaultCluster()
+	result.AddErrors(m.ValidateLoadBalancerPorts(e))
+	hostnameErrors := m.ValidateIngressHostnames(e)
+	result.AddErrors(hostnameErrors)
+	if len(hostnameErrors) == 0 && e.Provider.IsIngress() {
+		result.Warnings = append(result.Warnings, s.getIngressHostnameConflicts(m, project, e)...)
+	}
+
+	return result
+}
+
+// validateLoadBalancerPorts returns an error if m gets a load balancer in the cluster of project and its ports mix
+// protocols. Without project settings the cluster is unknown and nothing is checked
+func (s *Server) validateLoadBalancerPorts(m *model.Service, project *model.Project) *model.AppError {
+	if project.LoadedSettings == nil || project.LoadedSettings.Provider == nil {
+		return nil
+	}
+
+	e := s.buildEnvironment(project)
+	e.Provider.LoadDefaultCluster()
+	if errs := m.ValidateLoadBalancerPorts(e); len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// getIngressHostnameConflicts returns a warning for every ingress hostname of m that is used by another service of the project
+func (s *Server) getIngressHostnameConflicts(m *model.Service, project *model.Project, e *model.Environment) []*model.AppError {
+	warnings := []*model.AppError{}
+	services, appErr := s.GetServices(project.ID, false)
+	if appErr != nil {
+		logger.Error(errors.Wrapf(appErr, "failed to get the services of project-%s", project.ID))
+		return warnings
+	}
+
+	used := map[string]string{}
+	for i := range services {
+		if services[i].Name == m.Name || services[i].Status == model.DestroyedService {
+			continue
+		}
+
+		other, appErr := buildService(&services[i], project, false, nil)
+		if appErr != nil {
+			continue
+		}
+
+		for _, h := range other.GetIngressHostnames(e) {
+			used[h] = other.Name
+		}
+	}
+
+	for _, h := range m.GetIngressHostnames(e) {
+		if name, ok := used[h]; ok {
+			warnings = append(warnings, &model.AppError{
+				Status:  http.StatusBadRequest,
+				Code:    model.IngressHostnameConflict,
+				Data:    map[string]string{"hostname": h, "service": name},
+				Message: fmt.Sprintf("'%s' is already used by service '%s'", h, name)})
+		}
+	}
+
+	return warnings
+}
+
+// encodeManifest returns m in base64, m can already be encoded or be a raw yaml document
+func encodeManifest(m string) string {
+	if m == "" {
+		return ""
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(m); err == nil {
+		return m
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(m))
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+func TestValidateManifest(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	p := &model.Project{
+		Model:   model.Model{ID: "1-2-3-4"},
+		Name:    "testproject",
+		DNSName: "testproject",
+		LoadedSettings: &model.ProjectSettings{
+			Provider:  &model.Provider{Type: "k8", Ingress: &model.IngressController{Domain: "example.com"}},
+			Secrets:   []*model.EnvVar{&model.EnvVar{Name: "DATABASE_PASSWORD", Value: "secret"}},
+			Variables: map[string]string{"REPLICAS": "2"},
+		},
+	}
+
+	lb := &model.Project{
+		Model:          model.Model{ID: "5-6-7-8"},
+		Name:           "lbproject",
+		DNSName:        "lbproject",
+		LoadedSettings: &model.ProjectSettings{Provider: &model.Provider{Type: "k8"}},
+	}
+
+	existing := &model.Service{
+		ProjectID: p.ID,
+		Name:      "web",
+		Status:    model.DeployedService,
+		Manifest: base64.StdEncoding.EncodeToString([]byte(`name: web
+containers:
+  web:
+    image: nginx
+    ingress:
+      - host: www
+        port: 80`)),
+	}
+	db.Create(existing)
+
+	tests := []struct {
+		name     string
+		manifest string
+		project  *model.Project
+		errors   []model.AppErrorCode
+		warnings []model.AppErrorCode
+	}{
+		{
+			name: "valid",
+			manifest: `name: api
+replicas: ${REPLICAS}
+containers:
+  api:
+    image: okteto/api
+    environment:
+      - DATABASE_PASSWORD=$DATABASE_PASSWORD
+    ingress:
+      - host: api
+        port: 8080`,
+			project: p,
+		},
+		{
+			name:     "encoded",
+			manifest: base64.StdEncoding.EncodeToString([]byte("name: api\ncontainers:\n  api:\n    image: okteto/api")),
+			project:  &model.Project{},
+		},
+		{
+			name: "several-errors",
+			manifest: `name: api_v2
+replicas: 0
+containers:
+  api:
+    image: okteto/api`,
+			project: &model.Project{},
+			errors:  []model.AppErrorCode{model.InvalidName, model.InvalidReplicaCount},
+		},
+		{
+			name: "unknown-field",
+			manifest: `name: api
+replica: 2
+containers:
+  api:
+    image: okteto/api`,
+			project: &model.Project{},
+			errors:  []model.AppErrorCode{model.UnknownField},
+		},
+		{
+			name: "undefined-variable",
+			manifest: `name: api
+replicas: ${REPLICAS}
+containers:
+  api:
+    image: okteto/api`,
+			project: &model.Project{},
+			errors:  []model.AppErrorCode{model.UndefinedVariable},
+		},
+		{
+			name: "warnings",
+			manifest: `name: api
+containers:
+  api:
+    image: okteto/api
+    environment:
+      - API_TOKEN=$TOKEN
+    ingress:
+      - host: www
+        port: 8080`,
+			project:  p,
+			warnings: []model.AppErrorCode{model.UndefinedSecret, model.IngressHostnameConflict},
+		},
+		{
+			name: "invalid-hostname",
+			manifest: `name: api
+containers:
+  api:
+    image: okteto/api
+    ingress:
+      - host: my_api
+        port: 8080`,
+			project: p,
+			errors:  []model.AppErrorCode{model.InvalidIngressHostname},
+		},
+		{
+			name: "mixed-protocols-with-ingress",
+			manifest: `name: dns
+containers:
+  dns:
+    image: coredns/coredns
+    ports:
+      - 53/udp
+      - 53/tcp`,
+			project: p,
+		},
+		{
+			name: "mixed-protocols-with-load-balancer",
+			manifest: `name: dns
+containers:
+  dns:
+    image: coredns/coredns
+    ports:
+      - 53/udp
+      - 53/tcp`,
+			project: lb,
+			errors:  []model.AppErrorCode{model.InvalidPort},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := s.validateManifest(&model.ManifestValidation{Manifest: tt.manifest}, tt.project)
+			if result.Valid != (len(tt.errors) == 0) {
+				t.Errorf("expected valid to be %t, got %+v", len(tt.errors) == 0, result.Errors)
+			}
+
+			assertCodes(t, "errors", tt.errors, result.Errors)
+			assertCodes(t, "warnings", tt.warnings, result.Warnings)
+		})
+	}
+
+	var count int
+	db.Model(&model.Service{}).Count(&count)
+	if count != 1 {
+		t.Errorf("validating manifests created services")
+	}
+}
+
+func assertCodes(t *testing.T, name string, expected []model.AppErrorCode, got []*model.AppError) {
+	if len(expected) != len(got) {
+		t.Fatalf("expected %s %v, got %+v", name, expected, got)
+	}
+
+	for i := range expected {
+		if got[i].Code != expected[i] {
+			t.Errorf("expected %s %v, got %+v", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/config"
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	botEmail = "bot@okteto.com"
+
+	// failedActivityLogLines is the number of log lines included in the failed activity emails
+	failedActivityLogLines = 20
+)
+
+// GetEmailPreferences returns the email preferences of u. They are created with every email enabled the first time
+func (s *Server) GetEmailPreferences(u *model.User) (*model.EmailPreferences, error) {
+	prefs := &model.EmailPreferences{}
+	r := s.DB.Where(model.EmailPreferences{UserID: u.ID}).First(prefs)
+	if r.Error == nil {
+		return prefs, nil
+	}
+
+	if !r.RecordNotFound() {
+		return nil, errors.Wrapf(r.Error, "failed to get the email preferences of user-%s", u.ID)
+	}
+
+	prefs = model.NewEmailPreferences(u.ID)
+	if err := s.DB.Create(prefs).Error; err != nil {
+		// the preferences might have been created by a concurrent email
+		existing := &model.EmailPreferences{}
+		if s.DB.Where(model.EmailPreferences{UserID: u.ID}).First(existing).Error == nil {
+			return existing, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to create the email preferences of user-%s", u.ID)
+	}
+
+	return prefs, nil
+}
+
+// UpdateEmailPreferences saves the email preferences of u
+func (s *Server) UpdateEmailPreferences(u *model.User, update *model.EmailPreferences) (*model.EmailPreferences, error) {
+	prefs, err := s.GetEmailPreferences(u)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{
+		"failed_activities": update.FailedActivities,
+		"expiring_services": update.ExpiringServices,
+		"unsubscribed":      update.Unsubscribed,
+	}
+
+	if err := s.DB.Model(prefs).Updates(values).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to update the email preferences of user-%s", u.ID)
+	}
+
+	return prefs, nil
+}
+
+// Unsubscribe disables every email of the user with the unsubscribe key included in the emails
+func (s *Server) Unsubscribe(key string) error {
+	prefs, err := s.getEmailPreferencesByUnsubscribeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.DB.Model(prefs).Update("unsubscribed", true).Error; err != nil {
+		return errors.Wrapf(err, "failed to unsubscribe user-%s", prefs.UserID)
+	}
+
+	log.Printf("user-%s unsubscribed from every email", prefs.UserID)
+	return nil
+}
+
+// CheckUnsubscribeKey returns a not found error if key isn't the unsubscribe key of a user. It doesn't change the
+// email preferences
+func (s *Server) CheckUnsubscribeKey(key string) error {
+	_, err := s.getEmailPreferencesByUnsubscribeKey(key)
+	return err
+}
+
+func (s *Server) getEmailPreferencesByUnsubscribeKey(key string) (*model.EmailPreferences, error) {
+	if key == "" {
+		return nil, errors.Wrap(model.ErrNotFound, "empty unsubscribe key")
+	}
+
+	prefs := &model.EmailPreferences{}
+	r := s.DB.Where(model.EmailPreferences{UnsubscribeKey: key}).First(prefs)
+	if r.Error != nil {
+		if r.RecordNotFound() {
+			return nil, errors.Wrap(model.ErrNotFound, "unknown unsubscribe key")
+		}
+
+		return nil, errors.Wrap(r.Error, "failed to get the email preferences")
+	}
+
+	return prefs, nil
+}
+
+// isEmailEnabled returns true if the user with the given email wants to receive the emails of type n. Unknown users
+// haven't unsubscribed yet
+func (s *Server) isEmailEnabled(email string, n model.EmailNotification) bool {
+	u, err := s.getUser(model.User{Email: email})
+	if err != nil {
+		if err != model.ErrNotFound {
+			logger.Error(errors.Wrapf(err, "failed to get the user of %s", email))
+		}
+
+		return true
+	}
+
+	prefs, err := s.GetEmailPreferences(u)
+	if err != nil {
+		logger.Error(err)
+		return true
+	}
+
+	return prefs.IsEnabled(n)
+}
+
+func getUnsubscribeURL(prefs *model.EmailPreferences) string {
+	return fmt.Sprintf("%s/unsubscribe/%s", config.GetAPIURL(), prefs.UnsubscribeKey)
+}
+
+// notifyFailedActivity emails the last lines of the logs of a failed activity to the user that started it. The
+// activities started by the bot or by github events are not emailed
+func (s *Server) notifyFailedActivity(p *model.Project, service *model.Service, activityID string, activityType model.ActivityType, user *model.User) {
+	if s.Email == nil || user == nil || user.Email == botEmail || user.ID == githubActorID {
+		return
+	}
+
+	prefs, err := s.GetEmailPreferences(user)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	if !prefs.IsEnabled(model.FailedActivityEmail) {
+		return
+	}
+
+	logs, err := s.getActivityLogs(activityID)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get the logs of activity-%s", activityID))
+		return
+	}
+
+	data := &failedActivityEmail{
+		Project:     p.Name,
+		Service:     service.Name,
+		Operation:   getActivityOperation(activityType),
+		Logs:        getLastLogLines(logs, failedActivityLogLines),
+		LogsURL:     getActivityLogsURL(service.ProjectID, service.ID, activityID),
+		Unsubscribe: getUnsubscribeURL(prefs),
+	}
+
+	if err := s.Email.sendFailedActivityEmail(user.Email, data); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to email the failure of activity-%s to user-%s", activityID, user.ID))
+	}
+}
+
+// notifyExpiringServices emails the creators of the demo services that are going to be destroyed before the next
+// cleanup. Every service is only notified once
+func (s *Server) notifyExpiringServices() {
+	if s.Email == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	var services []model.Service
+	r := s.DB.Where(
+		"status in (?) AND created_at < ? AND created_at >= ? AND is_demo = ? AND expiration_notified = ?",
+		expirableStatuses, now.Add(-demoServiceLifetime+demoExpirationNotice), now.Add(-demoServiceLifetime), true, false).
+		Order("created_at").Find(&services)
+	if r.Error != nil {
+		logger.Error(errors.Wrap(r.Error, "failed to get the expiring demo services"))
+		return
+	}
+
+	users := []string{}
+	byUser := map[string][]model.Service{}
+	for _, svc := range services {
+		if _, ok := byUser[svc.CreatedBy]; !ok {
+			users = append(users, svc.CreatedBy)
+		}
+
+		byUser[svc.CreatedBy] = append(byUser[svc.CreatedBy], svc)
+	}
+
+	for _, userID := range users {
+		s.notifyExpiringUserServices(userID, byUser[userID])
+
+		ids := []string{}
+		for _, svc := range byUser[userID] {
+			ids = append(ids, svc.ID)
+		}
+
+		if err := s.DB.Model(&model.Service{}).Where("id in (?)", ids).Update("expiration_notified", true).Error; err != nil {
+			logger.Error(errors.Wrapf(err, "failed to mark the expiring services of user-%s as notified", userID))
+		}
+	}
+}
+
+// notifyExpiringUserServices sends a single email with all the expiring services of a user, sorted by creation
+func (s *Server) notifyExpiringUserServices(userID string, services []model.Service) {
+	if userID == "" {
+		return
+	}
+
+	u, err := s.getUser(model.User{Model: model.Model{ID: userID}})
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get the creator of the expiring services, user-%s", userID))
+		return
+	}
+
+	prefs, err := s.GetEmailPreferences(u)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	if !prefs.IsEnabled(model.ExpiringServicesEmail) {
+		return
+	}
+
+	projects := map[string]string{}
+	data := &expiringServicesEmail{
+		Lifetime:    fmt.Sprintf("%d minutes", int(demoServiceLifetime.Minutes())),
+		Expiration:  services[0].CreatedAt.Add(demoServiceLifetime).UTC().Format("15:04 MST"),
+		URL:         config.GetBaseURL(),
+		Unsubscribe: getUnsubscribeURL(prefs),
+	}
+
+	for _, svc := range services {
+		if _, ok := projects[svc.ProjectID]; !ok {
+			p := model.Project{}
+			if err := s.DB.Where("id = ?", svc.ProjectID).First(&p).Error; err != nil {
+				logger.Error(errors.Wrapf(err, "failed to get project-%s of expiring service-%s", svc.ProjectID, svc.ID))
+			}
+
+			projects[svc.ProjectID] = p.Name
+		}
+
+		data.Services = append(data.Services, expiringService{Name: svc.Name, Project: projects[svc.ProjectID]})
+	}
+
+	if err := s.Email.sendExpiringServicesEmail(u.Email, data); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to email the expiring services of user-%s", userID))
+	}
+}
+
+// getActivityOperation returns the verb used in the emails for an activity type
+func getActivityOperation(t model.ActivityType) string {
+	switch t {
+	case model.Deployed:
+		return "deploy"
+	case model.DevDeployed:
+		return "activate dev mode in"
+	case model.Destroyed:
+		return "destroy"
+	default:
+		return string(t)
+	}
+}
+
+// getLastLogLines returns the last n lines of the logs of an activity
+func getLastLogLines(logs []model.ActivityLog, n int) string {
+	entries := []string{}
+	for _, l := range logs {
+		entries = append(entries, strings.TrimRight(l.Log, "\n"))
+	}
+
+	lines := strings.Split(strings.Join(entries, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
+}
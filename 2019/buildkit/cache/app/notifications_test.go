@@ -0,0 +1,194 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+type sentEmail struct {
+	to    string
+	title string
+	body  string
+}
+
+// recordingEmailSender keeps the emails sent to check their content
+type recordingEmailSender struct {
+	sent []sentEmail
+}
+
+func (r *recordingEmailSender) send(from, title, body, bodyHTML string, to ...string) error {
+	r.sent = append(r.sent, sentEmail{to: to[0], title: title, body: body})
+	return nil
+}
+
+func TestUnsubscribe(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	e := &recordingEmailSender{}
+	s := Server{Email: NewMail("hello@okteto.com", e), DB: db}
+
+	authUser, _ := s.createUser("first@example.com", true, "")
+	projectID, appErr := s.CreateProject(&model.Project{Name: "project1"}, authUser)
+	if appErr != nil {
+		t.Fatalf("%+v", appErr)
+	}
+
+	if appErr := s.InviteUser(authUser, "invited@example.com", projectID, model.ProjectRoleUser); appErr != nil {
+		t.Fatalf("user wasn't invited: %+v", appErr)
+	}
+
+	invited, appErr := s.GetUser("invited@example.com")
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	prefs, err := s.GetEmailPreferences(invited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.sent) != 1 || !strings.Contains(e.sent[0].body, "/unsubscribe/"+prefs.UnsubscribeKey) {
+		t.Fatalf("the invite doesn't include the stored unsubscribe key: %+v", e.sent)
+	}
+
+	if err := s.Unsubscribe("wrong"); err == nil {
+		t.Errorf("unsubscribed with a wrong key")
+	}
+
+	if err := s.Unsubscribe(prefs.UnsubscribeKey); err != nil {
+		t.Fatal(err)
+	}
+
+	other, appErr := s.CreateProject(&model.Project{Name: "project2"}, authUser)
+	if appErr != nil {
+		t.Fatalf("%+v", appErr)
+	}
+
+	if appErr := s.InviteUser(authUser, "invited@example.com", other, model.ProjectRoleUser); appErr != nil {
+		t.Fatalf("user wasn't invited: %+v", appErr)
+	}
+
+	if len(e.sent) != 1 {
+		t.Errorf("an unsubscribed user received an invite: %+v", e.sent)
+	}
+}
+
+func TestNotifyFailedActivity(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	e := &recordingEmailSender{}
+	s := Server{Email: NewMail("hello@okteto.com", e), DB: db}
+
+	u, _ := s.createUser("user@example.com", true, "")
+	bot, _ := s.createUser(botEmail, true, "")
+	p := &model.Project{Name: "testproject"}
+	svc := &model.Service{Model: model.Model{ID: "service"}, ProjectID: "project", Name: "api"}
+	for i := 0; i < 25; i++ {
+		s.addLog("activity", fmt.Sprintf("line %d\n", i))
+		time.Sleep(time.Millisecond)
+	}
+
+	s.notifyFailedActivity(p, svc, "activity", model.Deployed, u)
+	if len(e.sent) != 1 {
+		t.Fatalf("expected a single email, got %+v", e.sent)
+	}
+
+	if e.sent[0].to != u.Email || e.sent[0].title != "Failed to deploy api in the testproject project" {
+		t.Errorf("wrong email: %+v", e.sent[0])
+	}
+
+	if !strings.Contains(e.sent[0].body, "line 24") || strings.Contains(e.sent[0].body, "line 4\n") {
+		t.Errorf("the email doesn't include the last log lines: %s", e.sent[0].body)
+	}
+
+	if !strings.Contains(e.sent[0].body, "/projects/project/services/service/activities/activity/logs") {
+		t.Errorf("the email doesn't link to the logs: %s", e.sent[0].body)
+	}
+
+	s.notifyFailedActivity(p, svc, "activity", model.Deployed, bot)
+	if len(e.sent) != 1 {
+		t.Errorf("the bot received an email")
+	}
+
+	s.notifyFailedActivity(p, svc, "activity", model.Deployed, githubUser())
+	if len(e.sent) != 1 {
+		t.Errorf("the github actor received an email")
+	}
+
+	if _, err := s.UpdateEmailPreferences(u, &model.EmailPreferences{ExpiringServices: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.notifyFailedActivity(p, svc, "activity", model.Deployed, u)
+	if len(e.sent) != 1 {
+		t.Errorf("the email was sent after disabling it")
+	}
+}
+
+func TestNotifyExpiringServices(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	e := &recordingEmailSender{}
+	s := Server{Email: NewMail("hello@okteto.com", e), DB: db}
+
+	u, _ := s.createUser("user@example.com", true, "")
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ages := map[string]time.Duration{"expiring": 45 * time.Minute, "recent": 10 * time.Minute, "expired": 100 * time.Minute}
+	for name, age := range ages {
+		svc := &model.Service{Name: name, ProjectID: p.ID, CreatedBy: u.ID, IsDemo: true, Status: model.DeployedService}
+		if err := db.Create(svc).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Model(svc).UpdateColumn("created_at", time.Now().Add(-age).UTC()).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.notifyExpiringServices()
+	if len(e.sent) != 1 {
+		t.Fatalf("expected a single email, got %+v", e.sent)
+	}
+
+	body := e.sent[0].body
+	if !strings.Contains(body, "- expiring in the \"testproject\" project") || strings.Contains(body, "recent") || strings.Contains(body, "- expired") {
+		t.Errorf("wrong services in the email: %s", body)
+	}
+
+	s.notifyExpiringServices()
+	if len(e.sent) != 1 {
+		t.Errorf("the services were notified twice")
+	}
+}
+
+func Test_getLastLogLines(t *testing.T) {
+	var tests = []struct {
+		name     string
+		logs     []model.ActivityLog
+		expected string
+	}{
+		{name: "empty", logs: nil, expected: ""},
+		{name: "short", logs: []model.ActivityLog{{Log: "a\n"}, {Log: "b"}}, expected: "a\nb"},
+		{name: "multiline", logs: []model.ActivityLog{{Log: "a\nb\nc\n"}, {Log: "d\ne"}}, expected: "c\nd\ne"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getLastLogLines(tt.logs, 3); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
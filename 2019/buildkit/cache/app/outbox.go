@@ -0,0 +1,194 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// outbox is a table of deliveries that are processed in the background by every replica. The deliveries are saved
+// before they are processed, so they aren't lost on restarts, and the failed attempts are retried with an exponential
+// backoff until maxAttempts. The github deliveries and the deliveries of the project webhooks are outboxes
+type outbox struct {
+	// name identifies the deliveries in the logs
+	name string
+
+	// record returns an empty delivery, it's the model of the table
+	record func() interface{}
+
+	// the statuses of the deliveries in the table
+	pending    string
+	processing string
+	processed  string
+	failed     string
+
+	// key is the column of the deliveries that are processed one at a time, e.g. the deliveries of the same webhook.
+	// The deliveries with an empty key are not restricted
+	key string
+
+	// ordered blocks the deliveries of a key until the older ones are processed or failed, including the ones waiting
+	// for a retry. Otherwise a delivery only waits for the one of its key that is processing
+	ordered bool
+
+	// workers is the number of deliveries processed at the same time
+	workers int
+
+	maxAttempts int
+	backoff     func(attempts int) time.Duration
+
+	// stuckPeriod is the time after which a delivery that is still processing is considered lost, e.g. if the server
+	// was restarted while processing it
+	stuckPeriod time.Duration
+
+	// retention is the time the processed and failed deliveries are kept
+	retention time.Duration
+
+	// pollInterval is the interval to look for deliveries to retry, or queued by other replicas
+	pollInterval time.Duration
+
+	// events wakes up the worker when a delivery is queued
+	events chan struct{}
+}
+
+// wakeUp notifies the worker without blocking, a notification is already pending if the channel is full
+func (o *outbox) wakeUp() {
+	select {
+	case o.events <- struct{}{}:
+	default:
+	}
+}
+
+// run processes the deliveries as they are queued, up to o.workers at the same time
+func (o *outbox) run(db *gorm.DB, process func(id string)) {
+	workers := make(chan struct{}, o.workers)
+	for {
+		for o.dispatch(db, workers, process) {
+		}
+
+		select {
+		case <-o.events:
+		case <-time.After(o.pollInterval):
+		}
+	}
+}
+
+// dispatch waits for a free worker and processes the next delivery with it. It returns false if there wasn't any
+// delivery to process
+func (o *outbox) dispatch(db *gorm.DB, workers chan struct{}, process func(id string)) bool {
+	workers <- struct{}{}
+	id, err := o.claim(db)
+	if err != nil || id == "" {
+		<-workers
+		if err != nil {
+			logger.Error(err)
+		}
+
+		return false
+	}
+
+	go func() {
+		defer func() { <-workers }()
+		process(id)
+	}()
+
+	return true
+}
+
+// claim marks the oldest pending delivery as processing and returns its ID, it's empty if there isn't any delivery to
+// process. The update only succeeds in one of the replicas, the others move on to the next delivery
+func (o *outbox) claim(db *gorm.DB) (string, error) {
+	query := o.unblocked(db.Model(o.record()).Where("status = ? AND next_attempt_at <= ?", o.pending, time.Now().UTC()))
+	var candidates []string
+	if err := query.Order("created_at").Limit(10).Pluck("id", &candidates).Error; err != nil {
+		return "", errors.Wrapf(err, "failed to get the pending %s deliveries", o.name)
+	}
+
+	for _, id := range candidates {
+		// the key is checked again, another replica could have claimed a delivery of the same key
+		r := o.unblocked(db.Model(o.record()).Where("id = ? AND status = ?", id, o.pending)).
+			Updates(map[string]interface{}{"status": o.processing, "attempts": gorm.Expr("attempts + 1")})
+		if r.Error != nil {
+			return "", errors.Wrapf(r.Error, "failed to claim %s delivery %s", o.name, id)
+		}
+
+		if r.RowsAffected == 1 {
+			return id, nil
+		}
+	}
+
+	return "", nil
+}
+
+// unblocked filters the deliveries of query that can be processed now: the ones without a key, and the ones whose key
+// doesn't have a delivery processing or, if the outbox is ordered, an older delivery pending
+func (o *outbox) unblocked(query *gorm.DB) *gorm.DB {
+	if o.key == "" {
+		return query
+	}
+
+	table := query.NewScope(o.record()).TableName()
+	blocking := "other.status = ?"
+	values := []interface{}{o.processing}
+	if o.ordered {
+		blocking = fmt.Sprintf("(other.status = ? OR (other.status = ? AND other.created_at < %s.created_at))", table)
+		values = append(values, o.pending)
+	}
+
+	condition := fmt.Sprintf(
+		"%[1]s.%[2]s = '' OR NOT EXISTS (SELECT 1 FROM %[1]s other WHERE other.%[2]s = %[1]s.%[2]s AND other.id != %[1]s.id AND %[3]s)",
+		table, o.key, blocking)
+	return query.Where(condition, values...)
+}
+
+// complete saves the result of the attempt number attempts of the delivery id, with the values of the attempt. Failed
+// attempts are retried after the backoff until the delivery reaches maxAttempts
+func (o *outbox) complete(db *gorm.DB, id string, attempts int, values map[string]interface{}, err error) {
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	values["status"] = o.processed
+	values["last_error"] = ""
+	if err != nil {
+		values["last_error"] = err.Error()
+		if attempts >= o.maxAttempts {
+			values["status"] = o.failed
+		} else {
+			values["status"] = o.pending
+			values["next_attempt_at"] = time.Now().UTC().Add(o.backoff(attempts))
+		}
+	}
+
+	if err := db.Model(o.record()).Where("id = ?", id).Updates(values).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to save the status of %s delivery %s", o.name, id))
+	}
+}
+
+// sync retries the deliveries that were lost while processing, or fails them if they reached maxAttempts, and removes
+// the deliveries that are older than the retention
+func (o *outbox) sync(db *gorm.DB) {
+	stuckPeriod := time.Now().Add(-o.stuckPeriod).UTC()
+	r := db.Model(o.record()).Where("status = ? AND updated_at < ? AND attempts < ?", o.processing, stuckPeriod, o.maxAttempts).
+		Updates(map[string]interface{}{"status": o.pending, "last_error": "the delivery timed out", "next_attempt_at": time.Now().UTC()})
+	if r.Error != nil {
+		logger.Error(errors.Wrapf(r.Error, "failed to retry stuck %s deliveries", o.name))
+	} else if r.RowsAffected > 0 {
+		log.Printf("retrying %d stuck %s deliveries", r.RowsAffected, o.name)
+	}
+
+	r = db.Model(o.record()).Where("status = ? AND updated_at < ?", o.processing, stuckPeriod).
+		Updates(map[string]interface{}{"status": o.failed, "last_error": "the delivery timed out"})
+	if r.Error != nil {
+		logger.Error(errors.Wrapf(r.Error, "failed to timeout stuck %s deliveries", o.name))
+	}
+
+	threshold := time.Now().UTC().Add(-o.retention)
+	statuses := []string{o.processed, o.failed}
+	if err := db.Unscoped().Where("created_at < ? AND status in (?)", threshold, statuses).Delete(o.record()).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to delete old %s deliveries", o.name))
+	}
+}
@@ -0,0 +1,563 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/providers"
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	openedPullRequest      = "opened"
+	reopenedPullRequest    = "reopened"
+	synchronizePullRequest = "synchronize"
+	closedPullRequest      = "closed"
+
+	shortCommitLength = 7
+)
+
+var (
+	// postPreviewComment creates or edits the comment of the pull request of a preview, and returns the ID of the comment
+	postPreviewComment = postPreviewCommentToGH
+
+	// destroyNamespace deletes the namespace of a closed preview
+	destroyNamespace = providers.DestroyNamespace
+
+	// trustedAuthors are the author associations of the pull requests from forks that are previewed by default
+	trustedAuthors = map[string]bool{"OWNER": true, "MEMBER": true, "COLLABORATOR": true}
+)
+
+func (s *Server) handlePullRequest(webhook *GHWebhookPayload) error {
+	pr := webhook.PullRequest
+	if pr == nil {
+		return errors.New("webhook didn't have a pull request")
+	}
+
+	var err error
+	switch webhook.Action {
+	case openedPullRequest, reopenedPullRequest, synchronizePullRequest:
+		err = s.deployPreviews(webhook.Installation.ID, webhook.Repository, pr, webhook.Sender.Login)
+	case closedPullRequest:
+		err = s.closePreviews(webhook.Installation.ID, webhook.Repository.ID, pr.Number)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to handle the %s action of pull request #%d of repository-%d", webhook.Action, pr.Number, webhook.Repository.ID)
+	}
+
+	return nil
+}
+
+// deployPreviews copies the services linked to the base branch of the pull request to the namespace of its preview, and
+// deploys them at the head commit of the pull request
+func (s *Server) deployPreviews(installationID int, repo *GHRepo, pr *GHPullRequest, author string) error {
+	if pr.Head.SHA == "" {
+		return errors.New("the pull request didn't have a head commit")
+	}
+
+	branch := getCanonicalBranchName(pr.Base.Ref)
+	links, err := s.getRefLinks(model.GHRepoLink{InstallationID: installationID, RepositoryID: repo.ID}, branch)
+	if err != nil {
+		return errors.Wrap(err, "failed to query for GHRepoLink")
+	}
+
+	// the links that failed are logged and the delivery is retried, the previews that were deployed are updated again
+	failed := 0
+	previews := map[string]*model.Preview{}
+	for i := range links {
+		if err := s.deployLinkPreviews(&links[i], repo, pr, author, previews); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to deploy the previews of ghrepolink-%s", links[i].ID))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to deploy the previews of %d of %d links", failed, len(links))
+	}
+
+	return nil
+}
+
+// deployLinkPreviews copies the services of link to the preview of their project. previews has the previews already
+// updated by this pull request event, by project, and nil for the projects that don't preview the pull request. It
+// returns an error if any of the services wasn't copied
+func (s *Server) deployLinkPreviews(link *model.GHRepoLink, repo *GHRepo, pr *GHPullRequest, author string, previews map[string]*model.Preview) error {
+	var services []model.Service
+	r := s.DB.Where(model.Service{GHRepoLinkID: link.ID}).Where("preview_id = '' AND status != ?", model.DestroyedService).Find(&services)
+	if r.Error != nil {
+		return errors.Wrap(r.Error, "failed to query for github linked services")
+	}
+
+	if len(services) == 0 {
+		return nil
+	}
+
+	manifests, err := s.getLinkManifests(link)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	updates := map[string]*model.Service{}
+	logMessage := fmt.Sprintf("Updated manifest due to commit #%s of pull request #%d by %s", pr.Head.SHA, pr.Number, author)
+	for _, svc := range services {
+		preview, ok := previews[svc.ProjectID]
+		if !ok {
+			project, err := s.getProjectByID(svc.ProjectID)
+			if err != nil {
+				logger.Error(errors.Wrapf(err, "failed to load project-%s to deploy a preview", svc.ProjectID))
+				failed++
+				continue
+			}
+
+			if project == nil {
+				log.Printf("project-%s doesn't exist, service-%s won't be previewed", svc.ProjectID, svc.ID)
+				continue
+			}
+
+			if !canPreview(project, repo, pr) {
+				log.Printf("pull request #%d of repository-%d is from a fork, project-%s doesn't preview it", pr.Number, repo.ID, project.ID)
+				previews[svc.ProjectID] = nil
+				continue
+			}
+
+			preview, err = s.activatePreview(project, link.InstallationID, repo, pr)
+			if err != nil {
+				logger.Error(err)
+				failed++
+				continue
+			}
+
+			previews[svc.ProjectID] = preview
+		}
+
+		if preview == nil {
+			continue
+		}
+
+		// the commits of pull requests from forks are also available in the base repository
+		m := getServiceManifest(link, manifests, svc.ID)
+		update, err := s.getManifestUpdate(updates, link, m, repo.Owner.Login, repo.Name, getCanonicalBranchName(pr.Head.Ref), pr.Head.SHA)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to read the manifest of service-%s", svc.ID))
+			failed++
+			continue
+		}
+
+		serviceID, err := s.syncPreviewService(preview, link, m, manifests, update, logMessage)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to copy service-%s to preview-%s", svc.ID, preview.ID))
+			failed++
+			continue
+		}
+
+		if err := s.scheduleAutoDeploy(serviceID, pr.Head.SHA); err != nil {
+			logger.Error(err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d services weren't previewed", failed, len(services))
+	}
+
+	return nil
+}
+
+// canPreview returns true if project deploys the preview of pr. The previews of pull requests from forks are deployed
+// with the secrets of the project, only the ones of trusted authors are deployed unless the project allows every fork
+func canPreview(project *model.Project, repo *GHRepo, pr *GHPullRequest) bool {
+	if pr.Head.Repo != nil && pr.Head.Repo.ID == repo.ID {
+		return true
+	}
+
+	if trustedAuthors[pr.AuthorAssociation] {
+		return true
+	}
+
+	settings := project.LoadedSettings
+	return settings != nil && settings.Previews != nil && settings.Previews.Forks
+}
+
+// activatePreview returns the preview of the pull request in project at its head commit. The preview is created the first
+// time the pull request is opened, and reused if the pull request is reopened
+func (s *Server) activatePreview(project *model.Project, installationID int, repo *GHRepo, pr *GHPullRequest) (*model.Preview, error) {
+	preview := &model.Preview{}
+	r := s.DB.Where(model.Preview{ProjectID: project.ID, InstallationID: installationID, RepositoryID: repo.ID, PullRequest: pr.Number}).First(preview)
+	if r.Error != nil && !r.RecordNotFound() {
+		return nil, errors.Wrapf(r.Error, "failed to get the preview of pull request #%d for project-%s", pr.Number, project.ID)
+	}
+
+	preview.ProjectID = project.ID
+	preview.InstallationID = installationID
+	preview.RepositoryID = repo.ID
+	preview.RepositoryOwner = repo.Owner.Login
+	preview.RepositoryName = repo.Name
+	preview.PullRequest = pr.Number
+	preview.Branch = getCanonicalBranchName(pr.Head.Ref)
+	preview.Commit = pr.Head.SHA
+	preview.Status = model.ActivePreview
+	if err := s.DB.Save(preview).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to save the preview of pull request #%d for project-%s", pr.Number, project.ID)
+	}
+
+	// the namespace is derived from the ID, it's set once the preview is created
+	if preview.Namespace == "" {
+		preview.Namespace = model.GetPreviewNamespace(preview.ID)
+		if err := s.DB.Model(preview).Update("namespace", preview.Namespace).Error; err != nil {
+			return nil, errors.Wrapf(err, "failed to save the namespace of preview-%s", preview.ID)
+		}
+	}
+
+	return preview, nil
+}
+
+// syncPreviewService creates or updates the copy in the preview of the services of link that read the manifest m, and
+// returns its ID. manifests has the manifests of the services of link, by service
+func (s *Server) syncPreviewService(preview *model.Preview, link *model.GHRepoLink, m *model.GHLinkManifest, manifests map[string]*model.GHLinkManifest, update *model.Service, logMessage string) (string, error) {
+	var copies []model.Service
+	r := s.DB.Where(model.Service{PreviewID: preview.ID, GHRepoLinkID: link.ID}).Where("status != ?", model.DestroyedService).Find(&copies)
+	if r.Error != nil {
+		return "", errors.Wrapf(r.Error, "failed to get the services of preview-%s", preview.ID)
+	}
+
+	for _, existing := range copies {
+		if getManifestKey(getServiceManifest(link, manifests, existing.ID)) != getManifestKey(m) {
+			continue
+		}
+
+		if appErr := s.UpdateManifest(preview.ProjectID, existing.ID, update, getOverlayUpdate(m, update), githubActorID, logMessage); appErr != nil {
+			return "", appErr
+		}
+
+		return existing.ID, nil
+	}
+
+	project, err := s.getProjectByID(preview.ProjectID)
+	if err != nil {
+		return "", err
+	}
+
+	if project == nil {
+		return "", errors.Wrapf(model.ErrNotFound, "project-%s not found in the DB", preview.ProjectID)
+	}
+
+	service := &model.Service{
+		Manifest:     update.Manifest,
+		Overlay:      update.Overlay,
+		Branch:       update.Branch,
+		Commit:       update.Commit,
+		GHRepoLinkID: link.ID,
+		PreviewID:    preview.ID,
+		Namespace:    preview.Namespace,
+		CreatedBy:    githubActorID,
+	}
+
+	if _, appErr := s.CreateService(project, service, githubUser()); appErr != nil {
+		return "", appErr
+	}
+
+	// the copy reads the same manifest as the service, the services without a manifest use the one of the link
+	if m.ID != "" {
+		copyManifest := &model.GHLinkManifest{
+			GHRepoLinkID: link.ID,
+			ServiceID:    service.ID,
+			Manifest:     m.Manifest,
+			Overlay:      m.Overlay,
+			Paths:        m.Paths,
+		}
+
+		if err := s.DB.Create(copyManifest).Error; err != nil {
+			return "", errors.Wrapf(err, "failed to save the manifest of service-%s", service.ID)
+		}
+
+		manifests[service.ID] = copyManifest
+	}
+
+	return service.ID, nil
+}
+
+// closePreviews destroys the previews of a closed or merged pull request
+func (s *Server) closePreviews(installationID, repositoryID, number int) error {
+	var previews []model.Preview
+	r := s.DB.Where(model.Preview{InstallationID: installationID, RepositoryID: repositoryID, PullRequest: number, Status: model.ActivePreview}).Find(&previews)
+	if r.Error != nil {
+		return errors.Wrap(r.Error, "failed to query for previews")
+	}
+
+	failed := 0
+	for i := range previews {
+		if err := s.closePreview(&previews[i]); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to close preview-%s", previews[i].ID))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to close %d of %d previews", failed, len(previews))
+	}
+
+	return nil
+}
+
+func (s *Server) closePreview(preview *model.Preview) error {
+	if err := s.DB.Model(preview).Update("status", model.ClosedPreview).Error; err != nil {
+		return err
+	}
+
+	project, err := s.getProjectByID(preview.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	if project != nil {
+		services, err := s.getPreviewServices(preview.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, svc := range services {
+			if err := s.cancelAutoDeploy(svc.ID); err != nil {
+				return err
+			}
+			s.destroyPreviewService(project, preview.ID, svc.ID)
+		}
+
+		s.destroyPreviewNamespace(project, preview.ID)
+	}
+
+	s.updatePreviewComment(preview.ID)
+	return nil
+}
+
+// destroyPreviewNamespace deletes the namespace of a closed preview once its services are destroyed, so the destroys
+// clean up the resources outside of the namespace. The services whose destroy failed are deleted with the namespace
+func (s *Server) destroyPreviewNamespace(project *model.Project, previewID string) {
+	var preview model.Preview
+	if err := s.DB.Where("id = ?", previewID).First(&preview).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load preview-%s", previewID))
+		return
+	}
+
+	// the pull request was reopened while the services were destroyed
+	if preview.Status == model.ActivePreview {
+		return
+	}
+
+	services, err := s.getPreviewServices(previewID)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	for i := range services {
+		destroyed, err := s.isDestroyFinished(&services[i])
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+
+		if !destroyed {
+			time.AfterFunc(autoDeployDelay, func() { s.destroyPreviewNamespace(project, previewID) })
+			return
+		}
+	}
+
+	env := s.buildServiceEnvironment(project, &model.Service{Namespace: preview.Namespace, PreviewID: preview.ID})
+	env.Provider.LoadDefaultCluster()
+	if err := destroyNamespace(env, log.New(os.Stderr, "", log.LstdFlags)); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to delete the namespace of preview-%s", previewID))
+	}
+}
+
+// destroyPreviewService destroys a service of a closed preview. If another operation of the service is in progress, the
+// destroy is retried every autoDeployDelay until the operation finishes. The stuck operations fail after a timeout
+func (s *Server) destroyPreviewService(project *model.Project, previewID, serviceID string) {
+	var preview model.Preview
+	if err := s.DB.Where("id = ?", previewID).First(&preview).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load preview-%s", previewID))
+		return
+	}
+
+	// the pull request was reopened while the service was busy
+	if preview.Status == model.ActivePreview {
+		return
+	}
+
+	service, appErr := s.getService(project, serviceID)
+	if appErr != nil {
+		logger.Error(errors.Wrapf(appErr, "failed to load service-%s to destroy it", serviceID))
+		return
+	}
+
+	if service.IsDestroyed() {
+		return
+	}
+
+	appErr = s.DeleteService(project, serviceID, githubUser())
+	if appErr == nil {
+		return
+	}
+
+	if appErr.Code != model.InvalidServiceStatus {
+		logger.Error(errors.Wrapf(appErr, "failed to destroy service-%s of preview-%s", serviceID, previewID))
+		return
+	}
+
+	time.AfterFunc(autoDeployDelay, func() { s.destroyPreviewService(project, previewID, serviceID) })
+}
+
+// isDestroyFinished returns true if the service is destroyed or its last operation is a destroy that failed
+func (s *Server) isDestroyFinished(service *model.Service) (bool, error) {
+	if service.Status == model.DestroyedService {
+		return true, nil
+	}
+
+	if service.Status != model.FailedService {
+		return false, nil
+	}
+
+	var activity model.Activity
+	r := s.DB.Where("service_id = ?", service.ID).Order("created_at desc").First(&activity)
+	if r.Error != nil {
+		return false, errors.Wrapf(r.Error, "failed to get the last activity of service-%s", service.ID)
+	}
+
+	return activity.Type == model.Destroyed, nil
+}
+
+func (s *Server) getPreviewServices(previewID string) ([]model.Service, error) {
+	var services []model.Service
+	r := s.DB.Where(model.Service{PreviewID: previewID}).Where("status != ?", model.DestroyedService).Order("name").Find(&services)
+	if r.Error != nil {
+		return nil, errors.Wrapf(r.Error, "failed to get the services of preview-%s", previewID)
+	}
+
+	return services, nil
+}
+
+// updatePreviewComment creates or updates the comment of the pull request with the status and the endpoints of the preview
+func (s *Server) updatePreviewComment(previewID string) {
+	// the services of a preview are deployed in parallel, only the first one creates the comment
+	s.previewsMutex.Lock()
+	defer s.previewsMutex.Unlock()
+
+	preview := &model.Preview{}
+	if err := s.DB.Where("id = ?", previewID).First(preview).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load preview-%s", previewID))
+		return
+	}
+
+	body := "The preview of this pull request was destroyed."
+	if preview.Status == model.ActivePreview {
+		project, err := s.getProjectByID(preview.ProjectID)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to load project-%s of preview-%s", preview.ProjectID, previewID))
+			return
+		}
+
+		if project == nil {
+			log.Printf("project-%s of preview-%s doesn't exist", preview.ProjectID, previewID)
+			return
+		}
+
+		services, err := s.getPreviewServices(previewID)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+
+		body = s.renderPreviewComment(project, preview, services)
+	}
+
+	commentID, err := postPreviewComment(preview, body)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to comment pull request #%d of preview-%s", preview.PullRequest, previewID))
+		return
+	}
+
+	if commentID != preview.CommentID {
+		if err := s.DB.Model(preview).Update("comment_id", commentID).Error; err != nil {
+			logger.Error(errors.Wrapf(err, "failed to save the comment of preview-%s", previewID))
+		}
+	}
+}
+
+func (s *Server) renderPreviewComment(project *model.Project, preview *model.Preview, services []model.Service) string {
+	commit := preview.Commit
+	if len(commit) > shortCommitLength {
+		commit = commit[:shortCommitLength]
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "The preview of commit %s is deployed in the namespace `%s`:\n\n", commit, preview.Namespace)
+	fmt.Fprintln(b, "| Service | Status | Endpoints |")
+	fmt.Fprintln(b, "| --- | --- | --- |")
+	for i := range services {
+		svc := &services[i]
+		endpoints := []string{}
+		if project.LoadedSettings != nil {
+			if m, appErr := buildService(svc, project, false, nil); appErr == nil {
+				endpoints = s.buildServiceEndpoints(m, getServiceProject(project, svc), svc.DNS)
+			}
+		}
+
+		fmt.Fprintf(b, "| %s | %s | %s |\n", svc.Name, svc.Status, strings.Join(endpoints, "<br>"))
+	}
+
+	return b.String()
+}
+
+func postPreviewCommentToGH(preview *model.Preview, body string) (int64, error) {
+	client, err := newGithubClient(preview.InstallationID)
+	if err != nil {
+		return 0, err
+	}
+
+	comment := &github.IssueComment{Body: &body}
+	if preview.CommentID != 0 {
+		_, _, err := client.Issues.EditComment(context.Background(), preview.RepositoryOwner, preview.RepositoryName, preview.CommentID, comment)
+		return preview.CommentID, err
+	}
+
+	created, _, err := client.Issues.CreateComment(context.Background(), preview.RepositoryOwner, preview.RepositoryName, preview.PullRequest, comment)
+	if err != nil {
+		return 0, err
+	}
+
+	return created.GetID(), nil
+}
+
+// getServiceProject returns the project with the namespace where d is deployed. The services of a preview are deployed
+// in the namespace of the preview, and the namespace is part of their ingress hostnames so they don't take the hostnames
+// of the project services
+func getServiceProject(project *model.Project, d *model.Service) *model.Project {
+	if d.Namespace == "" {
+		return project
+	}
+
+	p := *project
+	p.DNSName = d.Namespace
+	if settings := project.LoadedSettings; settings != nil && settings.Provider != nil && settings.Provider.Ingress != nil {
+		loaded := *settings
+		provider := *settings.Provider
+		ingress := *settings.Provider.Ingress
+		ingress.AppendProject = true
+		provider.Ingress = &ingress
+		loaded.Provider = &provider
+		p.LoadedSettings = &loaded
+	}
+
+	return &p
+}
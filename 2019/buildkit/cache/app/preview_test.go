@@ -0,0 +1,289 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/providers"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+type fakeCommenter struct {
+	comments map[int64]string
+	mu       sync.Mutex
+}
+
+func (f *fakeCommenter) post(preview *model.Preview, body string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := preview.CommentID
+	if id == 0 {
+		id = int64(len(f.comments) + 1)
+	}
+
+	f.comments[id] = body
+	return id, nil
+}
+
+func (f *fakeCommenter) waitFor(id int64, text string) error {
+	for i := 0; i < 100; i++ {
+		f.mu.Lock()
+		body := f.comments[id]
+		f.mu.Unlock()
+		if strings.Contains(body, text) {
+			return nil
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return fmt.Errorf("comment %d never contained '%s': %+v", id, text, f.comments)
+}
+
+func newPullRequestEvent(action, sha string) *GHWebhookPayload {
+	return &GHWebhookPayload{
+		Event:        pullRequestEvent,
+		Action:       action,
+		Installation: &GHInstallation{ID: 1},
+		Repository:   &GHRepo{ID: 2, Name: "app", Owner: &GHAccount{Login: "okteto"}},
+		Sender:       &GHAccount{Login: "developer"},
+		PullRequest: &GHPullRequest{
+			Number: 7,
+			Head:   GHBranch{Ref: "feature", SHA: sha, Repo: &GHRepo{ID: 2}},
+			Base:   GHBranch{Ref: "master", SHA: "b0", Repo: &GHRepo{ID: 2}},
+		},
+	}
+}
+
+func TestPullRequestPreview(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	autoDeployDelay = 10 * time.Millisecond
+	defer func() { autoDeployDelay = 10 * time.Second }()
+
+	manifest, _ := base64.StdEncoding.DecodeString(httpsService)
+	fetched := []string{}
+	getFileFromRepo = func(installationID int, owner, name, path, commit string) (string, error) {
+		fetched = append(fetched, commit)
+		return string(manifest), nil
+	}
+	defer func() { getFileFromRepo = downloadFileFromGH }()
+
+	githubReporter = &fakeReporter{}
+	defer func() { githubReporter = &GHAPIReporter{} }()
+
+	commenter := &fakeCommenter{comments: map[int64]string{}}
+	postPreviewComment = commenter.post
+	defer func() { postPreviewComment = postPreviewCommentToGH }()
+
+	destroyed := make(chan string, 1)
+	destroyNamespace = func(e *model.Environment, l *log.Logger) error {
+		destroyed <- e.Name + "," + e.Labels[model.PreviewLabel]
+		return nil
+	}
+	defer func() { destroyNamespace = providers.DestroyNamespace }()
+
+	s := Server{DB: db}
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Branch: "refs/heads/master", Manifest: "okteto.yaml"}
+	if err := db.Create(link).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &model.Service{Manifest: httpsService, Name: "service", GHRepoLinkID: link.ID}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	s.handlePullRequest(newPullRequestEvent(openedPullRequest, "a1"))
+
+	var preview model.Preview
+	if err := db.Where(model.Preview{ProjectID: p.ID, PullRequest: 7}).First(&preview).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if preview.Namespace != model.GetPreviewNamespace(preview.ID) || preview.Status != model.ActivePreview {
+		t.Errorf("wrong preview: %+v", preview)
+	}
+
+	services, err := s.getPreviewServices(preview.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services) != 1 {
+		t.Fatalf("expected one service in the preview, got %+v", services)
+	}
+
+	copied := services[0]
+	if copied.Namespace != preview.Namespace || copied.Commit != "a1" || copied.Branch != "refs/heads/feature" || copied.CreatedBy != githubActorID {
+		t.Errorf("wrong preview service: %+v", copied)
+	}
+
+	if err := s.waitUntil(p, copied.ID, model.DeployedService); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := commenter.waitFor(1, "| oktetotest | deployed |"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.handlePullRequest(newPullRequestEvent(synchronizePullRequest, "c3"))
+	services, _ = s.getPreviewServices(preview.ID)
+	if len(services) != 1 || services[0].ID != copied.ID || services[0].Commit != "c3" {
+		t.Errorf("the preview service wasn't updated: %+v", services)
+	}
+
+	if fetched[len(fetched)-1] != "c3" {
+		t.Errorf("the manifest was fetched at %+v", fetched)
+	}
+
+	if err := commenter.waitFor(1, "commit c3 "); err != nil {
+		t.Fatal(err)
+	}
+
+	s.handlePullRequest(newPullRequestEvent(closedPullRequest, "c3"))
+	if err := s.waitUntil(p, copied.ID, model.DestroyedService); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := commenter.waitFor(1, "destroyed"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case namespace := <-destroyed:
+		if namespace != preview.Namespace+","+preview.ID {
+			t.Errorf("deleted the namespace %s", namespace)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the namespace of the preview wasn't deleted")
+	}
+
+	if len(commenter.comments) != 1 {
+		t.Errorf("expected a single comment, got %+v", commenter.comments)
+	}
+
+	original, appErr := s.getService(p, svc.ID)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	if original.Status != model.CreatedService || original.Commit != "" {
+		t.Errorf("the linked service was changed by the preview: %+v", original)
+	}
+}
+
+func TestPullRequestPreviewFailuresAreReturned(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	autoDeployDelay = 10 * time.Millisecond
+	defer func() { autoDeployDelay = 10 * time.Second }()
+
+	manifest, _ := base64.StdEncoding.DecodeString(httpsService)
+	unavailable := true
+	getFileFromRepo = func(installationID int, owner, name, path, commit string) (string, error) {
+		if unavailable {
+			return "", fmt.Errorf("github is unavailable")
+		}
+
+		return string(manifest), nil
+	}
+	defer func() { getFileFromRepo = downloadFileFromGH }()
+
+	githubReporter = &fakeReporter{}
+	defer func() { githubReporter = &GHAPIReporter{} }()
+
+	commenter := &fakeCommenter{comments: map[int64]string{}}
+	postPreviewComment = commenter.post
+	defer func() { postPreviewComment = postPreviewCommentToGH }()
+
+	s := Server{DB: db}
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	link := &model.GHRepoLink{InstallationID: 1, RepositoryID: 2, Branch: "refs/heads/master", Manifest: "okteto.yaml"}
+	if err := db.Create(link).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &model.Service{Manifest: httpsService, Name: "service", GHRepoLinkID: link.ID}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	if err := s.handlePullRequest(newPullRequestEvent(openedPullRequest, "a1")); err == nil {
+		t.Fatal("the failed preview wasn't returned, the delivery won't be retried")
+	}
+
+	unavailable = false
+	if err := s.handlePullRequest(newPullRequestEvent(openedPullRequest, "a1")); err != nil {
+		t.Fatal(err)
+	}
+
+	var preview model.Preview
+	if err := db.Where(model.Preview{ProjectID: p.ID, PullRequest: 7}).First(&preview).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := s.getPreviewServices(preview.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services) != 1 {
+		t.Fatalf("expected one service in the preview, got %+v", services)
+	}
+
+	if err := s.waitUntil(p, services[0].ID, model.DeployedService); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_canPreview(t *testing.T) {
+	repo := &GHRepo{ID: 2}
+	allowForks := &model.Project{LoadedSettings: &model.ProjectSettings{Previews: &model.PreviewSettings{Forks: true}}}
+	tests := []struct {
+		name    string
+		project *model.Project
+		head    *GHRepo
+		author  string
+		want    bool
+	}{
+		{name: "same-repository", project: &model.Project{}, head: repo, author: "CONTRIBUTOR", want: true},
+		{name: "fork-of-member", project: &model.Project{}, head: &GHRepo{ID: 3}, author: "MEMBER", want: true},
+		{name: "fork-of-contributor", project: &model.Project{}, head: &GHRepo{ID: 3}, author: "CONTRIBUTOR", want: false},
+		{name: "deleted-fork", project: &model.Project{}, head: nil, author: "NONE", want: false},
+		{name: "forks-allowed", project: allowForks, head: &GHRepo{ID: 3}, author: "NONE", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &GHPullRequest{Number: 7, Head: GHBranch{Repo: tt.head}, AuthorAssociation: tt.author}
+			if got := canPreview(tt.project, repo, pr); got != tt.want {
+				t.Errorf("canPreview() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
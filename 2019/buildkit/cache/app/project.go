@@ -322,6 +322,7 @@ func (s *Server) UpdateProjectSettings(loggedUser *model.User, existing *model.P
 
 	invitedUsers := findInvitedUsers(previousUsers, append(existing.LoadedSettings.Administrators, existing.LoadedSettings.Users...))
 	s.sendProjectInvite(invitedUsers, existing.Name)
+	go s.notifyMembersAdded(existing, invitedUsers, loggedUser)
 	return pendingUsers, nil
 }
 
@@ -397,6 +398,12 @@ func (s *Server) RemoveProjectGithubLink(existing *model.Project, tx *gorm.DB) e
 			tx.Rollback()
 			return errors.Wrap(err, "couldn't delete gh_repo_links")
 		}
+
+		err = tx.Unscoped().Where("service_id IN (?)", toRemoveLink).Delete(model.GHLinkManifest{}).Error
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "couldn't delete gh_link_manifests")
+		}
 	}
 
 	err = tx.Commit().Error
@@ -529,6 +536,11 @@ func setUsers(settings *model.ProjectSettings, existingUsers []model.ProjectACL)
 func (s *Server) sendProjectInvite(users []string, projectName string) {
 	url := config.GetBaseURL()
 	for _, u := range users {
+		if !s.isEmailEnabled(u, model.InviteEmail) {
+			log.Printf("%s is unsubscribed, the invite to %s wasn't sent", u, projectName)
+			continue
+		}
+
 		err := s.Email.sendProjectInviteEmail(u, projectName, url)
 		if err != nil {
 			log.Printf("Failed to send email: %s", err.Error())
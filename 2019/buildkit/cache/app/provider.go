@@ -18,25 +18,36 @@ import (
 const providerTimeout = 15 * time.Minute
 
 func (s *Server) devDeploy(d *model.Service, project *model.Project, activityID string) error {
-	return s.callProvider(d, project, activityID, providers.DevDeploy)
+	return s.callProvider(d, project, activityID, true, providers.DevDeploy)
 }
 
 func (s *Server) deploy(d *model.Service, project *model.Project, activityID string) error {
-	return s.callProvider(d, project, activityID, providers.Deploy)
+	return s.callProvider(d, project, activityID, true, providers.Deploy)
 }
 
 func (s *Server) destroy(d *model.Service, project *model.Project, activityID string) error {
-	return s.callProvider(d, project, activityID, providers.Destroy)
+	return s.callProvider(d, project, activityID, false, providers.Destroy)
 }
 
-func (s *Server) callProvider(d *model.Service, project *model.Project, activityID string, f func(*model.Service, *model.Environment, *log.Logger) error) error {
-	service, appErr := buildService(d.ID, d.Manifest)
+func (s *Server) callProvider(d *model.Service, project *model.Project, activityID string, deploying bool, f func(*model.Service, *model.Environment, *log.Logger) error) error {
+	// the project settings might have changed since the manifest was saved, they are checked again before deploying
+	var settings *model.ProjectSettings
+	if deploying {
+		settings = project.LoadedSettings
+	}
+
+	service, appErr := buildService(d, project, false, settings)
 	if appErr != nil {
+		// the project variables and policies might have changed since the manifest was saved
+		if hasErrorCode(appErr, model.UndefinedVariable, model.SchedulingNotAllowed, model.SecurityPolicyViolation) {
+			return appErr
+		}
+
 		logger.Error(errors.Wrap(appErr, "failed to load service, this is most likely a bug or a service schema change issue"))
 		return model.ErrUnknown
 	}
 
-	env := s.buildEnvironment(project)
+	env := s.buildServiceEnvironment(project, d)
 	if err := env.Validate(); err != nil {
 		logger.Error(errors.Wrap(appErr, "failed to validate the environment , this is most likely a bug or a project schema change issue"))
 		return model.ErrUnknown
@@ -46,6 +57,36 @@ func (s *Server) callProvider(d *model.Service, project *model.Project, activity
 
 	injectSecrets(service, project.LoadedSettings.Secrets)
 
+	if deploying {
+		// the cluster of the project might have changed since the manifest was saved
+		if errs := service.ValidateLoadBalancerPorts(env); len(errs) > 0 {
+			return errs[0]
+		}
+
+		applyProjectDefaults(service, project.LoadedSettings)
+
+		if err := s.loadConfigFiles(service, d); err != nil {
+			return err
+		}
+
+		if err := s.buildImages(service, d, project, activityID); err != nil {
+			return err
+		}
+
+		if err := s.resolveImages(service, project.LoadedSettings.Registry, activityID); err != nil {
+			return err
+		}
+	}
+
+	l, stop := s.startLogs(activityID)
+	err := f(service, env, l)
+	stop()
+
+	return err
+}
+
+// startLogs returns a logger that saves its output in the logs of the activity. stop waits until every log is saved
+func (s *Server) startLogs(activityID string) (l *log.Logger, stop func()) {
 	l, reader := getLogger()
 
 	// done is used by saveLogs to know when the the deployment is done
@@ -55,12 +96,10 @@ func (s *Server) callProvider(d *model.Service, project *model.Project, activity
 	wait.Add(1)
 
 	go s.saveLogs(activityID, reader, done, wait)
-	err := f(service, env, l)
-
-	done <- true
-	wait.Wait()
-
-	return err
+	return l, func() {
+		done <- true
+		wait.Wait()
+	}
 }
 
 func (s *Server) buildEnvironment(project *model.Project) *model.Environment {
@@ -70,21 +109,80 @@ func (s *Server) buildEnvironment(project *model.Project) *model.Environment {
 	e.ID = project.ID
 	e.Provider = project.LoadedSettings.Provider
 	e.Registry = project.LoadedSettings.Registry
+	e.Security = project.LoadedSettings.Security
 	e.DNSProvider = s.DNSProvider
 	return e
 }
 
-func buildService(serviceID, manifest string) (*model.Service, *model.AppError) {
-	s, err := model.ParseEncodedManifest(manifest)
+// buildServiceEnvironment returns the environment where d is deployed. The namespaces of the previews are labelled
+// with the preview, only the namespaces with its label are deleted when the preview is closed
+func (s *Server) buildServiceEnvironment(project *model.Project, d *model.Service) *model.Environment {
+	e := s.buildEnvironment(getServiceProject(project, d))
+	if d.PreviewID != "" {
+		e.Labels = map[string]string{model.PreviewLabel: d.PreviewID}
+	}
+
+	return e
+}
+
+// buildService renders the manifest of d. Manifests sent by the users are parsed with strict set to true, stored manifests
+// are not, so they keep working if a field is removed from the schema. The manifests that are saved or deployed must
+// comply with the policies of settings, the ones that are only read pass nil
+func buildService(d *model.Service, project *model.Project, strict bool, settings *model.ProjectSettings) (*model.Service, *model.AppError) {
+	overlays := []string{}
+	if d.Overlay != "" {
+		overlays = append(overlays, d.Overlay)
+	}
+
+	s, err := model.RenderEncodedManifest(d.Manifest, overlays, getManifestVariables(d, project), strict, settings)
 	if err != nil {
 		return nil, err
 	}
 
-	s.ID = serviceID
+	s.ID = d.ID
 
 	return s, nil
 }
 
+// getManifestVariables returns the project variables and the builtin values that are interpolated in the manifest of d
+func getManifestVariables(d *model.Service, project *model.Project) map[string]string {
+	vars := map[string]string{}
+	if project.LoadedSettings != nil {
+		for k, v := range project.LoadedSettings.Variables {
+			vars[k] = v
+		}
+	}
+
+	vars[model.ProjectVariable] = project.Name
+	vars[model.ServiceVariable] = d.Name
+	vars[model.BranchVariable] = d.Branch
+	vars[model.CommitVariable] = d.Commit
+	vars[model.RefVariable] = model.GetRefVariable(d.Branch)
+	return vars
+}
+
+// hasErrorCode returns true if appErr, or any of the errors it aggregates, has one of the codes
+func hasErrorCode(appErr *model.AppError, codes ...model.AppErrorCode) bool {
+	for _, e := range append([]*model.AppError{appErr}, appErr.Errors...) {
+		for _, c := range codes {
+			if e.Code == c {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// applyProjectDefaults sets the project settings defaults that are not declared in the manifest
+func applyProjectDefaults(service *model.Service, settings *model.ProjectSettings) {
+	if settings == nil {
+		return
+	}
+
+	service.ApplySchedulingDefaults(settings.Scheduling)
+}
+
 func orDefault(v string, d string) string {
 	if v == "" {
 		return d
@@ -138,23 +236,30 @@ func (s *Server) saveLogs(activityID string, reader *bufio.Reader, done <-chan b
 }
 
 func injectSecrets(service *model.Service, secrets []*model.EnvVar) {
-	for c := range service.Containers {
-		for _, e := range service.Containers[c].Environment {
-			if e.Value == "" {
-				for _, s := range secrets {
-					if e.Name == s.Name {
-						e.Value = s.Value
-					}
+	for _, c := range service.Containers {
+		injectContainerSecrets(c, secrets)
+	}
+	for _, c := range service.InitContainers {
+		injectContainerSecrets(&c.Container, secrets)
+	}
+}
+
+func injectContainerSecrets(c *model.Container, secrets []*model.EnvVar) {
+	for _, e := range c.Environment {
+		if e.Value == "" {
+			for _, s := range secrets {
+				if e.Name == s.Name {
+					e.Value = s.Value
 				}
 			}
+		}
 
-			if strings.HasPrefix(e.Value, "$") {
-				secretName := e.Value[1:]
-				e.Value = ""
-				for _, s := range secrets {
-					if secretName == s.Name {
-						e.Value = s.Value
-					}
+		if strings.HasPrefix(e.Value, "$") {
+			secretName := e.Value[1:]
+			e.Value = ""
+			for _, s := range secrets {
+				if secretName == s.Name {
+					e.Value = s.Value
 				}
 			}
 		}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"encoding/base64"
 	"fmt"
 	"reflect"
 	"sync"
@@ -143,7 +144,7 @@ func Test_buildService(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			d := &model.Service{Manifest: tt.manifest}
-			_, err := buildService(d.ID, d.Manifest)
+			_, err := buildService(d, &model.Project{}, true, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("buildService() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -151,6 +152,41 @@ func Test_buildService(t *testing.T) {
 	}
 }
 
+func Test_buildServiceWithVariables(t *testing.T) {
+	manifest := base64.StdEncoding.EncodeToString([]byte(`
+name: ${OKTETO_SERVICE}
+containers:
+  app:
+    image: ${REGISTRY}/app:${OKTETO_COMMIT}
+    environment:
+      - PROJECT=${OKTETO_PROJECT}
+      - BRANCH=${OKTETO_BRANCH}
+`))
+	overlay := base64.StdEncoding.EncodeToString([]byte(`replicas: 2`))
+
+	d := &model.Service{Name: "web", Manifest: manifest, Overlay: overlay, Branch: "master", Commit: "a1b2c3"}
+	p := &model.Project{Name: "project", LoadedSettings: &model.ProjectSettings{Variables: map[string]string{"REGISTRY": "registry.okteto.com"}}}
+
+	s, err := buildService(d, p, true, nil)
+	if err != nil {
+		t.Fatalf("buildService() error = %v", err)
+	}
+
+	if s.Name != "web" || s.Replicas != 2 || s.Containers["app"].Image != "registry.okteto.com/app:a1b2c3" {
+		t.Errorf("wrong service: %+v", s)
+	}
+
+	env := s.Containers["app"].Environment
+	if env[0].Value != "project" || env[1].Value != "master" {
+		t.Errorf("wrong environment: %+v %+v", env[0], env[1])
+	}
+
+	p.LoadedSettings = nil
+	if _, err := buildService(d, p, true, nil); err == nil || err.Code != model.UndefinedVariable {
+		t.Errorf("expected %s, got %+v", model.UndefinedVariable, err)
+	}
+}
+
 func Test_orDefault(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,409 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+// SCMProvider is a source control provider where the manifests of the linked services are read from
+type SCMProvider interface {
+	// ListRepositories returns the repositories that can be linked to the services of p
+	ListRepositories(p *model.Project) ([]*SCMRepository, error)
+
+	// GetRepository returns the repository owner/name, it's used to link it to a service of p
+	GetRepository(p *model.Project, owner, name string) (*SCMRepository, error)
+
+	// GetFile returns the content of a file of the linked repository at ref
+	GetFile(link *model.GHRepoLink, path, ref string) (string, error)
+
+	// DownloadRepository extracts the linked repository at commit into dir
+	DownloadRepository(link *model.GHRepoLink, commit, dir string) error
+
+	// VerifyWebhook returns true if the webhook delivery was sent by a webhook of the provider with secret
+	VerifyWebhook(header http.Header, body []byte, secret string) bool
+
+	// CreateStatus reports the status of a commit of the linked repository
+	CreateStatus(link *model.GHRepoLink, commit string, status *SCMStatus) error
+}
+
+// SCMRepository is a repository of a source control provider
+type SCMRepository struct {
+	// ID is the numeric ID of the github and gitlab repositories
+	ID            int
+	Owner         string
+	Name          string
+	FullName      string
+	DefaultBranch string
+	URL           string
+}
+
+// SCMStatus is the status of a commit. State is one of ghPendingState, ghSuccessState or ghFailureState
+type SCMStatus struct {
+	State       string
+	TargetURL   string
+	Description string
+	Context     string
+}
+
+// SCMCommit has the files changed by a commit of a push event, github and gitlab send the same fields
+type SCMCommit struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+const (
+	// maxPushCommits is the number of commits in the push events of github and gitlab
+	maxPushCommits = 20
+
+	scmAPITimeout = 30 * time.Second
+)
+
+// scmClient sends the requests to the apis of gitlab and bitbucket, the archives are downloaded with archiveClient
+var scmClient = &http.Client{Timeout: scmAPITimeout}
+
+// GetSCMProvider returns the implementation of a source control provider
+func (s *Server) GetSCMProvider(provider model.SCMProviderType) (SCMProvider, error) {
+	switch provider {
+	case model.GithubProvider:
+		return &GithubSCM{s: s}, nil
+	case model.GitlabProvider:
+		return &GitlabSCM{s: s}, nil
+	case model.BitbucketProvider:
+		return &BitbucketSCM{s: s}, nil
+	default:
+		return nil, errors.Wrapf(model.ErrUnknownSCMProvider, "'%s' is not a source control provider", provider)
+	}
+}
+
+// IsSCMEventSupported returns true if the gitlab or bitbucket event is supported
+func IsSCMEventSupported(provider model.SCMProviderType, event string) bool {
+	switch provider {
+	case model.GitlabProvider:
+		return event == gitlabPushEvent || event == gitlabTagEvent
+	case model.BitbucketProvider:
+		return event == bitbucketPushEvent
+	default:
+		return false
+	}
+}
+
+// getSCMEventRepository returns the full name of the repository of a gitlab or bitbucket delivery, it's empty if the
+// payload doesn't have a repository
+func getSCMEventRepository(provider model.SCMProviderType, body []byte) string {
+	switch provider {
+	case model.GitlabProvider:
+		payload := &gitlabPushPayload{}
+		if err := json.Unmarshal(body, payload); err == nil && payload.Project != nil {
+			return payload.Project.PathWithNamespace
+		}
+	case model.BitbucketProvider:
+		payload := &bitbucketPushPayload{}
+		if err := json.Unmarshal(body, payload); err == nil && payload.Repository != nil {
+			return payload.Repository.FullName
+		}
+	}
+
+	return ""
+}
+
+// getSCMDeliveryKey returns the key of a gitlab or bitbucket delivery of projectID, the deliveries with the same key
+// are processed in order. The gitlab pushes are ordered by ref, a bitbucket push can update several refs and they are
+// ordered by repository
+func getSCMDeliveryKey(provider model.SCMProviderType, projectID, repository string, body []byte) string {
+	if repository == "" {
+		return ""
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", provider, projectID, repository)
+	if provider == model.GitlabProvider {
+		payload := &gitlabPushPayload{}
+		if err := json.Unmarshal(body, payload); err == nil && payload.Ref != "" {
+			key = fmt.Sprintf("%s/%s", key, payload.Ref)
+		}
+	}
+
+	return key
+}
+
+// VerifySCMWebhook returns the project whose webhook sent a gitlab or bitbucket delivery. The projects have their own
+// secret for the webhooks of the repositories they link, it returns false if the delivery doesn't match any of them
+func (s *Server) VerifySCMWebhook(provider model.SCMProviderType, header http.Header, body []byte) (string, bool) {
+	scm, err := s.GetSCMProvider(provider)
+	if err != nil {
+		logger.Error(err)
+		return "", false
+	}
+
+	repository := getSCMEventRepository(provider, body)
+	if repository == "" {
+		return "", false
+	}
+
+	var links []model.GHRepoLink
+	if err := s.DB.Where(model.GHRepoLink{Provider: provider, Repository: repository}).Find(&links).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get the links of %s", repository))
+		return "", false
+	}
+
+	for _, l := range links {
+		if l.WebhookSecret != "" && scm.VerifyWebhook(header, body, l.WebhookSecret) {
+			return l.ProjectID, true
+		}
+	}
+
+	return "", false
+}
+
+// getSCMWebhookSecret returns the secret of the webhook of a gitlab or bitbucket repository linked by p, a new secret is
+// generated the first time p links the repository
+func (s *Server) getSCMWebhookSecret(p *model.Project, provider model.SCMProviderType, repository string) (string, error) {
+	var link model.GHRepoLink
+	r := s.DB.Where(model.GHRepoLink{ProjectID: p.ID, Provider: provider, Repository: repository}).Where("webhook_secret != ''").First(&link)
+	if r.Error == nil {
+		return link.WebhookSecret, nil
+	}
+
+	if !r.RecordNotFound() {
+		return "", errors.Wrapf(r.Error, "failed to get the webhook secret of %s for project-%s", repository, p.ID)
+	}
+
+	return generateWebhookSecret()
+}
+
+// GetSCMRepositories returns the repositories of provider that p can link
+func (s *Server) GetSCMRepositories(p *model.Project, provider model.SCMProviderType) ([]*SCMRepository, error) {
+	scm, err := s.GetSCMProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return scm.ListRepositories(p)
+}
+
+// syncManifestFromSCM updates the manifest of the services linked by projectID to a branch of a gitlab or bitbucket
+// repository that are affected by the changed files, and deploys them if their link has auto deploy enabled
+func (s *Server) syncManifestFromSCM(provider model.SCMProviderType, projectID, repository, branch, commit, author string, files []string) error {
+	links, err := s.getRefLinks(model.GHRepoLink{Provider: provider, Repository: repository, ProjectID: projectID}, branch)
+	if err != nil {
+		return errors.Wrapf(err, "failed to query for the links of %s", repository)
+	}
+
+	owner, name := splitRepository(repository)
+	for i := range links {
+		if err := s.syncLinkedServices(&links[i], owner, name, branch, commit, author, files); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to sync the services of ghrepolink-%s", links[i].ID))
+		}
+	}
+
+	return nil
+}
+
+// getRefLinks returns the links of the repository of query whose branch is ref or matches ref
+func (s *Server) getRefLinks(query model.GHRepoLink, ref string) ([]model.GHRepoLink, error) {
+	var links []model.GHRepoLink
+	if err := s.DB.Where(query).Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	result := []model.GHRepoLink{}
+	for _, l := range links {
+		if l.MatchesRef(ref) {
+			result = append(result, l)
+		}
+	}
+
+	return result, nil
+}
+
+// getChangedFiles returns the files changed by the commits of a push. It returns nil if they are unknown, when the push
+// has more commits than the payload or none at all
+func getChangedFiles(commits []SCMCommit, total int) []string {
+	if len(commits) == 0 || len(commits) >= maxPushCommits || total > len(commits) {
+		return nil
+	}
+
+	files := []string{}
+	seen := map[string]bool{}
+	for _, c := range commits {
+		for _, changes := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range changes {
+				if !seen[f] {
+					seen[f] = true
+					files = append(files, f)
+				}
+			}
+		}
+	}
+
+	return files
+}
+
+// getLinkProvider returns the source control provider of the linked repository
+func (s *Server) getLinkProvider(link *model.GHRepoLink) (SCMProvider, error) {
+	return s.GetSCMProvider(link.GetProvider())
+}
+
+// getLinkCredentials returns the credentials of the project that linked a gitlab or bitbucket repository
+func (s *Server) getLinkCredentials(link *model.GHRepoLink) (*model.SCMCredentials, error) {
+	p, err := s.getProjectByID(link.ProjectID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get project-%s of ghrepolink-%s", link.ProjectID, link.ID)
+	}
+
+	if p == nil {
+		return nil, errors.Wrapf(model.ErrNotFound, "project-%s of ghrepolink-%s not found in the DB", link.ProjectID, link.ID)
+	}
+
+	return getSCMCredentials(p, link.GetProvider())
+}
+
+// getSCMCredentials returns the credentials of provider in the settings of p
+func getSCMCredentials(p *model.Project, provider model.SCMProviderType) (*model.SCMCredentials, error) {
+	var credentials *model.SCMCredentials
+	if p.LoadedSettings != nil {
+		switch provider {
+		case model.GitlabProvider:
+			credentials = p.LoadedSettings.Gitlab
+		case model.BitbucketProvider:
+			credentials = p.LoadedSettings.Bitbucket
+		}
+	}
+
+	if credentials == nil {
+		return nil, model.ErrProjectNotLinkedToSCM
+	}
+
+	return credentials, nil
+}
+
+// sendSCMRequest sends a request to the api of a source control provider. The body of the response must be closed
+// by the caller, an error is returned if the status code is not 2xx
+func sendSCMRequest(req *http.Request) (*http.Response, error) {
+	return doSCMRequest(scmClient, req)
+}
+
+// downloadSCMArchive sends a request for the archive of a repository, it has the longer timeout of archiveClient
+func downloadSCMArchive(req *http.Request) (*http.Response, error) {
+	return doSCMRequest(archiveClient, req)
+}
+
+func doSCMRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s failed", req.Method, req.URL.Path)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s %s failed: %s", req.Method, req.URL.Path, res.Status)
+	}
+
+	return res, nil
+}
+
+// newSCMRequest returns a request with body encoded as json, if it's set
+func newSCMRequest(method, url string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode the request")
+		}
+
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the request to %s", url)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
+
+// decodeSCMResponse sends req and decodes the json response into result
+func decodeSCMResponse(req *http.Request, result interface{}) error {
+	res, err := sendSCMRequest(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return errors.Wrapf(err, "failed to decode the response of %s", req.URL.Path)
+	}
+
+	return nil
+}
+
+// validHubSignature returns true if signature is the HMAC of body with the webhook secret, in the form
+// sha256=<hex> or sha1=<hex>. Every delivery is invalid if the secret is not configured
+func validHubSignature(secret, body []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	var h func() hash.Hash
+	switch parts[0] {
+	case "sha256":
+		h = sha256.New
+	case "sha1":
+		h = sha1.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(h, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// splitRepository returns the owner and the name of a repository full name. The owner of a gitlab repository can
+// include subgroups
+func splitRepository(fullName string) (string, string) {
+	i := strings.LastIndex(fullName, "/")
+	if i < 0 {
+		return "", fullName
+	}
+
+	return fullName[:i], fullName[i+1:]
+}
+
+// getShortRefName returns the name of a branch or a tag without the refs/heads/ or refs/tags/ prefix
+func getShortRefName(ref string) string {
+	return model.GetRefName(ref)
+}
+
+// isBranchOrTag returns true if ref is the ref of a branch or a tag
+func isBranchOrTag(ref string) bool {
+	return strings.HasPrefix(ref, model.BranchRefPrefix) || strings.HasPrefix(ref, model.TagRefPrefix)
+}
@@ -0,0 +1,448 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+// fakeSCMServer serves the manifest of the linked repositories and records the commit statuses
+type fakeSCMServer struct {
+	t        *testing.T
+	files    map[string]string
+	statuses []map[string]string
+	auth     func(r *http.Request) bool
+	mutex    sync.Mutex
+}
+
+func (f *fakeSCMServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.auth(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		status := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			f.t.Error(err)
+		}
+
+		status["path"] = r.URL.Path
+		f.mutex.Lock()
+		f.statuses = append(f.statuses, status)
+		f.mutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	content, ok := f.files[r.URL.RequestURI()]
+	if !ok {
+		f.t.Logf("unexpected request: %s", r.URL.RequestURI())
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	fmt.Fprint(w, content)
+}
+
+func (f *fakeSCMServer) getStatuses() []map[string]string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]map[string]string{}, f.statuses...)
+}
+
+func createSCMProject(t *testing.T, s *Server, settings string) (*model.Project, *model.User) {
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: base64.StdEncoding.EncodeToString([]byte(settings))}
+	if err := s.DB.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	s.DB.Create(u)
+
+	loaded, err := s.getProjectByID(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loaded, u
+}
+
+func createSCMLinkedService(t *testing.T, s *Server, p *model.Project, u *model.User, link *model.GHRepoLink) *model.Service {
+	if err := s.DB.Create(link).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &model.Service{Manifest: httpsService, Name: "service", GHRepoLinkID: link.ID}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	return svc
+}
+
+func getTestManifest(t *testing.T) string {
+	manifest, err := base64.StdEncoding.DecodeString(httpsService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(manifest)
+}
+
+func TestGitlabPush(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	fake := &fakeSCMServer{
+		t:     t,
+		files: map[string]string{"/api/v4/projects/7/repository/files/okteto.yaml/raw?ref=c3": getTestManifest(t)},
+		auth:  func(r *http.Request) bool { return r.Header.Get("PRIVATE-TOKEN") == "glpat-123" },
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, u := createSCMProject(t, &s, fmt.Sprintf("provider:\n  type: demo\nadministrators:\n  - user1@example.com\ngitlab:\n  url: %s\n  token: glpat-123\n", server.URL))
+	link := &model.GHRepoLink{Provider: model.GitlabProvider, Repository: "okteto/app", RepositoryID: 7, Branch: "refs/heads/master", Manifest: "okteto.yaml", ProjectID: p.ID}
+	svc := createSCMLinkedService(t, &s, p, u, link)
+
+	ignored := `{"ref":"refs/heads/other","checkout_sha":"b2","user_username":"cindy","project":{"id":7,"path_with_namespace":"okteto/app"}}`
+	if err := s.handleGitlabEvent(p.ID, gitlabPushEvent, []byte(ignored)); err != nil {
+		t.Fatal(err)
+	}
+
+	push := `{"ref":"refs/heads/master","checkout_sha":"c3","user_username":"cindy","project":{"id":7,"path_with_namespace":"okteto/app"}}`
+	if err := s.handleGitlabEvent(p.ID, gitlabPushEvent, []byte(push)); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, appErr := s.GetServiceByID(svc.ID)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	if updated.Commit != "c3" {
+		t.Errorf("the manifest wasn't updated at the pushed commit: %s", updated.Commit)
+	}
+
+	provider := &GitlabSCM{s: &s}
+	status := &SCMStatus{State: ghFailureState, TargetURL: "https://okteto.com/logs", Description: "failed", Context: "okteto/testproject/service"}
+	if err := provider.CreateStatus(link, "c3", status); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, st := range fake.getStatuses() {
+		if st["state"] == "failed" && st["name"] == status.Context {
+			found = true
+			if st["path"] != "/api/v4/projects/7/statuses/c3" {
+				t.Errorf("the status was created in %s", st["path"])
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("the commit status wasn't created: %+v", fake.getStatuses())
+	}
+}
+
+func TestBitbucketPush(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	fake := &fakeSCMServer{
+		t:     t,
+		files: map[string]string{"/repositories/okteto/app/src/c3/okteto.yaml": getTestManifest(t)},
+		auth: func(r *http.Request) bool {
+			username, password, ok := r.BasicAuth()
+			return ok && username == "cindy" && password == "app-password"
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	bitbucketAPIURL = server.URL
+	defer func() { bitbucketAPIURL = "https://api.bitbucket.org/2.0" }()
+
+	p, u := createSCMProject(t, &s, "provider:\n  type: demo\nadministrators:\n  - user1@example.com\nbitbucket:\n  username: cindy\n  token: app-password\n")
+	link := &model.GHRepoLink{Provider: model.BitbucketProvider, Repository: "okteto/app", Branch: "refs/heads/master", Manifest: "okteto.yaml", ProjectID: p.ID}
+	svc := createSCMLinkedService(t, &s, p, u, link)
+
+	push := `{"actor":{"nickname":"cindy"},"repository":{"full_name":"okteto/app"},"push":{"changes":[{"new":null},{"new":{"type":"tag","name":"v1","target":{"hash":"b2"}}},{"new":{"type":"branch","name":"master","target":{"hash":"c3"}}}]}}`
+	if err := s.handleBitbucketEvent(p.ID, bitbucketPushEvent, []byte(push)); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, appErr := s.GetServiceByID(svc.ID)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	if updated.Commit != "c3" {
+		t.Errorf("the manifest wasn't updated at the pushed commit: %s", updated.Commit)
+	}
+
+	provider := &BitbucketSCM{s: &s}
+	context := "okteto/a-very-long-namespace-name/a-very-long-service-name"
+	if err := provider.CreateStatus(link, "c3", &SCMStatus{State: ghSuccessState, TargetURL: "https://okteto.com/logs", Context: context}); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, st := range fake.getStatuses() {
+		if st["name"] == context {
+			found = true
+			if st["state"] != "SUCCESSFUL" || len(st["key"]) > maxBitbucketStatusKey || st["path"] != "/repositories/okteto/app/commit/c3/statuses/build" {
+				t.Errorf("the status wasn't valid: %+v", st)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("the commit status wasn't created: %+v", fake.getStatuses())
+	}
+
+	if _, err := (&GitlabSCM{s: &s}).ListRepositories(p); err != model.ErrProjectNotLinkedToSCM {
+		t.Errorf("the gitlab repositories were listed without credentials: %v", err)
+	}
+}
+
+func TestLinkToggleAutoDeploy(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	fake := &fakeSCMServer{
+		t:     t,
+		files: map[string]string{"/repositories/okteto/app": `{"slug":"app","full_name":"okteto/app","mainbranch":{"name":"master"}}`},
+		auth:  func(r *http.Request) bool { return true },
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	bitbucketAPIURL = server.URL
+	defer func() { bitbucketAPIURL = "https://api.bitbucket.org/2.0" }()
+
+	p, u := createSCMProject(t, &s, "provider:\n  type: demo\nadministrators:\n  - user1@example.com\nbitbucket:\n  username: cindy\n  token: app-password\n")
+	svc := &model.Service{Manifest: httpsService, Name: "service"}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	secret := ""
+	for _, autoDeploy := range []bool{true, false, true} {
+		link, err := s.LinkGHRepositoryToService(p, svc.ID, model.BitbucketProvider, "okteto", "app", "", "okteto.yaml", "", nil, autoDeploy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		links := []model.GHRepoLink{}
+		if err := db.Find(&links).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if len(links) != 1 || links[0].AutoDeploy != autoDeploy {
+			t.Fatalf("expected one link with auto deploy %t, got %+v", autoDeploy, links)
+		}
+
+		if secret == "" {
+			secret = link.WebhookSecret
+		}
+
+		if link.WebhookSecret == "" || links[0].WebhookSecret != secret {
+			t.Errorf("the webhook secret of the link changed: %s %s", secret, links[0].WebhookSecret)
+		}
+	}
+}
+
+func TestVerifySCMWebhooks(t *testing.T) {
+	s := &Server{}
+	body := []byte(`{"ref":"refs/heads/master"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name     string
+		provider model.SCMProviderType
+		header   http.Header
+		expected bool
+	}{
+		{name: "gitlab", provider: model.GitlabProvider, header: http.Header{"X-Gitlab-Token": []string{"secret"}}, expected: true},
+		{name: "gitlab-wrong-token", provider: model.GitlabProvider, header: http.Header{"X-Gitlab-Token": []string{"other"}}, expected: false},
+		{name: "gitlab-missing-token", provider: model.GitlabProvider, header: http.Header{}, expected: false},
+		{name: "bitbucket", provider: model.BitbucketProvider, header: http.Header{"X-Hub-Signature": []string{signature}}, expected: true},
+		{name: "bitbucket-missing-signature", provider: model.BitbucketProvider, header: http.Header{}, expected: false},
+		{name: "github", provider: model.GithubProvider, header: http.Header{"X-Hub-Signature-256": []string{signature}}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := s.GetSCMProvider(tt.provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := provider.VerifyWebhook(tt.header, body, "secret"); got != tt.expected {
+				t.Errorf("expected %t got %t", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := s.GetSCMProvider("svn"); err == nil {
+		t.Errorf("an unknown provider was returned")
+	}
+}
+
+func TestVerifySCMWebhookOfProjects(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	links := []*model.GHRepoLink{
+		{Provider: model.GitlabProvider, Repository: "okteto/app", Branch: "refs/heads/master", ProjectID: "p1", WebhookSecret: "secret1"},
+		{Provider: model.GitlabProvider, Repository: "okteto/app", Branch: "refs/heads/master", ProjectID: "p2", WebhookSecret: "secret2"},
+		{Provider: model.GitlabProvider, Repository: "okteto/legacy", Branch: "refs/heads/master", ProjectID: "p3"},
+	}
+	for _, l := range links {
+		if err := db.Create(l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := []byte(`{"ref":"refs/heads/master","project":{"path_with_namespace":"okteto/app"}}`)
+	legacy := []byte(`{"ref":"refs/heads/master","project":{"path_with_namespace":"okteto/legacy"}}`)
+	tests := []struct {
+		name    string
+		body    []byte
+		token   string
+		project string
+		ok      bool
+	}{
+		{name: "first-project", body: app, token: "secret1", project: "p1", ok: true},
+		{name: "second-project", body: app, token: "secret2", project: "p2", ok: true},
+		{name: "wrong-token", body: app, token: "other", ok: false},
+		{name: "without-secret", body: legacy, token: "", ok: false},
+		{name: "secret-of-another-repository", body: legacy, token: "secret1", ok: false},
+		{name: "without-repository", body: []byte(`{"ref":"refs/heads/master"}`), token: "secret1", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, ok := s.VerifySCMWebhook(model.GitlabProvider, http.Header{"X-Gitlab-Token": []string{tt.token}}, tt.body)
+			if ok != tt.ok || project != tt.project {
+				t.Errorf("expected %t %s got %t %s", tt.ok, tt.project, ok, project)
+			}
+		})
+	}
+}
+
+func Test_validHubSignature(t *testing.T) {
+	sign := func(h func() hash.Hash, secret, body string) string {
+		mac := hmac.New(h, []byte(secret))
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	body := []byte("payload")
+	valid := "sha1=" + sign(sha1.New, "secret", "payload")
+
+	if !validHubSignature([]byte("secret"), body, valid) {
+		t.Errorf("a valid signature was rejected")
+	}
+
+	if validHubSignature(nil, body, valid) {
+		t.Errorf("a signature was accepted without a secret")
+	}
+
+	for _, s := range []string{"", "sha1", "md5=abcd", "sha1=zz", "sha256=" + sign(sha1.New, "secret", "payload")} {
+		if validHubSignature([]byte("secret"), body, s) {
+			t.Errorf("the signature '%s' was accepted", s)
+		}
+	}
+}
+
+func Test_splitRepository(t *testing.T) {
+	tests := []struct {
+		fullName string
+		owner    string
+		name     string
+	}{
+		{fullName: "okteto/app", owner: "okteto", name: "app"},
+		{fullName: "okteto/backend/api", owner: "okteto/backend", name: "api"},
+		{fullName: "app", owner: "", name: "app"},
+	}
+
+	for _, tt := range tests {
+		owner, name := splitRepository(tt.fullName)
+		if owner != tt.owner || name != tt.name {
+			t.Errorf("%s: expected %s %s got %s %s", tt.fullName, tt.owner, tt.name, owner, name)
+		}
+	}
+
+	if key := getBitbucketStatusKey(strings.Repeat("a", 41)); len(key) != maxBitbucketStatusKey {
+		t.Errorf("the key wasn't shortened: %s", key)
+	}
+}
+
+func Test_getChangedFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		commits  []SCMCommit
+		total    int
+		expected []string
+	}{
+		{
+			name:     "commits",
+			commits:  []SCMCommit{{Added: []string{"a.go"}, Modified: []string{"b.go"}}, {Modified: []string{"b.go"}, Removed: []string{"c.go"}}},
+			total:    2,
+			expected: []string{"a.go", "b.go", "c.go"},
+		},
+		{
+			name:     "no-files",
+			commits:  []SCMCommit{{}},
+			total:    1,
+			expected: []string{},
+		},
+		{
+			name:     "no-commits",
+			expected: nil,
+		},
+		{
+			name:     "truncated",
+			commits:  []SCMCommit{{Added: []string{"a.go"}}},
+			total:    25,
+			expected: nil,
+		},
+		{
+			name:     "max-commits",
+			commits:  make([]SCMCommit, maxPushCommits),
+			total:    maxPushCommits,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getChangedFiles(tt.commits, tt.total); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -24,9 +24,14 @@ type Server struct {
 	Metrics           *http.Server
 	Hub               *events.Hub
 	Email             *EmailProvider
+	Images            ImageResolver
+	Builder           Builder
 	DNSProvider       *model.DNSProvider
 	Listener          *pq.Listener
 	pendingOperations sync.WaitGroup
+	previewsMutex     sync.Mutex
+	reports           map[string][]func()
+	reportsMutex      sync.Mutex
 	DB                *gorm.DB
 }
 
@@ -37,6 +42,7 @@ func (s *Server) Start() {
 		go s.Hub.Run()
 		go s.processEvents()
 		go s.processGithubEvents()
+		go s.processWebhookDeliveries()
 		go s.sync()
 		go s.cleanExpiredServices()
 
@@ -10,6 +10,7 @@ import (
 
 	"bitbucket.org/okteto/okteto/backend/config"
 	"bitbucket.org/okteto/okteto/backend/model"
+	K8Deployment "bitbucket.org/okteto/okteto/backend/providers/k8/deployment"
 	K8Service "bitbucket.org/okteto/okteto/backend/providers/k8/service"
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
@@ -19,6 +20,9 @@ const (
 	maxDemoServices = 5
 )
 
+// getReplicaStatus returns the current replica count of a deployed service, it calls the cluster
+var getReplicaStatus = K8Deployment.GetReplicaStatus
+
 // CreateService validates the compose and the device, and if valid, saves it to the
 // DB.
 func (s *Server) CreateService(project *model.Project, service *model.Service, user *model.User) (*model.Service, *model.AppError) {
@@ -37,11 +41,15 @@ func (s *Server) CreateService(project *model.Project, service *model.Service, u
 	}
 	service.ProjectID = project.ID
 
-	m, appErr := model.ParseEncodedManifest(service.Manifest)
+	m, appErr := buildService(service, project, true, project.LoadedSettings)
 	if appErr != nil {
 		return nil, appErr
 	}
 
+	if appErr := s.validateLoadBalancerPorts(m, project); appErr != nil {
+		return nil, appErr
+	}
+
 	activity := model.Activity{
 		ActorID:   user.ID,
 		ServiceID: service.ID,
@@ -63,6 +71,7 @@ func (s *Server) CreateService(project *model.Project, service *model.Service, u
 	}
 
 	go s.newServiceNotification(user)
+	go s.notifyServiceEvent(model.ServiceCreatedEvent, project, service, nil, user)
 	return service, nil
 }
 
@@ -103,7 +112,7 @@ func (s *Server) GetServiceAndActivities(project *model.Project, serviceID strin
 		return nil, appErr
 	}
 
-	result = s.DB.Raw("select a.id, a.created_at, a.updated_at, a.type, a.status, u.email as actor_email from activities as a LEFT JOIN users as u on a.actor_id = u.id WHERE service_id = ? ORDER BY a.updated_at ASC", serviceID)
+	result = s.DB.Raw("select a.id, a.created_at, a.updated_at, a.type, a.status, COALESCE(a.\"commit\", ''), u.email as actor_email from activities as a LEFT JOIN users as u on a.actor_id = u.id WHERE service_id = ? ORDER BY a.updated_at ASC", serviceID)
 	rows, err := result.Rows()
 
 	if err != nil {
@@ -115,7 +124,7 @@ func (s *Server) GetServiceAndActivities(project *model.Project, serviceID strin
 	var activities []model.Activity
 	for rows.Next() {
 		var activity model.Activity
-		err := rows.Scan(&activity.ID, &activity.CreatedAt, &activity.UpdatedAt, &activity.Type, &activity.Status, &activity.ActorEmail)
+		err := rows.Scan(&activity.ID, &activity.CreatedAt, &activity.UpdatedAt, &activity.Type, &activity.Status, &activity.Commit, &activity.ActorEmail)
 		if err != nil {
 			return nil, &model.AppError{Status: 500, Code: model.InternalServerError, Message: err.Error()}
 		}
@@ -123,6 +132,15 @@ func (s *Server) GetServiceAndActivities(project *model.Project, serviceID strin
 		activities = append(activities, activity)
 	}
 
+	images, err := s.getActivityImages(activities)
+	if err != nil {
+		return nil, &model.AppError{Status: 500, Code: model.InternalServerError, Message: err.Error()}
+	}
+
+	for i := range activities {
+		activities[i].Images = images[activities[i].ID]
+	}
+
 	service.Activities = activities
 	s.buildLinks(&service, project)
 	return &service, nil
@@ -209,6 +227,8 @@ func (s *Server) updateServiceActivity(project *model.Project, serviceID string,
 		return errors.Wrap(result.Error, fmt.Sprintf("failed to update service-%s is in a bad state", serviceID))
 	}
 
+	// the activity might have blocked an auto deploy of the service
+	s.runAutoDeploys()
 	return nil
 }
 
@@ -232,6 +252,7 @@ func (s *Server) StartService(project *model.Project, serviceID string, user *mo
 		ServiceID: serviceID,
 		Type:      model.Deployed,
 		Status:    model.InProgress,
+		Commit:    service.Commit,
 	}
 
 	result := s.DB.Create(&activity)
@@ -245,11 +266,13 @@ func (s *Server) StartService(project *model.Project, serviceID string, user *mo
 	}
 
 	go s.deployedServiceNotification(user)
+	go s.notifyServiceEvent(model.DeployStartedEvent, project, service, &activity, user)
 
 	// launch deploy in a goroutine
 	go func(p *model.Project, d *model.Service, activityID string) {
 		s.pendingOperations.Add(1)
 		defer s.pendingOperations.Done()
+		s.reportActivity(activityID)
 		err := s.deploy(d, p, activityID)
 
 		var activityStatus = model.Completed
@@ -263,11 +286,26 @@ func (s *Server) StartService(project *model.Project, serviceID string, user *mo
 			activityStatus = model.Completed
 		}
 
-		dns := s.buildProjectDNS(&p.DNSName, p.LoadedSettings)
+		dns := s.buildProjectDNS(&getServiceProject(p, d).DNSName, p.LoadedSettings)
 		err = s.updateServiceActivity(p, d.ID, activityID, activityStatus, dns)
 		if err != nil {
 			logger.Error(errors.Wrapf(err, "failed to update the service-%s activity-%s after the deploy operation was %s", d.ID, activityID, activityStatus))
 		}
+
+		event := model.DeploySucceededEvent
+		if activityStatus == model.Failed {
+			event = model.DeployFailedEvent
+		}
+
+		s.notifyServiceEvent(event, p, d, &model.Activity{Model: model.Model{ID: activityID}, Type: model.Deployed, Status: activityStatus, Commit: d.Commit}, user)
+		if activityStatus == model.Failed {
+			s.notifyFailedActivity(p, d, activityID, model.Deployed, user)
+		}
+
+		s.reportActivity(activityID)
+		if d.PreviewID != "" {
+			s.updatePreviewComment(d.PreviewID)
+		}
 	}(project, service, activity.ID)
 	return nil
 }
@@ -305,6 +343,7 @@ func (s *Server) EnableDevMode(project *model.Project, serviceID string, user *m
 	}
 
 	go s.devDeployedServiceNotification(user)
+	go s.notifyServiceEvent(model.DevModeEnabledEvent, project, service, &activity, user)
 
 	go func(p *model.Project, d *model.Service, activityID string) {
 		s.pendingOperations.Add(1)
@@ -322,11 +361,15 @@ func (s *Server) EnableDevMode(project *model.Project, serviceID string, user *m
 			activityStatus = model.Completed
 		}
 
-		dns := s.buildProjectDNS(&p.DNSName, p.LoadedSettings)
+		dns := s.buildProjectDNS(&getServiceProject(p, d).DNSName, p.LoadedSettings)
 		err = s.updateServiceActivity(p, d.ID, activityID, activityStatus, dns)
 		if err != nil {
 			logger.Error(errors.Wrapf(err, "failed to update the service-%s activity-%s after the dev mode operation was %s", d.ID, activityID, activityStatus))
 		}
+
+		if activityStatus == model.Failed {
+			s.notifyFailedActivity(p, d, activityID, model.DevDeployed, user)
+		}
 	}(project, service, activity.ID)
 	return nil
 }
@@ -386,23 +429,54 @@ func (s *Server) DeleteService(project *model.Project, serviceID string, user *m
 		} else {
 			log.Printf("deleted service-%s activity-%s", d.ID, activityID)
 		}
+
+		s.notifyServiceEvent(model.ServiceDestroyedEvent, p, d, &model.Activity{Model: model.Model{ID: activityID}, Type: model.Destroyed, Status: activityStatus}, user)
+		if activityStatus == model.Failed {
+			s.notifyFailedActivity(p, d, activityID, model.Destroyed, user)
+		}
 	}(project, service, activity.ID)
 
 	return nil
 }
 
-// UpdateManifest updates the service's manifest.
-func (s *Server) UpdateManifest(projectID, serviceID, manifest string, actorID string, updateLog string) *model.AppError {
+// UpdateManifest updates the service's manifest. The overlay is only replaced when overlay is set, an empty overlay
+// removes it. The branch and commit of the service are only updated when they are set in update.
+func (s *Server) UpdateManifest(projectID, serviceID string, update *model.Service, overlay *string, actorID string, updateLog string) *model.AppError {
+	project, err := s.getProjectByID(projectID)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to load project-%s", projectID))
+		return &model.AppError{Status: 500, Code: model.InternalServerError, Message: err.Error()}
+	}
 
-	m, appErr := model.ParseEncodedManifest(manifest)
+	if project == nil {
+		// the policies and the variables are checked again when the service is deployed
+		project = &model.Project{Model: model.Model{ID: projectID}}
+	}
+
+	svc, appErr := s.getService(project, serviceID)
 	if appErr != nil {
 		return appErr
 	}
 
-	svc := model.Service{Model: model.Model{ID: serviceID}}
-	result := s.DB.Model(&svc).
+	svc.Manifest = update.Manifest
+	if overlay != nil {
+		svc.Overlay = *overlay
+	}
+	svc.Branch = orDefault(update.Branch, svc.Branch)
+	svc.Commit = orDefault(update.Commit, svc.Commit)
+
+	m, appErr := buildService(svc, project, true, project.LoadedSettings)
+	if appErr != nil {
+		return appErr
+	}
+
+	if appErr := s.validateLoadBalancerPorts(m, project); appErr != nil {
+		return appErr
+	}
+
+	result := s.DB.Model(svc).
 		Where("id = ?", serviceID).Where("project_id = ?", projectID).
-		Updates(model.Service{Manifest: manifest, Name: m.Name})
+		Updates(map[string]interface{}{"manifest": svc.Manifest, "name": m.Name, "overlay": svc.Overlay, "branch": svc.Branch, "commit": svc.Commit})
 
 	if result.Error != nil {
 		// handle service_unique_name
@@ -418,6 +492,7 @@ func (s *Server) UpdateManifest(projectID, serviceID, manifest string, actorID s
 		ServiceID: serviceID,
 		Type:      model.Updated,
 		Status:    model.Completed,
+		Commit:    update.Commit,
 	}
 
 	result = s.DB.Create(&activity)
@@ -426,9 +501,34 @@ func (s *Server) UpdateManifest(projectID, serviceID, manifest string, actorID s
 	}
 
 	s.addLog(activity.ID, updateLog)
+	s.reportActivity(activity.ID)
 	return nil
 }
 
+// getProjectByID returns the project with its settings loaded, or nil if the project doesn't exist
+func (s *Server) getProjectByID(projectID string) (*model.Project, error) {
+	var p model.Project
+	r := s.DB.Where("id = ?", projectID).First(&p)
+	if r.Error != nil {
+		if r.RecordNotFound() {
+			return nil, nil
+		}
+
+		return nil, r.Error
+	}
+
+	if p.Settings != "" {
+		settings, appErr := model.ParseProjectSettings(p.Settings)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		p.LoadedSettings = settings
+	}
+
+	return &p, nil
+}
+
 // GetActivityLogs returns the logs of a given identity, or a 404 if the logs don't exist
 func (s *Server) GetActivityLogs(project *model.Project, serviceID string, activityID string) ([]model.ActivityLog, *model.AppError) {
 
@@ -508,18 +608,46 @@ func (s *Server) buildServiceEndpoints(service *model.Service, project *model.Pr
 		}
 		endpoint = target
 	}
-	for _, port := range service.GetLoadBalancerPorts() {
-		if port == "443" {
+	for _, port := range service.GetServicePorts(service.GetLoadBalancerPorts()) {
+		if port.Number == 443 && port.Protocol == model.TCP {
 			endpoints = append(endpoints, fmt.Sprintf("https://%s", endpoint))
-		} else if port == "80" {
+		} else if port.Number == 80 && port.Protocol == model.TCP {
 			endpoints = append(endpoints, fmt.Sprintf("http://%s", endpoint))
 		} else {
-			endpoints = append(endpoints, fmt.Sprintf("%s:%s", endpoint, port))
+			endpoints = append(endpoints, fmt.Sprintf("%s:%d", endpoint, port.Number))
 		}
 	}
 	return endpoints
 }
 
+func (s *Server) buildReplicaStatus(service *model.Service, project *model.Project, status model.ServiceStatus) *model.ReplicaStatus {
+	if status == model.DevDeployedService {
+		// dev mode always runs a single replica without autoscaling
+		service.Replicas = 1
+		service.Autoscale = nil
+	}
+	replicas := &model.ReplicaStatus{Desired: service.Replicas}
+	if service.IsAutoscaled() {
+		replicas.Desired = service.Autoscale.Min
+		replicas.Min = service.Autoscale.Min
+		replicas.Max = service.Autoscale.Max
+	}
+	if status != model.DeployedService && status != model.DevDeployedService {
+		return replicas
+	}
+	e := s.buildEnvironment(project)
+	e.Provider.LoadDefaultCluster()
+	current, err := getReplicaStatus(service, e)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get the replica status of service-%s", service.ID))
+		return replicas
+	}
+	if current == nil {
+		return replicas
+	}
+	return current
+}
+
 func (s *Server) buildProjectDNS(projectDNSName *string, settings *model.ProjectSettings) *string {
 	var dns string
 	if settings == nil || settings.Provider == nil {
@@ -538,19 +666,32 @@ func (s *Server) buildProjectDNS(projectDNSName *string, settings *model.Project
 func (s *Server) buildLinks(service *model.Service, project *model.Project) {
 	service.Links = buildServiceLinks(service.ProjectID, service.ID)
 
-	m, appErr := buildService(service.ID, service.Manifest)
-	if appErr != nil {
-		logger.Error(errors.Wrap(appErr, "parse error when trying to build service links"))
-		return
-	}
-
 	if project.LoadedSettings == nil {
 		settings, _ := model.ParseProjectSettings(project.Settings)
 		settings.Provider.LoadDefaultCluster()
 		project.LoadedSettings = settings
 	}
 
-	service.Endpoints = s.buildServiceEndpoints(m, project, service.DNS)
+	m, appErr := buildService(service, project, false, nil)
+	if appErr != nil {
+		logger.Error(errors.Wrap(appErr, "parse error when trying to build service links"))
+		return
+	}
+
+	namespace := getServiceProject(project, service)
+	service.Endpoints = s.buildServiceEndpoints(m, namespace, service.DNS)
 	return
 
 }
+
+// LoadReplicaStatus sets the current replica count of a service. It calls the cluster, so it's only loaded for the
+// endpoint of a single service and not for the lists or the notifications of the service
+func (s *Server) LoadReplicaStatus(service *model.Service, project *model.Project) {
+	m, appErr := buildService(service, project, false, nil)
+	if appErr != nil {
+		logger.Error(errors.Wrapf(appErr, "failed to load the replica status of service-%s", service.ID))
+		return
+	}
+
+	service.ReplicaStatus = s.buildReplicaStatus(m, getServiceProject(project, service), service.Status)
+}
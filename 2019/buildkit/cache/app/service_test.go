@@ -1,6 +1,7 @@
 package app
 
 import (
+	"encoding/base64"
 	"fmt"
 	"reflect"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	"github.com/spf13/viper"
 
 	"bitbucket.org/okteto/okteto/backend/model"
+	K8Deployment "bitbucket.org/okteto/okteto/backend/providers/k8/deployment"
 	"bitbucket.org/okteto/okteto/backend/store"
 )
 
@@ -132,6 +134,33 @@ func TestCreatServiceQuotaNoForOktetos(t *testing.T) {
 
 }
 
+func TestUpdateServiceRemovesOverlay(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+	p := &model.Project{Model: model.Model{ID: "1-2-3-4"}, Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	overlay := base64.StdEncoding.EncodeToString([]byte("replicas: 2"))
+
+	u, _ := s.GetOrCreateUser("user@example.com", true, "")
+	created, appErr := s.CreateService(p, &model.Service{Manifest: httpsService, Overlay: overlay}, u)
+	if appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	if appErr := s.UpdateManifest(p.ID, created.ID, &model.Service{Manifest: httpsService}, new(string), u.ID, "overlay removed"); appErr != nil {
+		t.Fatalf("Update failed %+v", appErr)
+	}
+
+	svc, appErr := s.GetServiceByID(created.ID)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	if svc.Overlay != "" {
+		t.Errorf("the overlay wasn't removed: %s", svc.Overlay)
+	}
+}
+
 func TestUpdateService(t *testing.T) {
 	db := store.NewMemoryStore()
 	defer db.Close()
@@ -145,7 +174,7 @@ func TestUpdateService(t *testing.T) {
 		t.Fatalf("Create failed %+v", err)
 	}
 
-	s.UpdateManifest(p.ID, created.ID, httpService, u.ID, "manifest updated")
+	s.UpdateManifest(p.ID, created.ID, &model.Service{Manifest: httpService}, nil, u.ID, "manifest updated")
 	if err != nil {
 		t.Fatalf("Update failed %+v", err)
 	}
@@ -317,6 +346,54 @@ func TestGetServiceAndActivities(t *testing.T) {
 	}
 }
 
+func TestReplicaStatusIsOnlyLoadedOnRequest(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+	svc := &model.Service{Manifest: httpsService, Name: "service"}
+	p := &model.Project{Model: model.Model{ID: "project-1"}, Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	p.LoadedSettings, _ = model.ParseProjectSettings(p.Settings)
+	u := &model.User{Email: "user@example.com"}
+	db.Create(&u)
+
+	calls := 0
+	getReplicaStatus = func(svc *model.Service, e *model.Environment) (*model.ReplicaStatus, error) {
+		calls++
+		return &model.ReplicaStatus{Desired: 1, Current: 1, Ready: 1}, nil
+	}
+	defer func() { getReplicaStatus = K8Deployment.GetReplicaStatus }()
+
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	if appErr := s.StartService(p, svc.ID, u); appErr != nil {
+		t.Fatalf("Start failed %+v", appErr)
+	}
+
+	if err := s.waitUntil(p, svc.ID, model.DeployedService); err != nil {
+		t.Fatal(err)
+	}
+
+	get, appErr := s.GetServiceAndActivities(p, svc.ID)
+	if appErr != nil {
+		t.Fatalf("Get failed %+v", appErr)
+	}
+
+	if calls != 0 || get.ReplicaStatus != nil {
+		t.Fatalf("the replica status was loaded with the service: %d calls", calls)
+	}
+
+	s.LoadReplicaStatus(get, p)
+	if calls != 1 {
+		t.Fatalf("expected a call to the cluster, got %d", calls)
+	}
+
+	if get.ReplicaStatus == nil || get.ReplicaStatus.Ready != 1 {
+		t.Errorf("wrong replica status: %+v", get.ReplicaStatus)
+	}
+}
+
 func TestServiceLifecycle(t *testing.T) {
 	db := store.NewMemoryStore()
 	defer db.Close()
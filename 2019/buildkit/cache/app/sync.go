@@ -13,6 +13,9 @@ func (s *Server) sync() {
 	for {
 		s.syncActivities()
 		s.syncServices()
+		s.syncGHDeliveries()
+		s.syncWebhookDeliveries()
+		s.runAutoDeploys()
 		time.Sleep(60 * time.Second)
 	}
 }
@@ -38,6 +41,8 @@ func (s *Server) syncActivities() {
 			logger.Error(errors.Wrapf(r.Error, "failed to timeout stuck service-%s", svc.ID))
 			continue
 		}
+
+		s.reportActivity(a.ID)
 	}
 }
 
@@ -126,9 +126,18 @@ func (s *Server) InviteUser(authenticatedUser *model.User, email string, project
 		return &model.AppError{Status: http.StatusInternalServerError, Code: model.FailToSendEmail}
 	}
 
-	// todo: map this to the email, and implement the actual unsubscribe URL
-	unsubscribeKey := model.GenerateRandomString(40)
-	emailErr := s.Email.sendInviteEmail(u.Email, p.Name, config.GetBaseURL(), fmt.Sprintf("%s/unsubscribe/%s", config.GetBaseURL(), unsubscribeKey))
+	prefs, prefsErr := s.GetEmailPreferences(u)
+	if prefsErr != nil {
+		logger.Error(prefsErr)
+		return &model.AppError{Status: http.StatusInternalServerError, Code: model.FailToSendEmail, Message: prefsErr.Error()}
+	}
+
+	if !prefs.IsEnabled(model.InviteEmail) {
+		log.Printf("user-%s is unsubscribed, the invite to project-%s wasn't sent", u.ID, p.ID)
+		return nil
+	}
+
+	emailErr := s.Email.sendInviteEmail(u.Email, p.Name, config.GetBaseURL(), getUnsubscribeURL(prefs))
 	if emailErr != nil {
 		log.Printf("failed to sendInviteEmail to %s", u.Email)
 		return &model.AppError{Status: http.StatusInternalServerError, Code: model.FailToSendEmail, Message: emailErr.Error()}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+)
+
+const (
+	webhookSuccessColor = "2eb886"
+	webhookFailureColor = "d00000"
+	webhookDefaultColor = "439fe0"
+)
+
+// slackMessage is the body of a slack incoming webhook
+type slackMessage struct {
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Fallback  string       `json:"fallback"`
+	Color     string       `json:"color"`
+	Title     string       `json:"title"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Fields    []slackField `json:"fields,omitempty"`
+	Timestamp int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// teamsMessage is the message card sent to a microsoft teams incoming webhook
+type teamsMessage struct {
+	Type            string         `json:"@type"`
+	Context         string         `json:"@context"`
+	Summary         string         `json:"summary"`
+	ThemeColor      string         `json:"themeColor"`
+	Title           string         `json:"title"`
+	Sections        []teamsSection `json:"sections,omitempty"`
+	PotentialAction []teamsAction  `json:"potentialAction,omitempty"`
+}
+
+type teamsSection struct {
+	Facts []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+// webhookField is a fact of an event shown in the chat messages
+type webhookField struct {
+	name  string
+	value string
+	short bool
+}
+
+// renderWebhookPayload returns the body sent to a webhook of type t for payload
+func renderWebhookPayload(t model.WebhookType, payload *WebhookPayload) ([]byte, error) {
+	switch t {
+	case model.SlackWebhook:
+		return json.Marshal(getSlackMessage(payload))
+	case model.TeamsWebhook:
+		return json.Marshal(getTeamsMessage(payload))
+	default:
+		return json.Marshal(payload)
+	}
+}
+
+func getSlackMessage(payload *WebhookPayload) *slackMessage {
+	title := getWebhookMessage(payload)
+	attachment := slackAttachment{
+		Fallback:  title,
+		Color:     "#" + getWebhookColor(payload),
+		Title:     title,
+		Timestamp: payload.CreatedAt.Unix(),
+	}
+
+	if payload.Activity != nil {
+		attachment.TitleLink = payload.Activity.PageURL
+	}
+
+	for _, f := range getWebhookFields(payload) {
+		attachment.Fields = append(attachment.Fields, slackField{Title: f.name, Value: f.value, Short: f.short})
+	}
+
+	return &slackMessage{Attachments: []slackAttachment{attachment}}
+}
+
+func getTeamsMessage(payload *WebhookPayload) *teamsMessage {
+	title := getWebhookMessage(payload)
+	message := &teamsMessage{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    title,
+		ThemeColor: getWebhookColor(payload),
+		Title:      title,
+	}
+
+	facts := []teamsFact{}
+	for _, f := range getWebhookFields(payload) {
+		// teams renders the facts as markdown, the line breaks need two spaces
+		facts = append(facts, teamsFact{Name: f.name, Value: strings.Replace(f.value, "\n", "  \n", -1)})
+	}
+
+	if len(facts) > 0 {
+		message.Sections = []teamsSection{{Facts: facts}}
+	}
+
+	if payload.Activity != nil && payload.Activity.PageURL != "" {
+		message.PotentialAction = []teamsAction{
+			{
+				Type:    "OpenUri",
+				Name:    "View logs",
+				Targets: []teamsTarget{{OS: "default", URI: payload.Activity.PageURL}},
+			},
+		}
+	}
+
+	return message
+}
+
+// getWebhookMessage returns a readable description of the event
+func getWebhookMessage(payload *WebhookPayload) string {
+	project := payload.Project.Name
+	service := ""
+	if payload.Service != nil {
+		service = payload.Service.Name
+	}
+
+	switch payload.Event {
+	case model.ServiceCreatedEvent:
+		return fmt.Sprintf("'%s' was created in %s", service, project)
+	case model.DeployStartedEvent:
+		return fmt.Sprintf("'%s' is deploying in %s", service, project)
+	case model.DeploySucceededEvent:
+		return fmt.Sprintf("'%s' was deployed in %s", service, project)
+	case model.DeployFailedEvent:
+		return fmt.Sprintf("'%s' failed to deploy in %s", service, project)
+	case model.ServiceDestroyedEvent:
+		if payload.Activity != nil && payload.Activity.Status == model.Failed {
+			return fmt.Sprintf("'%s' failed to be destroyed in %s", service, project)
+		}
+
+		return fmt.Sprintf("'%s' was destroyed in %s", service, project)
+	case model.DevModeEnabledEvent:
+		return fmt.Sprintf("Dev mode was enabled in '%s' in %s", service, project)
+	case model.MemberAddedEvent:
+		return fmt.Sprintf("%s was added to %s", payload.Member, project)
+	case model.PingEvent:
+		return fmt.Sprintf("Okteto will notify the activity of %s in this channel", project)
+	default:
+		return fmt.Sprintf("%s in %s", payload.Event, project)
+	}
+}
+
+func getWebhookColor(payload *WebhookPayload) string {
+	switch {
+	case payload.Event == model.DeploySucceededEvent:
+		return webhookSuccessColor
+	case payload.Event == model.DeployFailedEvent:
+		return webhookFailureColor
+	case payload.Activity != nil && payload.Activity.Status == model.Failed:
+		return webhookFailureColor
+	default:
+		return webhookDefaultColor
+	}
+}
+
+func getWebhookFields(payload *WebhookPayload) []webhookField {
+	fields := []webhookField{}
+	if payload.Service != nil {
+		fields = append(fields, webhookField{name: "Service", value: payload.Service.Name, short: true})
+	}
+
+	if payload.Activity != nil {
+		fields = append(fields, webhookField{name: "Status", value: string(payload.Activity.Status), short: true})
+		if payload.Activity.Commit != "" {
+			fields = append(fields, webhookField{name: "Commit", value: payload.Activity.Commit, short: true})
+		}
+	}
+
+	if payload.Actor != "" {
+		fields = append(fields, webhookField{name: "Actor", value: payload.Actor, short: true})
+	}
+
+	if payload.Service != nil && len(payload.Service.Endpoints) > 0 {
+		fields = append(fields, webhookField{name: "Endpoints", value: strings.Join(payload.Service.Endpoints, "\n")})
+	}
+
+	return fields
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+func getFailedDeployPayload() *WebhookPayload {
+	return &WebhookPayload{
+		Event:     model.DeployFailedEvent,
+		CreatedAt: time.Now().UTC(),
+		Project:   WebhookProject{ID: "project", Name: "testproject"},
+		Service:   &WebhookService{ID: "service", Name: "api"},
+		Activity: &WebhookActivity{
+			ID:      "activity",
+			Type:    model.Deployed,
+			Status:  model.Failed,
+			Commit:  "abc123",
+			LogsURL: "https://okteto.example.com/api/v1/projects/project/services/service/activities/activity/logs",
+			PageURL: "https://okteto.example.com/projects/project/services/service/activities/activity",
+		},
+		Actor: "user@example.com",
+	}
+}
+
+func Test_getSlackMessage(t *testing.T) {
+	payload := getFailedDeployPayload()
+	m := getSlackMessage(payload)
+	if len(m.Attachments) != 1 {
+		t.Fatalf("expected a single attachment, got %+v", m)
+	}
+
+	a := m.Attachments[0]
+	if a.Title != "'api' failed to deploy in testproject" || a.Fallback != a.Title {
+		t.Errorf("wrong title: %s", a.Title)
+	}
+
+	if a.TitleLink != payload.Activity.PageURL {
+		t.Errorf("the title doesn't link to the page of the activity: %s", a.TitleLink)
+	}
+
+	if a.Color != "#"+webhookFailureColor {
+		t.Errorf("wrong color: %s", a.Color)
+	}
+
+	fields := map[string]string{}
+	for _, f := range a.Fields {
+		fields[f.Title] = f.Value
+	}
+
+	expected := map[string]string{"Service": "api", "Status": "failed", "Commit": "abc123", "Actor": "user@example.com"}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("expected %s for field %s, got %s", v, k, fields[k])
+		}
+	}
+
+	if _, ok := fields["Endpoints"]; ok {
+		t.Errorf("a failed deploy has endpoints: %+v", fields)
+	}
+}
+
+func Test_getTeamsMessage(t *testing.T) {
+	payload := getFailedDeployPayload()
+	payload.Event = model.DeploySucceededEvent
+	payload.Activity.Status = model.Completed
+	payload.Service.Endpoints = []string{"https://api-testproject.okteto.net", "http://api-testproject.okteto.net"}
+
+	m := getTeamsMessage(payload)
+	if m.Type != "MessageCard" || m.Title != "'api' was deployed in testproject" || m.ThemeColor != webhookSuccessColor {
+		t.Errorf("wrong card: %+v", m)
+	}
+
+	if len(m.PotentialAction) != 1 || m.PotentialAction[0].Targets[0].URI != payload.Activity.PageURL {
+		t.Errorf("the card doesn't link to the page of the activity: %+v", m.PotentialAction)
+	}
+
+	if len(m.Sections) != 1 {
+		t.Fatalf("expected a single section, got %+v", m.Sections)
+	}
+
+	found := false
+	for _, f := range m.Sections[0].Facts {
+		if f.Name == "Endpoints" {
+			found = true
+			if f.Value != "https://api-testproject.okteto.net  \nhttp://api-testproject.okteto.net" {
+				t.Errorf("wrong endpoints: %s", f.Value)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("the endpoints are missing: %+v", m.Sections[0].Facts)
+	}
+}
+
+func Test_getWebhookMessage(t *testing.T) {
+	var tests = []struct {
+		name     string
+		payload  *WebhookPayload
+		expected string
+	}{
+		{
+			name:     "created",
+			payload:  &WebhookPayload{Event: model.ServiceCreatedEvent, Project: WebhookProject{Name: "p"}, Service: &WebhookService{Name: "api"}},
+			expected: "'api' was created in p",
+		},
+		{
+			name:     "destroy-failed",
+			payload:  &WebhookPayload{Event: model.ServiceDestroyedEvent, Project: WebhookProject{Name: "p"}, Service: &WebhookService{Name: "api"}, Activity: &WebhookActivity{Status: model.Failed}},
+			expected: "'api' failed to be destroyed in p",
+		},
+		{
+			name:     "member",
+			payload:  &WebhookPayload{Event: model.MemberAddedEvent, Project: WebhookProject{Name: "p"}, Member: "new@example.com"},
+			expected: "new@example.com was added to p",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getWebhookMessage(tt.payload); got != tt.expected {
+				t.Errorf("expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestChatWebhookDelivery(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	slack := &model.ProjectWebhook{URL: "https://hooks.slack.com/services/T0/B0/x", Type: model.SlackWebhook, Events: "deploy.failed"}
+	teams := &model.ProjectWebhook{URL: "https://outlook.office.com/webhook/x", Type: model.TeamsWebhook, Events: "deploy.failed"}
+	for _, w := range []*model.ProjectWebhook{slack, teams} {
+		w.Secret = "secret"
+		if _, appErr := s.CreateProjectWebhook(p, w); appErr != nil {
+			t.Fatal(appErr)
+		}
+
+		saved := &model.ProjectWebhook{}
+		if err := db.Where("id = ?", w.ID).First(saved).Error; err != nil || saved.Secret != "" {
+			t.Errorf("the %s webhook has a secret: %+v %s", w.Type, saved, err)
+		}
+	}
+
+	svc := &model.Service{Model: model.Model{ID: "service"}, ProjectID: p.ID, Name: "api"}
+	activity := &model.Activity{Model: model.Model{ID: "activity"}, Type: model.Deployed, Status: model.Failed}
+	s.notifyServiceEvent(model.DeployFailedEvent, p, svc, activity, &model.User{Email: "user@example.com"})
+
+	deliveries, err := s.GetWebhookDeliveries(p, slack.ID)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("the slack delivery wasn't queued: %+v %s", deliveries, err)
+	}
+
+	m := &slackMessage{}
+	if err := json.Unmarshal([]byte(deliveries[0].Payload), m); err != nil || len(m.Attachments) != 1 {
+		t.Fatalf("the slack delivery isn't a slack message: %s", deliveries[0].Payload)
+	}
+
+	if m.Attachments[0].TitleLink != getActivityPageURL(p.ID, "service", "activity") {
+		t.Errorf("wrong activity link: %s", m.Attachments[0].TitleLink)
+	}
+
+	deliveries, err = s.GetWebhookDeliveries(p, teams.ID)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("the teams delivery wasn't queued: %+v %s", deliveries, err)
+	}
+
+	if !strings.Contains(deliveries[0].Payload, `"@type":"MessageCard"`) {
+		t.Errorf("the teams delivery isn't a message card: %s", deliveries[0].Payload)
+	}
+}
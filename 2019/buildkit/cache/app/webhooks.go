@@ -0,0 +1,512 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/config"
+	"bitbucket.org/okteto/okteto/backend/logger"
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	webhookEventHeader     = "X-Okteto-Event"
+	webhookDeliveryHeader  = "X-Okteto-Delivery"
+	webhookSignatureHeader = "X-Okteto-Signature-256"
+
+	// the delivery log of the webhooks keeps a week of deliveries
+	webhookDeliveryRetention = 7 * 24 * time.Hour
+
+	webhookMaxAttempts = 5
+
+	// webhookStuckPeriod is the time after which a delivery that is still sending is considered lost
+	webhookStuckPeriod = 15 * time.Minute
+
+	webhookSecretBytes = 20
+
+	webhookTimeout = 10 * time.Second
+
+	// webhookWorkers is the number of deliveries sent at the same time, a slow webhook doesn't delay the others
+	webhookWorkers = 10
+)
+
+var (
+	// webhookBackoff is the delay before the second attempt of a delivery, it's doubled on every failed attempt
+	webhookBackoff = 30 * time.Second
+
+	// webhookDeliveries of the same webhook are sent one at a time, the deliveries of different webhooks at the same time
+	webhookDeliveries = &outbox{
+		name:         "webhook",
+		record:       func() interface{} { return &model.WebhookDelivery{} },
+		pending:      string(model.PendingWebhookDelivery),
+		processing:   string(model.SendingWebhookDelivery),
+		processed:    string(model.SucceededWebhookDelivery),
+		failed:       string(model.FailedWebhookDelivery),
+		key:          "webhook_id",
+		workers:      webhookWorkers,
+		maxAttempts:  webhookMaxAttempts,
+		backoff:      getWebhookBackoff,
+		stuckPeriod:  webhookStuckPeriod,
+		retention:    webhookDeliveryRetention,
+		pollInterval: 5 * time.Second,
+		events:       make(chan struct{}, 1),
+	}
+
+	// webhookClient only connects to public addresses, the urls of the webhooks are set by the users and they must not
+	// reach the cluster or the cloud metadata. The redirects are not followed, they could point to private addresses
+	webhookClient = &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: webhookTimeout, Control: checkWebhookAddress}).DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// isWebhookAddressAllowed returns true if the webhooks can connect to ip
+	isWebhookAddressAllowed = model.IsPublicIP
+)
+
+// WebhookPayload is the body of the requests sent to the json project webhooks. Slack and teams webhooks receive
+// it rendered as a chat message
+type WebhookPayload struct {
+	Event     model.WebhookEventType `json:"event"`
+	CreatedAt time.Time              `json:"created_at"`
+	Project   WebhookProject         `json:"project"`
+	Service   *WebhookService        `json:"service,omitempty"`
+	Activity  *WebhookActivity       `json:"activity,omitempty"`
+
+	// Actor is the email of the user that triggered the event
+	Actor string `json:"actor,omitempty"`
+
+	// Member is the email of the user added to the project
+	Member string `json:"member,omitempty"`
+}
+
+// WebhookProject is the project of a webhook event
+type WebhookProject struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebhookService is the service of a webhook event
+type WebhookService struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Endpoints are the public urls of the service, they are only set when a deploy succeeds
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// WebhookActivity is the activity of the service of a webhook event
+type WebhookActivity struct {
+	ID     string               `json:"id"`
+	Type   model.ActivityType   `json:"type"`
+	Status model.ActivityStatus `json:"status"`
+	Commit string               `json:"commit,omitempty"`
+
+	// LogsURL is the url of the logs of the activity
+	LogsURL string `json:"logs_url,omitempty"`
+
+	// PageURL is the page of the activity in the dashboard, the chat messages link to it
+	PageURL string `json:"page_url,omitempty"`
+}
+
+// CreateProjectWebhook subscribes a webhook to the events of p. A secret is generated if it's not set, it's only
+// returned by this call. Slack and teams webhooks don't have a secret
+func (s *Server) CreateProjectWebhook(p *model.Project, w *model.ProjectWebhook) (*model.ProjectWebhook, *model.AppError) {
+	if appErr := w.Validate(); appErr != nil {
+		return nil, appErr
+	}
+
+	if w.IsChat() {
+		w.Secret = ""
+	} else if w.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			logger.Error(err)
+			return nil, &model.AppError{Status: 500, Code: model.InternalServerError, Message: err.Error()}
+		}
+
+		w.Secret = secret
+	}
+
+	w.ProjectID = p.ID
+	if err := s.DB.Create(w).Error; err != nil {
+		return nil, &model.AppError{Status: 500, Code: model.InsertFailed, Message: err.Error()}
+	}
+
+	log.Printf("webhook-%s created for project-%s", w.ID, p.ID)
+	return w, nil
+}
+
+// GetProjectWebhooks returns the webhooks of p, without their secrets
+func (s *Server) GetProjectWebhooks(p *model.Project) ([]model.ProjectWebhook, error) {
+	webhooks := []model.ProjectWebhook{}
+	if err := s.DB.Where(model.ProjectWebhook{ProjectID: p.ID}).Order("created_at").Find(&webhooks).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to get the webhooks of project-%s", p.ID)
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// DeleteProjectWebhook removes a webhook of p and its delivery log
+func (s *Server) DeleteProjectWebhook(p *model.Project, webhookID string) error {
+	w, err := s.getProjectWebhook(p, webhookID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.DB.Where(model.WebhookDelivery{WebhookID: w.ID}).Delete(model.WebhookDelivery{}).Error; err != nil {
+		return errors.Wrapf(err, "failed to delete the deliveries of webhook-%s", w.ID)
+	}
+
+	if err := s.DB.Delete(w).Error; err != nil {
+		return errors.Wrapf(err, "failed to delete webhook-%s", w.ID)
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook of p, the newest first
+func (s *Server) GetWebhookDeliveries(p *model.Project, webhookID string) ([]model.WebhookDelivery, error) {
+	w, err := s.getProjectWebhook(p, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []model.WebhookDelivery{}
+	if err := s.DB.Where(model.WebhookDelivery{WebhookID: w.ID}).Order("created_at desc").Find(&deliveries).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to get the deliveries of webhook-%s", w.ID)
+	}
+
+	return deliveries, nil
+}
+
+// PingProjectWebhook sends a ping event to a webhook of p and returns the result. Pings are not retried
+func (s *Server) PingProjectWebhook(p *model.Project, webhookID string) (*model.WebhookDelivery, error) {
+	w, err := s.getProjectWebhook(p, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := renderWebhookPayload(w.GetType(), newWebhookPayload(model.PingEvent, p))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the ping")
+	}
+
+	delivery := &model.WebhookDelivery{
+		WebhookID: w.ID,
+		Event:     model.PingEvent,
+		Payload:   string(body),
+		Status:    model.SendingWebhookDelivery,
+		Attempts:  1,
+	}
+
+	if err := s.DB.Create(delivery).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to save the ping of webhook-%s", w.ID)
+	}
+
+	code, err := sendWebhook(w, delivery)
+	delivery.ResponseCode = code
+	delivery.Status = model.SucceededWebhookDelivery
+	if err != nil {
+		delivery.Status = model.FailedWebhookDelivery
+		delivery.LastError = err.Error()
+	}
+
+	values := map[string]interface{}{"status": delivery.Status, "response_code": code, "last_error": delivery.LastError}
+	if err := s.DB.Model(delivery).Updates(values).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to save the result of the ping of webhook-%s", w.ID)
+	}
+
+	return delivery, nil
+}
+
+func (s *Server) getProjectWebhook(p *model.Project, webhookID string) (*model.ProjectWebhook, error) {
+	w := &model.ProjectWebhook{}
+	r := s.DB.Where("id = ? AND project_id = ?", webhookID, p.ID).First(w)
+	if r.Error != nil {
+		if r.RecordNotFound() {
+			return nil, errors.Wrapf(model.ErrNotFound, "webhook-%s not found in project-%s", webhookID, p.ID)
+		}
+
+		return nil, errors.Wrapf(r.Error, "failed to get webhook-%s", webhookID)
+	}
+
+	return w, nil
+}
+
+func newWebhookPayload(event model.WebhookEventType, p *model.Project) *WebhookPayload {
+	return &WebhookPayload{
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Project:   WebhookProject{ID: p.ID, Name: p.Name},
+	}
+}
+
+// notifyServiceEvent sends an event of a service to the webhooks of p. activity is nil for the events that are not
+// related to an activity
+func (s *Server) notifyServiceEvent(event model.WebhookEventType, p *model.Project, service *model.Service, activity *model.Activity, actor *model.User) {
+	payload := newWebhookPayload(event, p)
+	payload.Service = &WebhookService{ID: service.ID, Name: service.Name}
+	if activity != nil {
+		payload.Activity = &WebhookActivity{
+			ID:      activity.ID,
+			Type:    activity.Type,
+			Status:  activity.Status,
+			Commit:  activity.Commit,
+			LogsURL: getActivityLogsURL(service.ProjectID, service.ID, activity.ID),
+			PageURL: getActivityPageURL(service.ProjectID, service.ID, activity.ID),
+		}
+	}
+
+	if event == model.DeploySucceededEvent {
+		payload.Service.Endpoints = s.getWebhookEndpoints(p, service.ID)
+	}
+
+	if actor != nil {
+		payload.Actor = actor.Email
+	}
+
+	s.notifyWebhooks(p.ID, payload)
+}
+
+// getWebhookEndpoints returns the endpoints of a service. The service is reloaded to get the dns of the deploy
+func (s *Server) getWebhookEndpoints(p *model.Project, serviceID string) []string {
+	if p.LoadedSettings == nil {
+		return nil
+	}
+
+	service, appErr := s.GetServiceByID(serviceID)
+	if appErr != nil {
+		logger.Error(errors.Wrapf(appErr, "failed to get the endpoints of service-%s", serviceID))
+		return nil
+	}
+
+	m, appErr := buildService(service, p, false, nil)
+	if appErr != nil {
+		return nil
+	}
+
+	return s.buildServiceEndpoints(m, getServiceProject(p, service), service.DNS)
+}
+
+func getActivityLogsURL(projectID, serviceID, activityID string) string {
+	return fmt.Sprintf("%s/projects/%s/services/%s/activities/%s/logs", config.GetAPIURL(), projectID, serviceID, activityID)
+}
+
+// getActivityPageURL returns the page of the dashboard with the logs of an activity
+func getActivityPageURL(projectID, serviceID, activityID string) string {
+	return fmt.Sprintf("%s/projects/%s/services/%s/activities/%s", config.GetBaseURL(), projectID, serviceID, activityID)
+}
+
+// notifyMembersAdded sends an event to the webhooks of p for every user added to the project
+func (s *Server) notifyMembersAdded(p *model.Project, members []string, actor *model.User) {
+	for _, m := range members {
+		payload := newWebhookPayload(model.MemberAddedEvent, p)
+		payload.Member = m
+		payload.Actor = actor.Email
+		s.notifyWebhooks(p.ID, payload)
+	}
+}
+
+// notifyWebhooks queues a delivery of payload to every webhook of the project subscribed to its event. The payload is
+// rendered once for every type of webhook
+func (s *Server) notifyWebhooks(projectID string, payload *WebhookPayload) {
+	var webhooks []model.ProjectWebhook
+	if err := s.DB.Where(model.ProjectWebhook{ProjectID: projectID}).Find(&webhooks).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get the webhooks of project-%s", projectID))
+		return
+	}
+
+	bodies := map[model.WebhookType][]byte{}
+	queued := false
+	for _, w := range webhooks {
+		if !w.IsSubscribed(payload.Event) {
+			continue
+		}
+
+		body, ok := bodies[w.GetType()]
+		if !ok {
+			var err error
+			body, err = renderWebhookPayload(w.GetType(), payload)
+			if err != nil {
+				logger.Error(errors.Wrapf(err, "failed to encode the %s event of project-%s", payload.Event, projectID))
+				return
+			}
+
+			bodies[w.GetType()] = body
+		}
+
+		delivery := &model.WebhookDelivery{
+			WebhookID:     w.ID,
+			Event:         payload.Event,
+			Payload:       string(body),
+			Status:        model.PendingWebhookDelivery,
+			NextAttemptAt: time.Now().UTC(),
+		}
+
+		if err := s.DB.Create(delivery).Error; err != nil {
+			logger.Error(errors.Wrapf(err, "failed to queue the %s event for webhook-%s", payload.Event, w.ID))
+			continue
+		}
+
+		queued = true
+	}
+
+	if queued {
+		wakeUpWebhookWorker()
+	}
+}
+
+// wakeUpWebhookWorker notifies the worker that a delivery was queued
+func wakeUpWebhookWorker() {
+	webhookDeliveries.wakeUp()
+}
+
+func (s *Server) processWebhookDeliveries() {
+	webhookDeliveries.run(s.DB, s.sendWebhookDelivery)
+}
+
+// processNextWebhookDelivery sends the oldest pending delivery. It returns false if there wasn't any delivery to send
+func (s *Server) processNextWebhookDelivery() bool {
+	id, err := webhookDeliveries.claim(s.DB)
+	if err != nil {
+		logger.Error(err)
+		return false
+	}
+
+	if id == "" {
+		return false
+	}
+
+	s.sendWebhookDelivery(id)
+	return true
+}
+
+// sendWebhookDelivery sends a claimed delivery to its webhook and saves the result of the attempt
+func (s *Server) sendWebhookDelivery(id string) {
+	delivery := &model.WebhookDelivery{}
+	if err := s.DB.Where("id = ?", id).First(delivery).Error; err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get webhook delivery %s, it will be retried when it times out", id))
+		return
+	}
+
+	w := &model.ProjectWebhook{}
+	r := s.DB.Where("id = ?", delivery.WebhookID).First(w)
+	if r.Error != nil {
+		if !r.RecordNotFound() {
+			logger.Error(errors.Wrapf(r.Error, "failed to get webhook-%s", delivery.WebhookID))
+			s.completeWebhookDelivery(delivery, 0, r.Error)
+			return
+		}
+
+		// the deliveries of deleted webhooks are not retried
+		delivery.Attempts = webhookMaxAttempts
+		s.completeWebhookDelivery(delivery, 0, errors.New("the webhook was deleted"))
+		return
+	}
+
+	code, err := sendWebhook(w, delivery)
+	s.completeWebhookDelivery(delivery, code, err)
+}
+
+// sendWebhook posts the payload of delivery to the webhook, and returns the status code of the response
+func sendWebhook(w *model.ProjectWebhook, delivery *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create the request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "okteto-webhooks")
+	req.Header.Set(webhookEventHeader, string(delivery.Event))
+	req.Header.Set(webhookDeliveryHeader, delivery.ID)
+	if !w.IsChat() {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(w.Secret, []byte(delivery.Payload)))
+	}
+
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("the webhook responded %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+// signWebhookPayload returns the hex encoded HMAC-SHA256 of body with the secret of the webhook
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// completeWebhookDelivery saves the result of an attempt and the response code of the webhook
+func (s *Server) completeWebhookDelivery(delivery *model.WebhookDelivery, code int, err error) {
+	if err != nil {
+		log.Printf("attempt %d of webhook delivery %s failed: %s", delivery.Attempts, delivery.ID, err)
+	}
+
+	webhookDeliveries.complete(s.DB, delivery.ID, delivery.Attempts, map[string]interface{}{"response_code": code}, err)
+}
+
+// checkWebhookAddress rejects the connections of the webhooks and the registry resolver to private addresses. It's
+// called once the host of the url is resolved, so the names that resolve to private addresses are rejected too
+func checkWebhookAddress(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !isWebhookAddressAllowed(ip) {
+		return fmt.Errorf("can't connect to the private address %s", host)
+	}
+
+	return nil
+}
+
+func getWebhookBackoff(attempts int) time.Duration {
+	return webhookBackoff * time.Duration(1<<uint(attempts-1))
+}
+
+// syncWebhookDeliveries retries the deliveries that were lost while sending, and removes the deliveries that are
+// older than webhookDeliveryRetention
+func (s *Server) syncWebhookDeliveries() {
+	webhookDeliveries.sync(s.DB)
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate the webhook secret")
+	}
+
+	return hex.EncodeToString(b), nil
+}
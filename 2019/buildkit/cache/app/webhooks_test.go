@@ -0,0 +1,355 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/store"
+)
+
+// fakeWebhookReceiver records the payloads with a valid signature, and fails the first failures requests
+type fakeWebhookReceiver struct {
+	t        *testing.T
+	secret   string
+	failures int
+	received []*WebhookPayload
+	mutex    sync.Mutex
+}
+
+func (f *fakeWebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Header.Get(webhookSignatureHeader) != "sha256="+signWebhookPayload(f.secret, body) {
+		f.t.Errorf("wrong signature: %s", r.Header.Get(webhookSignatureHeader))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	payload := &WebhookPayload{}
+	if err := json.Unmarshal(body, payload); err != nil {
+		f.t.Error(err)
+	}
+
+	if string(payload.Event) != r.Header.Get(webhookEventHeader) || r.Header.Get(webhookDeliveryHeader) == "" {
+		f.t.Errorf("wrong headers: %+v", r.Header)
+	}
+
+	f.received = append(f.received, payload)
+}
+
+func (f *fakeWebhookReceiver) getReceived() []*WebhookPayload {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*WebhookPayload{}, f.received...)
+}
+
+func waitForWebhookDeliveries(t *testing.T, s *Server, count int) {
+	for i := 0; i < 100; i++ {
+		var current int
+		s.DB.Model(&model.WebhookDelivery{}).Count(&current)
+		if current >= count {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("%d webhook deliveries were never queued", count)
+}
+
+// allowLocalWebhooks lets the webhooks connect to the local test servers
+func allowLocalWebhooks() func() {
+	isWebhookAddressAllowed = func(ip net.IP) bool { return true }
+	return func() { isWebhookAddressAllowed = model.IsPublicIP }
+}
+
+// createTestWebhook saves a webhook of p without validating it, the test servers listen on a loopback address
+func createTestWebhook(t *testing.T, s *Server, p *model.Project, w *model.ProjectWebhook) {
+	w.ProjectID = p.ID
+	if err := s.DB.Create(w).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProjectWebhookDelivery(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	webhookBackoff = 0
+	defer func() { webhookBackoff = 30 * time.Second }()
+	defer allowLocalWebhooks()()
+
+	receiver := &fakeWebhookReceiver{t: t, secret: "secret", failures: 2}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	p := &model.Project{Name: "testproject", DNSName: "testproject", Settings: demoProject}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	u := &model.User{Email: "user@example.com"}
+	db.Create(u)
+
+	w := &model.ProjectWebhook{URL: server.URL, Secret: "secret", Events: "service.created,deploy.started"}
+	createTestWebhook(t, &s, p, w)
+
+	unsubscribed := &model.ProjectWebhook{URL: "https://example.com/other", Events: "member.added"}
+	if _, appErr := s.CreateProjectWebhook(p, unsubscribed); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	if unsubscribed.Secret == "" {
+		t.Errorf("the secret wasn't generated")
+	}
+
+	svc := &model.Service{Manifest: httpsService, Name: "service"}
+	if _, appErr := s.CreateService(p, svc, u); appErr != nil {
+		t.Fatalf("Create failed %+v", appErr)
+	}
+
+	waitForWebhookDeliveries(t, &s, 1)
+	for i := 0; s.processNextWebhookDelivery(); i++ {
+		if i > webhookMaxAttempts {
+			t.Fatal("the delivery was sent too many times")
+		}
+	}
+
+	received := receiver.getReceived()
+	if len(received) != 1 {
+		t.Fatalf("expected a single payload, got %+v", received)
+	}
+
+	if received[0].Event != model.ServiceCreatedEvent || received[0].Project.ID != p.ID || received[0].Service.ID != svc.ID || received[0].Actor != u.Email {
+		t.Errorf("wrong payload: %+v", received[0])
+	}
+
+	deliveries, err := s.GetWebhookDeliveries(p, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].Status != model.SucceededWebhookDelivery || deliveries[0].Attempts != 3 || deliveries[0].ResponseCode != http.StatusOK {
+		t.Errorf("the delivery wasn't retried until it succeeded: %+v", deliveries)
+	}
+
+	if others, _ := s.GetWebhookDeliveries(p, unsubscribed.ID); len(others) != 0 {
+		t.Errorf("a webhook received an event it isn't subscribed to: %+v", others)
+	}
+
+	webhooks, err := s.GetProjectWebhooks(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(webhooks) != 2 || webhooks[0].Secret != "" || webhooks[1].Secret != "" {
+		t.Errorf("wrong webhooks: %+v", webhooks)
+	}
+
+	if err := s.DeleteProjectWebhook(p, unsubscribed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteProjectWebhook(&model.Project{Model: model.Model{ID: "other"}}, w.ID); err == nil {
+		t.Errorf("a webhook was deleted from another project")
+	}
+}
+
+func TestProjectWebhookFailures(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+
+	webhookBackoff = 0
+	defer func() { webhookBackoff = 30 * time.Second }()
+	defer allowLocalWebhooks()()
+
+	receiver := &fakeWebhookReceiver{t: t, secret: "secret", failures: 2 * webhookMaxAttempts}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	p := &model.Project{Name: "testproject", DNSName: "testproject"}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &model.ProjectWebhook{URL: server.URL, Secret: "secret", Events: "member.added"}
+	createTestWebhook(t, &s, p, w)
+
+	s.notifyMembersAdded(p, []string{"new@example.com"}, &model.User{Email: "admin@example.com"})
+	for s.processNextWebhookDelivery() {
+	}
+
+	deliveries, err := s.GetWebhookDeliveries(p, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].Status != model.FailedWebhookDelivery || deliveries[0].Attempts != webhookMaxAttempts || deliveries[0].ResponseCode != http.StatusBadGateway {
+		t.Errorf("the delivery wasn't retried until it failed: %+v", deliveries)
+	}
+
+	receiver.failures = 0
+	ping, err := s.PingProjectWebhook(p, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ping.Status != model.SucceededWebhookDelivery || ping.Event != model.PingEvent {
+		t.Errorf("the ping failed: %+v", ping)
+	}
+
+	received := receiver.getReceived()
+	if len(received) != 1 || received[0].Event != model.PingEvent {
+		t.Errorf("the ping wasn't received: %+v", received)
+	}
+
+	if _, err := s.PingProjectWebhook(p, "missing"); err == nil {
+		t.Errorf("a missing webhook was pinged")
+	}
+}
+
+func TestWebhooksOnlyReachPublicAddresses(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { redirected = true }))
+	defer target.Close()
+
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirect.Close()
+
+	delivery := &model.WebhookDelivery{Model: model.Model{ID: "delivery"}, Event: model.PingEvent, Payload: "{}"}
+	if _, err := sendWebhook(&model.ProjectWebhook{URL: target.URL}, delivery); err == nil || !strings.Contains(err.Error(), "private address") {
+		t.Errorf("the webhook connected to a loopback address: %v", err)
+	}
+
+	defer allowLocalWebhooks()()
+	code, err := sendWebhook(&model.ProjectWebhook{URL: redirect.URL}, delivery)
+	if err == nil || code != http.StatusFound || redirected {
+		t.Errorf("the webhook followed the redirect: %d %v", code, err)
+	}
+}
+
+func TestChatWebhooksAreNotSigned(t *testing.T) {
+	defer allowLocalWebhooks()()
+	signatures := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signatures = append(signatures, r.Header.Get(webhookSignatureHeader))
+	}))
+	defer server.Close()
+
+	delivery := &model.WebhookDelivery{Model: model.Model{ID: "delivery"}, Event: model.PingEvent, Payload: "{}"}
+	for _, w := range []*model.ProjectWebhook{{URL: server.URL, Secret: "secret"}, {URL: server.URL, Type: model.SlackWebhook}} {
+		if _, err := sendWebhook(w, delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(signatures) != 2 || signatures[0] == "" || signatures[1] != "" {
+		t.Errorf("only the json webhook must be signed: %v", signatures)
+	}
+}
+
+func TestWebhookDeliveriesAreSentConcurrently(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	s := Server{DB: db}
+	defer allowLocalWebhooks()()
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	p := &model.Project{Name: "testproject", DNSName: "testproject"}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	slowWebhook := &model.ProjectWebhook{URL: slow.URL, Secret: "secret", Events: "member.added"}
+	fastWebhook := &model.ProjectWebhook{URL: fast.URL, Secret: "secret", Events: "member.added"}
+	createTestWebhook(t, &s, p, slowWebhook)
+	createTestWebhook(t, &s, p, fastWebhook)
+
+	queue := func(w *model.ProjectWebhook) *model.WebhookDelivery {
+		d := &model.WebhookDelivery{WebhookID: w.ID, Event: model.MemberAddedEvent, Payload: "{}", Status: model.PendingWebhookDelivery}
+		if err := db.Create(d).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		return d
+	}
+
+	first := queue(slowWebhook)
+	second := queue(slowWebhook)
+	other := queue(fastWebhook)
+
+	getStatus := func(d *model.WebhookDelivery) model.WebhookDeliveryStatus {
+		var current model.WebhookDelivery
+		if err := db.Where("id = ?", d.ID).First(&current).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		return current.Status
+	}
+
+	waitForStatus := func(d *model.WebhookDelivery, status model.WebhookDeliveryStatus) {
+		for i := 0; i < 100 && getStatus(d) != status; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if current := getStatus(d); current != status {
+			t.Fatalf("delivery %s: expected %s got %s", d.ID, status, current)
+		}
+	}
+
+	workers := make(chan struct{}, 2)
+	if !webhookDeliveries.dispatch(db, workers, s.sendWebhookDelivery) || !webhookDeliveries.dispatch(db, workers, s.sendWebhookDelivery) {
+		t.Fatal("the deliveries of both webhooks weren't sent")
+	}
+
+	waitForStatus(other, model.SucceededWebhookDelivery)
+	if getStatus(first) != model.SendingWebhookDelivery {
+		t.Errorf("the delivery to the slow webhook isn't in progress")
+	}
+
+	if webhookDeliveries.dispatch(db, workers, s.sendWebhookDelivery) {
+		t.Errorf("a second delivery to the slow webhook was sent before the first one finished")
+	}
+
+	close(release)
+	waitForStatus(first, model.SucceededWebhookDelivery)
+	if !webhookDeliveries.dispatch(db, workers, s.sendWebhookDelivery) {
+		t.Fatal("the second delivery to the slow webhook wasn't sent")
+	}
+
+	waitForStatus(second, model.SucceededWebhookDelivery)
+}
+
+func Test_getWebhookBackoff(t *testing.T) {
+	if got := getWebhookBackoff(1); got != webhookBackoff {
+		t.Errorf("expected %s, got %s", webhookBackoff, got)
+	}
+
+	if got := getWebhookBackoff(3); got != 4*webhookBackoff {
+		t.Errorf("expected %s, got %s", 4*webhookBackoff, got)
+	}
+}
@@ -88,6 +88,25 @@ func GetMailgunCredentials() (string, string) {
 	return viper.GetString("mail.domain"), viper.GetString("mail.api")
 }
 
+//GetMailTransport returns how the emails are sent: mailgun, smtp or file. If it's empty mailgun is used when its
+//credentials are configured, and the emails are only logged otherwise
+func GetMailTransport() string {
+	return viper.GetString("mail.transport")
+}
+
+//GetSMTPConfiguration returns the host:port of the SMTP server, the credentials to authenticate, and if STARTTLS is required
+func GetSMTPConfiguration() (string, string, string, bool) {
+	return viper.GetString("mail.smtp.address"),
+		viper.GetString("mail.smtp.username"),
+		viper.GetString("mail.smtp.password"),
+		viper.GetBool("mail.smtp.starttls")
+}
+
+//GetMailDirectory returns the maildir where the file transport writes the emails
+func GetMailDirectory() string {
+	return viper.GetString("mail.file.directory")
+}
+
 //GetNotificationEmail returns the email to use when sending notifications
 func GetNotificationEmail() string {
 	notification := viper.GetString("mail.notification")
@@ -143,6 +162,11 @@ func GetGithubApp() (int, []byte, error) {
 	return app, []byte(viper.GetString("github.privatekey")), nil
 }
 
+// GetGithubWebhookSecret returns the secret used to sign the webhook deliveries of the Github application
+func GetGithubWebhookSecret() string {
+	return viper.GetString("github.webhooksecret")
+}
+
 // GetClusterName returns the name of the default cluster
 func GetClusterName() string {
 	return viper.GetString("cluster.name")
@@ -171,6 +195,21 @@ func UseInClusterConfig() bool {
 	return viper.GetBool("cluster.enabled")
 }
 
+// ImageResolutionEnabled returns true if the images are resolved to digests through the registry API before deploying
+func ImageResolutionEnabled() bool {
+	return viper.GetBool("images.resolve")
+}
+
+// GetBuilder returns the builder of the images of the services, only buildkit is supported. Builds are disabled if it's empty
+func GetBuilder() string {
+	return viper.GetString("builder.type")
+}
+
+// GetBuildKitAddress returns the address of the buildkitd daemon, e.g. tcp://buildkitd:1234
+func GetBuildKitAddress() string {
+	return viper.GetString("builder.buildkit.address")
+}
+
 // GetEnvironmentName returns the environment name of the deployment.
 // This is used for analytics tags
 func GetEnvironmentName() string {
@@ -96,3 +96,24 @@ func TestAnalytics(t *testing.T) {
 	}
 
 }
+
+func TestMailTransport(t *testing.T) {
+	LoadConfig()
+	if GetMailTransport() != "" {
+		t.Errorf("the mail transport isn't empty by default")
+	}
+
+	if _, _, _, startTLS := GetSMTPConfiguration(); !startTLS {
+		t.Errorf("STARTTLS isn't required by default")
+	}
+
+	os.Setenv("OKTETO_MAIL_TRANSPORT", "smtp")
+	os.Setenv("OKTETO_MAIL_SMTP_ADDRESS", "smtp.example.com:587")
+	defer os.Unsetenv("OKTETO_MAIL_TRANSPORT")
+	defer os.Unsetenv("OKTETO_MAIL_SMTP_ADDRESS")
+	LoadConfig()
+	address, _, _, _ := GetSMTPConfiguration()
+	if GetMailTransport() != "smtp" || address != "smtp.example.com:587" {
+		t.Errorf("the smtp configuration wasn't retrieved from the env vars: %s %s", GetMailTransport(), address)
+	}
+}
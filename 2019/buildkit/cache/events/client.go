@@ -28,6 +28,9 @@ const (
 	maxMessageSize = 512
 
 	authTimeout = (30 * time.Second)
+
+	// Maximum number of log lines sent in a single message
+	maxLogsPerMessage = 100
 )
 
 var (
@@ -36,8 +39,12 @@ var (
 )
 
 const (
-	authMessage    = MessageType("auth")
-	serviceMessage = MessageType("service")
+	authMessage            = MessageType("auth")
+	serviceMessage         = MessageType("service")
+	subscribeLogsMessage   = MessageType("logs.subscribe")
+	unsubscribeLogsMessage = MessageType("logs.unsubscribe")
+	logsMessage            = MessageType("logs")
+	logsErrorMessage       = MessageType("logs.error")
 )
 
 // MessageType is the type of event send or received
@@ -48,6 +55,28 @@ type Message struct {
 	MessageType MessageType    `json:"type,omitempty"`
 	Token       string         `json:"token,omitempty"`
 	Service     *model.Service `json:"service,omitempty"`
+
+	// Activity is the activity of the logs messages
+	Activity string `json:"activity,omitempty"`
+
+	// Offset is the number of log lines of the activity that the client already has. Clients send it to resume
+	// a subscription, and it's updated on every logs message
+	Offset int `json:"offset,omitempty"`
+
+	Logs  []model.ActivityLog `json:"logs,omitempty"`
+	Error string              `json:"error,omitempty"`
+}
+
+// logPosition is the number of lines of an activity sent to a client, and the last of them
+type logPosition struct {
+	offset int
+	last   *model.ActivityLog
+}
+
+// logsRequest is a subscription change read from the client
+type logsRequest struct {
+	message *Message
+	user    string
 }
 
 // Client is a middleman between the websocket connection and the hub.
@@ -73,6 +102,19 @@ type Client struct {
 
 	authFn Auth
 
+	logsAuthFn LogsAuth
+
+	logsFn LogsLoader
+
+	// Subscription changes, they are processed by writePump
+	logRequests chan *logsRequest
+
+	// Notifies writePump that there are new lines in the subscribed activities
+	logs chan struct{}
+
+	// The lines sent of every subscribed activity, it's only used by writePump
+	logPositions map[string]*logPosition
+
 	// The connection start time
 	started time.Time
 
@@ -106,6 +148,20 @@ func (c *Client) writePump() {
 				return
 			}
 
+		case r := <-c.logRequests:
+			if err := c.processLogsRequest(r); err != nil {
+				logger.Error(errors.Wrapf(err, "ws-%s error when writing logs", c.id))
+				return
+			}
+
+		case <-c.logs:
+			for activityID := range c.logPositions {
+				if err := c.sendLogs(activityID); err != nil {
+					logger.Error(errors.Wrapf(err, "ws-%s error when writing logs", c.id))
+					return
+				}
+			}
+
 		case <-c.ticker.C:
 			if !c.authenticated && time.Now().Sub(c.started) > authTimeout {
 				log.Printf("ws-%s not authenticated, disconnecting", c.id)
@@ -145,6 +201,17 @@ func (c *Client) readPump() {
 			}
 
 			c.finishAuth(m.Token)
+		} else if m.MessageType == subscribeLogsMessage || m.MessageType == unsubscribeLogsMessage {
+			if !c.authenticated {
+				logger.Error(fmt.Errorf("ws-%s tried to subscribe to logs before authenticating", c.id))
+				continue
+			}
+
+			select {
+			case c.logRequests <- &logsRequest{message: m, user: c.user}:
+			default:
+				logger.Error(fmt.Errorf("ws-%s sent too many log subscriptions, ignoring %s", c.id, m.Activity))
+			}
 		} else {
 			logger.Error(fmt.Errorf("ws-%s received an unknown event: %s", c.id, m.MessageType))
 		}
@@ -169,8 +236,66 @@ func (c *Client) finishAuth(token string) {
 	log.Printf("ws-%s authenticated successfully with user-%s", c.id, u.ID)
 }
 
+// processLogsRequest subscribes or unsubscribes the client to the logs of an activity. The lines after the offset of
+// the request are sent right away, the rest as they are written
+func (c *Client) processLogsRequest(r *logsRequest) error {
+	activityID := r.message.Activity
+	if r.message.MessageType == unsubscribeLogsMessage {
+		c.hub.unsubscribeLogs(c, activityID)
+		delete(c.logPositions, activityID)
+		return nil
+	}
+
+	if err := c.logsAuthFn(r.user, c.project, activityID); err != nil {
+		logger.Info("ws-%s can't read the logs of activity-%s: %s", c.id, activityID, err)
+		return c.write(&Message{MessageType: logsErrorMessage, Activity: activityID, Error: "activity not found"})
+	}
+
+	offset := r.message.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	// the client is subscribed before reading the logs so no line is missed
+	c.logPositions[activityID] = &logPosition{offset: offset}
+	c.hub.subscribeLogs(c, activityID)
+	return c.sendLogs(activityID)
+}
+
+// sendLogs sends the lines of an activity written after the last line sent to the client
+func (c *Client) sendLogs(activityID string) error {
+	position := c.logPositions[activityID]
+	for {
+		logs, err := c.logsFn(activityID, position.last, position.offset, maxLogsPerMessage)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "ws-%s failed to load the logs of activity-%s", c.id, activityID))
+			return nil
+		}
+
+		if len(logs) == 0 {
+			return nil
+		}
+
+		position.offset += len(logs)
+		position.last = &logs[len(logs)-1]
+		m := &Message{MessageType: logsMessage, Activity: activityID, Offset: position.offset, Logs: logs}
+		if err := c.write(m); err != nil {
+			return err
+		}
+
+		if len(logs) < maxLogsPerMessage {
+			return nil
+		}
+	}
+}
+
+func (c *Client) write(m *Message) error {
+	c.conn.SetWriteDeadline(time.Now().UTC().Add(writeWait))
+	return c.conn.WriteJSON(m)
+}
+
 //StartNewClient registers and starts processing requests from a new client
-func (hub *Hub) StartNewClient(conn *websocket.Conn, projectID string, authFn Auth) string {
+func (hub *Hub) StartNewClient(conn *websocket.Conn, projectID string, authFn Auth, logsAuthFn LogsAuth, logsFn LogsLoader) string {
 	client := &Client{
 		id:            uuid.NewV4().String(),
 		hub:           hub,
@@ -179,6 +304,11 @@ func (hub *Hub) StartNewClient(conn *websocket.Conn, projectID string, authFn Au
 		project:       projectID,
 		authenticated: false,
 		authFn:        authFn,
+		logsAuthFn:    logsAuthFn,
+		logsFn:        logsFn,
+		logRequests:   make(chan *logsRequest, 16),
+		logs:          make(chan struct{}, 1),
+		logPositions:  map[string]*logPosition{},
 		started:       time.Now(),
 	}
 	client.hub.register <- client
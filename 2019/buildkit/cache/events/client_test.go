@@ -0,0 +1,176 @@
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	"github.com/gorilla/websocket"
+)
+
+// fakeLogs is an in memory store of the logs of the activities
+type fakeLogs struct {
+	logs  map[string][]model.ActivityLog
+	mutex sync.Mutex
+}
+
+func (f *fakeLogs) add(activityID string, lines ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, l := range lines {
+		id := fmt.Sprintf("%s-%d", activityID, len(f.logs[activityID]))
+		f.logs[activityID] = append(f.logs[activityID], model.ActivityLog{Model: model.Model{ID: id}, ActivityID: activityID, Log: l})
+	}
+}
+
+func (f *fakeLogs) load(activityID string, after *model.ActivityLog, offset, limit int) ([]model.ActivityLog, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	logs := f.logs[activityID]
+	if after != nil {
+		offset = len(logs)
+		for i := range logs {
+			if logs[i].ID == after.ID {
+				offset = i + 1
+			}
+		}
+	}
+
+	if offset >= len(logs) {
+		return nil, nil
+	}
+
+	logs = logs[offset:]
+	if len(logs) > limit {
+		logs = logs[:limit]
+	}
+
+	return append([]model.ActivityLog{}, logs...), nil
+}
+
+func startTestClient(t *testing.T, hub *Hub, logs *fakeLogs) (*websocket.Conn, func()) {
+	upgrader := websocket.Upgrader{}
+	auth := func(token string) (*model.User, error) {
+		return &model.User{Model: model.Model{ID: token}}, nil
+	}
+
+	logsAuth := func(userID, projectID, activityID string) error {
+		if userID != "user" || projectID != "project" || activityID == "forbidden" {
+			return fmt.Errorf("user-%s can't read activity-%s", userID, activityID)
+		}
+
+		return nil
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		hub.StartNewClient(conn, "project", auth, logsAuth, logs.load)
+	}))
+
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL, "http", "ws", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) *Message {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	m := &Message{}
+	if err := conn.ReadJSON(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestLogsSubscription(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	logs := &fakeLogs{logs: map[string][]model.ActivityLog{}}
+	logs.add("activity", "line 0", "line 1", "line 2")
+
+	conn, stop := startTestClient(t, hub, logs)
+	defer stop()
+
+	if m := readMessage(t, conn); m.MessageType != authMessage {
+		t.Fatalf("expected the auth message, got %+v", m)
+	}
+
+	conn.WriteJSON(&Message{MessageType: authMessage, Token: "user"})
+	conn.WriteJSON(&Message{MessageType: subscribeLogsMessage, Activity: "forbidden"})
+	m := readMessage(t, conn)
+	if m.MessageType != logsErrorMessage || m.Activity != "forbidden" {
+		t.Errorf("subscribed to the logs of a forbidden activity: %+v", m)
+	}
+
+	// the client resumes after the first line
+	conn.WriteJSON(&Message{MessageType: subscribeLogsMessage, Activity: "activity", Offset: 1})
+	m = readMessage(t, conn)
+	if m.MessageType != logsMessage || m.Offset != 3 || len(m.Logs) != 2 || m.Logs[0].Log != "line 1" {
+		t.Fatalf("wrong logs after subscribing: %+v", m)
+	}
+
+	logs.add("activity", "line 3")
+	hub.SendLog("activity")
+	m = readMessage(t, conn)
+	if m.MessageType != logsMessage || m.Offset != 4 || len(m.Logs) != 1 || m.Logs[0].Log != "line 3" {
+		t.Fatalf("wrong new logs: %+v", m)
+	}
+
+	conn.WriteJSON(&Message{MessageType: unsubscribeLogsMessage, Activity: "activity"})
+	for i := 0; i < 100; i++ {
+		hub.logsMutex.RLock()
+		subscribed := len(hub.logSubscribers["activity"])
+		hub.logsMutex.RUnlock()
+		if subscribed == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("the client wasn't unsubscribed")
+}
+
+func TestLogsSubscriptionPagination(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	logs := &fakeLogs{logs: map[string][]model.ActivityLog{}}
+	for i := 0; i < maxLogsPerMessage+10; i++ {
+		logs.add("activity", fmt.Sprintf("line %d", i))
+	}
+
+	conn, stop := startTestClient(t, hub, logs)
+	defer stop()
+
+	readMessage(t, conn)
+	conn.WriteJSON(&Message{MessageType: authMessage, Token: "user"})
+	conn.WriteJSON(&Message{MessageType: subscribeLogsMessage, Activity: "activity"})
+
+	first := readMessage(t, conn)
+	second := readMessage(t, conn)
+	if len(first.Logs) != maxLogsPerMessage || first.Offset != maxLogsPerMessage {
+		t.Errorf("wrong first page: %d lines, offset %d", len(first.Logs), first.Offset)
+	}
+
+	if len(second.Logs) != 10 || second.Offset != maxLogsPerMessage+10 || second.Logs[0].Log != fmt.Sprintf("line %d", maxLogsPerMessage) {
+		t.Errorf("wrong second page: %d lines, offset %d", len(second.Logs), second.Offset)
+	}
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"log"
+	"sync"
 
 	"bitbucket.org/okteto/okteto/backend/model"
 )
@@ -9,6 +10,13 @@ import (
 //Auth is the function used to authenticate a token based on the information received
 type Auth func(string) (*model.User, error)
 
+//LogsAuth returns an error if the user can't read the logs of the activity in the project
+type LogsAuth func(userID, projectID, activityID string) error
+
+//LogsLoader returns up to limit lines of the logs of an activity written after the line after. If after is nil, the
+//first offset lines are skipped
+type LogsLoader func(activityID string, after *model.ActivityLog, offset, limit int) ([]model.ActivityLog, error)
+
 // Hub maintains the set of active clients and broadcasts messages to the
 // clients.
 type Hub struct {
@@ -23,14 +31,19 @@ type Hub struct {
 
 	// Unregister requests from clients.
 	unregister chan *Client
+
+	// Clients subscribed to the logs of every activity
+	logSubscribers map[string]map[*Client]bool
+	logsMutex      sync.RWMutex
 }
 
 // NewHub initializes the channels and maps in newhub
 func NewHub() *Hub {
 	return &Hub{
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		register:       make(chan *Client),
+		unregister:     make(chan *Client),
+		clients:        make(map[*Client]bool),
+		logSubscribers: make(map[string]map[*Client]bool),
 	}
 }
 
@@ -44,6 +57,7 @@ func (h *Hub) Run() {
 			if _, ok := h.clients[client]; ok {
 				close(client.send)
 				delete(h.clients, client)
+				h.unsubscribeAllLogs(client)
 			}
 		}
 	}
@@ -70,3 +84,46 @@ func (h *Hub) SendNotification(s *model.Service) {
 		}
 	}()
 }
+
+//SendLog notifies the clients subscribed to the logs of an activity that it has new lines
+func (h *Hub) SendLog(activityID string) {
+	h.logsMutex.RLock()
+	defer h.logsMutex.RUnlock()
+	for c := range h.logSubscribers[activityID] {
+		// a notification that is already pending also sends the new lines
+		select {
+		case c.logs <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *Hub) subscribeLogs(c *Client, activityID string) {
+	h.logsMutex.Lock()
+	defer h.logsMutex.Unlock()
+	if _, ok := h.logSubscribers[activityID]; !ok {
+		h.logSubscribers[activityID] = map[*Client]bool{}
+	}
+
+	h.logSubscribers[activityID][c] = true
+}
+
+func (h *Hub) unsubscribeLogs(c *Client, activityID string) {
+	h.logsMutex.Lock()
+	defer h.logsMutex.Unlock()
+	delete(h.logSubscribers[activityID], c)
+	if len(h.logSubscribers[activityID]) == 0 {
+		delete(h.logSubscribers, activityID)
+	}
+}
+
+func (h *Hub) unsubscribeAllLogs(c *Client) {
+	h.logsMutex.Lock()
+	defer h.logsMutex.Unlock()
+	for activityID, clients := range h.logSubscribers {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.logSubscribers, activityID)
+		}
+	}
+}
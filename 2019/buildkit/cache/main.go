@@ -48,6 +48,8 @@ func main() {
 		Listener:    listener,
 		DNSProvider: getDNSProvider(),
 		Email:       getEmailProvider(),
+		Images:      getImageResolver(),
+		Builder:     getBuilder(),
 		DB:          gormDB,
 	}
 
@@ -183,12 +185,63 @@ func getDNSProvider() *model.DNSProvider {
 func getEmailProvider() *app.EmailProvider {
 	var sender app.EmailSender
 	domain, apiKey := config.GetMailgunCredentials()
-	if domain != "" && apiKey != "" {
+	switch transport := config.GetMailTransport(); transport {
+	case "smtp":
+		address, username, password, startTLS := config.GetSMTPConfiguration()
+		if address == "" {
+			logger.Fatal(errors.New("the smtp mail transport requires mail.smtp.address"))
+		}
+
+		log.Printf("using the smtp server %s to send email notifications", address)
+		sender = app.NewSMTPSender(address, username, password, startTLS)
+	case "file":
+		directory := config.GetMailDirectory()
+		s, err := app.NewFileMailSender(directory)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		log.Printf("email notifications will be written to the maildir %s", directory)
+		sender = s
+	case "mailgun":
+		if domain == "" || apiKey == "" {
+			logger.Fatal(errors.New("the mailgun mail transport requires mail.domain and mail.api"))
+		}
+
 		log.Printf("using mailgun to send email notifications")
 		sender = app.NewMailgunSender(apiKey, domain)
-	} else {
-		sender = &app.NoopMail{}
+	case "":
+		if domain != "" && apiKey != "" {
+			log.Printf("using mailgun to send email notifications")
+			sender = app.NewMailgunSender(apiKey, domain)
+		} else {
+			sender = &app.NoopMail{}
+		}
+	default:
+		logger.Fatal(fmt.Errorf("unknown mail transport '%s', it must be mailgun, smtp or file", transport))
 	}
 
 	return app.NewMail(config.GetNotificationEmail(), sender)
 }
+
+func getImageResolver() app.ImageResolver {
+	if !config.ImageResolutionEnabled() {
+		log.Printf("images won't be resolved to digests before deploying")
+		return nil
+	}
+
+	return app.NewRegistryResolver()
+}
+
+func getBuilder() app.Builder {
+	switch builder := config.GetBuilder(); builder {
+	case "buildkit":
+		log.Printf("using buildkit to build images")
+		return app.NewBuildKitBuilder(config.GetBuildKitAddress())
+	case "":
+		return nil
+	default:
+		logger.Fatal(fmt.Errorf("%s is not a supported builder", builder))
+		return nil
+	}
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS service_unique_name;
+CREATE UNIQUE INDEX IF NOT EXISTS service_unique_name ON services("name", project_id) WHERE ("status" != 'destroyed');
@@ -0,0 +1,4 @@
+UPDATE services SET preview_id = '' WHERE preview_id IS NULL;
+
+DROP INDEX IF EXISTS service_unique_name;
+CREATE UNIQUE INDEX IF NOT EXISTS service_unique_name ON services("name", project_id, preview_id) WHERE ("status" != 'destroyed');
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS notify_activity_log_event ON activity_logs;
+DROP FUNCTION IF EXISTS notify_activity_log_event();
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE OR REPLACE FUNCTION notify_activity_log_event() RETURNS TRIGGER AS $$
+    DECLARE 
+        notification json;
+    
+    BEGIN
+        notification = json_build_object(
+                      'table',TG_TABLE_NAME,
+                      'action', TG_OP,
+                      'id', NEW.id,
+                      'activity', NEW.activity_id);
+
+        PERFORM pg_notify('events', notification::text);
+        RETURN NULL; 
+    END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS notify_activity_log_event ON activity_logs;
+CREATE TRIGGER notify_activity_log_event
+  AFTER INSERT ON activity_logs
+    FOR EACH ROW EXECUTE PROCEDURE notify_activity_log_event();
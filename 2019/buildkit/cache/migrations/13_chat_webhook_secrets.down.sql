@@ -0,0 +1 @@
+-- the secrets of the slack and teams webhooks weren't used, they aren't restored
@@ -0,0 +1 @@
+UPDATE project_webhooks SET secret = '' WHERE "type" IN ('slack', 'teams');
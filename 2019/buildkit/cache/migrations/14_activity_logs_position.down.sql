@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_activity_logs_position;
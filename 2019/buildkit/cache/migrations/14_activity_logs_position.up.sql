@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_activity_logs_position ON activity_logs(activity_id, created_at, id);
@@ -41,11 +41,18 @@ var activityTypeComparison = map[ActivityType]int{
 // Activity is any step of the service lifecycle
 type Activity struct {
 	Model
-	Type       ActivityType   `json:"type,omitempty"`
-	Status     ActivityStatus `json:"status,omitempty" gorm:"index"`
-	ServiceID  string         `json:"-,omitempty"`
-	ActorID    string         `json:"-"`
-	ActorEmail string         `json:"actor,omitempty" gorm:"-"`
+	Type       ActivityType    `json:"type,omitempty"`
+	Status     ActivityStatus  `json:"status,omitempty" gorm:"index"`
+	ServiceID  string          `json:"-,omitempty"`
+	ActorID    string          `json:"-"`
+	ActorEmail string          `json:"actor,omitempty" gorm:"-"`
+	Images     []ActivityImage `json:"images,omitempty" gorm:"-"`
+
+	// Commit is the commit of the linked repository deployed or synced by the activity
+	Commit string `json:"commit,omitempty"`
+
+	// GHDeploymentID is the github deployment that reports the status of the activity
+	GHDeploymentID int64 `json:"-"`
 
 	// Links
 	Logs string `json:"logs,omitempty" gorm:"-"`
@@ -54,10 +61,19 @@ type Activity struct {
 // ActivityLog are all the logs generated by an activity
 type ActivityLog struct {
 	Model
-	ActivityID string `json:"activity,omitempty"`
+	ActivityID string `json:"activity,omitempty" gorm:"index"`
 	Log        string `json:"log,omitempty"`
 }
 
+// ActivityImage is the digest that an image of a container was resolved to when an activity deployed it
+type ActivityImage struct {
+	Model
+	ActivityID string `json:"-" gorm:"index"`
+	Container  string `json:"container,omitempty"`
+	Image      string `json:"image,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+}
+
 // IsOlder returns true if a is older, or false otherwise
 func (a *Activity) IsOlder(b *Activity) bool {
 	if a.UpdatedAt.Equal(b.UpdatedAt) {
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const defaultDockerfile = "Dockerfile"
+
+//Build represents how the image of a container is built from the linked github repository in a service.yml file.
+//The image is pushed to the image of the container
+type Build struct {
+	Context    string            `json:"context,omitempty" yaml:"context,omitempty"`
+	Dockerfile string            `json:"dockerfile,omitempty" yaml:"dockerfile,omitempty"`
+	Args       map[string]string `json:"args,omitempty" yaml:"args,omitempty"`
+	Target     string            `json:"target,omitempty" yaml:"target,omitempty"`
+}
+
+//GetContext returns the clean path of the build context in the repository, the root of the repository by default
+func (b *Build) GetContext() string {
+	return path.Clean("./" + b.Context)
+}
+
+//GetDockerfile returns the path of the Dockerfile relative to the build context
+func (b *Build) GetDockerfile() string {
+	if b.Dockerfile == "" {
+		return defaultDockerfile
+	}
+	return path.Clean("./" + b.Dockerfile)
+}
+
+//HasBuilds returns true if s builds the image of a container
+func (s *Service) HasBuilds() bool {
+	for _, c := range s.Containers {
+		if c != nil && c.Build != nil {
+			return true
+		}
+	}
+	for _, c := range s.InitContainers {
+		if c != nil && c.Build != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Container) validateBuild(parent, nC string) []*AppError {
+	if c.Build == nil {
+		return nil
+	}
+
+	errs := []*AppError{}
+	field := parent + ".build"
+	invalidBuild := func(f, message string) {
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidBuild,
+			Field:   f,
+			Data:    map[string]string{"container": nC},
+			Message: message})
+	}
+
+	if !isRelativePath(c.Build.Context) {
+		invalidBuild(field+".context", fmt.Sprintf("the build context of container '%s' must be a path inside the repository", nC))
+	}
+
+	if !isRelativePath(c.Build.Dockerfile) {
+		invalidBuild(field+".dockerfile", fmt.Sprintf("the Dockerfile of container '%s' must be a path inside the build context", nC))
+	}
+
+	if r, err := ParseImageReference(c.Image); err == nil && r.IsPinned() {
+		invalidBuild(parent+".image", fmt.Sprintf("container '%s' builds its image, the image can't be referenced by digest", nC))
+	}
+
+	return errs
+}
+
+// isRelativePath returns true if p is empty or a relative path that doesn't go to a parent directory
+func isRelativePath(p string) bool {
+	if p == "" {
+		return true
+	}
+
+	if strings.HasPrefix(p, "/") {
+		return false
+	}
+
+	clean := path.Clean(p)
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
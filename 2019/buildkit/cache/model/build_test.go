@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+)
+
+func TestReadBuild(t *testing.T) {
+	m, err := ioutil.ReadFile("./examples/service_with_build.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, appErr := RenderEncodedManifest(base64.StdEncoding.EncodeToString(m), nil, map[string]string{CommitVariable: "a1b2c3"}, true, nil)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	b := s.Containers["api"].Build
+	if b == nil {
+		t.Fatal("didn't parse the build section")
+	}
+
+	if b.GetContext() != "api" || b.GetDockerfile() != "build/Dockerfile" || b.Target != "production" {
+		t.Errorf("wrong build: %+v", b)
+	}
+
+	if b.Args["GO_VERSION"] != "1.11" || b.Args["DEBUG"] != "false" {
+		t.Errorf("wrong build args: %+v", b.Args)
+	}
+
+	init := s.InitContainers[0].Build
+	if init.GetContext() != "migrations" || init.GetDockerfile() != "Dockerfile" {
+		t.Errorf("wrong build defaults: %+v", init)
+	}
+
+	if !s.HasBuilds() {
+		t.Errorf("HasBuilds() returned false")
+	}
+}
+
+func TestValidateBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+		build *Build
+		field string
+	}{
+		{name: "no-build", image: "okteto/api"},
+		{name: "defaults", image: "okteto/api", build: &Build{}},
+		{name: "subdirectory", image: "okteto/api", build: &Build{Context: "./api/", Dockerfile: "docker/Dockerfile.prod"}},
+		{name: "absolute-context", image: "okteto/api", build: &Build{Context: "/api"}, field: "containers.api.build.context"},
+		{name: "parent-context", image: "okteto/api", build: &Build{Context: "api/../.."}, field: "containers.api.build.context"},
+		{name: "parent-dockerfile", image: "okteto/api", build: &Build{Dockerfile: "../Dockerfile"}, field: "containers.api.build.dockerfile"},
+		{
+			name:  "digest",
+			image: "okteto/api@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac",
+			build: &Build{},
+			field: "containers.api.image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				Name:       "test",
+				Replicas:   1,
+				Containers: map[string]*Container{"api": &Container{Image: tt.image, Build: tt.build}},
+			}
+
+			err := s.Validate(nil)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || err.Code != InvalidBuild || err.Field != tt.field {
+				t.Errorf("expected %s in %s, got %+v", InvalidBuild, tt.field, err)
+			}
+		})
+	}
+}
@@ -40,6 +40,12 @@ type Environment struct {
 	DNSProvider *DNSProvider `yaml:"dns,omitempty"`
 	Provider    *Provider    `yaml:"provider,omitempty"`
 	Registry    *Registry    `yaml:"registry,omitempty"`
+
+	//Labels are set on the namespace when it's created
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	//Security is the security baseline of the project, the containers injected in dev mode are checked against it
+	Security *SecuritySettings `yaml:"security,omitempty"`
 }
 
 //DNSProvider represents the info for the cloud provider where the DNS is created
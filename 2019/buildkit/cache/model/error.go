@@ -17,6 +17,18 @@ const (
 	// and we have some information on what went wrong
 	InvalidYAMLWithInfo AppErrorCode = "InvalidYAMLWithInfo"
 
+	// UnknownField is returned when a manifest has a field that doesn't exist
+	UnknownField AppErrorCode = "UnknownField"
+
+	// InvalidVariable is returned when a manifest references a variable with an invalid name
+	InvalidVariable AppErrorCode = "InvalidVariable"
+
+	// UndefinedVariable is returned when a manifest references a variable that is not defined and doesn't have a default
+	UndefinedVariable AppErrorCode = "UndefinedVariable"
+
+	// InvalidOverlay is returned when a manifest overlay can't be read or merged
+	InvalidOverlay AppErrorCode = "InvalidOverlay"
+
 	// InvalidBase64 is returned when the a base64 string can't be decoded
 	InvalidBase64 AppErrorCode = "InvalidBase64"
 
@@ -77,12 +89,51 @@ const (
 	// InvalidPersistentReplica is returned when the service manifest is configured to be persistent and it has more than one replica
 	InvalidPersistentReplica AppErrorCode = "InvalidPersistentReplica"
 
+	// InvalidAutoscale is returned when the service manifest autoscale configuration is not valid
+	InvalidAutoscale AppErrorCode = "InvalidAutoscale"
+
+	// MissingResourceRequests is returned when a container of an autoscaled service doesn't define resource requests
+	MissingResourceRequests AppErrorCode = "MissingResourceRequests"
+
 	// InvalidDevContainerCount is returned when more than on container is configured with dev mode
 	InvalidDevContainerCount AppErrorCode = "InvalidDevContainerCount"
 
+	// InvalidInitContainer is returned when an init container of the service manifest is not valid
+	InvalidInitContainer AppErrorCode = "InvalidInitContainer"
+
+	// InvalidScheduling is returned when the scheduling configuration of the service manifest is not valid
+	InvalidScheduling AppErrorCode = "InvalidScheduling"
+
+	// SchedulingNotAllowed is returned when the scheduling configuration of the service manifest is restricted by the project settings
+	SchedulingNotAllowed AppErrorCode = "SchedulingNotAllowed"
+
+	// InvalidSecurityContext is returned when the security context of a container is not valid
+	InvalidSecurityContext AppErrorCode = "InvalidSecurityContext"
+
+	// SecurityPolicyViolation is returned when a container doesn't comply with the security baseline of the project settings
+	SecurityPolicyViolation AppErrorCode = "SecurityPolicyViolation"
+
+	// InvalidConfig is returned when a config of the service manifest is not valid
+	InvalidConfig AppErrorCode = "InvalidConfig"
+
 	// VolumeNotDefined is returned when a volume is mentioned in the service but not defined in the list
 	VolumeNotDefined AppErrorCode = "VolumeNotDefined"
 
+	// InvalidPort is returned when a port doesn't follow the [NAME:]PORT[/PROTOCOL] syntax
+	InvalidPort AppErrorCode = "InvalidPort"
+
+	// InvalidBuild is returned when the build section of a container is not valid
+	InvalidBuild AppErrorCode = "InvalidBuild"
+
+	// UndefinedSecret is returned when a manifest references a secret that is not defined in the project settings
+	UndefinedSecret AppErrorCode = "UndefinedSecret"
+
+	// InvalidIngressHostname is returned when an ingress hostname is not a valid DNS name
+	InvalidIngressHostname AppErrorCode = "InvalidIngressHostname"
+
+	// IngressHostnameConflict is returned when an ingress hostname is already used by another service of the project
+	IngressHostnameConflict AppErrorCode = "IngressHostnameConflict"
+
 	// ProjectNotEmpty is returned when a project still has active services
 	ProjectNotEmpty AppErrorCode = "ProjectNotEmpty"
 
@@ -125,6 +176,15 @@ const (
 	//AccoutNotLinkedToGithub is returned when the account selected is not yet linked to github
 	AccoutNotLinkedToGithub AppErrorCode = "AccoutNotLinkedToGithub"
 
+	// InvalidSCMCredentials is returned when the gitlab or bitbucket credentials of the project settings are incomplete
+	InvalidSCMCredentials AppErrorCode = "InvalidSCMCredentials"
+
+	// InvalidWebhookEvent is returned when a project webhook is subscribed to an event that doesn't exist
+	InvalidWebhookEvent AppErrorCode = "InvalidWebhookEvent"
+
+	// InvalidWebhookType is returned when a project webhook has a type that doesn't exist
+	InvalidWebhookType AppErrorCode = "InvalidWebhookType"
+
 	// InvalidURL is returned when the request contains an invalid URL
 	InvalidURL AppErrorCode = "InvalidURL"
 
@@ -171,6 +231,15 @@ var ErrSHAMismatch = errors.New("sha-mismatch")
 //ErrProjectNotLinkedToGithub is returned when the project is not linked to github
 var ErrProjectNotLinkedToGithub = errors.New("project not linked to github")
 
+//ErrUnknownSCMProvider is returned when a repository is linked from an unsupported source control provider
+var ErrUnknownSCMProvider = errors.New("unknown-scm-provider")
+
+//ErrProjectNotLinkedToSCM is returned when the project doesn't have the credentials of the source control provider
+var ErrProjectNotLinkedToSCM = errors.New("project not linked to the source control provider")
+
+//ErrDeliveryNotFailed is returned when replaying a github delivery that didn't fail
+var ErrDeliveryNotFailed = errors.New("delivery-not-failed")
+
 //AppErrorCode is the type of error generated by an api call
 type AppErrorCode string
 
@@ -180,9 +249,26 @@ type AppError struct {
 	Status  int               `json:"status"`
 	Message string            `json:"-"`
 	Data    map[string]string `json:"data"`
+
+	// Field is the path of the manifest field that caused the error, e.g. containers.nginx.image
+	Field string `json:"field,omitempty"`
+
+	// Errors lists every problem found when the error aggregates several of them
+	Errors []*AppError `json:"errors,omitempty"`
 }
 
 // Error returns a string of the error
 func (a *AppError) Error() string {
 	return fmt.Sprintf("%s - %s", a.Code, a.Message)
 }
+
+// newAppErrorList returns nil if errs is empty. Otherwise it returns a copy of the first error that includes all of them in Errors
+func newAppErrorList(errs []*AppError) *AppError {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	result := *errs[0]
+	result.Errors = errs
+	return &result
+}
@@ -1,5 +1,12 @@
 package model
 
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
 //GHScope The github scope of the installation. This can be User or Organization.
 type GHScope string
 
@@ -23,20 +30,225 @@ type GHInstallation struct {
 	Scope GHScope
 }
 
+//SCMProviderType is the source control provider of a linked repository
+type SCMProviderType string
+
 // GHRepoLink represents every repository/branch combination linked to one (or more) okteto services
-// A service can only be linked if the project was linked first
+// A github repository can only be linked if the project was linked first. Gitlab and bitbucket repositories
+// are read with the credentials of the project settings
 type GHRepoLink struct {
 	Model
-	InstallationID int    `gorm:"index:idx_installation_repo_branch"`
-	RepositoryID   int    `gorm:"index:idx_installation_repo_branch"`
-	Branch         string `gorm:"index:idx_installation_repo_branch"`
-	Manifest       string
+	InstallationID int `gorm:"index:idx_installation_repo_branch"`
+	RepositoryID   int `gorm:"index:idx_installation_repo_branch"`
+
+	//Branch is the ref of a branch or a tag, or a glob pattern of refs like refs/heads/release/* or refs/tags/v*
+	Branch string `gorm:"index:idx_installation_repo_branch,idx_provider_repo_branch"`
+
+	//Manifest and Overlay are read by the services linked before the manifests were stored by service, see GHLinkManifest
+	Manifest string
+	Overlay  string
+
+	//AutoDeploy deploys the linked services after a push updates their manifest
+	AutoDeploy bool
+
+	//Provider is empty for the links created before gitlab and bitbucket were supported
+	Provider SCMProviderType `gorm:"index:idx_provider_repo_branch"`
+
+	//Repository is the full name of the repository, e.g. owner/name
+	Repository string `gorm:"index:idx_provider_repo_branch"`
+
+	//ProjectID is the project whose credentials are used to read the repository, github uses the installation instead
+	ProjectID string
+
+	//WebhookSecret verifies the deliveries of the gitlab and bitbucket webhooks, the links of a project to the same
+	//repository share it
+	WebhookSecret string
+}
+
+// GHLinkManifest maps a service to the manifest it reads from its linked repository. A link holds a manifest per
+// service, so the services of a monorepo can be linked to the same repository and branch
+type GHLinkManifest struct {
+	Model
+	GHRepoLinkID string `json:"-" gorm:"index"`
+	ServiceID    string `json:"service" gorm:"unique_index"`
+	Manifest     string `json:"manifest"`
+	Overlay      string `json:"overlay,omitempty"`
+
+	//Paths are the comma separated globs of the files that affect the service. The service is updated by every push
+	//when it's empty
+	Paths string `json:"paths,omitempty"`
+}
+
+//GetPaths returns the path globs of the manifest
+func (m *GHLinkManifest) GetPaths() []string {
+	paths := []string{}
+	for _, p := range strings.Split(m.Paths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
+
+//IsAffectedBy returns true if any of the changed files matches the path globs, the manifest or the overlay. A nil
+//files means the changed files are unknown, and every service is affected
+func (m *GHLinkManifest) IsAffectedBy(files []string) bool {
+	paths := m.GetPaths()
+	if files == nil || len(paths) == 0 {
+		return true
+	}
+
+	for _, f := range files {
+		f = strings.TrimPrefix(path.Clean(f), "/")
+		if f == m.Manifest || (m.Overlay != "" && f == m.Overlay) {
+			return true
+		}
+
+		for _, p := range paths {
+			if MatchPathGlob(p, f) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//MatchPathGlob returns true if file matches the glob pattern. '*' and '?' don't match '/', '**' matches any number
+//of directories and a pattern ending in '/' matches every file of the directory
+func MatchPathGlob(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern = pattern + "**"
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					expr.WriteString("(.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	r, err := regexp.Compile(expr.String())
+	if err != nil {
+		return false
+	}
+
+	return r.MatchString(file)
+}
+
+//IsPattern returns true if the branch of the link is a glob pattern of branches or tags, e.g. refs/tags/v*
+func (l *GHRepoLink) IsPattern() bool {
+	return strings.ContainsAny(l.Branch, "*?")
+}
+
+//MatchesRef returns true if ref is the branch of the link, or it matches its pattern
+func (l *GHRepoLink) MatchesRef(ref string) bool {
+	if l.Branch == ref {
+		return true
+	}
+
+	return l.IsPattern() && MatchPathGlob(l.Branch, ref)
+}
+
+//GetRefName returns the name of a branch or tag ref, without the refs/heads/ or refs/tags/ prefix
+func GetRefName(ref string) string {
+	if strings.HasPrefix(ref, TagRefPrefix) {
+		return strings.TrimPrefix(ref, TagRefPrefix)
+	}
+
+	return strings.TrimPrefix(ref, BranchRefPrefix)
+}
+
+//GetProvider returns the source control provider of the link
+func (l *GHRepoLink) GetProvider() SCMProviderType {
+	if l.Provider == "" {
+		return GithubProvider
+	}
+
+	return l.Provider
+}
+
+//GHDeliveryStatus is the processing status of a github webhook delivery
+type GHDeliveryStatus string
+
+// GHDelivery is a webhook delivery received from github. It's stored before it's processed, so the deliveries
+// aren't lost on restarts and the failed ones can be retried. It's also used to discard the deliveries received
+// more than once
+type GHDelivery struct {
+	Model
+	DeliveryID     string           `json:"delivery" gorm:"unique_index"`
+	Provider       SCMProviderType  `json:"provider,omitempty"`
+	Event          string           `json:"event"`
+	Action         string           `json:"action,omitempty"`
+	InstallationID int              `json:"-" gorm:"index"`
+	Payload        string           `json:"-"`
+	Status         GHDeliveryStatus `json:"status" gorm:"index"`
+	Attempts       int              `json:"attempts"`
+	LastError      string           `json:"last_error,omitempty"`
+
+	//Repository is the full name of the repository of the gitlab and bitbucket deliveries, they don't have an installation
+	Repository string `json:"repository,omitempty" gorm:"index"`
+
+	//ProjectID is the project whose webhook sent a gitlab or bitbucket delivery
+	ProjectID string `json:"-" gorm:"index"`
+
+	//Key is the ref or the pull request of the delivery, the deliveries with the same key are processed in order
+	Key string `json:"-" gorm:"index"`
+
+	//NextAttemptAt is when the delivery will be processed again
+	NextAttemptAt time.Time `json:"next_attempt,omitempty"`
 }
 
 const (
+	//BranchRefPrefix is the prefix of the git refs of branches
+	BranchRefPrefix = "refs/heads/"
+
+	//TagRefPrefix is the prefix of the git refs of tags
+	TagRefPrefix = "refs/tags/"
+
+	//GithubProvider is github.com
+	GithubProvider = SCMProviderType("github")
+
+	//GitlabProvider is gitlab.com or a self-managed gitlab instance
+	GitlabProvider = SCMProviderType("gitlab")
+
+	//BitbucketProvider is bitbucket cloud
+	BitbucketProvider = SCMProviderType("bitbucket")
+
 	//GHUser a github user
 	GHUser = GHScope("User")
 
 	//GHOrganization a github org
 	GHOrganization = GHScope("Organization")
+
+	//PendingDelivery is the status of a delivery waiting to be processed
+	PendingDelivery = GHDeliveryStatus("pending")
+
+	//ProcessingDelivery is the status of a delivery while it's processed
+	ProcessingDelivery = GHDeliveryStatus("processing")
+
+	//ProcessedDelivery is the status of a delivery that was processed
+	ProcessedDelivery = GHDeliveryStatus("processed")
+
+	//FailedDelivery is the status of a delivery that failed every attempt
+	FailedDelivery = GHDeliveryStatus("failed")
 )
@@ -0,0 +1,85 @@
+package model
+
+import "testing"
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		file     string
+		expected bool
+	}{
+		{pattern: "frontend/**", file: "frontend/src/app.js", expected: true},
+		{pattern: "frontend/**", file: "frontend", expected: false},
+		{pattern: "frontend/", file: "frontend/package.json", expected: true},
+		{pattern: "/frontend/", file: "frontend/package.json", expected: true},
+		{pattern: "frontend/*", file: "frontend/src/app.js", expected: false},
+		{pattern: "frontend/*.js", file: "frontend/app.js", expected: true},
+		{pattern: "**/*.go", file: "main.go", expected: true},
+		{pattern: "**/*.go", file: "backend/cmd/main.go", expected: true},
+		{pattern: "backend/**/*.go", file: "backend/main.go", expected: true},
+		{pattern: "backend/**/*.go", file: "backendx/main.go", expected: false},
+		{pattern: "v?.txt", file: "v1.txt", expected: true},
+		{pattern: "v?.txt", file: "v10.txt", expected: false},
+		{pattern: "docs/(draft).md", file: "docs/(draft).md", expected: true},
+		{pattern: "Dockerfile", file: "backend/Dockerfile", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"-"+tt.file, func(t *testing.T) {
+			if got := MatchPathGlob(tt.pattern, tt.file); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGHLinkManifestIsAffectedBy(t *testing.T) {
+	m := &GHLinkManifest{Manifest: "api/okteto.yml", Overlay: "api/overlay.yml", Paths: " api/** , shared/ "}
+	tests := []struct {
+		name     string
+		manifest *GHLinkManifest
+		files    []string
+		expected bool
+	}{
+		{name: "unknown-files", manifest: m, files: nil, expected: true},
+		{name: "no-files", manifest: m, files: []string{}, expected: false},
+		{name: "path", manifest: m, files: []string{"README.md", "shared/types.go"}, expected: true},
+		{name: "unrelated", manifest: m, files: []string{"web/index.html"}, expected: false},
+		{name: "without-paths", manifest: &GHLinkManifest{Manifest: "okteto.yml"}, files: []string{"web/index.html"}, expected: true},
+		{name: "manifest", manifest: &GHLinkManifest{Manifest: "deploy/okteto.yml", Paths: "api/"}, files: []string{"deploy/okteto.yml"}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.manifest.IsAffectedBy(tt.files); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGHRepoLinkMatchesRef(t *testing.T) {
+	tests := []struct {
+		branch   string
+		ref      string
+		expected bool
+	}{
+		{branch: "refs/heads/master", ref: "refs/heads/master", expected: true},
+		{branch: "refs/heads/master", ref: "refs/heads/main", expected: false},
+		{branch: "refs/heads/release/*", ref: "refs/heads/release/1.0", expected: true},
+		{branch: "refs/heads/release/*", ref: "refs/heads/release/1.0/hotfix", expected: false},
+		{branch: "refs/heads/release/**", ref: "refs/heads/release/1.0/hotfix", expected: true},
+		{branch: "refs/tags/v*", ref: "refs/tags/v1.2.0", expected: true},
+		{branch: "refs/tags/v*", ref: "refs/heads/v1.2.0", expected: false},
+		{branch: "refs/tags/v?", ref: "refs/tags/v10", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch+"-"+tt.ref, func(t *testing.T) {
+			l := &GHRepoLink{Branch: tt.branch}
+			if got := l.MatchesRef(tt.ref); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
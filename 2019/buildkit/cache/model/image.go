@@ -0,0 +1,90 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	//DockerHub is the registry of the images that don't declare one
+	DockerHub = "docker.io"
+
+	defaultTag = "latest"
+)
+
+var isDigest = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`).MatchString
+
+//ImageReference is a container image split in the parts used by the registry API, e.g. nginx:alpine is
+//registry docker.io, repository library/nginx and tag alpine
+type ImageReference struct {
+	Name       string
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+//ParseImageReference returns the ImageReference of image, with the syntax [REGISTRY/]REPOSITORY[:TAG][@DIGEST]
+func ParseImageReference(image string) (*ImageReference, error) {
+	if image == "" || strings.TrimSpace(image) != image {
+		return nil, fmt.Errorf("'%s' is not a valid image", image)
+	}
+
+	r := &ImageReference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		r.Digest = name[i+1:]
+		name = name[:i]
+		if !isDigest(r.Digest) {
+			return nil, fmt.Errorf("'%s' is not a valid digest", r.Digest)
+		}
+	}
+
+	// the tag is after the last colon, unless it's the port of the registry
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		r.Tag = name[i+1:]
+		name = name[:i]
+	}
+
+	if name == "" || strings.HasSuffix(name, "/") {
+		return nil, fmt.Errorf("'%s' is not a valid image", image)
+	}
+
+	r.Name = name
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		r.Registry = parts[0]
+		r.Repository = parts[1]
+	} else {
+		r.Registry = DockerHub
+		r.Repository = name
+		if len(parts) == 1 {
+			r.Repository = "library/" + name
+		}
+	}
+
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = defaultTag
+	}
+
+	return r, nil
+}
+
+//IsPinned returns true if r references an image by digest, the content of a tag can change between deployments
+func (r *ImageReference) IsPinned() bool {
+	return r.Digest != ""
+}
+
+//Reference returns the tag or digest requested to the registry
+func (r *ImageReference) Reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+//Pin returns the image of r referenced by digest
+func (r *ImageReference) Pin(digest string) string {
+	return fmt.Sprintf("%s@%s", r.Name, digest)
+}
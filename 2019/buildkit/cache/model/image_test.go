@@ -0,0 +1,87 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	digest := "sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"
+	tests := []struct {
+		image    string
+		expected *ImageReference
+	}{
+		{
+			image:    "nginx",
+			expected: &ImageReference{Name: "nginx", Registry: DockerHub, Repository: "library/nginx", Tag: "latest"},
+		},
+		{
+			image:    "nginx:alpine",
+			expected: &ImageReference{Name: "nginx", Registry: DockerHub, Repository: "library/nginx", Tag: "alpine"},
+		},
+		{
+			image:    "okteto/api:1.0",
+			expected: &ImageReference{Name: "okteto/api", Registry: DockerHub, Repository: "okteto/api", Tag: "1.0"},
+		},
+		{
+			image:    "gcr.io/okteto/api",
+			expected: &ImageReference{Name: "gcr.io/okteto/api", Registry: "gcr.io", Repository: "okteto/api", Tag: "latest"},
+		},
+		{
+			image:    "registry:5000/api:dev",
+			expected: &ImageReference{Name: "registry:5000/api", Registry: "registry:5000", Repository: "api", Tag: "dev"},
+		},
+		{
+			image:    "localhost/api",
+			expected: &ImageReference{Name: "localhost/api", Registry: "localhost", Repository: "api", Tag: "latest"},
+		},
+		{
+			image:    "nginx@" + digest,
+			expected: &ImageReference{Name: "nginx", Registry: DockerHub, Repository: "library/nginx", Digest: digest},
+		},
+		{
+			image:    "nginx:alpine@" + digest,
+			expected: &ImageReference{Name: "nginx", Registry: DockerHub, Repository: "library/nginx", Tag: "alpine", Digest: digest},
+		},
+		{image: ""},
+		{image: "nginx@sha256:abc"},
+		{image: "nginx "},
+		{image: ":latest"},
+		{image: "gcr.io/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			r, err := ParseImageReference(tt.image)
+			if tt.expected == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", r)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(r, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, r)
+			}
+
+			if r.IsPinned() != (tt.expected.Digest != "") {
+				t.Errorf("IsPinned() returned %t", r.IsPinned())
+			}
+		})
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	r, err := ParseImageReference("registry:5000/api:dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pinned := r.Pin("sha256:abc"); pinned != "registry:5000/api@sha256:abc" {
+		t.Errorf("got %s", pinned)
+	}
+}
@@ -0,0 +1,208 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	//ProjectVariable is the builtin variable with the name of the project
+	ProjectVariable = "OKTETO_PROJECT"
+
+	//ServiceVariable is the builtin variable with the name of the service
+	ServiceVariable = "OKTETO_SERVICE"
+
+	//BranchVariable is the builtin variable with the branch of the linked repository
+	BranchVariable = "OKTETO_BRANCH"
+
+	//CommitVariable is the builtin variable with the commit SHA of the linked repository
+	CommitVariable = "OKTETO_COMMIT"
+
+	//RefVariable is the builtin variable with the name of the branch or tag of the linked repository. The characters
+	//that are not valid in an image tag are replaced by '-', so it can be used to tag the images of a release
+	RefVariable = "OKTETO_REF"
+
+	maxImageTagLength = 128
+)
+
+var (
+	variableReference    = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+	isVariableName       = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`).MatchString
+	invalidImageTagChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+)
+
+//GetRefVariable returns the value of RefVariable for a branch or tag ref
+func GetRefVariable(ref string) string {
+	value := invalidImageTagChars.ReplaceAllString(GetRefName(ref), "-")
+	value = strings.TrimLeft(value, ".-")
+	if len(value) > maxImageTagLength {
+		value = value[:maxImageTagLength]
+	}
+
+	return value
+}
+
+// interpolate replaces every ${VAR} and ${VAR:-default} reference in the values and keys of the yaml document m with
+// its value in vars. $${ is an escaped ${ and is kept verbatim. References without braces, like $PROJECT_NAME, are
+// ignored. The values are substituted in the parsed document, so a value can't add keys to the manifest: a scalar that
+// is a single reference keeps the type of its value only if it's a number, a boolean or null, otherwise it's a string
+func interpolate(m []byte, vars map[string]string) ([]byte, *AppError) {
+	if !variableReference.Match(m) {
+		return m, nil
+	}
+
+	// nested maps are decoded as MapSlices too, which keeps the order of the keys
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(m, &doc); err != nil {
+		return nil, &AppError{Status: http.StatusBadRequest, Code: InvalidYAML, Message: err.Error()}
+	}
+
+	result, appErr := interpolateValue(doc, vars)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	out, err := yaml.Marshal(result)
+	if err != nil {
+		return nil, &AppError{Status: http.StatusBadRequest, Code: InvalidYAML, Message: err.Error()}
+	}
+
+	return out, nil
+}
+
+func interpolateValue(v interface{}, vars map[string]string) (interface{}, *AppError) {
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		result := make(yaml.MapSlice, 0, len(value))
+		for _, item := range value {
+			key := item.Key
+			if k, ok := key.(string); ok {
+				interpolated, appErr := interpolateString(k, vars)
+				if appErr != nil {
+					return nil, appErr
+				}
+				key = interpolated
+			}
+
+			interpolated, appErr := interpolateValue(item.Value, vars)
+			if appErr != nil {
+				return nil, appErr
+			}
+
+			result = append(result, yaml.MapItem{Key: key, Value: interpolated})
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			interpolated, appErr := interpolateValue(item, vars)
+			if appErr != nil {
+				return nil, appErr
+			}
+			result = append(result, interpolated)
+		}
+		return result, nil
+	case string:
+		interpolated, appErr := interpolateString(value, vars)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		if interpolated == value {
+			return value, nil
+		}
+
+		return resolveScalar(interpolated), nil
+	}
+
+	return v, nil
+}
+
+// resolveScalar returns the number, boolean or null that s is the canonical yaml form of, e.g. replicas: ${REPLICAS}.
+// Any other value, including maps, sequences and non canonical forms like 1.10, is kept as a string
+func resolveScalar(s string) interface{} {
+	var resolved interface{}
+	if err := yaml.Unmarshal([]byte(s), &resolved); err != nil {
+		return s
+	}
+
+	switch resolved.(type) {
+	case nil, int, int64, uint64, float64, bool:
+		out, err := yaml.Marshal(resolved)
+		if err == nil && strings.TrimSpace(string(out)) == s {
+			return resolved
+		}
+	}
+
+	return s
+}
+
+func interpolateString(s string, vars map[string]string) (string, *AppError) {
+	var appErr *AppError
+	result := variableReference.ReplaceAllStringFunc(s, func(match string) string {
+		if appErr != nil {
+			return match
+		}
+
+		if match == "$${" {
+			return "${"
+		}
+
+		reference := match[2 : len(match)-1]
+		name := reference
+		defaultValue := ""
+		hasDefault := false
+		if i := strings.Index(reference, ":-"); i >= 0 {
+			name = reference[:i]
+			defaultValue = reference[i+2:]
+			hasDefault = true
+		}
+
+		if !isVariableName(name) {
+			appErr = &AppError{
+				Status:  http.StatusBadRequest,
+				Code:    InvalidVariable,
+				Data:    map[string]string{"variable": name},
+				Message: fmt.Sprintf("'${%s}' is not a valid variable reference", reference)}
+			return match
+		}
+
+		if value, ok := vars[name]; ok {
+			return value
+		}
+
+		if hasDefault {
+			return defaultValue
+		}
+
+		appErr = &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    UndefinedVariable,
+			Data:    map[string]string{"variable": name},
+			Message: fmt.Sprintf("Variable '%s' is not defined", name)}
+		return match
+	})
+
+	if appErr != nil {
+		return "", appErr
+	}
+
+	return result, nil
+}
+
+func validateVariables(vars map[string]string) *AppError {
+	for name := range vars {
+		if !isVariableName(name) {
+			return &AppError{
+				Status:  http.StatusBadRequest,
+				Code:    InvalidVariable,
+				Data:    map[string]string{"variable": name},
+				Message: fmt.Sprintf("'%s' is not a valid variable name", name)}
+		}
+	}
+	return nil
+}
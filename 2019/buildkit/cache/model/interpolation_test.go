@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{
+		"NAME":      "api",
+		"EMPTY":     "",
+		"COUNT":     "3",
+		"VERSION":   "1.10",
+		"MULTILINE": "api\nreplicas: 5",
+		"MAP":       "{replicas: 5}",
+		"COLON":     "okteto: api",
+	}
+	tests := []struct {
+		name     string
+		manifest string
+		expected string
+		err      AppErrorCode
+	}{
+		{name: "variable", manifest: "name: ${NAME}", expected: "name: api"},
+		{name: "empty-variable", manifest: "name: x${EMPTY}", expected: "name: x"},
+		{name: "default", manifest: "replicas: ${REPLICAS:-2}", expected: "replicas: 2"},
+		{name: "defined-with-default", manifest: "name: ${NAME:-web}", expected: "name: api"},
+		{name: "empty-default", manifest: "tag: ${TAG:-}", expected: `tag: ""`},
+		{name: "escaped", manifest: "cmd: $${NAME}", expected: "cmd: ${NAME}"},
+		{name: "no-braces", manifest: "host: $PROJECT_NAME", expected: "host: $PROJECT_NAME"},
+		{name: "typed", manifest: "replicas: ${COUNT}", expected: "replicas: 3"},
+		{name: "non-canonical-number", manifest: "tag: ${VERSION}", expected: `tag: "1.10"`},
+		{name: "newline", manifest: "name: ${MULTILINE}", expected: "name: |-\n  api\n  replicas: 5"},
+		{name: "map", manifest: "name: ${MAP}", expected: `name: '{replicas: 5}'`},
+		{name: "colon", manifest: "name: ${COLON}", expected: `name: 'okteto: api'`},
+		{name: "key", manifest: "containers:\n  ${NAME}:\n    image: okteto/${NAME}", expected: "containers:\n  api:\n    image: okteto/api"},
+		{name: "sequence", manifest: "environment:\n- NAME=${NAME}", expected: "environment:\n- NAME=api"},
+		{name: "undefined", manifest: "name: ${MISSING}", err: UndefinedVariable},
+		{name: "invalid", manifest: "name: ${NOT-VALID}", err: InvalidVariable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := interpolate([]byte(tt.manifest), vars)
+			if tt.err != "" {
+				if err == nil {
+					t.Fatalf("didn't get the expected error %s", tt.err)
+				}
+				if err.Code != tt.err {
+					t.Errorf("expected %s, got %s", tt.err, err.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got unexpected error: %s", err.Error())
+			}
+
+			if strings.TrimSuffix(string(result), "\n") != tt.expected {
+				t.Errorf("expected '%s', got '%s'", tt.expected, string(result))
+			}
+		})
+	}
+}
+
+func TestGetRefVariable(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{ref: "refs/tags/v1.2.0", expected: "v1.2.0"},
+		{ref: "refs/heads/master", expected: "master"},
+		{ref: "refs/heads/release/1.0", expected: "release-1.0"},
+		{ref: "refs/heads/feature/#12+fix", expected: "feature-12-fix"},
+		{ref: "refs/tags/.hidden", expected: "hidden"},
+		{ref: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := GetRefVariable(tt.ref); got != tt.expected {
+				t.Errorf("expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
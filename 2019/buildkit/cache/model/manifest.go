@@ -3,7 +3,9 @@ package model
 import (
 	"encoding/base64"
 	"fmt"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"bitbucket.org/okteto/okteto/backend/logger"
@@ -11,75 +13,359 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
-var cannotUnmarshall = regexp.MustCompile(`line (\d*): cannot unmarshal (!!.*) into (.*)`)
+var (
+	cannotUnmarshall = regexp.MustCompile(`line (\d*): cannot unmarshal (!!.*) into (.*)`)
+	unknownField     = regexp.MustCompile(`line (\d*): field (\S*) not found in struct (.*)`)
+	linePrefix       = regexp.MustCompile(`^line \d*: `)
+)
+
+// parseManifest returns the Service defined in m. If strict is true, fields that don't exist in the manifest schema are
+// reported as errors instead of being ignored. The service is validated against the policies of settings if it isn't nil.
+// The fields with the wrong type don't stop the validation, every problem of the manifest is returned at once
+func parseManifest(m []byte, strict bool, settings *ProjectSettings) (*Service, *AppError) {
+	service, errs, appErr := decodeManifest(m, strict)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return validateManifest(service, m, errs, settings)
+}
 
-func parseManifest(m []byte) (*Service, *AppError) {
+// decodeManifest returns the Service defined in m and an error for each field of m that couldn't be decoded. The
+// returned *AppError is only set if m isn't a valid yaml document
+func decodeManifest(m []byte, strict bool) (*Service, []*AppError, *AppError) {
 	var service Service
-	err := yaml.Unmarshal(m, &service)
+	var err error
+	if strict {
+		err = yaml.UnmarshalStrict(m, &service)
+	} else {
+		err = yaml.Unmarshal(m, &service)
+	}
 
+	errs := []*AppError{}
 	if err != nil {
-		if v, ok := err.(*yaml.TypeError); ok {
-			return nil, translateYamlTypeError(v)
+		v, ok := err.(*yaml.TypeError)
+		if !ok {
+			return nil, nil, &AppError{Status: 400, Code: InvalidYAML, Message: err.Error()}
 		}
 
-		return nil, &AppError{Status: 400, Code: InvalidYAML, Message: err.Error()}
+		errs = translateYamlErrors(v, getUnknownFields(m))
 	}
 
-	return &service, service.Validate()
+	return &service, errs, nil
 }
 
-func translateYamlTypeError(err *yaml.TypeError) *AppError {
-	if len(err.Errors) > 0 {
-		if matches := cannotUnmarshall.FindStringSubmatch(err.Errors[0]); matches != nil {
-			received := "unknown"
+// validateManifest validates service, decoded from m, and returns it if neither errs nor the validation have errors.
+// The validation errors of the fields that couldn't be decoded are left out, they are a consequence of the decode error
+func validateManifest(service *Service, m []byte, errs []*AppError, settings *ProjectSettings) (*Service, *AppError) {
+	if appErr := service.Validate(settings); appErr != nil {
+		undecodable := []string{}
+		if len(errs) > 0 {
+			undecodable = getUndecodableFields(m)
+		}
+
+		for _, e := range appErr.Errors {
+			if !isRelatedToAny(e.Field, undecodable) {
+				errs = append(errs, e)
+			}
+		}
+	}
 
-			// the error for a string includes the value (e.g. !!str `hello`), we ignore it.
-			recievedType := strings.Split(matches[2], " ")[0]
-			switch recievedType {
-			case "!!seq":
-				received = "sequence"
+	if len(errs) > 0 {
+		return nil, newAppErrorList(errs)
+	}
 
-			case "!!map":
-				received = "map"
+	return service, nil
+}
 
-			case "!!str":
-				received = "string"
+// checkManifestSource returns the errors of the fields of m, a manifest or an overlay before it's interpolated, that
+// can't be decoded. The lines with variable references are skipped since their type is only known after interpolating
+func checkManifestSource(m []byte, strict bool) ([]*AppError, *AppError) {
+	_, errs, appErr := decodeManifest(m, strict)
+	if appErr != nil {
+		return nil, appErr
+	}
 
-			case "!!bool":
-				received = "boolean"
-			default:
-				logger.Error(fmt.Errorf("unknown received type: '%s' \n yaml error: %s", matches[2], err.Errors[0]))
+	lines := strings.Split(string(m), "\n")
+	result := []*AppError{}
+	for _, e := range errs {
+		line, err := strconv.Atoi(e.Data["line"])
+		if err == nil && line > 0 && line <= len(lines) && hasVariableReference(lines[line-1]) {
+			continue
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+func hasVariableReference(s string) bool {
+	for _, match := range variableReference.FindAllString(s, -1) {
+		if match != "$${" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getUndecodableFields returns the paths of the deepest fields of the manifest m that can't be decoded, e.g.
+// containers.web.ports[1] or replicas
+func getUndecodableFields(m []byte) []string {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(m, &doc); err != nil {
+		return nil
+	}
+
+	return findUndecodableFields(doc, reflect.TypeOf(Service{}), "")
+}
+
+func findUndecodableFields(v interface{}, t reflect.Type, path string) []string {
+	if isDecodable(v, t) {
+		return nil
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	undecodable := []string{}
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := getYAMLFields(t)
+			for _, item := range value {
+				key := fmt.Sprint(item.Key)
+				if f, ok := fields[key]; ok {
+					undecodable = append(undecodable, findUndecodableFields(item.Value, f.Type, joinPath(path, key))...)
+				}
 			}
+		case reflect.Map:
+			for _, item := range value {
+				undecodable = append(undecodable, findUndecodableFields(item.Value, t.Elem(), joinPath(path, fmt.Sprint(item.Key)))...)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice {
+			for i, item := range value {
+				undecodable = append(undecodable, findUndecodableFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	if len(undecodable) == 0 {
+		return []string{path}
+	}
+
+	return undecodable
+}
 
-			expected := "map"
-			if strings.Contains(matches[3], "[]") {
-				expected = "sequence"
-			} else {
-				switch matches[3] {
-				case "string":
-					expected = "string"
-				case "bool":
-					expected = "boolean"
-				default:
-					expected = "map"
+func isDecodable(v interface{}, t reflect.Type) bool {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return false
+	}
+
+	return yaml.Unmarshal(b, reflect.New(t).Interface()) == nil
+}
+
+// isRelatedToAny returns true if field is one of paths, is inside of one of them or contains one of them
+func isRelatedToAny(field string, paths []string) bool {
+	for _, p := range paths {
+		if p == "" || field == p || isInsidePath(field, p) || isInsidePath(p, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isInsidePath(field, path string) bool {
+	return strings.HasPrefix(field, path+".") || strings.HasPrefix(field, path+"[")
+}
+
+func translateYamlTypeError(err *yaml.TypeError) *AppError {
+	if len(err.Errors) == 0 {
+		return &AppError{Status: 400, Code: InvalidYAML, Message: err.Error()}
+	}
+
+	return newAppErrorList(translateYamlErrors(err, nil))
+}
+
+// translateYamlErrors returns an error for each problem of err. unknownFields has the paths of the unknown fields of the
+// manifest in the order yaml reports them, they replace the key in the Field of the errors
+func translateYamlErrors(err *yaml.TypeError, unknownFields []string) []*AppError {
+	errs := []*AppError{}
+	for _, e := range err.Errors {
+		appErr := translateYamlError(e)
+		if appErr.Code == UnknownField {
+			for i, path := range unknownFields {
+				if getPathKey(path) == appErr.Field {
+					appErr.Field = path
+					unknownFields = unknownFields[i+1:]
+					break
 				}
 			}
+		}
+
+		errs = append(errs, appErr)
+	}
+
+	return errs
+}
+
+// getUnknownFields returns the paths of the keys of the manifest m that are not fields of a Service, e.g.
+// containers.web.imag, in the order they are written
+func getUnknownFields(m []byte) []string {
+	// nested maps are decoded as MapSlices too, which keeps the order of the keys
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(m, &doc); err != nil {
+		return nil
+	}
+
+	return findUnknownFields(doc, reflect.TypeOf(Service{}), "")
+}
 
-			return &AppError{
-				Status:  400,
-				Code:    InvalidYAMLWithInfo,
-				Message: err.Error(),
-				Data: map[string]string{
-					"line":     matches[1],
-					"expected": expected,
-					"received": received,
-				},
+func findUnknownFields(v interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	unknown := []string{}
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := getYAMLFields(t)
+			for _, item := range value {
+				key := fmt.Sprint(item.Key)
+				f, ok := fields[key]
+				if !ok {
+					unknown = append(unknown, joinPath(path, key))
+					continue
+				}
+
+				unknown = append(unknown, findUnknownFields(item.Value, f.Type, joinPath(path, key))...)
+			}
+		case reflect.Map:
+			for _, item := range value {
+				unknown = append(unknown, findUnknownFields(item.Value, t.Elem(), joinPath(path, fmt.Sprint(item.Key)))...)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice {
+			for i, item := range value {
+				unknown = append(unknown, findUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return unknown
+}
+
+// getYAMLFields returns the fields of the struct t by their yaml key, with the same rules as the yaml decoder
+func getYAMLFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+
+		if len(tag) > 1 && tag[1] == "inline" {
+			for k, inlined := range getYAMLFields(f.Type) {
+				fields[k] = inlined
+			}
+			continue
+		}
+
+		fields[getYAMLFieldName(f)] = f
+	}
+
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// getPathKey returns the last key of a path, e.g. imag for containers.web.imag
+func getPathKey(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+func translateYamlError(e string) *AppError {
+	if matches := unknownField.FindStringSubmatch(e); matches != nil {
+		return &AppError{
+			Status:  400,
+			Code:    UnknownField,
+			Message: e,
+			Field:   matches[2],
+			Data: map[string]string{
+				"line":  matches[1],
+				"field": matches[2],
+			},
+		}
+	}
+
+	if matches := cannotUnmarshall.FindStringSubmatch(e); matches != nil {
+		received := "unknown"
+
+		// the error for a string includes the value (e.g. !!str `hello`), we ignore it.
+		recievedType := strings.Split(matches[2], " ")[0]
+		switch recievedType {
+		case "!!seq":
+			received = "sequence"
+
+		case "!!map":
+			received = "map"
+
+		case "!!str":
+			received = "string"
+
+		case "!!bool":
+			received = "boolean"
+		default:
+			logger.Error(fmt.Errorf("unknown received type: '%s' \n yaml error: %s", matches[2], e))
+		}
+
+		expected := "map"
+		if strings.Contains(matches[3], "[]") {
+			expected = "sequence"
+		} else {
+			switch matches[3] {
+			case "string":
+				expected = "string"
+			case "bool":
+				expected = "boolean"
+			default:
+				expected = "map"
 			}
 		}
 
+		return &AppError{
+			Status:  400,
+			Code:    InvalidYAMLWithInfo,
+			Message: e,
+			Data: map[string]string{
+				"line":     matches[1],
+				"expected": expected,
+				"received": received,
+			},
+		}
 	}
 
-	return &AppError{Status: 400, Code: InvalidYAML, Message: err.Error()}
+	return &AppError{Status: 400, Code: InvalidYAML, Message: e}
 }
 
 // ParseEncodedManifest decodes m and returns a instance of Service
@@ -89,5 +375,78 @@ func ParseEncodedManifest(m string) (*Service, *AppError) {
 		return nil, &AppError{Status: 400, Code: InvalidBase64}
 	}
 
-	return parseManifest(decodedManifest)
+	return parseManifest(decodedManifest, false, nil)
+}
+
+// RenderEncodedManifest decodes m and its overlays, interpolates vars in each of them, merges the overlays on top of m and
+// returns a instance of the resulting Service. If strict is true, unknown fields are reported as errors. If settings isn't
+// nil, the resulting Service must comply with its policies. The lines of the errors are the ones of m or, if they have
+// the overlay data, of the overlay with that index
+func RenderEncodedManifest(m string, overlays []string, vars map[string]string, strict bool, settings *ProjectSettings) (*Service, *AppError) {
+	decodedManifest, err := base64.StdEncoding.DecodeString(m)
+	if err != nil {
+		return nil, &AppError{Status: 400, Code: InvalidBase64}
+	}
+
+	// the lines of the interpolated and merged manifest don't match the ones the user wrote
+	errs, appErr := checkManifestSource(decodedManifest, strict)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	manifest, appErr := interpolate(decodedManifest, vars)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	decodedOverlays := [][]byte{}
+	for i, o := range overlays {
+		decodedOverlay, err := base64.StdEncoding.DecodeString(o)
+		if err != nil {
+			return nil, &AppError{Status: 400, Code: InvalidBase64}
+		}
+
+		overlayErrs, appErr := checkManifestSource(decodedOverlay, strict)
+		if appErr != nil {
+			return nil, &AppError{Status: 400, Code: InvalidOverlay, Message: appErr.Message}
+		}
+
+		for _, e := range overlayErrs {
+			if e.Data == nil {
+				e.Data = map[string]string{}
+			}
+			e.Data["overlay"] = strconv.Itoa(i)
+		}
+		errs = append(errs, overlayErrs...)
+
+		overlay, appErr := interpolate(decodedOverlay, vars)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		decodedOverlays = append(decodedOverlays, overlay)
+	}
+
+	if len(decodedOverlays) > 0 {
+		manifest, appErr = mergeManifests(manifest, decodedOverlays...)
+		if appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	service, renderedErrs, appErr := decodeManifest(manifest, strict)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if len(errs) == 0 {
+		// the remaining errors come from the value of a variable, their line is the one of the rendered manifest
+		for _, e := range renderedErrs {
+			delete(e.Data, "line")
+			e.Message = linePrefix.ReplaceAllString(e.Message, "")
+		}
+		errs = renderedErrs
+	}
+
+	return validateManifest(service, manifest, errs, settings)
 }
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -97,6 +100,11 @@ func Test_translateYamlError(t *testing.T) {
 			err:      &yaml.TypeError{Errors: []string{"line 4: cannot unmarshal !!str `aha` into bool"}},
 			expected: map[string]string{"line": "4", "expected": "boolean", "received": "string"},
 		},
+		{
+			name:     "unknown-field",
+			err:      &yaml.TypeError{Errors: []string{"line 3: field replica not found in struct model.Service"}},
+			expected: map[string]string{"line": "3", "field": "replica"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -108,3 +116,137 @@ func Test_translateYamlError(t *testing.T) {
 		})
 	}
 }
+
+func TestParseManifestStrict(t *testing.T) {
+	manifest := []byte(`name: silent-sky
+replica: 3
+containers:
+  site:
+    image: okteto/welcome
+    enviroment:
+      - A=B
+`)
+
+	if _, appErr := parseManifest(manifest, false, nil); appErr != nil {
+		t.Fatalf("unexpected error when parsing without strict: %+v", appErr)
+	}
+
+	_, appErr := parseManifest(manifest, true, nil)
+	if appErr == nil {
+		t.Fatal("unknown fields didn't fail")
+	}
+
+	if appErr.Code != UnknownField {
+		t.Errorf("expected %s, got %s", UnknownField, appErr.Code)
+	}
+
+	if len(appErr.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %+v", appErr.Errors)
+	}
+
+	expected := []map[string]string{
+		{"line": "2", "field": "replica"},
+		{"line": "6", "field": "enviroment"},
+	}
+	for i, e := range expected {
+		if !reflect.DeepEqual(appErr.Errors[i].Data, e) {
+			t.Errorf("expected %+v, got %+v", e, appErr.Errors[i].Data)
+		}
+	}
+
+	if appErr.Errors[0].Field != "replica" || appErr.Errors[1].Field != "containers.site.enviroment" {
+		t.Errorf("the errors don't have the path of the unknown fields: %s, %s", appErr.Errors[0].Field, appErr.Errors[1].Field)
+	}
+}
+
+func TestParseManifestReportsEveryError(t *testing.T) {
+	manifest := []byte(`name: silent-sky
+replicas: many
+tolerations:
+  - key: spot
+    operatr: Exists
+containers:
+  site:
+    imag: okteto/welcome
+    environment: A=B
+`)
+
+	_, appErr := parseManifest(manifest, true, nil)
+	if appErr == nil {
+		t.Fatal("the manifest didn't fail")
+	}
+
+	got := []string{}
+	for _, e := range appErr.Errors {
+		got = append(got, fmt.Sprintf("%s:%s", e.Code, e.Field))
+	}
+
+	expected := []string{
+		fmt.Sprintf("%s:", InvalidYAMLWithInfo),
+		fmt.Sprintf("%s:tolerations[0].operatr", UnknownField),
+		fmt.Sprintf("%s:containers.site.imag", UnknownField),
+		fmt.Sprintf("%s:", InvalidYAMLWithInfo),
+		fmt.Sprintf("%s:containers.site.image", MissingContainerImage),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the errors %v, got %v", expected, got)
+	}
+}
+
+func Test_getUnknownFields(t *testing.T) {
+	manifest := []byte(`name: test
+id: abc
+model:
+  id: abc
+volumes:
+  data:
+    persistent: true
+    sise: 10Gi
+configs:
+  nginx:
+    content: a
+    path: /etc
+containers:
+  web:
+    image: okteto/web
+    mounts:
+      data:
+        path: /data
+        readonly: true
+    environment:
+      - A=B
+unknown:
+  image: okteto/web
+`)
+
+	expected := []string{"id", "volumes.data.sise", "configs.nginx.path", "containers.web.mounts.data.readonly", "unknown"}
+	if got := getUnknownFields(manifest); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseExamplesStrict(t *testing.T) {
+	examples, err := filepath.Glob("./examples/service*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range examples {
+		t.Run(e, func(t *testing.T) {
+			m, err := ioutil.ReadFile(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m, appErr := interpolate(m, map[string]string{CommitVariable: "a1b2c3"})
+			if appErr != nil {
+				t.Fatal(appErr)
+			}
+
+			var s Service
+			if err := yaml.UnmarshalStrict(m, &s); err != nil {
+				t.Errorf("%s is not a valid manifest: %s", e, err)
+			}
+		})
+	}
+}
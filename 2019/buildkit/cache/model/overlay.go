@@ -0,0 +1,72 @@
+package model
+
+import (
+	"net/http"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// mergeManifests applies overlays, in order, on top of the manifest b.
+// Maps are merged key by key, any other value in an overlay replaces the base value, and a null value removes the key.
+func mergeManifests(b []byte, overlays ...[]byte) ([]byte, *AppError) {
+	// nested maps are decoded as MapSlices too, which keeps the order of the keys
+	var base yaml.MapSlice
+	if err := yaml.Unmarshal(b, &base); err != nil {
+		return nil, &AppError{Status: http.StatusBadRequest, Code: InvalidYAML, Message: err.Error()}
+	}
+
+	var merged interface{} = base
+	for _, o := range overlays {
+		var overlay yaml.MapSlice
+		if err := yaml.Unmarshal(o, &overlay); err != nil {
+			return nil, &AppError{Status: http.StatusBadRequest, Code: InvalidOverlay, Message: err.Error()}
+		}
+
+		merged = mergeValues(merged, overlay)
+	}
+
+	result, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, &AppError{Status: http.StatusBadRequest, Code: InvalidOverlay, Message: err.Error()}
+	}
+
+	return result, nil
+}
+
+func mergeValues(base, overlay interface{}) interface{} {
+	b, ok := base.(yaml.MapSlice)
+	if !ok {
+		return overlay
+	}
+
+	o, ok := overlay.(yaml.MapSlice)
+	if !ok {
+		return overlay
+	}
+
+	result := append(yaml.MapSlice{}, b...)
+	for _, item := range o {
+		i := indexOfKey(result, item.Key)
+		switch {
+		case item.Value == nil:
+			if i >= 0 {
+				result = append(result[:i], result[i+1:]...)
+			}
+		case i < 0:
+			result = append(result, item)
+		default:
+			result[i].Value = mergeValues(result[i].Value, item.Value)
+		}
+	}
+
+	return result
+}
+
+func indexOfKey(m yaml.MapSlice, key interface{}) int {
+	for i, item := range m {
+		if item.Key == key {
+			return i
+		}
+	}
+	return -1
+}
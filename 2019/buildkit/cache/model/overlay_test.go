@@ -0,0 +1,149 @@
+package model
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeManifests(t *testing.T) {
+	base := []byte(`
+name: api
+replicas: 1
+labels:
+  team: core
+  tier: backend
+containers:
+  api:
+    image: okteto/api
+    ports:
+      - 8080
+`)
+	overlay := []byte(`
+replicas: 3
+labels:
+  tier: ~
+  env: staging
+containers:
+  api:
+    ports:
+      - 9090
+`)
+
+	merged, err := mergeManifests(base, overlay)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+
+	s, err := parseManifest(merged, true, nil)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+
+	if s.Name != "api" || s.Replicas != 3 {
+		t.Errorf("wrong service: %+v", s)
+	}
+	if !reflect.DeepEqual(s.Labels, map[string]string{"team": "core", "env": "staging"}) {
+		t.Errorf("wrong labels: %+v", s.Labels)
+	}
+	if s.Containers["api"].Image != "okteto/api" || !reflect.DeepEqual(s.Containers["api"].Ports, []string{"9090"}) {
+		t.Errorf("wrong container: %+v", s.Containers["api"])
+	}
+
+	if _, err := mergeManifests(base, []byte("- not a map")); err == nil || err.Code != InvalidOverlay {
+		t.Errorf("expected %s, got %+v", InvalidOverlay, err)
+	}
+}
+
+func TestRenderEncodedManifest(t *testing.T) {
+	manifest, err := ioutil.ReadFile("./examples/service_with_variables.yml")
+	require.NoError(t, err)
+	overlay, err := ioutil.ReadFile("./examples/service_overlay_staging.yml")
+	require.NoError(t, err)
+
+	vars := map[string]string{BranchVariable: "staging", CommitVariable: "a1b2c3", "REPLICAS": "2"}
+	s, appErr := RenderEncodedManifest(base64.StdEncoding.EncodeToString(manifest), nil, vars, true, nil)
+	if appErr != nil {
+		t.Fatalf("got unexpected error: %s", appErr.Error())
+	}
+
+	if s.Name != "api-staging" || s.Replicas != 2 || s.Containers["api"].Image != "okteto/api:a1b2c3" {
+		t.Errorf("wrong service: %+v", s)
+	}
+
+	expected := []*EnvVar{
+		&EnvVar{Name: "LOG_LEVEL", Value: "info"},
+		&EnvVar{Name: "DATABASE_PASSWORD", Value: "$DATABASE_PASSWORD"},
+		&EnvVar{Name: "TEMPLATE", Value: "${NOT_INTERPOLATED}"},
+	}
+	if !reflect.DeepEqual(s.Containers["api"].Environment, expected) {
+		t.Errorf("wrong environment: %+v", s.Containers["api"].Environment)
+	}
+	if s.Containers["api"].Ingress[0].Host != ProjectName {
+		t.Errorf("wrong ingress: %+v", s.Containers["api"].Ingress[0])
+	}
+
+	s, appErr = RenderEncodedManifest(base64.StdEncoding.EncodeToString(manifest), []string{base64.StdEncoding.EncodeToString(overlay)}, vars, true, nil)
+	if appErr != nil {
+		t.Fatalf("got unexpected error: %s", appErr.Error())
+	}
+
+	c := s.Containers["api"]
+	if s.Replicas != 3 || c.Image != "okteto/api:a1b2c3" || len(c.Ingress) != 0 || c.Resources.Limits.Memory != "512Mi" {
+		t.Errorf("wrong service: %+v %+v", s, c)
+	}
+	if len(c.Environment) != 1 || c.Environment[0].Value != "debug" {
+		t.Errorf("wrong environment: %+v", c.Environment)
+	}
+
+	_, appErr = RenderEncodedManifest(base64.StdEncoding.EncodeToString(manifest), nil, map[string]string{}, true, nil)
+	if appErr == nil || appErr.Code != UndefinedVariable || appErr.Data["variable"] != CommitVariable {
+		t.Errorf("expected %s, got %+v", UndefinedVariable, appErr)
+	}
+}
+
+func TestRenderEncodedManifestReportsTheLinesOfTheSources(t *testing.T) {
+	manifest := []byte("# comment\n\n\nname: ${NAME}\n\n\ncontainers:\n  web:\n    image: nginx\n    replica: 3\n")
+	overlay := []byte("# staging\nreplicas: ${REPLICAS}\ncontainers:\n  web:\n    ports: {http: 8080}\n")
+	vars := map[string]string{"NAME": "web", "REPLICAS": "2"}
+
+	_, appErr := RenderEncodedManifest(base64.StdEncoding.EncodeToString(manifest), nil, vars, true, nil)
+	if appErr == nil || len(appErr.Errors) != 1 {
+		t.Fatalf("expected a single error, got %+v", appErr)
+	}
+	if e := appErr.Errors[0]; e.Code != UnknownField || e.Field != "containers.web.replica" || e.Data["line"] != "10" {
+		t.Errorf("wrong error: %+v", e)
+	}
+
+	_, appErr = RenderEncodedManifest(base64.StdEncoding.EncodeToString(manifest), []string{base64.StdEncoding.EncodeToString(overlay)}, vars, true, nil)
+	if appErr == nil || len(appErr.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %+v", appErr)
+	}
+	if e := appErr.Errors[1]; e.Code != InvalidYAMLWithInfo || e.Data["line"] != "5" || e.Data["overlay"] != "0" {
+		t.Errorf("wrong overlay error: %+v", e)
+	}
+
+	manifest = []byte("name: web\nreplicas: ${REPLICAS}\ncontainers:\n  web:\n    image: nginx\n")
+	_, appErr = RenderEncodedManifest(base64.StdEncoding.EncodeToString(manifest), nil, map[string]string{"REPLICAS": "many"}, true, nil)
+	if appErr == nil || len(appErr.Errors) != 1 {
+		t.Fatalf("expected a single error, got %+v", appErr)
+	}
+	if e := appErr.Errors[0]; e.Code != InvalidYAMLWithInfo || e.Data["line"] != "" {
+		t.Errorf("the error of a variable has the line of the rendered manifest: %+v", e)
+	}
+}
+
+func TestRenderEncodedManifestSkipsTheErrorsOfUndecodableFields(t *testing.T) {
+	manifest := []byte("name: web\ncontainers:\n  web: nginx\n")
+
+	_, appErr := RenderEncodedManifest(base64.StdEncoding.EncodeToString(manifest), nil, nil, true, nil)
+	if appErr == nil || len(appErr.Errors) != 1 || appErr.Code != InvalidYAMLWithInfo {
+		t.Fatalf("expected only %s, got %+v", InvalidYAMLWithInfo, appErr.Errors)
+	}
+	if appErr.Data["line"] != "3" {
+		t.Errorf("wrong line: %+v", appErr.Data)
+	}
+}
@@ -0,0 +1,245 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	//TCP is the default protocol of the ports
+	TCP = "TCP"
+
+	//UDP is the protocol of the ports declared as PORT/udp
+	UDP = "UDP"
+
+	maxPortName = 15
+)
+
+var (
+	portSyntax = regexp.MustCompile(`^(?:([a-z0-9-]+):)?([0-9]+)(?:/([a-zA-Z]+))?$`)
+	isPortName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`).MatchString
+	hasALetter = regexp.MustCompile(`[a-z]`).MatchString
+)
+
+//Port represents a port of a service.yml file with the syntax [NAME:]PORT[/PROTOCOL], e.g. dns:53/udp
+type Port struct {
+	Name     string
+	Number   int32
+	Protocol string
+}
+
+//ServicePort represents a port exposed by the kubernetes services of a service
+type ServicePort struct {
+	Port
+
+	// TargetName is true if the port is declared by a container, services target those ports by name
+	TargetName bool
+}
+
+//ParsePort returns the Port declared by p
+func ParsePort(p string) (*Port, error) {
+	matches := portSyntax.FindStringSubmatch(p)
+	if matches == nil {
+		return nil, fmt.Errorf("'%s' must follow the syntax [NAME:]PORT[/PROTOCOL]", p)
+	}
+
+	number, err := strconv.ParseInt(matches[2], 10, 32)
+	if err != nil || number < 1 || number > 65535 {
+		return nil, fmt.Errorf("'%s' must be between 1 and 65535", matches[2])
+	}
+
+	protocol := TCP
+	if matches[3] != "" {
+		protocol = strings.ToUpper(matches[3])
+		if protocol != TCP && protocol != UDP {
+			return nil, fmt.Errorf("'%s' is not a supported protocol, use tcp or udp", matches[3])
+		}
+	}
+
+	name := matches[1]
+	if name != "" && (len(name) > maxPortName || !isPortName(name) || !hasALetter(name)) {
+		return nil, fmt.Errorf("'%s' must have at most %d lowercase alphanumeric characters or dashes and contain a letter", name, maxPortName)
+	}
+
+	return &Port{Name: name, Number: int32(number), Protocol: protocol}, nil
+}
+
+//GetName returns the name of p, ports without a name are called p<PORT> or p<PORT>-udp
+func (p *Port) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+
+	if p.Protocol == UDP {
+		return fmt.Sprintf("p%d-udp", p.Number)
+	}
+
+	return fmt.Sprintf("p%d", p.Number)
+}
+
+//GetServicePorts parses ports and returns them without duplicates. Ports declared by a container take the name of the
+//container port
+func (s *Service) GetServicePorts(ports []string) []*ServicePort {
+	declared := map[string]*Port{}
+	for _, nC := range getContainerNames(s.Containers) {
+		if s.Containers[nC] == nil {
+			continue
+		}
+
+		for _, p := range s.Containers[nC].Ports {
+			if port, err := ParsePort(p); err == nil {
+				declared[port.key()] = port
+			}
+		}
+	}
+
+	result := []*ServicePort{}
+	seen := map[string]bool{}
+	for _, p := range ports {
+		port, err := ParsePort(p)
+		if err != nil || seen[port.key()] {
+			continue
+		}
+
+		seen[port.key()] = true
+		sp := &ServicePort{Port: *port}
+		if d, ok := declared[port.key()]; ok {
+			sp.Name = d.Name
+			sp.TargetName = true
+		}
+		result = append(result, sp)
+	}
+
+	return result
+}
+
+func (p *Port) key() string {
+	return fmt.Sprintf("%d/%s", p.Number, p.Protocol)
+}
+
+func (s *Service) validatePorts(nC string, c *Container) []*AppError {
+	errs := []*AppError{}
+	invalidPort := func(field, message string) {
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidPort,
+			Field:   field,
+			Data:    map[string]string{"container": nC},
+			Message: message})
+	}
+
+	for i, p := range c.Ports {
+		if _, err := ParsePort(p); err != nil {
+			invalidPort(fmt.Sprintf("containers.%s.ports[%d]", nC, i), err.Error())
+		}
+	}
+
+	for i, p := range c.Expose {
+		if _, err := ParsePort(p); err != nil {
+			invalidPort(fmt.Sprintf("containers.%s.expose[%d]", nC, i), err.Error())
+		}
+	}
+
+	for i, ingress := range c.Ingress {
+		if ingress == nil {
+			continue
+		}
+
+		field := fmt.Sprintf("containers.%s.ingress[%d].port", nC, i)
+		if _, err := strconv.Atoi(ingress.Port); err != nil {
+			invalidPort(field, fmt.Sprintf("the ingress port '%s' must be a number", ingress.Port))
+			continue
+		}
+
+		if port, err := ParsePort(ingress.Port); err != nil {
+			invalidPort(field, err.Error())
+		} else if c.declaresPort(port.Number, UDP) && !c.declaresPort(port.Number, TCP) {
+			invalidPort(field, fmt.Sprintf("the ingress port '%s' must be a tcp port", ingress.Port))
+		}
+	}
+
+	return errs
+}
+
+// validatePortNames returns an error if the same port name is used for different ports, services can't have duplicated names
+func (s *Service) validatePortNames() []*AppError {
+	errs := []*AppError{}
+	names := map[string]string{}
+	for _, nC := range getContainerNames(s.Containers) {
+		c := s.Containers[nC]
+		if c == nil {
+			continue
+		}
+
+		for i, p := range c.Ports {
+			port, err := ParsePort(p)
+			if err != nil {
+				continue
+			}
+
+			if key, ok := names[port.GetName()]; ok && key != port.key() {
+				errs = append(errs, &AppError{
+					Status:  http.StatusBadRequest,
+					Code:    InvalidPort,
+					Field:   fmt.Sprintf("containers.%s.ports[%d]", nC, i),
+					Data:    map[string]string{"container": nC, "port": port.GetName()},
+					Message: fmt.Sprintf("the port name '%s' is used by different ports", port.GetName())})
+				continue
+			}
+
+			names[port.GetName()] = port.key()
+		}
+	}
+	return errs
+}
+
+// declaresPort returns if c has a port with number and protocol
+func (c *Container) declaresPort(number int32, protocol string) bool {
+	for _, declared := range c.Ports {
+		if port, err := ParsePort(declared); err == nil && port.Number == number && port.Protocol == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+//ValidateLoadBalancerPorts returns an error if s gets a load balancer in e and its ports mix tcp and udp, the load
+//balancers of most clouds only accept one protocol. If e is nil nothing is checked, since the provider is unknown
+func (s *Service) ValidateLoadBalancerPorts(e *Environment) []*AppError {
+	if e == nil || e.Provider == nil || e.Provider.IsIngress() {
+		return nil
+	}
+
+	protocol := ""
+	for _, nC := range getContainerNames(s.Containers) {
+		if s.Containers[nC] == nil {
+			continue
+		}
+
+		for i, p := range s.Containers[nC].Ports {
+			port, err := ParsePort(p)
+			if err != nil {
+				continue
+			}
+
+			if protocol == "" {
+				protocol = port.Protocol
+				continue
+			}
+
+			if port.Protocol != protocol {
+				return []*AppError{&AppError{
+					Status:  http.StatusBadRequest,
+					Code:    InvalidPort,
+					Field:   fmt.Sprintf("containers.%s.ports[%d]", nC, i),
+					Data:    map[string]string{"container": nC, "port": p},
+					Message: "the ports of a service with a load balancer must all be tcp or all be udp"}}
+			}
+		}
+	}
+
+	return nil
+}
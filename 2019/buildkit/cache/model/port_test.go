@@ -0,0 +1,157 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		port     string
+		expected *Port
+	}{
+		{port: "8080", expected: &Port{Number: 8080, Protocol: TCP}},
+		{port: "53/udp", expected: &Port{Number: 53, Protocol: UDP}},
+		{port: "53/UDP", expected: &Port{Number: 53, Protocol: UDP}},
+		{port: "443/tcp", expected: &Port{Number: 443, Protocol: TCP}},
+		{port: "dns:53/udp", expected: &Port{Name: "dns", Number: 53, Protocol: UDP}},
+		{port: "metrics:9090", expected: &Port{Name: "metrics", Number: 9090, Protocol: TCP}},
+		{port: "http"},
+		{port: "0"},
+		{port: "70000"},
+		{port: "53/sctp"},
+		{port: "HTTP:80"},
+		{port: "8080:80"},
+		{port: "a-very-long-port-name:80"},
+		{port: "http:80:http:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.port, func(t *testing.T) {
+			port, err := ParsePort(tt.port)
+			if tt.expected == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", port)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(port, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, port)
+			}
+		})
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      *Container
+		fields []string
+	}{
+		{
+			name: "valid",
+			c: &Container{
+				Ports:   []string{"53/udp", "53", "metrics:9153"},
+				Expose:  []string{"8080/tcp"},
+				Ingress: []*Ingress{&Ingress{Port: "53"}},
+			},
+		},
+		{
+			name:   "bad-port",
+			c:      &Container{Ports: []string{"53/sctp"}, Expose: []string{"http"}},
+			fields: []string{"containers.api.ports[0]", "containers.api.expose[0]"},
+		},
+		{
+			name: "udp-ingress",
+			c: &Container{
+				Ports:   []string{"53/udp"},
+				Ingress: []*Ingress{&Ingress{Port: "53"}},
+			},
+			fields: []string{"containers.api.ingress[0].port"},
+		},
+		{
+			name:   "named-ingress",
+			c:      &Container{Ingress: []*Ingress{&Ingress{Port: "http"}}},
+			fields: []string{"containers.api.ingress[0].port"},
+		},
+		{
+			name:   "duplicated-name",
+			c:      &Container{Ports: []string{"web:80", "web:8080"}},
+			fields: []string{"containers.api.ports[1]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.Image = "okteto/api"
+			s := &Service{Name: "test", Replicas: 1, Containers: map[string]*Container{"api": tt.c}}
+			fields := []string{}
+			for _, err := range s.validate() {
+				if err.Code != InvalidPort {
+					t.Errorf("unexpected error %s", err.Code)
+				}
+				fields = append(fields, err.Field)
+			}
+
+			if len(tt.fields) == 0 {
+				tt.fields = []string{}
+			}
+
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("expected errors in %v, got %v", tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestValidateLoadBalancerPorts(t *testing.T) {
+	s := &Service{
+		Containers: map[string]*Container{
+			"dns": &Container{Ports: []string{"53/udp"}},
+			"web": &Container{Ports: []string{"8080", "dns:53/udp", "53"}},
+		},
+	}
+
+	ingress := &Environment{Provider: &Provider{Ingress: &IngressController{Domain: "example.com"}}}
+	if errs := s.ValidateLoadBalancerPorts(ingress); len(errs) != 0 {
+		t.Errorf("the ingress ports were rejected: %+v", errs)
+	}
+
+	if errs := s.ValidateLoadBalancerPorts(nil); len(errs) != 0 {
+		t.Errorf("the ports were rejected without an environment: %+v", errs)
+	}
+
+	errs := s.ValidateLoadBalancerPorts(&Environment{Provider: &Provider{}})
+	if len(errs) != 1 || errs[0].Code != InvalidPort || errs[0].Field != "containers.web.ports[0]" {
+		t.Fatalf("expected an error in containers.web.ports[0], got %+v", errs)
+	}
+
+	s.Containers["web"].Ports = []string{"dns:53/udp"}
+	if errs := s.ValidateLoadBalancerPorts(&Environment{Provider: &Provider{}}); len(errs) != 0 {
+		t.Errorf("the udp ports were rejected: %+v", errs)
+	}
+}
+
+func TestGetServicePorts(t *testing.T) {
+	s := &Service{
+		Containers: map[string]*Container{
+			"api": &Container{Ports: []string{"web:8080", "53/udp"}},
+		},
+	}
+
+	ports := s.GetServicePorts([]string{"8080", "web:8080", "53/udp", "9090"})
+	expected := []*ServicePort{
+		&ServicePort{Port: Port{Name: "web", Number: 8080, Protocol: TCP}, TargetName: true},
+		&ServicePort{Port: Port{Number: 53, Protocol: UDP}, TargetName: true},
+		&ServicePort{Port: Port{Number: 9090, Protocol: TCP}},
+	}
+
+	if !reflect.DeepEqual(ports, expected) {
+		t.Errorf("expected %+v, got %+v", expected, ports)
+	}
+}
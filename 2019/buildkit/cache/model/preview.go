@@ -0,0 +1,57 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+//PreviewStatus is the status of the preview of a pull request
+type PreviewStatus string
+
+const (
+	//ActivePreview is the status of a preview while its pull request is open
+	ActivePreview PreviewStatus = "active"
+
+	//ClosedPreview is the status of a preview after its pull request is closed or merged
+	ClosedPreview PreviewStatus = "closed"
+
+	//PreviewNamespacePrefix is the prefix of the namespaces of the previews, the project names can't start with it
+	PreviewNamespacePrefix = "okteto-preview-"
+
+	//PreviewLabel is the label of the namespace of a preview, its value is the ID of the preview
+	PreviewLabel = "okteto-preview"
+
+	previewHashLength = 16
+)
+
+// Preview is a copy of the linked services of a project, deployed in its own namespace at the head commit of a pull request
+type Preview struct {
+	Model
+	ProjectID       string        `json:"project,omitempty" gorm:"index"`
+	InstallationID  int           `json:"-" gorm:"index:idx_preview_pull_request"`
+	RepositoryID    int           `json:"-" gorm:"index:idx_preview_pull_request"`
+	RepositoryOwner string        `json:"owner,omitempty"`
+	RepositoryName  string        `json:"repository,omitempty"`
+	PullRequest     int           `json:"pull_request,omitempty" gorm:"index:idx_preview_pull_request"`
+	Branch          string        `json:"branch,omitempty"`
+	Commit          string        `json:"commit,omitempty"`
+	Namespace       string        `json:"namespace,omitempty"`
+	Status          PreviewStatus `json:"status,omitempty"`
+
+	//CommentID is the comment of the pull request with the endpoints of the preview
+	CommentID int64 `json:"-"`
+}
+
+//PreviewSettings configure the previews of the pull requests of a project
+type PreviewSettings struct {
+	//Forks deploys the previews of pull requests from forks opened by anyone. They are deployed with the secrets of the
+	//project, by default only the previews of the owners, members and collaborators of the repository are deployed
+	Forks bool `yaml:"forks,omitempty"`
+}
+
+//GetPreviewNamespace returns the namespace of a preview. It starts with PreviewNamespacePrefix, which the project names
+//can't use, and a hash of the preview, so it can't be the namespace of a project or of another preview
+func GetPreviewNamespace(previewID string) string {
+	sum := sha256.Sum256([]byte(previewID))
+	return PreviewNamespacePrefix + hex.EncodeToString(sum[:])[:previewHashLength]
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetPreviewNamespace(t *testing.T) {
+	namespace := GetPreviewNamespace("b4b3c4bc-0d2d-4a4b-9bd0-0e5dd5a7f25b")
+	if !strings.HasPrefix(namespace, PreviewNamespacePrefix) || len(namespace) != len(PreviewNamespacePrefix)+previewHashLength {
+		t.Errorf("wrong namespace: %s", namespace)
+	}
+
+	if GetPreviewNamespace("b4b3c4bc-0d2d-4a4b-9bd0-0e5dd5a7f25b") != namespace {
+		t.Errorf("the namespace of a preview changed")
+	}
+
+	if GetPreviewNamespace("e1e3e1a8-3f0a-4f45-8d1c-2b1c1a4c2f6e") == namespace {
+		t.Errorf("two previews have the same namespace")
+	}
+
+	p := &Project{Name: "Okteto-Preview-" + strings.TrimPrefix(namespace, PreviewNamespacePrefix)}
+	if p.Validate() == nil {
+		t.Errorf("a project can take the namespace of a preview")
+	}
+}
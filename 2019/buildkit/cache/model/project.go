@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/mail"
 	"regexp"
+	"strings"
 	"time"
 
 	yaml "gopkg.in/yaml.v2"
@@ -35,6 +36,16 @@ type ProjectSettings struct {
 	Registry       *Registry `yaml:"registry,omitempty"`
 	Secrets        []*EnvVar `yaml:"secrets,omitempty"`
 	Github         *Github   `yaml:"github,omitempty"`
+
+	// Gitlab and Bitbucket are the credentials used to read the linked repositories and report their commit statuses
+	Gitlab    *SCMCredentials `yaml:"gitlab,omitempty"`
+	Bitbucket *SCMCredentials `yaml:"bitbucket,omitempty"`
+
+	// Variables are interpolated in the manifests of the project services
+	Variables  map[string]string   `yaml:"variables,omitempty"`
+	Scheduling *SchedulingSettings `yaml:"scheduling,omitempty"`
+	Security   *SecuritySettings   `yaml:"security,omitempty"`
+	Previews   *PreviewSettings    `yaml:"previews,omitempty"`
 }
 
 // ProjectRole represents the type role of a user in a project
@@ -57,6 +68,18 @@ type Github struct {
 	LinkedBy string `yaml:"linked_by,omitempty"`
 }
 
+//SCMCredentials are the credentials of a source control provider
+type SCMCredentials struct {
+	//URL is the address of a self-managed gitlab instance
+	URL string `yaml:"url,omitempty"`
+
+	//Username is the bitbucket account of the app password
+	Username string `yaml:"username,omitempty"`
+
+	//Token is a gitlab personal access token or a bitbucket app password
+	Token string `yaml:"token,omitempty"`
+}
+
 const (
 	//ProjectRoleUser is a normal user of a project
 	ProjectRoleUser ProjectRole = "user"
@@ -135,6 +158,30 @@ func ParseProjectSettings(s string) (*ProjectSettings, *AppError) {
 		}
 	}
 
+	if settings.Gitlab != nil && settings.Gitlab.Token == "" {
+		return nil, &AppError{Status: 400, Code: InvalidSCMCredentials, Field: "gitlab.token", Message: "'gitlab.token' is mandatory"}
+	}
+
+	if settings.Bitbucket != nil {
+		if settings.Bitbucket.Username == "" {
+			return nil, &AppError{Status: 400, Code: InvalidSCMCredentials, Field: "bitbucket.username", Message: "'bitbucket.username' is mandatory"}
+		}
+
+		if settings.Bitbucket.Token == "" {
+			return nil, &AppError{Status: 400, Code: InvalidSCMCredentials, Field: "bitbucket.token", Message: "'bitbucket.token' is mandatory"}
+		}
+	}
+
+	if appErr := validateVariables(settings.Variables); appErr != nil {
+		return nil, appErr
+	}
+
+	if settings.Scheduling != nil {
+		if appErr := settings.Scheduling.validate(); appErr != nil {
+			return nil, appErr
+		}
+	}
+
 	return &settings, nil
 }
 
@@ -202,6 +249,11 @@ func (p *Project) Validate() *AppError {
 		}
 	}
 
+	// the namespace of a project is its name, they can't take the namespaces of the previews
+	if strings.HasPrefix(strings.ToLower(p.Name), PreviewNamespacePrefix) {
+		return &AppError{Status: 400, Code: InvalidName}
+	}
+
 	if !validProjectName.MatchString(p.Name) {
 		return &AppError{Status: 400, Code: InvalidName}
 	}
@@ -78,6 +78,68 @@ github:
 				Github:         &Github{LinkedBy: "user1@example.com"}},
 			expectError: false,
 		},
+		{
+			name: "settings with gitlab and bitbucket",
+			settings: []byte(`
+provider:
+  type: demo
+administrators:
+  - user1@example.com
+gitlab:
+  url: https://gitlab.example.com
+  token: glpat-123
+bitbucket:
+  username: user1
+  token: app-password
+`),
+			want: ProjectSettings{
+				Administrators: []string{"user1@example.com"},
+				Provider:       &Provider{Type: "demo"},
+				Gitlab:         &SCMCredentials{URL: "https://gitlab.example.com", Token: "glpat-123"},
+				Bitbucket:      &SCMCredentials{Username: "user1", Token: "app-password"}},
+			expectError: false,
+		},
+		{
+			name: "gitlab without token",
+			settings: []byte(`
+provider:
+  type: demo
+administrators:
+  - user1@example.com
+gitlab:
+  url: https://gitlab.example.com
+`),
+			want:        ProjectSettings{},
+			expectError: true,
+		},
+		{
+			name: "bitbucket without username",
+			settings: []byte(`
+provider:
+  type: demo
+administrators:
+  - user1@example.com
+bitbucket:
+  token: app-password
+`),
+			want:        ProjectSettings{},
+			expectError: true,
+		},
+		{
+			name: "invalid scheduling toleration",
+			settings: []byte(`
+provider:
+  type: demo
+administrators:
+  - user1@example.com
+scheduling:
+  tolerations:
+    - key: dedicated
+      operator: Equals
+`),
+			want:        ProjectSettings{},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -120,6 +182,14 @@ github:
 
 				}
 			}
+
+			if !reflect.DeepEqual(s.Gitlab, tt.want.Gitlab) {
+				t.Errorf("got %+v, expected %+v", s.Gitlab, tt.want.Gitlab)
+			}
+
+			if !reflect.DeepEqual(s.Bitbucket, tt.want.Bitbucket) {
+				t.Errorf("got %+v, expected %+v", s.Bitbucket, tt.want.Bitbucket)
+			}
 		})
 	}
 }
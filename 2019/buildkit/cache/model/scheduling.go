@@ -0,0 +1,202 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	//PreferredAntiAffinity spreads the replicas of a service across nodes when possible
+	PreferredAntiAffinity = "preferred"
+
+	//RequiredAntiAffinity never schedules two replicas of a service in the same node
+	RequiredAntiAffinity = "required"
+)
+
+var (
+	validTolerationOperators = []string{"", "Equal", "Exists"}
+	validTolerationEffects   = []string{"", "NoSchedule", "PreferNoSchedule", "NoExecute"}
+)
+
+//Toleration represents a node taint tolerated by a service
+type Toleration struct {
+	Key      string `json:"key,omitempty" yaml:"key,omitempty"`
+	Operator string `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Value    string `json:"value,omitempty" yaml:"value,omitempty"`
+	Effect   string `json:"effect,omitempty" yaml:"effect,omitempty"`
+}
+
+//SchedulingSettings are the scheduling defaults and restrictions of a project.
+//An empty list of allowed values doesn't restrict the manifests
+type SchedulingSettings struct {
+	NodeSelector  map[string]string `yaml:"node_selector,omitempty"`
+	Tolerations   []*Toleration     `yaml:"tolerations,omitempty"`
+	PriorityClass string            `yaml:"priority_class,omitempty"`
+
+	AllowedNodeSelectors   map[string][]string `yaml:"allowed_node_selectors,omitempty"`
+	AllowedTolerations     []string            `yaml:"allowed_tolerations,omitempty"`
+	AllowedPriorityClasses []string            `yaml:"allowed_priority_classes,omitempty"`
+}
+
+func (t *Toleration) validate() *AppError {
+	if t == nil {
+		return &AppError{Status: http.StatusBadRequest, Code: InvalidScheduling, Message: "tolerations can't be empty"}
+	}
+	if !containsString(validTolerationOperators, t.Operator) {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidScheduling,
+			Data:    map[string]string{"toleration": t.Key},
+			Message: fmt.Sprintf("Toleration '%s' has an invalid operator '%s'", t.Key, t.Operator)}
+	}
+	if !containsString(validTolerationEffects, t.Effect) {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidScheduling,
+			Data:    map[string]string{"toleration": t.Key},
+			Message: fmt.Sprintf("Toleration '%s' has an invalid effect '%s'", t.Key, t.Effect)}
+	}
+	if t.Operator == "Exists" && t.Value != "" {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidScheduling,
+			Data:    map[string]string{"toleration": t.Key},
+			Message: fmt.Sprintf("Toleration '%s' can't have a value with the 'Exists' operator", t.Key)}
+	}
+	if t.Key == "" && t.Operator != "Exists" {
+		return &AppError{Status: http.StatusBadRequest, Code: InvalidScheduling, Message: "Tolerations without a key must use the 'Exists' operator"}
+	}
+	return nil
+}
+
+func (s *Service) validateScheduling() []*AppError {
+	errs := []*AppError{}
+	if s.AntiAffinity != "" && s.AntiAffinity != PreferredAntiAffinity && s.AntiAffinity != RequiredAntiAffinity {
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidScheduling,
+			Field:   "anti_affinity",
+			Message: fmt.Sprintf("'service.anti_affinity' must be '%s' or '%s'", PreferredAntiAffinity, RequiredAntiAffinity)})
+	}
+	for i, t := range s.Tolerations {
+		if err := t.validate(); err != nil {
+			err.Field = fmt.Sprintf("tolerations[%d]", i)
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (ss *SchedulingSettings) validate() *AppError {
+	for i, t := range ss.Tolerations {
+		if err := t.validate(); err != nil {
+			err.Field = fmt.Sprintf("scheduling.tolerations[%d]", i)
+			return err
+		}
+	}
+	return nil
+}
+
+//ValidateScheduling returns an error if the scheduling of s is not allowed by the project settings
+func (s *Service) ValidateScheduling(ss *SchedulingSettings) *AppError {
+	if ss == nil {
+		return nil
+	}
+
+	if len(ss.AllowedNodeSelectors) > 0 {
+		for k, v := range s.NodeSelector {
+			if !containsString(ss.AllowedNodeSelectors[k], v) {
+				return &AppError{
+					Status:  http.StatusBadRequest,
+					Code:    SchedulingNotAllowed,
+					Data:    map[string]string{"node_selector": fmt.Sprintf("%s=%s", k, v)},
+					Message: fmt.Sprintf("The node selector '%s=%s' is not allowed in this project", k, v)}
+			}
+		}
+	}
+
+	if len(ss.AllowedTolerations) > 0 {
+		for _, t := range s.Tolerations {
+			if !containsString(ss.AllowedTolerations, t.Key) {
+				return &AppError{
+					Status:  http.StatusBadRequest,
+					Code:    SchedulingNotAllowed,
+					Data:    map[string]string{"toleration": t.Key},
+					Message: fmt.Sprintf("The toleration '%s' is not allowed in this project", t.Key)}
+			}
+		}
+	}
+
+	if len(ss.AllowedPriorityClasses) > 0 && s.PriorityClass != "" {
+		if !containsString(ss.AllowedPriorityClasses, s.PriorityClass) {
+			return &AppError{
+				Status:  http.StatusBadRequest,
+				Code:    SchedulingNotAllowed,
+				Data:    map[string]string{"priority_class": s.PriorityClass},
+				Message: fmt.Sprintf("The priority class '%s' is not allowed in this project", s.PriorityClass)}
+		}
+	}
+
+	return nil
+}
+
+//ApplySchedulingDefaults sets the project scheduling defaults that are not overriden by s
+func (s *Service) ApplySchedulingDefaults(ss *SchedulingSettings) {
+	if ss == nil {
+		return
+	}
+
+	if len(ss.NodeSelector) > 0 {
+		if s.NodeSelector == nil {
+			s.NodeSelector = map[string]string{}
+		}
+		for k, v := range ss.NodeSelector {
+			if _, ok := s.NodeSelector[k]; !ok {
+				s.NodeSelector[k] = v
+			}
+		}
+	}
+
+	for _, t := range ss.Tolerations {
+		if !s.hasToleration(t) {
+			s.Tolerations = append(s.Tolerations, t)
+		}
+	}
+
+	if s.PriorityClass == "" {
+		s.PriorityClass = ss.PriorityClass
+	}
+}
+
+func (s *Service) hasToleration(t *Toleration) bool {
+	for _, existing := range s.Tolerations {
+		if existing != nil && existing.equals(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// equals returns true if t and other tolerate the same taints, kubernetes defaults the operator to 'Equal'
+func (t *Toleration) equals(other *Toleration) bool {
+	return t.Key == other.Key &&
+		orDefaultOperator(t.Operator) == orDefaultOperator(other.Operator) &&
+		t.Value == other.Value &&
+		t.Effect == other.Effect
+}
+
+func orDefaultOperator(operator string) string {
+	if operator == "" {
+		return "Equal"
+	}
+	return operator
+}
+
+func containsString(array []string, elem string) bool {
+	for _, a := range array {
+		if a == elem {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateSchedulingFields(t *testing.T) {
+	tests := []struct {
+		name         string
+		tolerations  []*Toleration
+		antiAffinity string
+		expected     AppErrorCode
+	}{
+		{
+			name:         "valid",
+			tolerations:  []*Toleration{&Toleration{Key: "dedicated", Operator: "Equal", Value: "gpu", Effect: "NoSchedule"}},
+			antiAffinity: PreferredAntiAffinity,
+		},
+		{
+			name:        "exists",
+			tolerations: []*Toleration{&Toleration{Operator: "Exists"}},
+		},
+		{
+			name:        "invalid-operator",
+			tolerations: []*Toleration{&Toleration{Key: "dedicated", Operator: "In"}},
+			expected:    InvalidScheduling,
+		},
+		{
+			name:        "invalid-effect",
+			tolerations: []*Toleration{&Toleration{Key: "dedicated", Effect: "Never"}},
+			expected:    InvalidScheduling,
+		},
+		{
+			name:        "exists-with-value",
+			tolerations: []*Toleration{&Toleration{Key: "dedicated", Operator: "Exists", Value: "gpu"}},
+			expected:    InvalidScheduling,
+		},
+		{
+			name:        "missing-key",
+			tolerations: []*Toleration{&Toleration{Value: "gpu"}},
+			expected:    InvalidScheduling,
+		},
+		{
+			name:         "invalid-anti-affinity",
+			antiAffinity: "always",
+			expected:     InvalidScheduling,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				Name:         "test",
+				Replicas:     1,
+				Tolerations:  tt.tolerations,
+				AntiAffinity: tt.antiAffinity,
+				Containers: map[string]*Container{
+					"nginx": &Container{Image: "nginx"},
+				},
+			}
+
+			err := s.Validate(nil)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("didn't get the expected error %s", tt.expected)
+			}
+
+			if err.Code != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, err.Code)
+			}
+		})
+	}
+}
+
+func TestValidateSchedulingSettings(t *testing.T) {
+	settings := &SchedulingSettings{
+		AllowedNodeSelectors:   map[string][]string{"pool": []string{"spot", "ondemand"}},
+		AllowedTolerations:     []string{"dedicated"},
+		AllowedPriorityClasses: []string{"low", "normal"},
+	}
+
+	tests := []struct {
+		name     string
+		service  *Service
+		expected bool
+	}{
+		{
+			name:    "empty",
+			service: &Service{},
+		},
+		{
+			name: "allowed",
+			service: &Service{
+				NodeSelector:  map[string]string{"pool": "spot"},
+				Tolerations:   []*Toleration{&Toleration{Key: "dedicated", Operator: "Exists"}},
+				PriorityClass: "low",
+			},
+		},
+		{
+			name:     "node-selector-value",
+			service:  &Service{NodeSelector: map[string]string{"pool": "system"}},
+			expected: true,
+		},
+		{
+			name:     "node-selector-label",
+			service:  &Service{NodeSelector: map[string]string{"kubernetes.io/hostname": "node-1"}},
+			expected: true,
+		},
+		{
+			name:     "toleration",
+			service:  &Service{Tolerations: []*Toleration{&Toleration{Key: "node-role.kubernetes.io/master", Operator: "Exists"}}},
+			expected: true,
+		},
+		{
+			name:     "priority-class",
+			service:  &Service{PriorityClass: "system-cluster-critical"},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.service.ValidateScheduling(settings)
+			if !tt.expected {
+				if err != nil {
+					t.Fatalf("got unexpected error: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("didn't get the expected error")
+			}
+
+			if err.Code != SchedulingNotAllowed {
+				t.Errorf("expected %s, got %s", SchedulingNotAllowed, err.Code)
+			}
+		})
+	}
+
+	if err := tests[2].service.ValidateScheduling(nil); err != nil {
+		t.Errorf("got unexpected error without settings: %s", err.Error())
+	}
+}
+
+func TestApplySchedulingDefaults(t *testing.T) {
+	settings := &SchedulingSettings{
+		NodeSelector:  map[string]string{"pool": "spot", "zone": "a"},
+		Tolerations:   []*Toleration{&Toleration{Key: "spot", Operator: "Exists"}},
+		PriorityClass: "normal",
+	}
+
+	s := &Service{
+		NodeSelector:  map[string]string{"pool": "ondemand"},
+		Tolerations:   []*Toleration{&Toleration{Key: "dedicated", Operator: "Exists"}},
+		PriorityClass: "low",
+	}
+	s.ApplySchedulingDefaults(settings)
+
+	if !reflect.DeepEqual(s.NodeSelector, map[string]string{"pool": "ondemand", "zone": "a"}) {
+		t.Errorf("wrong node selector: %+v", s.NodeSelector)
+	}
+	if len(s.Tolerations) != 2 || s.Tolerations[1].Key != "spot" {
+		t.Errorf("wrong tolerations: %+v", s.Tolerations)
+	}
+	if s.PriorityClass != "low" {
+		t.Errorf("wrong priority class: %s", s.PriorityClass)
+	}
+
+	s.ApplySchedulingDefaults(settings)
+	if len(s.Tolerations) != 2 {
+		t.Errorf("the defaults were applied twice: %+v", s.Tolerations)
+	}
+
+	duplicated := &Service{Tolerations: []*Toleration{&Toleration{Key: "gpu", Value: "true"}, &Toleration{Key: "spot", Operator: "Exists"}}}
+	duplicated.ApplySchedulingDefaults(&SchedulingSettings{
+		Tolerations: []*Toleration{&Toleration{Key: "spot", Operator: "Exists"}, &Toleration{Key: "gpu", Operator: "Equal", Value: "true"}},
+	})
+	if len(duplicated.Tolerations) != 2 {
+		t.Errorf("the tolerations of the manifest were duplicated: %+v", duplicated.Tolerations)
+	}
+
+	empty := &Service{}
+	empty.ApplySchedulingDefaults(settings)
+	if empty.PriorityClass != "normal" || empty.NodeSelector["pool"] != "spot" {
+		t.Errorf("defaults not applied: %+v", empty)
+	}
+}
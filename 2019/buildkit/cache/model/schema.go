@@ -0,0 +1,211 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+)
+
+//SchemaVersion is the version of the published JSON Schemas of the manifests and project settings
+const SchemaVersion = "v1"
+
+//JSONSchema is a JSON Schema document
+type JSONSchema map[string]interface{}
+
+const (
+	jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+	// an environment variable is NAME=VALUE, the value can be empty
+	envVarPattern = `^=?[^=]+=[\s\S]*$`
+
+	// a port is [NAME:]PORT[/PROTOCOL], e.g. dns:53/udp, or the compose syntax, e.g. http:80:http:8080
+	portPattern = `^(([a-z0-9-]+:)?[0-9]+(/([tT][cC][pP]|[uU][dD][pP]))?|[a-zA-Z]+:[0-9]+:[a-zA-Z]+:[0-9]+(:.+)?)$`
+
+	// a variable interpolated in the manifest, e.g. ${REPLICAS:-1} or $REPLICAS. The numbers and booleans of the
+	// manifests can be set with a variable, the pattern only applies to the strings
+	variablePattern = `^\$(\{[^}]+\}|[a-zA-Z_][a-zA-Z0-9_]*)$`
+)
+
+var (
+	// yaml decodes any scalar into a string, e.g. a label with the value 1
+	scalarSchema = JSONSchema{"type": []string{"string", "number", "boolean"}}
+
+	schemaTypeOverrides = map[reflect.Type]JSONSchema{
+		reflect.TypeOf(EnvVar{}): JSONSchema{
+			"type":        "string",
+			"pattern":     envVarPattern,
+			"description": "environment variable with the NAME=VALUE syntax",
+		},
+	}
+
+	schemaFieldOverrides = map[string]JSONSchema{
+		"Container.ports": JSONSchema{
+			"type": []string{"array", "null"},
+			"items": JSONSchema{
+				"anyOf": []JSONSchema{
+					JSONSchema{"type": "integer", "minimum": 1, "maximum": 65535},
+					JSONSchema{"type": "string", "pattern": portPattern},
+					JSONSchema{"type": "string", "pattern": variablePattern},
+				},
+			},
+		},
+		"Container.expose": JSONSchema{
+			"type": []string{"array", "null"},
+			"items": JSONSchema{
+				"anyOf": []JSONSchema{
+					JSONSchema{"type": "integer", "minimum": 1, "maximum": 65535},
+					JSONSchema{"type": "string", "pattern": portPattern},
+					JSONSchema{"type": "string", "pattern": variablePattern},
+				},
+			},
+		},
+		"Ingress.port":          JSONSchema{"type": []string{"integer", "string"}},
+		"Service.anti_affinity": JSONSchema{"type": "string", "enum": []string{PreferredAntiAffinity, RequiredAntiAffinity}},
+		"Toleration.operator":   JSONSchema{"type": "string", "enum": validTolerationOperators},
+		"Toleration.effect":     JSONSchema{"type": "string", "enum": validTolerationEffects},
+	}
+
+	schemaFieldDescriptions = map[string]string{
+		"Service.init_containers": "containers that run to completion before the containers are started, one after the other in the order they are declared",
+	}
+
+	schemaRequiredFields = map[string][]string{
+		"Service":         []string{"name", "containers"},
+		"Container":       []string{"image"},
+		"InitContainer":   []string{"name"},
+		"ProjectSettings": []string{"administrators", "provider"},
+		"Provider":        []string{"type"},
+	}
+)
+
+type schemaGenerator struct {
+	definitions map[string]JSONSchema
+}
+
+//GetServiceSchema returns the JSON Schema of the service manifests, id is the URL where the schema is published
+func GetServiceSchema(id string) JSONSchema {
+	return generateSchema(id, "Okteto service manifest", reflect.TypeOf(Service{}))
+}
+
+//GetProjectSettingsSchema returns the JSON Schema of the project settings, id is the URL where the schema is published
+func GetProjectSettingsSchema(id string) JSONSchema {
+	return generateSchema(id, "Okteto project settings", reflect.TypeOf(ProjectSettings{}))
+}
+
+func generateSchema(id, title string, t reflect.Type) JSONSchema {
+	g := &schemaGenerator{definitions: map[string]JSONSchema{}}
+	s := g.structSchema(t)
+	s["$schema"] = jsonSchemaDraft
+	s["$id"] = id
+	s["title"] = title
+	s["definitions"] = g.definitions
+	return s
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) JSONSchema {
+	if t.Kind() == reflect.Ptr {
+		return g.schemaFor(t.Elem())
+	}
+
+	if s, ok := schemaTypeOverrides[t]; ok {
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Bool:
+		return JSONSchema{"type": []string{"boolean", "string"}, "pattern": variablePattern}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": []string{"integer", "string"}, "pattern": variablePattern}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": []string{"number", "string"}, "pattern": variablePattern}
+	case reflect.Slice:
+		return JSONSchema{"type": []string{"array", "null"}, "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		values := scalarSchema
+		if t.Elem().Kind() != reflect.String {
+			values = g.schemaFor(t.Elem())
+		}
+		return JSONSchema{"type": []string{"object", "null"}, "additionalProperties": values}
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// the placeholder stops the recursion of self referencing types
+			g.definitions[t.Name()] = JSONSchema{}
+			s := g.structSchema(t)
+			s["type"] = []string{"object", "null"}
+			g.definitions[t.Name()] = s
+		}
+		return JSONSchema{"$ref": "#/definitions/" + t.Name()}
+	}
+
+	return JSONSchema{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) JSONSchema {
+	properties := JSONSchema{}
+	required := schemaRequiredFields[t.Name()]
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && strings.HasSuffix(f.Tag.Get("yaml"), ",inline") {
+			// the fields of an inlined struct are keys of the same yaml map
+			inlined := g.structSchema(f.Type)
+			for name, p := range inlined["properties"].(JSONSchema) {
+				properties[name] = p
+			}
+			if r, ok := inlined["required"].([]string); ok {
+				required = append(append([]string{}, required...), r...)
+			}
+			continue
+		}
+
+		if f.Anonymous || f.PkgPath != "" {
+			continue
+		}
+
+		name := getYAMLFieldName(f)
+		if name == "-" {
+			continue
+		}
+
+		if s, ok := schemaFieldOverrides[t.Name()+"."+name]; ok {
+			properties[name] = s
+		} else {
+			properties[name] = g.schemaFor(f.Type)
+		}
+
+		if d, ok := schemaFieldDescriptions[t.Name()+"."+name]; ok {
+			properties[name] = withDescription(properties[name].(JSONSchema), d)
+		}
+	}
+
+	s := JSONSchema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s
+}
+
+// withDescription returns a copy of s with a description, the schemas of the overrides are shared
+func withDescription(s JSONSchema, description string) JSONSchema {
+	result := JSONSchema{"description": description}
+	for k, v := range s {
+		result[k] = v
+	}
+	return result
+}
+
+// getYAMLFieldName returns the key of f in a yaml document, yaml uses the lowercased field name by default
+func getYAMLFieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
@@ -0,0 +1,309 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestServiceSchema(t *testing.T) {
+	schema := toJSON(t, GetServiceSchema("https://example.com/api/v1/schemas/v1/service.json"))
+
+	examples, err := filepath.Glob("./examples/service*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range examples {
+		t.Run(e, func(t *testing.T) {
+			m, err := ioutil.ReadFile(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the examples are validated before the variables are interpolated, as the editors do
+			// overlays are partial manifests
+			if strings.Contains(e, "overlay") {
+				return
+			}
+
+			if errs := validateAgainstSchema(schema, schema, yamlToJSON(t, m), ""); len(errs) > 0 {
+				t.Errorf("%s doesn't match the schema: %s", e, strings.Join(errs, ", "))
+			}
+		})
+	}
+}
+
+func TestServiceSchemaDocumentsInitContainersOrder(t *testing.T) {
+	schema := GetServiceSchema("https://example.com/api/v1/schemas/v1/service.json")
+	init := schema["properties"].(JSONSchema)["init_containers"].(JSONSchema)
+	if d, _ := init["description"].(string); !strings.Contains(d, "in the order they are declared") {
+		t.Errorf("the order of the init containers isn't documented: %+v", init)
+	}
+
+	if init["items"] == nil {
+		t.Errorf("the description replaced the schema of the init containers: %+v", init)
+	}
+
+	definition := schema["definitions"].(map[string]JSONSchema)["InitContainer"]
+	properties := definition["properties"].(JSONSchema)
+	if properties["name"] == nil || properties["image"] == nil || properties["model"] != nil {
+		t.Errorf("the fields of the container aren't inlined: %+v", properties)
+	}
+	if !reflect.DeepEqual(definition["required"], []string{"name", "image"}) {
+		t.Errorf("wrong required fields: %+v", definition["required"])
+	}
+}
+
+func TestServiceSchemaErrors(t *testing.T) {
+	schema := toJSON(t, GetServiceSchema("https://example.com/api/v1/schemas/v1/service.json"))
+
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{
+			name: "unknown-field",
+			manifest: `
+name: test
+replica: 2
+containers:
+  api:
+    image: okteto/api`,
+		},
+		{
+			name: "missing-image",
+			manifest: `
+name: test
+containers:
+  api:
+    command: start.sh`,
+		},
+		{
+			name: "bad-environment",
+			manifest: `
+name: test
+containers:
+  api:
+    image: okteto/api
+    environment:
+      - DEBUG`,
+		},
+		{
+			name: "bad-port",
+			manifest: `
+name: test
+containers:
+  api:
+    image: okteto/api
+    ports:
+      - http:80/sctp`,
+		},
+		{
+			name: "bad-replicas",
+			manifest: `
+name: test
+replicas: two
+containers:
+  api:
+    image: okteto/api`,
+		},
+		{
+			name: "bad-variable",
+			manifest: `
+name: test
+replicas: ${REPLICAS
+containers:
+  api:
+    image: okteto/api`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := validateAgainstSchema(schema, schema, yamlToJSON(t, []byte(tt.manifest)), ""); len(errs) == 0 {
+				t.Errorf("the manifest matched the schema")
+			}
+		})
+	}
+}
+
+func TestProjectSettingsSchema(t *testing.T) {
+	schema := toJSON(t, GetProjectSettingsSchema("https://example.com/api/v1/schemas/v1/project-settings.json"))
+
+	m, err := ioutil.ReadFile("./examples/project_settings.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := validateAgainstSchema(schema, schema, yamlToJSON(t, m), ""); len(errs) > 0 {
+		t.Errorf("the project settings don't match the schema: %s", strings.Join(errs, ", "))
+	}
+
+	var settings ProjectSettings
+	if err := yaml.UnmarshalStrict(m, &settings); err != nil {
+		t.Errorf("the project settings example is not valid: %s", err)
+	}
+}
+
+func toJSON(t *testing.T, s JSONSchema) map[string]interface{} {
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("the schema can't be serialized: %s", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func yamlToJSON(t *testing.T, m []byte) interface{} {
+	var value interface{}
+	if err := yaml.Unmarshal(m, &value); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(stringKeys(value))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, e := range v {
+			result[fmt.Sprintf("%v", k)] = stringKeys(e)
+		}
+		return result
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+	return value
+}
+
+// validateAgainstSchema implements the subset of JSON Schema used by the generated schemas
+func validateAgainstSchema(root, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		definition := root["definitions"].(map[string]interface{})[name].(map[string]interface{})
+		return validateAgainstSchema(root, definition, value, path)
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, s := range anyOf {
+			if len(validateAgainstSchema(root, s.(map[string]interface{}), value, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{fmt.Sprintf("%s: doesn't match any schema", path)}
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		return []string{fmt.Sprintf("%s: %v is not a %v", path, value, t)}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == value
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %v is not one of %v", path, value, enum)}
+		}
+	}
+
+	errs := []string{}
+	switch v := value.(type) {
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && v < minimum {
+			errs = append(errs, fmt.Sprintf("%s: %v is less than %v", path, v, minimum))
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && v > maximum {
+			errs = append(errs, fmt.Sprintf("%s: %v is greater than %v", path, v, maximum))
+		}
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+			errs = append(errs, fmt.Sprintf("%s: '%s' doesn't match %s", path, v, pattern))
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, e := range v {
+				errs = append(errs, validateAgainstSchema(root, items, e, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := v[r.(string)]; !ok {
+					errs = append(errs, fmt.Sprintf("%s: '%s' is required", path, r))
+				}
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		for k, e := range v {
+			if p, ok := properties[k]; ok {
+				errs = append(errs, validateAgainstSchema(root, p.(map[string]interface{}), e, path+"."+k)...)
+				continue
+			}
+
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fmt.Sprintf("%s: unknown field '%s'", path, k))
+				}
+			case map[string]interface{}:
+				errs = append(errs, validateAgainstSchema(root, additional, e, path+"."+k)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func matchesType(t interface{}, value interface{}) bool {
+	if types, ok := t.([]interface{}); ok {
+		for _, e := range types {
+			if matchesType(e, value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return t == "null"
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
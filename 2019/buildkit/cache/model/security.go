@@ -0,0 +1,152 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//SecurityContext represents the security options of a container in a service.yml file
+type SecurityContext struct {
+	RunAsUser                *int64        `json:"run_as_user,omitempty" yaml:"run_as_user,omitempty"`
+	RunAsGroup               *int64        `json:"run_as_group,omitempty" yaml:"run_as_group,omitempty"`
+	RunAsNonRoot             bool          `json:"run_as_non_root,omitempty" yaml:"run_as_non_root,omitempty"`
+	ReadOnlyRootFilesystem   bool          `json:"read_only_root_filesystem,omitempty" yaml:"read_only_root_filesystem,omitempty"`
+	AllowPrivilegeEscalation *bool         `json:"allow_privilege_escalation,omitempty" yaml:"allow_privilege_escalation,omitempty"`
+	Capabilities             *Capabilities `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+}
+
+//Capabilities represents the linux capabilities added to or dropped from a container
+type Capabilities struct {
+	Add  []string `json:"add,omitempty" yaml:"add,omitempty"`
+	Drop []string `json:"drop,omitempty" yaml:"drop,omitempty"`
+}
+
+//SecuritySettings are the security baseline that every container of a project must comply with, including the dev
+//image and the syncthing container deployed in dev mode.
+//An empty list of allowed capabilities doesn't restrict the manifests. DisallowMutableTags requires images referenced by digest,
+//except the images that are built
+type SecuritySettings struct {
+	RunAsNonRoot                bool     `yaml:"run_as_non_root,omitempty"`
+	ReadOnlyRootFilesystem      bool     `yaml:"read_only_root_filesystem,omitempty"`
+	DisallowPrivilegeEscalation bool     `yaml:"disallow_privilege_escalation,omitempty"`
+	RequiredDropCapabilities    []string `yaml:"required_drop_capabilities,omitempty"`
+	AllowedCapabilities         []string `yaml:"allowed_capabilities,omitempty"`
+	DisallowMutableTags         bool     `yaml:"disallow_mutable_tags,omitempty"`
+}
+
+func (sc *SecurityContext) validate(nC string) *AppError {
+	if sc.RunAsUser != nil && *sc.RunAsUser < 0 {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidSecurityContext,
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("'run_as_user' of container '%s' must be greater or equal than zero", nC)}
+	}
+	if sc.RunAsGroup != nil && *sc.RunAsGroup < 0 {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidSecurityContext,
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("'run_as_group' of container '%s' must be greater or equal than zero", nC)}
+	}
+	if sc.RunAsNonRoot && sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidSecurityContext,
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("container '%s' can't set 'run_as_non_root' and run as the root user", nC)}
+	}
+	return nil
+}
+
+//ValidateSecurity returns a SecurityPolicyViolation error if a container of s doesn't comply with the project security baseline
+func (s *Service) ValidateSecurity(ss *SecuritySettings) *AppError {
+	if ss == nil {
+		return nil
+	}
+
+	for nC, c := range s.Containers {
+		if err := ss.check(nC, c); err != nil {
+			return err
+		}
+	}
+	for _, c := range s.InitContainers {
+		if c == nil {
+			continue
+		}
+		if err := ss.check(c.Name, &c.Container); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ss *SecuritySettings) check(nC string, c *Container) *AppError {
+	// the images built by okteto are deployed by the digest of the pushed image
+	if ss.DisallowMutableTags && c.Build == nil {
+		if r, err := ParseImageReference(c.Image); err == nil && !r.IsPinned() {
+			return newSecurityPolicyViolation(nC, "disallow_mutable_tags", fmt.Sprintf("container '%s' must reference its image by digest", nC))
+		}
+	}
+
+	sc := c.SecurityContext
+	if sc == nil {
+		sc = &SecurityContext{}
+	}
+
+	if ss.RunAsNonRoot && !sc.RunAsNonRoot && (sc.RunAsUser == nil || *sc.RunAsUser == 0) {
+		return newSecurityPolicyViolation(nC, "run_as_non_root", fmt.Sprintf("container '%s' must run as a non root user", nC))
+	}
+
+	if ss.ReadOnlyRootFilesystem && !sc.ReadOnlyRootFilesystem {
+		return newSecurityPolicyViolation(nC, "read_only_root_filesystem", fmt.Sprintf("container '%s' must use a read only root filesystem", nC))
+	}
+
+	if ss.DisallowPrivilegeEscalation && (sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation) {
+		return newSecurityPolicyViolation(nC, "allow_privilege_escalation", fmt.Sprintf("container '%s' must set 'allow_privilege_escalation' to false", nC))
+	}
+
+	dropped := []string{}
+	added := []string{}
+	if sc.Capabilities != nil {
+		dropped = sc.Capabilities.Drop
+		added = sc.Capabilities.Add
+	}
+
+	for _, required := range ss.RequiredDropCapabilities {
+		if !containsCapability(dropped, required) && !containsCapability(dropped, "ALL") {
+			return newSecurityPolicyViolation(nC, "capabilities", fmt.Sprintf("container '%s' must drop the '%s' capability", nC, required))
+		}
+	}
+
+	if len(ss.AllowedCapabilities) > 0 {
+		for _, a := range added {
+			if !containsCapability(ss.AllowedCapabilities, a) {
+				return newSecurityPolicyViolation(nC, "capabilities", fmt.Sprintf("container '%s' can't add the '%s' capability", nC, a))
+			}
+		}
+	}
+
+	return nil
+}
+
+func newSecurityPolicyViolation(nC, policy, message string) *AppError {
+	return &AppError{
+		Status:  http.StatusBadRequest,
+		Code:    SecurityPolicyViolation,
+		Data:    map[string]string{"container": nC, "policy": policy},
+		Message: message,
+	}
+}
+
+// containsCapability compares capabilities ignoring the case and the optional CAP_ prefix
+func containsCapability(capabilities []string, capability string) bool {
+	capability = strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+	for _, c := range capabilities {
+		if strings.TrimPrefix(strings.ToUpper(c), "CAP_") == capability {
+			return true
+		}
+	}
+	return false
+}
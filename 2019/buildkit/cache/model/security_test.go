@@ -0,0 +1,245 @@
+package model
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestReadSecurityContext(t *testing.T) {
+	readBytes, err := ioutil.ReadFile("./examples/service_with_security_context.yml")
+	require.NoError(t, err)
+	var s Service
+	err = yaml.Unmarshal(readBytes, &s)
+	require.NoError(t, err)
+
+	sc := s.Containers["app"].SecurityContext
+	if sc == nil {
+		t.Fatal("didn't parse security_context")
+	}
+
+	if *sc.RunAsUser != 1000 || *sc.RunAsGroup != 1000 || !sc.RunAsNonRoot || !sc.ReadOnlyRootFilesystem || *sc.AllowPrivilegeEscalation {
+		t.Errorf("wrong security context: %+v", sc)
+	}
+
+	if err := s.Validate(nil); err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+
+	baseline := &SecuritySettings{
+		RunAsNonRoot:                true,
+		ReadOnlyRootFilesystem:      true,
+		DisallowPrivilegeEscalation: true,
+		RequiredDropCapabilities:    []string{"NET_RAW"},
+		AllowedCapabilities:         []string{"CAP_NET_BIND_SERVICE"},
+	}
+	if err := s.ValidateSecurity(baseline); err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+}
+
+func TestValidateSecurity(t *testing.T) {
+	root := int64(0)
+	user := int64(1000)
+	no := false
+	yes := true
+
+	tests := []struct {
+		name     string
+		baseline *SecuritySettings
+		context  *SecurityContext
+		image    string
+		build    *Build
+		policy   string
+	}{
+		{
+			name:     "no-baseline",
+			baseline: nil,
+			context:  nil,
+		},
+		{
+			name:     "non-root-user",
+			baseline: &SecuritySettings{RunAsNonRoot: true},
+			context:  &SecurityContext{RunAsUser: &user},
+		},
+		{
+			name:     "non-root-flag",
+			baseline: &SecuritySettings{RunAsNonRoot: true},
+			context:  &SecurityContext{RunAsNonRoot: true},
+		},
+		{
+			name:     "missing-context",
+			baseline: &SecuritySettings{RunAsNonRoot: true},
+			context:  nil,
+			policy:   "run_as_non_root",
+		},
+		{
+			name:     "root-user",
+			baseline: &SecuritySettings{RunAsNonRoot: true},
+			context:  &SecurityContext{RunAsUser: &root},
+			policy:   "run_as_non_root",
+		},
+		{
+			name:     "writable-root",
+			baseline: &SecuritySettings{ReadOnlyRootFilesystem: true},
+			context:  &SecurityContext{},
+			policy:   "read_only_root_filesystem",
+		},
+		{
+			name:     "privilege-escalation-unset",
+			baseline: &SecuritySettings{DisallowPrivilegeEscalation: true},
+			context:  &SecurityContext{},
+			policy:   "allow_privilege_escalation",
+		},
+		{
+			name:     "privilege-escalation-enabled",
+			baseline: &SecuritySettings{DisallowPrivilegeEscalation: true},
+			context:  &SecurityContext{AllowPrivilegeEscalation: &yes},
+			policy:   "allow_privilege_escalation",
+		},
+		{
+			name:     "privilege-escalation-disabled",
+			baseline: &SecuritySettings{DisallowPrivilegeEscalation: true},
+			context:  &SecurityContext{AllowPrivilegeEscalation: &no},
+		},
+		{
+			name:     "drop-all",
+			baseline: &SecuritySettings{RequiredDropCapabilities: []string{"NET_RAW", "SYS_ADMIN"}},
+			context:  &SecurityContext{Capabilities: &Capabilities{Drop: []string{"all"}}},
+		},
+		{
+			name:     "missing-drop",
+			baseline: &SecuritySettings{RequiredDropCapabilities: []string{"NET_RAW"}},
+			context:  &SecurityContext{Capabilities: &Capabilities{Drop: []string{"SYS_ADMIN"}}},
+			policy:   "capabilities",
+		},
+		{
+			name:     "added-capability",
+			baseline: &SecuritySettings{AllowedCapabilities: []string{"NET_BIND_SERVICE"}},
+			context:  &SecurityContext{Capabilities: &Capabilities{Add: []string{"SYS_ADMIN"}}},
+			policy:   "capabilities",
+		},
+		{
+			name:     "mutable-tag",
+			baseline: &SecuritySettings{DisallowMutableTags: true},
+			image:    "nginx:alpine",
+			policy:   "disallow_mutable_tags",
+		},
+		{
+			name:     "pinned-digest",
+			baseline: &SecuritySettings{DisallowMutableTags: true},
+			image:    "nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac",
+		},
+		{
+			name:     "built-image",
+			baseline: &SecuritySettings{DisallowMutableTags: true},
+			image:    "registry.okteto.net/okteto/api:master",
+			build:    &Build{Context: "api"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := tt.image
+			if image == "" {
+				image = "app"
+			}
+
+			s := &Service{
+				Name:     "test",
+				Replicas: 1,
+				Containers: map[string]*Container{
+					"app": &Container{Image: image, Build: tt.build, SecurityContext: tt.context},
+				},
+			}
+
+			err := s.ValidateSecurity(tt.baseline)
+			if tt.policy == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("didn't get the expected error for %s", tt.policy)
+			}
+
+			if err.Code != SecurityPolicyViolation || err.Data["policy"] != tt.policy || err.Data["container"] != "app" {
+				t.Errorf("expected %s for %s, got %s %+v", SecurityPolicyViolation, tt.policy, err.Code, err.Data)
+			}
+		})
+	}
+}
+
+func TestValidateSecurityContext(t *testing.T) {
+	root := int64(0)
+	negative := int64(-1)
+	tests := []struct {
+		name    string
+		context *SecurityContext
+		wantErr bool
+	}{
+		{name: "empty", context: &SecurityContext{}},
+		{name: "negative-user", context: &SecurityContext{RunAsUser: &negative}, wantErr: true},
+		{name: "negative-group", context: &SecurityContext{RunAsGroup: &negative}, wantErr: true},
+		{name: "non-root-as-root", context: &SecurityContext{RunAsUser: &root, RunAsNonRoot: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				Name:     "test",
+				Replicas: 1,
+				Containers: map[string]*Container{
+					"app": &Container{Image: "app", SecurityContext: tt.context},
+				},
+			}
+
+			err := s.Validate(nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if err != nil && err.Code != InvalidSecurityContext {
+				t.Errorf("expected %s, got %s", InvalidSecurityContext, err.Code)
+			}
+		})
+	}
+}
+
+func TestValidateChecksProjectPolicies(t *testing.T) {
+	manifest := []byte(`
+name: test
+tolerations:
+  - key: gpu
+    operator: Exists
+containers:
+  app:
+    image: okteto/app`)
+
+	if _, err := parseManifest(manifest, true, nil); err != nil {
+		t.Fatalf("the manifest without settings failed: %s", err)
+	}
+
+	settings := &ProjectSettings{
+		Security:   &SecuritySettings{RunAsNonRoot: true},
+		Scheduling: &SchedulingSettings{AllowedTolerations: []string{"spot"}},
+	}
+
+	_, err := parseManifest(manifest, true, settings)
+	if err == nil {
+		t.Fatal("the manifest didn't comply with the project policies")
+	}
+
+	codes := map[AppErrorCode]bool{}
+	for _, e := range err.Errors {
+		codes[e.Code] = true
+	}
+
+	if !codes[SecurityPolicyViolation] || !codes[SchedulingNotAllowed] {
+		t.Errorf("expected every policy violation, got %+v", err.Errors)
+	}
+}
@@ -19,7 +19,8 @@ func (s *Service) translatePorts() {
 			for _, p := range c.Ports {
 				parts := strings.SplitN(p, ":", 4)
 				if len(parts) == 4 {
-					ports = append(ports, parts[3])
+					// the legacy syntax can have a certificate after the port, e.g. https:443:http:8000:arn:...
+					ports = append(ports, strings.SplitN(parts[3], ":", 2)[0])
 				} else {
 					ports = append(ports, p)
 				}
@@ -53,6 +54,16 @@ func (s *Service) translateVolumes() {
 	}
 }
 
+func (s *Service) translateConfigs() {
+	for cName, c := range s.Configs {
+		if c == nil {
+			c = &Config{}
+			s.Configs[cName] = c
+		}
+		c.Name = cName
+	}
+}
+
 //MarshalYAML serializes e into a YAML document. The return value is a string; It will fail if e has an empty name.
 func (e *EnvVar) MarshalYAML() (interface{}, error) {
 	if e.Name == "" {
@@ -98,7 +98,7 @@ func TestTranslatePorts(t *testing.T) {
 				Name: "test",
 				Containers: map[string]*Container{
 					"nginx": &Container{
-						Ports: []string{"https:443:http:80"},
+						Ports: []string{"https:443:http:80", "https:443:http:8000:arn:aws:acm:us-west-2:account:certificate/uuid", "53/udp"},
 						Ingress: []*Ingress{
 							{
 								Port: "8080",
@@ -111,7 +111,7 @@ func TestTranslatePorts(t *testing.T) {
 				Name: "test",
 				Containers: map[string]*Container{
 					"nginx": &Container{
-						Ports: []string{"80"},
+						Ports: []string{"80", "8000", "53/udp"},
 						Ingress: []*Ingress{
 							{
 								Host: "test",
@@ -309,7 +309,7 @@ containers:
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, appErr := parseManifest(tt.manifest)
+			_, appErr := parseManifest(tt.manifest, true, nil)
 			if tt.expected == "" {
 				if appErr != nil {
 					t.Errorf("Unexpected error: %s,  %s", appErr.Code, appErr.Message)
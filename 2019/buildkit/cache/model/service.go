@@ -1,10 +1,17 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
 )
 
 var isAlphaNumeric = regexp.MustCompile(`^[a-zA-Z0-9][-a-zA-Z0-9]*$`).MatchString
@@ -65,19 +72,68 @@ type Service struct {
 	DNS          string        `json:"-" gorm:"dns" yaml:"-"`
 	Manifest     string        `json:"manifest,omitempty" gorm:"manifest"  yaml:"-"`
 	GHRepoLinkID string        `json:"-,omitempty" yaml:"-" gorm:"index"`
+	Overlay      string        `json:"overlay,omitempty" gorm:"overlay" yaml:"-"`
+	Branch       string        `json:"branch,omitempty" yaml:"-"`
+	Commit       string        `json:"commit,omitempty" yaml:"-"`
+	PreviewID    string        `json:"preview,omitempty" yaml:"-" gorm:"index"`
+	Namespace    string        `json:"namespace,omitempty" yaml:"-"`
+
+	// ExpirationNotified is true once the creator of a demo service was notified that it's going to be destroyed
+	ExpirationNotified bool `json:"-" yaml:"-"`
+
+	// AutoDeployCommit is the commit of the linked repository waiting to be auto deployed at AutoDeployAt. They are
+	// saved so the pending auto deploys aren't lost on restarts
+	AutoDeployCommit string     `json:"-" yaml:"-"`
+	AutoDeployAt     *time.Time `json:"-" yaml:"-" gorm:"index"`
 
 	// YAML content
 	Replicas    int                   `json:"replicas,omitempty" yaml:"replicas,omitempty" gorm:"-"`
+	Autoscale   *Autoscale            `json:"autoscale,omitempty" yaml:"autoscale,omitempty" gorm:"-"`
 	GracePeriod int                   `json:"grace_period,omitempty" yaml:"grace_period,omitempty" gorm:"-"`
 	Containers  map[string]*Container `json:"containers,omitempty" yaml:"containers,omitempty" gorm:"-"`
 	Volumes     map[string]*Volume    `json:"volumes,omitempty" yaml:"volumes,omitempty" gorm:"-"`
+	Configs     map[string]*Config    `json:"configs,omitempty" yaml:"configs,omitempty" gorm:"-"`
 	Labels      map[string]string     `json:"labels,omitempty" yaml:"labels,omitempty" gorm:"-"`
 
+	NodeSelector  map[string]string `json:"node_selector,omitempty" yaml:"node_selector,omitempty" gorm:"-"`
+	Tolerations   []*Toleration     `json:"tolerations,omitempty" yaml:"tolerations,omitempty" gorm:"-"`
+	AntiAffinity  string            `json:"anti_affinity,omitempty" yaml:"anti_affinity,omitempty" gorm:"-"`
+	PriorityClass string            `json:"priority_class,omitempty" yaml:"priority_class,omitempty" gorm:"-"`
+
+	// InitContainers run to completion before the containers are started, one after the other in the order they are
+	// declared
+	InitContainers []*InitContainer `json:"init_containers,omitempty" yaml:"init_containers,omitempty" gorm:"-"`
+
 	// Linked resources
 	Activities []Activity `json:"activities,omitempty" yaml:"-"`
 
-	Endpoints []string     `json:"endpoints,omitempty" gorm:"-"  yaml:"-"`
-	Links     ServiceLinks `json:"links,omitempty" gorm:"-"  yaml:"-"`
+	Endpoints     []string       `json:"endpoints,omitempty" gorm:"-"  yaml:"-"`
+	Links         ServiceLinks   `json:"links,omitempty" gorm:"-"  yaml:"-"`
+	ReplicaStatus *ReplicaStatus `json:"replica_status,omitempty" gorm:"-"  yaml:"-"`
+}
+
+//Autoscale represents the horizontal autoscaling configuration of a service
+type Autoscale struct {
+	Min    int `json:"min,omitempty" yaml:"min,omitempty"`
+	Max    int `json:"max,omitempty" yaml:"max,omitempty"`
+	CPU    int `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	Memory int `json:"memory,omitempty" yaml:"memory,omitempty"`
+}
+
+//ReplicaStatus represents the current replica count of a deployed service
+type ReplicaStatus struct {
+	Desired int `json:"desired"`
+	Current int `json:"current"`
+	Ready   int `json:"ready"`
+	Min     int `json:"min,omitempty"`
+	Max     int `json:"max,omitempty"`
+}
+
+//InitContainer represents an init container in a service.yml file, a container that runs to completion before the
+//containers of the service are started
+type InitContainer struct {
+	Name      string `json:"name" yaml:"name"`
+	Container `yaml:",inline"`
 }
 
 //Container represents a container in a service.yml file
@@ -93,6 +149,9 @@ type Container struct {
 	Environment []*EnvVar         `json:"environment,omitempty" yaml:"environment,omitempty"`
 	Resources   *Resources        `json:"resources,omitempty" yaml:"resources,omitempty"`
 	Development *Development      `json:"dev,omitempty" yaml:"dev,omitempty"`
+	Build       *Build            `json:"build,omitempty" yaml:"build,omitempty"`
+
+	SecurityContext *SecurityContext `json:"security_context,omitempty" yaml:"security_context,omitempty"`
 }
 
 //Volume represents a volume in a service.yml file
@@ -102,6 +161,14 @@ type Volume struct {
 	Size       string `json:"size,omitempty" yaml:"size,omitempty"`
 }
 
+//Config represents a configuration file in a service.yml file.
+//Its content is declared inline or read from a file of the linked github repository
+type Config struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Content string `json:"content,omitempty" yaml:"content,omitempty"`
+	File    string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
 //Mount represents a volume mount in a service.yml file
 type Mount struct {
 	Path string `json:"path,omitempty" yaml:"path,omitempty"`
@@ -132,14 +199,16 @@ type Resource struct {
 	CPU    string `json:"cpu,omitempty" yaml:"cpu,omitempty"`
 }
 
-//UnmarshalYAML sets the default value of replica to 1
+//UnmarshalYAML sets the default value of replica to 1. The fields are set even if some of them have the wrong type, so
+//the rest of the manifest can be validated too
 func (s *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type rawService Service
 	raw := rawService{}
 	raw.Replicas = 1
 	raw.GracePeriod = 30
 
-	if err := unmarshal(&raw); err != nil {
+	err := unmarshal(&raw)
+	if _, ok := err.(*yaml.TypeError); err != nil && !ok {
 		return err
 	}
 
@@ -147,7 +216,8 @@ func (s *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	s.translatePorts()
 	s.translateVolumes()
-	return nil
+	s.translateConfigs()
+	return err
 }
 
 func contains(array map[string]*Volume, elem string) bool {
@@ -159,65 +229,336 @@ func contains(array map[string]*Volume, elem string) bool {
 	return false
 }
 
-//Validate returns an error for invalid service.yml files
-func (s *Service) Validate() *AppError {
-	if s.Name == "" {
-		return &AppError{Status: http.StatusBadRequest, Code: MissingName, Message: "'service.name' is mandatory"}
+//Validate returns an error for invalid service.yml files, or for the ones that don't comply with the scheduling and
+//security policies of settings. The policies are skipped if settings is nil.
+//The error is the first problem found, and its Errors field lists every problem of the manifest
+func (s *Service) Validate(settings *ProjectSettings) *AppError {
+	errs := s.validate()
+	if settings != nil {
+		if err := s.ValidateScheduling(settings.Scheduling); err != nil {
+			errs = append(errs, err)
+		}
+		if err := s.ValidateSecurity(settings.Security); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	if !isAlphaNumeric(s.Name) {
-		return &AppError{Status: http.StatusBadRequest, Code: InvalidName, Message: "'service.name' only allows alphanumeric characters or dashes"}
+	return newAppErrorList(errs)
+}
+
+func (s *Service) validate() []*AppError {
+	errs := []*AppError{}
+	if s.Name == "" {
+		errs = append(errs, &AppError{Status: http.StatusBadRequest, Code: MissingName, Field: "name", Message: "'service.name' is mandatory"})
+	} else if !isAlphaNumeric(s.Name) {
+		errs = append(errs, &AppError{Status: http.StatusBadRequest, Code: InvalidName, Field: "name", Message: "'service.name' only allows alphanumeric characters or dashes"})
 	}
 
 	if s.GracePeriod < 0 {
-		return &AppError{Status: http.StatusBadRequest, Code: InvalidGracePeriod, Message: "'service.grace_period' must be greater than zero or zero for no grace period"}
+		errs = append(errs, &AppError{Status: http.StatusBadRequest, Code: InvalidGracePeriod, Field: "grace_period", Message: "'service.grace_period' must be greater than zero or zero for no grace period"})
 	}
 
 	if s.Replicas < 1 {
-		return &AppError{Status: http.StatusBadRequest, Code: InvalidReplicaCount, Message: "'service.replicas' must be greater than zero"}
+		errs = append(errs, &AppError{Status: http.StatusBadRequest, Code: InvalidReplicaCount, Field: "replicas", Message: "'service.replicas' must be greater than zero"})
+	} else if s.IsPersistent() && s.Replicas > 1 {
+		errs = append(errs, &AppError{Status: http.StatusBadRequest, Code: InvalidPersistentReplica, Field: "replicas", Message: "persistent volumes can only be used with a single replica"})
+	}
+
+	if s.Autoscale != nil {
+		if err := s.Autoscale.validate(); err != nil {
+			errs = append(errs, err)
+		} else if s.IsPersistent() && s.Autoscale.Max > 1 {
+			errs = append(errs, &AppError{Status: http.StatusBadRequest, Code: InvalidPersistentReplica, Field: "autoscale.max", Message: "persistent volumes can only be used with a single replica"})
+		}
 	}
-	if s.IsPersistent() && s.Replicas > 1 {
-		return &AppError{Status: http.StatusBadRequest, Code: InvalidPersistentReplica, Message: "persistent volumes can only be used with a single replica"}
+
+	errs = append(errs, s.validateScheduling()...)
+
+	for _, name := range getConfigNames(s.Configs) {
+		c := s.Configs[name]
+		if err := c.validate(); err != nil {
+			errs = append(errs, err)
+		} else if contains(s.Volumes, name) {
+			errs = append(errs, &AppError{
+				Status:  http.StatusBadRequest,
+				Code:    InvalidConfig,
+				Field:   fmt.Sprintf("configs.%s", name),
+				Data:    map[string]string{"config": name},
+				Message: fmt.Sprintf("Config '%s' has the same name as a volume", name)})
+		}
 	}
 
-	if s.Containers == nil || len(s.Containers) == 0 {
-		return &AppError{Status: 400, Code: InvalidContainerCount}
+	if len(s.Containers) == 0 {
+		errs = append(errs, &AppError{Status: 400, Code: InvalidContainerCount, Field: "containers"})
 	}
 
-	for name, c := range s.Containers {
+	devContainerCount := 0
+	for _, nC := range getContainerNames(s.Containers) {
+		c := s.Containers[nC]
 		if c == nil || c.Image == "" {
-			return &AppError{
+			errs = append(errs, &AppError{
 				Status:  400,
 				Code:    MissingContainerImage,
-				Data:    map[string]string{"container": name},
-				Message: fmt.Sprintf("%s must have an image defined", name),
-			}
+				Field:   fmt.Sprintf("containers.%s.image", nC),
+				Data:    map[string]string{"container": nC},
+				Message: fmt.Sprintf("%s must have an image defined", nC),
+			})
+			continue
 		}
-	}
 
-	devContainerCount := 0
-	for nC, c := range s.Containers {
 		if c.Development != nil {
 			devContainerCount++
 		}
 
-		for nV := range c.Mounts {
-			if !contains(s.Volumes, nV) {
-				return &AppError{
-					Status:  http.StatusBadRequest,
-					Code:    VolumeNotDefined,
-					Data:    map[string]string{"volume": nV, "container": nC},
-					Message: fmt.Sprintf("Volume '%s' in container '%s' not defined", nV, nC)}
+		if s.Autoscale != nil {
+			if err := s.Autoscale.validateRequests(nC, c); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		errs = append(errs, s.validatePorts(nC, c)...)
+		errs = append(errs, s.validateMounts(fmt.Sprintf("containers.%s", nC), nC, c)...)
+		errs = append(errs, c.validateBuild(fmt.Sprintf("containers.%s", nC), nC)...)
+
+		if c.SecurityContext != nil {
+			if err := c.SecurityContext.validate(nC); err != nil {
+				err.Field = fmt.Sprintf("containers.%s.security_context", nC)
+				errs = append(errs, err)
 			}
 		}
 	}
+	errs = append(errs, s.validatePortNames()...)
 	if devContainerCount > 1 {
-		return &AppError{Status: http.StatusBadRequest, Code: InvalidDevContainerCount, Message: "Services can only have one container configured for development"}
+		errs = append(errs, &AppError{Status: http.StatusBadRequest, Code: InvalidDevContainerCount, Field: "containers", Message: "Services can only have one container configured for development"})
+	}
+
+	names := map[string]bool{}
+	for i, c := range s.InitContainers {
+		errs = append(errs, s.validateInitContainer(i, c, names)...)
+	}
+
+	return errs
+}
+
+// validateMounts returns an error for every mount of c, the container nC declared at field, that isn't a volume or a
+// config of s
+func (s *Service) validateMounts(field, nC string, c *Container) []*AppError {
+	errs := []*AppError{}
+	names := make([]string, 0, len(c.Mounts))
+	for nV := range c.Mounts {
+		names = append(names, nV)
+	}
+	sort.Strings(names)
+
+	for _, nV := range names {
+		m := c.Mounts[nV]
+		field := fmt.Sprintf("%s.mounts.%s", field, nV)
+		if _, ok := s.Configs[nV]; ok {
+			if m == nil || !strings.HasPrefix(m.Path, "/") || strings.HasSuffix(m.Path, "/") {
+				errs = append(errs, &AppError{
+					Status:  http.StatusBadRequest,
+					Code:    InvalidConfig,
+					Field:   field,
+					Data:    map[string]string{"config": nV, "container": nC},
+					Message: fmt.Sprintf("Config '%s' in container '%s' must be mounted at an absolute file path", nV, nC)})
+			}
+			continue
+		}
+		if !contains(s.Volumes, nV) {
+			errs = append(errs, &AppError{
+				Status:  http.StatusBadRequest,
+				Code:    VolumeNotDefined,
+				Field:   field,
+				Data:    map[string]string{"volume": nV, "container": nC},
+				Message: fmt.Sprintf("Volume '%s' in container '%s' not defined", nV, nC)})
+		}
+	}
+	return errs
+}
 
+// validateInitContainer returns the errors of the init container c, declared at the position i. names has the names of
+// the init containers declared before it
+func (s *Service) validateInitContainer(i int, ic *InitContainer, names map[string]bool) []*AppError {
+	field := fmt.Sprintf("init_containers[%d]", i)
+	if ic == nil || ic.Name == "" {
+		return []*AppError{&AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidInitContainer,
+			Field:   field + ".name",
+			Message: fmt.Sprintf("'service.init_containers[%d].name' is mandatory", i),
+		}}
 	}
 
+	nC := ic.Name
+	c := &ic.Container
+	if c.Image == "" {
+		return []*AppError{&AppError{
+			Status:  http.StatusBadRequest,
+			Code:    MissingContainerImage,
+			Field:   field + ".image",
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("%s must have an image defined", nC),
+		}}
+	}
+
+	errs := []*AppError{}
+	if names[nC] {
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidInitContainer,
+			Field:   field + ".name",
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("Init container '%s' is declared more than once", nC),
+		})
+	}
+	names[nC] = true
+
+	if !isAlphaNumeric(nC) {
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidInitContainer,
+			Field:   field,
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("Init container '%s' only allows alphanumeric characters or dashes", nC),
+		})
+	}
+
+	if _, ok := s.Containers[nC]; ok {
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidInitContainer,
+			Field:   field,
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("Init container '%s' has the same name as a container", nC),
+		})
+	}
+
+	if c.Development != nil || len(c.Ports) > 0 || len(c.Ingress) > 0 || len(c.Expose) > 0 {
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidInitContainer,
+			Field:   field,
+			Data:    map[string]string{"container": nC},
+			Message: fmt.Sprintf("Init container '%s' can't declare 'ports', 'ingress', 'expose' or 'dev'", nC),
+		})
+	}
+
+	if c.SecurityContext != nil {
+		if err := c.SecurityContext.validate(nC); err != nil {
+			err.Field = field + ".security_context"
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, c.validateBuild(field, nC)...)
+	return append(errs, s.validateMounts(field, nC, c)...)
+}
+
+func (a *Autoscale) validate() *AppError {
+	if a.Min < 1 {
+		return &AppError{Status: http.StatusBadRequest, Code: InvalidAutoscale, Field: "autoscale.min", Message: "'service.autoscale.min' must be greater than zero"}
+	}
+	if a.Max < a.Min {
+		return &AppError{Status: http.StatusBadRequest, Code: InvalidAutoscale, Field: "autoscale.max", Message: "'service.autoscale.max' must be greater or equal than 'service.autoscale.min'"}
+	}
+	if a.CPU == 0 && a.Memory == 0 {
+		return &AppError{Status: http.StatusBadRequest, Code: InvalidAutoscale, Field: "autoscale", Message: "'service.autoscale' requires a 'cpu' or 'memory' target utilization"}
+	}
+	if a.CPU < 0 || a.CPU > 100 || a.Memory < 0 || a.Memory > 100 {
+		return &AppError{Status: http.StatusBadRequest, Code: InvalidAutoscale, Field: "autoscale", Message: "'service.autoscale' target utilization must be between 1 and 100"}
+	}
 	return nil
 }
 
+// validateRequests checks that c declares the resource requests needed to compute the utilization targets of a
+func (a *Autoscale) validateRequests(name string, c *Container) *AppError {
+	missing := ""
+	switch {
+	case c.Resources == nil || c.Resources.Requests == nil:
+		missing = "cpu"
+		if a.CPU == 0 {
+			missing = "memory"
+		}
+	case a.CPU > 0 && c.Resources.Requests.CPU == "":
+		missing = "cpu"
+	case a.Memory > 0 && c.Resources.Requests.Memory == "":
+		missing = "memory"
+	default:
+		return nil
+	}
+
+	return &AppError{
+		Status:  http.StatusBadRequest,
+		Code:    MissingResourceRequests,
+		Field:   fmt.Sprintf("containers.%s.resources.requests.%s", name, missing),
+		Data:    map[string]string{"container": name, "resource": missing},
+		Message: fmt.Sprintf("%s must define 'resources.requests.%s' to use autoscaling", name, missing),
+	}
+}
+
+func (c *Config) validate() *AppError {
+	if !isAlphaNumeric(c.Name) {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidConfig,
+			Field:   fmt.Sprintf("configs.%s", c.Name),
+			Data:    map[string]string{"config": c.Name},
+			Message: fmt.Sprintf("Config '%s' only allows alphanumeric characters or dashes", c.Name)}
+	}
+	if (c.Content == "") == (c.File == "") {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidConfig,
+			Field:   fmt.Sprintf("configs.%s", c.Name),
+			Data:    map[string]string{"config": c.Name},
+			Message: fmt.Sprintf("Config '%s' must declare either 'content' or 'file'", c.Name)}
+	}
+	return nil
+}
+
+//GetConfigMapName returns the name of the k8 config map holding the service configs
+func (s *Service) GetConfigMapName() string {
+	return fmt.Sprintf("%s-configs", s.Name)
+}
+
+//GetConfigsChecksum returns a checksum of the content of the service configs.
+//It changes whenever a config is added, removed or modified
+func (s *Service) GetConfigsChecksum() string {
+	names := []string{}
+	for name := range s.Configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%s\x00", name, s.Configs[name].Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func getConfigNames(configs map[string]*Config) []string {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getContainerNames(containers map[string]*Container) []string {
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//IsAutoscaled returns if the service replicas are managed by an autoscaler
+func (s *Service) IsAutoscaled() bool {
+	return s.Autoscale != nil
+}
+
 //GetDNS returns the service dns (record name)
 func (s *Service) GetDNS(e *Environment) string {
 	hostedZone := strings.TrimSuffix(e.DNSProvider.HostedZone, ".")
@@ -239,14 +580,20 @@ func (s *Service) GetLoadBalancerPorts() []string {
 	return result
 }
 
-//GetIngressRules returns the list of ingress rules of a service
+//GetIngressRules returns the list of ingress rules of a service. If all is true the tcp ports of the containers
+//are included with the number of the port, ingresses can't route udp traffic
 func (s *Service) GetIngressRules(all bool) []*Ingress {
 	result := []*Ingress{}
 	seen := map[string]bool{}
 	for _, container := range s.Containers {
 		if all {
 			for _, p := range container.Ports {
-				i := &Ingress{Host: s.Name, Path: "/", Port: p}
+				port, err := ParsePort(p)
+				if err != nil || port.Protocol != TCP {
+					continue
+				}
+
+				i := &Ingress{Host: s.Name, Path: "/", Port: strconv.Itoa(int(port.Number))}
 				value := fmt.Sprintf("%s-%s-%s", i.Host, i.Path, i.Port)
 				if _, ok := seen[value]; !ok {
 					seen[value] = true
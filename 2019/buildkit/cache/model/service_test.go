@@ -74,7 +74,7 @@ func TestValidateService(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.s.Validate()
+			err := tt.s.Validate(nil)
 			if tt.expectError {
 				if err == nil {
 					t.Error("did't got the expected error")
@@ -89,6 +89,49 @@ func TestValidateService(t *testing.T) {
 	}
 }
 
+func TestValidateServiceCollectsAllErrors(t *testing.T) {
+	s := &Service{
+		Name:     "test_service",
+		Replicas: 0,
+		Volumes:  map[string]*Volume{"data": &Volume{Name: "data"}},
+		Containers: map[string]*Container{
+			"api": &Container{
+				Mounts: map[string]*Mount{"data": &Mount{Path: "/data"}},
+			},
+			"web": &Container{
+				Image:  "nginx",
+				Mounts: map[string]*Mount{"cache": &Mount{Path: "/cache"}},
+			},
+		},
+	}
+
+	err := s.Validate(nil)
+	if err == nil {
+		t.Fatal("didn't get the expected error")
+	}
+
+	if err.Code != InvalidName {
+		t.Errorf("expected the first error to be %s, got %s", InvalidName, err.Code)
+	}
+
+	expected := map[string]AppErrorCode{
+		"name":                        InvalidName,
+		"replicas":                    InvalidReplicaCount,
+		"containers.api.image":        MissingContainerImage,
+		"containers.web.mounts.cache": VolumeNotDefined,
+	}
+
+	if len(err.Errors) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %+v", len(expected), len(err.Errors), err.Errors)
+	}
+
+	for _, e := range err.Errors {
+		if code, ok := expected[e.Field]; !ok || code != e.Code {
+			t.Errorf("unexpected error %s for field '%s'", e.Code, e.Field)
+		}
+	}
+}
+
 func TestGetDNS(t *testing.T) {
 	s := Service{Name: "test"}
 	e := Environment{
@@ -191,6 +234,26 @@ func TestGetIngressRules(t *testing.T) {
 	}
 }
 
+func TestGetIngressRulesOfNamedAndUDPPorts(t *testing.T) {
+	s := Service{
+		Name: "test",
+		Containers: map[string]*Container{
+			"api": &Container{
+				Ports: []string{"http:8080", "dns:53/udp", "5000/tcp"},
+			},
+		},
+	}
+
+	irs := s.GetIngressRules(true)
+	expected := []*Ingress{
+		&Ingress{Host: "test", Path: "/", Port: "8080"},
+		&Ingress{Host: "test", Path: "/", Port: "5000"},
+	}
+	if !reflect.DeepEqual(irs, expected) {
+		t.Errorf("Expected: %+v \n Received: %+v", expected, irs)
+	}
+}
+
 func TestGetPrivatePorts(t *testing.T) {
 	s := Service{
 		Name: "test",
@@ -357,6 +420,345 @@ func TestEmptyResources(t *testing.T) {
 	}
 }
 
+func TestReadAutoscale(t *testing.T) {
+	readBytes, err := ioutil.ReadFile("./examples/service_with_autoscale.yml")
+	require.NoError(t, err)
+	var s Service
+	err = yaml.Unmarshal(readBytes, &s)
+	require.NoError(t, err)
+
+	if !s.IsAutoscaled() {
+		t.Fatal("didn't parse autoscale")
+	}
+
+	expected := &Autoscale{Min: 2, Max: 10, CPU: 80}
+	if !reflect.DeepEqual(s.Autoscale, expected) {
+		t.Fatalf("Expected: %+v \n Received: %+v", expected, s.Autoscale)
+	}
+
+	if err := s.Validate(nil); err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+}
+
+func TestValidateAutoscale(t *testing.T) {
+	requests := &Resources{Requests: &Resource{CPU: "250m"}}
+	tests := []struct {
+		name      string
+		autoscale *Autoscale
+		resources *Resources
+		volumes   map[string]*Volume
+		expected  AppErrorCode
+	}{
+		{
+			name:      "valid",
+			autoscale: &Autoscale{Min: 1, Max: 3, CPU: 50},
+			resources: requests,
+		},
+		{
+			name:      "missing-min",
+			autoscale: &Autoscale{Max: 3, CPU: 50},
+			resources: requests,
+			expected:  InvalidAutoscale,
+		},
+		{
+			name:      "max-lower-than-min",
+			autoscale: &Autoscale{Min: 3, Max: 2, CPU: 50},
+			resources: requests,
+			expected:  InvalidAutoscale,
+		},
+		{
+			name:      "missing-target",
+			autoscale: &Autoscale{Min: 1, Max: 3},
+			resources: requests,
+			expected:  InvalidAutoscale,
+		},
+		{
+			name:      "invalid-target",
+			autoscale: &Autoscale{Min: 1, Max: 3, CPU: 150},
+			resources: requests,
+			expected:  InvalidAutoscale,
+		},
+		{
+			name:      "missing-requests",
+			autoscale: &Autoscale{Min: 1, Max: 3, CPU: 50},
+			expected:  MissingResourceRequests,
+		},
+		{
+			name:      "missing-memory-request",
+			autoscale: &Autoscale{Min: 1, Max: 3, CPU: 50, Memory: 50},
+			resources: requests,
+			expected:  MissingResourceRequests,
+		},
+		{
+			name:      "persistent",
+			autoscale: &Autoscale{Min: 1, Max: 3, CPU: 50},
+			resources: requests,
+			volumes:   map[string]*Volume{"data": &Volume{Name: "data", Persistent: true}},
+			expected:  InvalidPersistentReplica,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				Name:      "test",
+				Replicas:  1,
+				Autoscale: tt.autoscale,
+				Volumes:   tt.volumes,
+				Containers: map[string]*Container{
+					"nginx": &Container{Image: "nginx", Resources: tt.resources},
+				},
+			}
+
+			err := s.Validate(nil)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("didn't get the expected error %s", tt.expected)
+			}
+
+			if err.Code != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, err.Code)
+			}
+		})
+	}
+}
+
+func TestReadConfigs(t *testing.T) {
+	readBytes, err := ioutil.ReadFile("./examples/service_with_configs.yml")
+	require.NoError(t, err)
+	var s Service
+	err = yaml.Unmarshal(readBytes, &s)
+	require.NoError(t, err)
+
+	expected := map[string]*Config{
+		"nginx-conf":   &Config{Name: "nginx-conf", Content: "server {\n  listen 80;\n}\n"},
+		"app-settings": &Config{Name: "app-settings", File: "config/settings.yml"},
+	}
+	if !reflect.DeepEqual(s.Configs, expected) {
+		t.Fatalf("Expected: %+v \n Received: %+v", expected, s.Configs)
+	}
+
+	if err := s.Validate(nil); err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+}
+
+func TestValidateConfigs(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *Config
+		volumes  map[string]*Volume
+		path     string
+		expected AppErrorCode
+	}{
+		{
+			name:   "content",
+			config: &Config{Name: "conf", Content: "debug: true"},
+			path:   "/etc/app.yml",
+		},
+		{
+			name:   "file",
+			config: &Config{Name: "conf", File: "app.yml"},
+			path:   "/etc/app.yml",
+		},
+		{
+			name:     "content-and-file",
+			config:   &Config{Name: "conf", Content: "debug: true", File: "app.yml"},
+			path:     "/etc/app.yml",
+			expected: InvalidConfig,
+		},
+		{
+			name:     "empty",
+			config:   &Config{Name: "conf"},
+			path:     "/etc/app.yml",
+			expected: InvalidConfig,
+		},
+		{
+			name:     "invalid-name",
+			config:   &Config{Name: "app.yml", Content: "debug: true"},
+			path:     "/etc/app.yml",
+			expected: InvalidConfig,
+		},
+		{
+			name:     "relative-path",
+			config:   &Config{Name: "conf", Content: "debug: true"},
+			path:     "etc/app.yml",
+			expected: InvalidConfig,
+		},
+		{
+			name:     "directory-path",
+			config:   &Config{Name: "conf", Content: "debug: true"},
+			path:     "/etc/",
+			expected: InvalidConfig,
+		},
+		{
+			name:     "volume-name",
+			config:   &Config{Name: "conf", Content: "debug: true"},
+			volumes:  map[string]*Volume{"conf": &Volume{Name: "conf"}},
+			path:     "/etc/app.yml",
+			expected: InvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				Name:     "test",
+				Replicas: 1,
+				Volumes:  tt.volumes,
+				Configs:  map[string]*Config{tt.config.Name: tt.config},
+				Containers: map[string]*Container{
+					"nginx": &Container{
+						Image:  "nginx",
+						Mounts: map[string]*Mount{tt.config.Name: &Mount{Path: tt.path}},
+					},
+				},
+			}
+
+			err := s.Validate(nil)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("didn't get the expected error %s", tt.expected)
+			}
+
+			if err.Code != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, err.Code)
+			}
+		})
+	}
+}
+
+func TestGetConfigsChecksum(t *testing.T) {
+	s := &Service{Configs: map[string]*Config{"a": &Config{Name: "a", Content: "1"}, "b": &Config{Name: "b", Content: "2"}}}
+	checksum := s.GetConfigsChecksum()
+	if checksum != s.GetConfigsChecksum() {
+		t.Fatal("checksum is not stable")
+	}
+
+	s.Configs["b"].Content = "3"
+	if checksum == s.GetConfigsChecksum() {
+		t.Fatal("checksum didn't change with the content")
+	}
+}
+
+func TestReadInitContainers(t *testing.T) {
+	readBytes, err := ioutil.ReadFile("./examples/service_with_init_containers.yml")
+	require.NoError(t, err)
+	var s Service
+	err = yaml.Unmarshal(readBytes, &s)
+	require.NoError(t, err)
+
+	if len(s.InitContainers) != 2 || s.InitContainers[0].Name != "migrate" || s.InitContainers[1].Name != "seed" {
+		t.Fatalf("didn't parse init_containers in order: %+v", s.InitContainers)
+	}
+
+	c := s.InitContainers[0]
+	if c.Image != "okteto/app" || c.Command != "./migrate" || len(c.Environment) != 1 {
+		t.Fatalf("wrong init container: %+v", c)
+	}
+
+	if err := s.Validate(nil); err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+}
+
+func TestValidateInitContainers(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     []*InitContainer
+		expected AppErrorCode
+	}{
+		{
+			name: "valid",
+			init: []*InitContainer{
+				&InitContainer{Name: "migrate", Container: Container{Image: "app", Mounts: map[string]*Mount{"data": &Mount{Path: "/data"}}}},
+				&InitContainer{Name: "seed", Container: Container{Image: "app"}},
+			},
+		},
+		{
+			name:     "missing-name",
+			init:     []*InitContainer{&InitContainer{Container: Container{Image: "app"}}},
+			expected: InvalidInitContainer,
+		},
+		{
+			name:     "missing-image",
+			init:     []*InitContainer{&InitContainer{Name: "migrate"}},
+			expected: MissingContainerImage,
+		},
+		{
+			name:     "duplicated-name",
+			init:     []*InitContainer{&InitContainer{Name: "nginx", Container: Container{Image: "app"}}},
+			expected: InvalidInitContainer,
+		},
+		{
+			name: "declared-twice",
+			init: []*InitContainer{
+				&InitContainer{Name: "migrate", Container: Container{Image: "app"}},
+				&InitContainer{Name: "migrate", Container: Container{Image: "app"}},
+			},
+			expected: InvalidInitContainer,
+		},
+		{
+			name:     "ports",
+			init:     []*InitContainer{&InitContainer{Name: "migrate", Container: Container{Image: "app", Ports: []string{"8080"}}}},
+			expected: InvalidInitContainer,
+		},
+		{
+			name:     "dev",
+			init:     []*InitContainer{&InitContainer{Name: "migrate", Container: Container{Image: "app", Development: &Development{}}}},
+			expected: InvalidInitContainer,
+		},
+		{
+			name:     "undefined-volume",
+			init:     []*InitContainer{&InitContainer{Name: "migrate", Container: Container{Image: "app", Mounts: map[string]*Mount{"cache": &Mount{Path: "/cache"}}}}},
+			expected: VolumeNotDefined,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				Name:           "test",
+				Replicas:       1,
+				Volumes:        map[string]*Volume{"data": &Volume{Name: "data"}},
+				InitContainers: tt.init,
+				Containers: map[string]*Container{
+					"nginx": &Container{Image: "nginx"},
+				},
+			}
+
+			err := s.Validate(nil)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("didn't get the expected error %s", tt.expected)
+			}
+
+			if err.Code != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, err.Code)
+			}
+		})
+	}
+}
+
 func TestCanDeploy(t *testing.T) {
 	var tables = []struct {
 		service   Service
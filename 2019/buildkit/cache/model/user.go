@@ -15,6 +15,37 @@ type User struct {
 	Verified       bool
 }
 
+//EmailNotification is a type of email sent to the users
+type EmailNotification string
+
+const (
+	//InviteEmail is sent when a user is invited to a project
+	InviteEmail = EmailNotification("invite")
+
+	//FailedActivityEmail is sent when an activity started by the user fails
+	FailedActivityEmail = EmailNotification("failed_activity")
+
+	//ExpiringServicesEmail is sent before the demo services of the user are destroyed
+	ExpiringServicesEmail = EmailNotification("expiring_services")
+)
+
+//EmailPreferences are the emails that a user wants to receive. They are created with every email enabled the first
+//time an email is sent to the user
+type EmailPreferences struct {
+	Model
+	UserID         string `json:"-" gorm:"unique_index"`
+	UnsubscribeKey string `json:"-" gorm:"unique_index"`
+
+	// FailedActivities enables the emails about the failed activities started by the user
+	FailedActivities bool `json:"failed_activities"`
+
+	// ExpiringServices enables the emails about the demo services that are going to be destroyed
+	ExpiringServices bool `json:"expiring_services"`
+
+	// Unsubscribed disables every email, including the invites
+	Unsubscribed bool `json:"unsubscribed"`
+}
+
 //InviteUserRequest is the request used to invite a new user to the system
 type InviteUserRequest struct {
 	Email   string      `json:"email"`
@@ -33,6 +64,32 @@ func NewUser(email string) *User {
 	return &u
 }
 
+//NewEmailPreferences returns the default preferences of a user, with a random unsubscribe key
+func NewEmailPreferences(userID string) *EmailPreferences {
+	return &EmailPreferences{
+		UserID:           userID,
+		UnsubscribeKey:   GenerateRandomString(40),
+		FailedActivities: true,
+		ExpiringServices: true,
+	}
+}
+
+//IsEnabled returns true if the user wants to receive the emails of type n
+func (p *EmailPreferences) IsEnabled(n EmailNotification) bool {
+	if p.Unsubscribed {
+		return false
+	}
+
+	switch n {
+	case FailedActivityEmail:
+		return p.FailedActivities
+	case ExpiringServicesEmail:
+		return p.ExpiringServices
+	default:
+		return true
+	}
+}
+
 // Validate validates that r is well formed
 func (r *InviteUserRequest) Validate() *AppError {
 	if r.Email == "" {
@@ -1,9 +1,6 @@
 package model
 
-import (
-	"math/rand"
-	"time"
-)
+import "crypto/rand"
 
 var (
 	values = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
@@ -26,19 +23,24 @@ func generateRandomBytes(n int) ([]byte, error) {
 	return b, nil
 }
 
-// GenerateRandomString returns a random string composed of ascii characters
+// GenerateRandomString returns a random string composed of ascii characters. It's securely generated, the tokens and
+// the unsubscribe keys are random strings. It panics if the system's secure random number generator fails
 func GenerateRandomString(length int) string {
-	rand.Seed(time.Now().UnixNano())
-	buf := make([]rune, length)
-
-	buf[0] = values[rand.Intn(len(values))]
-	for i := 1; i < length; i++ {
-		buf[i] = values[rand.Intn(len(values))]
+	// the bytes over the last multiple of len(values) are discarded, every character has the same probability
+	limit := 256 - 256%len(values)
+	buf := make([]rune, 0, length)
+	for len(buf) < length {
+		b, err := generateRandomBytes(length)
+		if err != nil {
+			panic("failed to generate a random string: " + err.Error())
+		}
+
+		for i := 0; i < len(b) && len(buf) < length; i++ {
+			if int(b[i]) < limit {
+				buf = append(buf, values[int(b[i])%len(values)])
+			}
+		}
 	}
 
-	rand.Shuffle(len(buf), func(i, j int) {
-		buf[i], buf[j] = buf[j], buf[i]
-	})
-
 	return string(buf)
 }
@@ -1,11 +1,18 @@
 package model
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestGenerateRandomString(t *testing.T) {
 	results := make(map[string]bool)
 	for i := 0; i < 10000; i++ {
 		token := GenerateRandomString(40)
+		if len(token) != 40 || strings.Trim(token, string(values)) != "" {
+			t.Fatalf("wrong token was generated: %s", token)
+		}
+
 		if _, ok := results[token]; ok {
 			t.Fatalf("repeated token was generated: %s", token)
 		}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var isHostname = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`).MatchString
+
+//ManifestValidation is a manifest to validate without creating or updating a service.
+//Manifest and Overlay are base64 encoded or raw yaml documents
+type ManifestValidation struct {
+	Manifest string `json:"manifest"`
+	Overlay  string `json:"overlay,omitempty"`
+	Project  string `json:"project,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+}
+
+//ManifestValidationResult contains the problems found in a manifest.
+//Errors would make the creation or the update of the service fail, warnings wouldn't
+type ManifestValidationResult struct {
+	Valid    bool        `json:"valid"`
+	Errors   []*AppError `json:"errors"`
+	Warnings []*AppError `json:"warnings"`
+}
+
+//NewManifestValidationResult returns an empty result
+func NewManifestValidationResult() *ManifestValidationResult {
+	return &ManifestValidationResult{Valid: true, Errors: []*AppError{}, Warnings: []*AppError{}}
+}
+
+//AddError adds err, or every error it aggregates, to the errors of r
+func (r *ManifestValidationResult) AddError(err *AppError) {
+	if err == nil {
+		return
+	}
+
+	if len(err.Errors) > 0 {
+		r.Errors = append(r.Errors, err.Errors...)
+	} else {
+		r.Errors = append(r.Errors, err)
+	}
+
+	r.Valid = false
+}
+
+//AddErrors adds errs to the errors of r
+func (r *ManifestValidationResult) AddErrors(errs []*AppError) {
+	for _, err := range errs {
+		r.AddError(err)
+	}
+}
+
+//ValidateSecretReferences returns a warning for every environment variable that references a secret that is not in secrets.
+//Those variables are deployed with an empty value
+func (s *Service) ValidateSecretReferences(secrets []*EnvVar) []*AppError {
+	warnings := []*AppError{}
+	check := func(parent, nC string, c *Container) {
+		if c == nil {
+			return
+		}
+
+		for _, e := range c.Environment {
+			if !strings.HasPrefix(e.Value, "$") {
+				continue
+			}
+
+			secret := e.Value[1:]
+			if !containsSecret(secrets, secret) {
+				warnings = append(warnings, &AppError{
+					Status:  http.StatusBadRequest,
+					Code:    UndefinedSecret,
+					Field:   fmt.Sprintf("%s.environment.%s", parent, e.Name),
+					Data:    map[string]string{"container": nC, "secret": secret},
+					Message: fmt.Sprintf("Secret '%s' used by container '%s' is not defined in the project", secret, nC)})
+			}
+		}
+	}
+
+	for _, nC := range getContainerNames(s.Containers) {
+		check(fmt.Sprintf("containers.%s", nC), nC, s.Containers[nC])
+	}
+	for i, c := range s.InitContainers {
+		if c != nil {
+			check(fmt.Sprintf("init_containers[%d]", i), c.Name, &c.Container)
+		}
+	}
+	return warnings
+}
+
+//ValidateIngressHostnames returns an error for every ingress hostname of s that is not a valid DNS name.
+//If e is nil only the hosts declared in the manifest are checked, since the domain is unknown
+func (s *Service) ValidateIngressHostnames(e *Environment) []*AppError {
+	errs := []*AppError{}
+	hostnames := []string{}
+	if e != nil && e.Provider != nil && e.Provider.IsIngress() {
+		hostnames = s.GetIngressHostnames(e)
+	} else {
+		for _, i := range s.GetIngressRules(true) {
+			if i.Host != ProjectName {
+				hostnames = append(hostnames, i.Host)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, h := range hostnames {
+		if seen[h] || isValidHostname(h) {
+			continue
+		}
+
+		seen[h] = true
+		errs = append(errs, &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidIngressHostname,
+			Data:    map[string]string{"hostname": h},
+			Message: fmt.Sprintf("'%s' is not a valid hostname", h)})
+	}
+	return errs
+}
+
+func isValidHostname(h string) bool {
+	if len(h) > 253 || !isHostname(h) {
+		return false
+	}
+
+	for _, label := range strings.Split(h, ".") {
+		if len(label) > 63 {
+			return false
+		}
+	}
+	return true
+}
+
+func containsSecret(secrets []*EnvVar, name string) bool {
+	for _, s := range secrets {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
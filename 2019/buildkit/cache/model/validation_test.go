@@ -0,0 +1,102 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestValidateSecretReferences(t *testing.T) {
+	s := &Service{
+		Containers: map[string]*Container{
+			"api": &Container{
+				Environment: []*EnvVar{
+					&EnvVar{Name: "DATABASE_PASSWORD", Value: "$DATABASE_PASSWORD"},
+					&EnvVar{Name: "API_TOKEN", Value: "$TOKEN"},
+					&EnvVar{Name: "LOG_LEVEL", Value: "debug"},
+				},
+			},
+		},
+		InitContainers: []*InitContainer{
+			&InitContainer{
+				Name:      "migrate",
+				Container: Container{Environment: []*EnvVar{&EnvVar{Name: "DATABASE_URL", Value: "$DATABASE_URL"}}},
+			},
+		},
+	}
+
+	warnings := s.ValidateSecretReferences([]*EnvVar{&EnvVar{Name: "DATABASE_PASSWORD", Value: "secret"}})
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %+v", warnings)
+	}
+
+	expected := []string{"containers.api.environment.API_TOKEN", "init_containers[0].environment.DATABASE_URL"}
+	for i, w := range warnings {
+		if w.Code != UndefinedSecret {
+			t.Errorf("expected %s, got %s", UndefinedSecret, w.Code)
+		}
+		if w.Field != expected[i] {
+			t.Errorf("expected field '%s', got '%s'", expected[i], w.Field)
+		}
+	}
+}
+
+func TestValidateIngressHostnames(t *testing.T) {
+	e := &Environment{
+		Name:        "env-1",
+		ProjectName: "env",
+		Provider:    &Provider{Ingress: &IngressController{Domain: "example.com"}},
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		e      *Environment
+		errors int
+	}{
+		{name: "valid", host: "api", e: e, errors: 0},
+		{name: "valid-without-environment", host: "api", errors: 0},
+		{name: "project-name", host: ProjectName, e: e, errors: 0},
+		{name: "project-name-without-environment", host: ProjectName, errors: 0},
+		{name: "uppercase", host: "API", e: e, errors: 1},
+		{name: "underscore-without-environment", host: "my_api", errors: 1},
+		{name: "long-label", host: "a123456789012345678901234567890123456789012345678901234567890123", e: e, errors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{
+				Name: "api",
+				Containers: map[string]*Container{
+					"api": &Container{Ingress: []*Ingress{&Ingress{Host: tt.host, Path: "/", Port: "8080"}}},
+				},
+			}
+
+			errs := s.ValidateIngressHostnames(tt.e)
+			if len(errs) != tt.errors {
+				t.Fatalf("expected %d errors, got %+v", tt.errors, errs)
+			}
+
+			for _, err := range errs {
+				if err.Code != InvalidIngressHostname {
+					t.Errorf("expected %s, got %s", InvalidIngressHostname, err.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestValidationResultAddError(t *testing.T) {
+	r := NewManifestValidationResult()
+	r.AddError(nil)
+	if !r.Valid {
+		t.Fatal("a nil error made the result invalid")
+	}
+
+	r.AddError(newAppErrorList([]*AppError{&AppError{Code: InvalidName}, &AppError{Code: InvalidReplicaCount}}))
+	if r.Valid {
+		t.Error("the result is valid after adding errors")
+	}
+
+	if len(r.Errors) != 2 {
+		t.Errorf("the aggregated errors weren't added: %+v", r.Errors)
+	}
+}
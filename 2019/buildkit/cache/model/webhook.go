@@ -0,0 +1,251 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//WebhookEventType is an activity of a project that can be sent to its webhooks
+type WebhookEventType string
+
+//WebhookType is the format of the payloads sent to a webhook
+type WebhookType string
+
+//WebhookDeliveryStatus is the status of the delivery of an event to a webhook
+type WebhookDeliveryStatus string
+
+const (
+	//JSONWebhook receives the events as okteto json payloads, it's the default type
+	JSONWebhook = WebhookType("json")
+
+	//SlackWebhook is a slack incoming webhook, it receives the events as slack messages
+	SlackWebhook = WebhookType("slack")
+
+	//TeamsWebhook is a microsoft teams incoming webhook, it receives the events as message cards
+	TeamsWebhook = WebhookType("teams")
+
+	//ServiceCreatedEvent is sent when a service is created
+	ServiceCreatedEvent = WebhookEventType("service.created")
+
+	//DeployStartedEvent is sent when the deployment of a service starts
+	DeployStartedEvent = WebhookEventType("deploy.started")
+
+	//DeploySucceededEvent is sent when a service is deployed
+	DeploySucceededEvent = WebhookEventType("deploy.succeeded")
+
+	//DeployFailedEvent is sent when the deployment of a service fails
+	DeployFailedEvent = WebhookEventType("deploy.failed")
+
+	//ServiceDestroyedEvent is sent when a service is destroyed or its destroy fails
+	ServiceDestroyedEvent = WebhookEventType("service.destroyed")
+
+	//DevModeEnabledEvent is sent when dev mode is enabled in a service
+	DevModeEnabledEvent = WebhookEventType("dev.enabled")
+
+	//MemberAddedEvent is sent when a user is added to the project
+	MemberAddedEvent = WebhookEventType("member.added")
+
+	//PingEvent is sent on demand to test a webhook, every webhook receives it
+	PingEvent = WebhookEventType("ping")
+
+	//PendingWebhookDelivery is the status of a delivery waiting to be sent
+	PendingWebhookDelivery = WebhookDeliveryStatus("pending")
+
+	//SendingWebhookDelivery is the status of a delivery while it's sent
+	SendingWebhookDelivery = WebhookDeliveryStatus("sending")
+
+	//SucceededWebhookDelivery is the status of a delivery accepted by the webhook
+	SucceededWebhookDelivery = WebhookDeliveryStatus("succeeded")
+
+	//FailedWebhookDelivery is the status of a delivery that failed every attempt
+	FailedWebhookDelivery = WebhookDeliveryStatus("failed")
+)
+
+//privateNetworks are the unspecified, loopback, private, shared and link-local networks. The webhooks can't reach them
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+//WebhookEvents are the events a webhook can subscribe to
+var WebhookEvents = []WebhookEventType{
+	ServiceCreatedEvent,
+	DeployStartedEvent,
+	DeploySucceededEvent,
+	DeployFailedEvent,
+	ServiceDestroyedEvent,
+	DevModeEnabledEvent,
+	MemberAddedEvent,
+}
+
+// ProjectWebhook is an url that receives the activity of a project. The payloads are signed with the secret. Slack
+// and teams channels are webhooks whose payloads are chat messages, they don't have a secret
+type ProjectWebhook struct {
+	Model
+	ProjectID string      `json:"project" gorm:"index"`
+	URL       string      `json:"url"`
+	Secret    string      `json:"secret,omitempty"`
+	Type      WebhookType `json:"type,omitempty"`
+
+	//Events are the comma separated events the webhook is subscribed to
+	Events string `json:"events"`
+}
+
+//GetEvents returns the events the webhook is subscribed to
+func (w *ProjectWebhook) GetEvents() []WebhookEventType {
+	events := []WebhookEventType{}
+	for _, e := range strings.Split(w.Events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			events = append(events, WebhookEventType(e))
+		}
+	}
+
+	return events
+}
+
+//GetType returns the type of the webhook, json by default
+func (w *ProjectWebhook) GetType() WebhookType {
+	if w.Type == "" {
+		return JSONWebhook
+	}
+
+	return w.Type
+}
+
+//IsChat returns true if the webhook is a slack or teams channel. Their payloads are not signed
+func (w *ProjectWebhook) IsChat() bool {
+	t := w.GetType()
+	return t == SlackWebhook || t == TeamsWebhook
+}
+
+//IsSubscribed returns true if the webhook receives the event
+func (w *ProjectWebhook) IsSubscribed(event WebhookEventType) bool {
+	if event == PingEvent {
+		return true
+	}
+
+	for _, e := range w.GetEvents() {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Validate returns an error if the url or the events of the webhook are not valid
+func (w *ProjectWebhook) Validate() *AppError {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidURL,
+			Message: fmt.Sprintf("'%s' is not a valid http or https url", w.URL)}
+	}
+
+	// the names are checked when the webhook connects, once they are resolved
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); (ip != nil && !IsPublicIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidURL,
+			Message: fmt.Sprintf("'%s' is not a public url", w.URL)}
+	}
+
+	switch w.GetType() {
+	case JSONWebhook, SlackWebhook, TeamsWebhook:
+	default:
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidWebhookType,
+			Data:    map[string]string{"type": string(w.Type)},
+			Message: fmt.Sprintf("'%s' is not a webhook type, it must be json, slack or teams", w.Type)}
+	}
+
+	events := w.GetEvents()
+	if len(events) == 0 {
+		return &AppError{
+			Status:  http.StatusBadRequest,
+			Code:    InvalidWebhookEvent,
+			Message: "The webhook must be subscribed to at least one event"}
+	}
+
+	for _, e := range events {
+		if !isWebhookEvent(e) {
+			return &AppError{
+				Status:  http.StatusBadRequest,
+				Code:    InvalidWebhookEvent,
+				Data:    map[string]string{"event": string(e)},
+				Message: fmt.Sprintf("'%s' is not a webhook event", e)}
+		}
+	}
+
+	return nil
+}
+
+//IsPublicIP returns false if ip is in one of the private networks, or if it's a multicast address
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsMulticast() {
+		return false
+	}
+
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+
+		networks[i] = n
+	}
+
+	return networks
+}
+
+func isWebhookEvent(event WebhookEventType) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDelivery is an event sent to a webhook. It's kept as the delivery log of the webhook
+type WebhookDelivery struct {
+	Model
+	WebhookID    string                `json:"webhook" gorm:"index"`
+	Event        WebhookEventType      `json:"event"`
+	Payload      string                `json:"payload"`
+	Status       WebhookDeliveryStatus `json:"status" gorm:"index"`
+	Attempts     int                   `json:"attempts"`
+	ResponseCode int                   `json:"response_code,omitempty"`
+	LastError    string                `json:"last_error,omitempty"`
+
+	//NextAttemptAt is when the delivery will be sent again
+	NextAttemptAt time.Time `json:"next_attempt,omitempty"`
+}
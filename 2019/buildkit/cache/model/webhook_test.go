@@ -0,0 +1,79 @@
+package model
+
+import (
+	"net"
+	"testing"
+)
+
+func TestProjectWebhookValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook ProjectWebhook
+		code    AppErrorCode
+	}{
+		{name: "valid", webhook: ProjectWebhook{URL: "https://example.com/hooks", Events: "deploy.failed, member.added"}},
+		{name: "invalid-url", webhook: ProjectWebhook{URL: "example.com", Events: "deploy.failed"}, code: InvalidURL},
+		{name: "invalid-scheme", webhook: ProjectWebhook{URL: "ftp://example.com", Events: "deploy.failed"}, code: InvalidURL},
+		{name: "no-events", webhook: ProjectWebhook{URL: "https://example.com", Events: " , "}, code: InvalidWebhookEvent},
+		{name: "unknown-event", webhook: ProjectWebhook{URL: "https://example.com", Events: "deploy.failed,deploy.paused"}, code: InvalidWebhookEvent},
+		{name: "slack", webhook: ProjectWebhook{URL: "https://hooks.slack.com/services/T0/B0/x", Type: SlackWebhook, Events: "deploy.failed"}},
+		{name: "invalid-type", webhook: ProjectWebhook{URL: "https://example.com", Type: "discord", Events: "deploy.failed"}, code: InvalidWebhookType},
+		{name: "ping", webhook: ProjectWebhook{URL: "https://example.com", Events: "ping"}, code: InvalidWebhookEvent},
+		{name: "public-ip", webhook: ProjectWebhook{URL: "http://8.8.8.8:8080/hooks", Events: "deploy.failed"}},
+		{name: "loopback", webhook: ProjectWebhook{URL: "http://127.0.0.1:8080/hooks", Events: "deploy.failed"}, code: InvalidURL},
+		{name: "localhost", webhook: ProjectWebhook{URL: "http://LOCALHOST/hooks", Events: "deploy.failed"}, code: InvalidURL},
+		{name: "metadata", webhook: ProjectWebhook{URL: "http://169.254.169.254/latest/meta-data", Events: "deploy.failed"}, code: InvalidURL},
+		{name: "private-ipv6", webhook: ProjectWebhook{URL: "http://[fd00::1]/hooks", Events: "deploy.failed"}, code: InvalidURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := tt.webhook.Validate()
+			if tt.code == "" {
+				if appErr != nil {
+					t.Errorf("unexpected error: %s", appErr.Message)
+				}
+				return
+			}
+
+			if appErr == nil || appErr.Code != tt.code {
+				t.Errorf("expected %s, got %+v", tt.code, appErr)
+			}
+		})
+	}
+}
+
+func TestProjectWebhookIsSubscribed(t *testing.T) {
+	w := &ProjectWebhook{Events: "deploy.failed,member.added"}
+	if !w.IsSubscribed(DeployFailedEvent) || !w.IsSubscribed(PingEvent) {
+		t.Errorf("the webhook isn't subscribed to its events")
+	}
+
+	if w.IsSubscribed(DeploySucceededEvent) {
+		t.Errorf("the webhook is subscribed to %s", DeploySucceededEvent)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":          true,
+		"2001:4860::8888":  true,
+		"10.1.2.3":         false,
+		"172.20.0.1":       false,
+		"192.168.1.1":      false,
+		"100.64.0.1":       false,
+		"127.0.0.1":        false,
+		"0.0.0.0":          false,
+		"169.254.169.254":  false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"fe80::1":          false,
+		"224.0.0.1":        false,
+	}
+
+	for address, expected := range tests {
+		if got := IsPublicIP(net.ParseIP(address)); got != expected {
+			t.Errorf("%s: expected %t got %t", address, expected, got)
+		}
+	}
+}
@@ -30,3 +30,8 @@ func Destroy(s *model.Service, e *model.Environment, log *logger.Logger) error {
 	log.Printf("Service '%s' successfully destroyed.", s.Name)
 	return nil
 }
+
+//DestroyNamespace destroys the namespace of a given environment with the services left in it
+func DestroyNamespace(e *model.Environment, log *logger.Logger) error {
+	return k8.DestroyNamespace(e, log)
+}
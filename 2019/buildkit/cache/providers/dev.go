@@ -13,7 +13,14 @@ func DevDeploy(s *model.Service, e *model.Environment, log *logger.Logger) error
 	devContainer := findDevContainer(s)
 	swapDevContainerConfiguration(devContainer)
 	injectSyncthingContainer(s, devContainer.Development.Persistent)
+
+	// the dev image and the syncthing container aren't part of the manifest checked when it was saved
+	if err := s.ValidateSecurity(e.Security); err != nil {
+		return err
+	}
+
 	s.Replicas = 1
+	s.Autoscale = nil
 
 	if s.Labels == nil {
 		s.Labels = make(map[string]string)
@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"io/ioutil"
+	logger "log"
 	"testing"
 
 	"bitbucket.org/okteto/okteto/backend/model"
@@ -55,3 +57,27 @@ func TestFindDevContainer(t *testing.T) {
 	}
 
 }
+
+func TestDevDeployChecksTheInjectedContainers(t *testing.T) {
+	s := &model.Service{
+		Name: "service",
+		Containers: map[string]*model.Container{
+			"app": &model.Container{
+				Image:           "okteto/app@sha256:0f8a9a4b2c5e6d7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7",
+				SecurityContext: &model.SecurityContext{RunAsNonRoot: true},
+				Development:     &model.Development{Image: "okteto/app@sha256:0f8a9a4b2c5e6d7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7"},
+			},
+		},
+	}
+
+	e := &model.Environment{Security: &model.SecuritySettings{RunAsNonRoot: true}}
+	err := DevDeploy(s, e, logger.New(ioutil.Discard, "", 0))
+	appErr, ok := err.(*model.AppError)
+	if !ok {
+		t.Fatalf("expected a security policy violation, got %v", err)
+	}
+
+	if appErr.Code != model.SecurityPolicyViolation || appErr.Data["container"] != model.OktetoSyncContainer {
+		t.Errorf("expected a violation of the syncthing container, got %+v", appErr)
+	}
+}
@@ -0,0 +1,55 @@
+package autoscaler
+
+import (
+	"fmt"
+	"strings"
+
+	logger "log"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+//Deploy deploys a k8 horizontal pod autoscaler for a service
+func Deploy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) error {
+	autoscalerName := s.Name
+	hClient := c.AutoscalingV2beta1().HorizontalPodAutoscalers(e.Name)
+	k8Autoscaler, err := hClient.Get(autoscalerName, metav1.GetOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("Error getting kubernetes autoscaler: %s", err)
+	}
+	if k8Autoscaler.Name == "" {
+		log.Printf("Creating autoscaler '%s'...", autoscalerName)
+		_, err = hClient.Create(translate(s))
+		if err != nil {
+			return fmt.Errorf("Error creating kubernetes autoscaler: %s", err)
+		}
+		log.Printf("Created autoscaler '%s'.", autoscalerName)
+	} else {
+		log.Printf("Updating autoscaler '%s'...", autoscalerName)
+		h := translate(s)
+		h.ResourceVersion = k8Autoscaler.ResourceVersion
+		_, err = hClient.Update(h)
+		if err != nil {
+			return fmt.Errorf("Error updating kubernetes autoscaler: %s", err)
+		}
+		log.Printf("Updated autoscaler '%s'.", autoscalerName)
+	}
+	return nil
+}
+
+//Destroy destroys the k8 horizontal pod autoscaler created by a service
+func Destroy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) error {
+	autoscalerName := s.Name
+	hClient := c.AutoscalingV2beta1().HorizontalPodAutoscalers(e.Name)
+	err := hClient.Delete(autoscalerName, &metav1.DeleteOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return fmt.Errorf("Error deleting kubernetes autoscaler: %s", err)
+	}
+	log.Printf("Deleted autoscaler '%s'.", autoscalerName)
+	return nil
+}
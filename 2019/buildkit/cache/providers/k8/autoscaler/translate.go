@@ -0,0 +1,45 @@
+package autoscaler
+
+import (
+	"bitbucket.org/okteto/okteto/backend/model"
+	autoscalingv2 "k8s.io/api/autoscaling/v2beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func translate(s *model.Service) *autoscalingv2.HorizontalPodAutoscaler {
+	minReplicas := int32(s.Autoscale.Min)
+	metrics := []autoscalingv2.MetricSpec{}
+	if s.Autoscale.CPU > 0 {
+		metrics = append(metrics, getResourceMetric(apiv1.ResourceCPU, s.Autoscale.CPU))
+	}
+	if s.Autoscale.Memory > 0 {
+		metrics = append(metrics, getResourceMetric(apiv1.ResourceMemory, s.Autoscale.Memory))
+	}
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: s.Name,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       s.Name,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: int32(s.Autoscale.Max),
+			Metrics:     metrics,
+		},
+	}
+}
+
+func getResourceMetric(name apiv1.ResourceName, target int) autoscalingv2.MetricSpec {
+	utilization := int32(target)
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name:                     name,
+			TargetAverageUtilization: &utilization,
+		},
+	}
+}
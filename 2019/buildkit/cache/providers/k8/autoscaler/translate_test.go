@@ -0,0 +1,51 @@
+package autoscaler
+
+import (
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	autoscalingv2 "k8s.io/api/autoscaling/v2beta1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name      string
+		autoscale *model.Autoscale
+		expected  []apiv1.ResourceName
+	}{
+		{
+			name:      "cpu",
+			autoscale: &model.Autoscale{Min: 2, Max: 5, CPU: 80},
+			expected:  []apiv1.ResourceName{apiv1.ResourceCPU},
+		},
+		{
+			name:      "cpu-and-memory",
+			autoscale: &model.Autoscale{Min: 1, Max: 3, CPU: 80, Memory: 70},
+			expected:  []apiv1.ResourceName{apiv1.ResourceCPU, apiv1.ResourceMemory},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &model.Service{Name: "test", Autoscale: tt.autoscale}
+			h := translate(s)
+			if h.Name != "test" || h.Spec.ScaleTargetRef.Name != "test" || h.Spec.ScaleTargetRef.Kind != "Deployment" {
+				t.Errorf("wrong scale target: %+v", h.Spec.ScaleTargetRef)
+			}
+			if *h.Spec.MinReplicas != int32(tt.autoscale.Min) || h.Spec.MaxReplicas != int32(tt.autoscale.Max) {
+				t.Errorf("wrong replicas: %d-%d", *h.Spec.MinReplicas, h.Spec.MaxReplicas)
+			}
+			if len(h.Spec.Metrics) != len(tt.expected) {
+				t.Fatalf("expected %d metrics, got %d", len(tt.expected), len(h.Spec.Metrics))
+			}
+			for i, m := range h.Spec.Metrics {
+				if m.Type != autoscalingv2.ResourceMetricSourceType || m.Resource.Name != tt.expected[i] {
+					t.Errorf("wrong metric: %+v", m.Resource)
+				}
+			}
+			if *h.Spec.Metrics[0].Resource.TargetAverageUtilization != int32(tt.autoscale.CPU) {
+				t.Errorf("wrong cpu target: %d", *h.Spec.Metrics[0].Resource.TargetAverageUtilization)
+			}
+		})
+	}
+}
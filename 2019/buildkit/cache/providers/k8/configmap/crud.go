@@ -0,0 +1,55 @@
+package configmap
+
+import (
+	"fmt"
+	"strings"
+
+	logger "log"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+//Deploy deploys the k8 config map holding the configs of a service
+func Deploy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) error {
+	configMapName := s.GetConfigMapName()
+	cClient := c.CoreV1().ConfigMaps(e.Name)
+	k8ConfigMap, err := cClient.Get(configMapName, metav1.GetOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("Error getting kubernetes config map: %s", err)
+	}
+	if k8ConfigMap.Name == "" {
+		log.Printf("Creating config map '%s'...", configMapName)
+		_, err = cClient.Create(translate(s))
+		if err != nil {
+			return fmt.Errorf("Error creating kubernetes config map: %s", err)
+		}
+		log.Printf("Created config map '%s'.", configMapName)
+	} else {
+		log.Printf("Updating config map '%s'...", configMapName)
+		cm := translate(s)
+		cm.ResourceVersion = k8ConfigMap.ResourceVersion
+		_, err = cClient.Update(cm)
+		if err != nil {
+			return fmt.Errorf("Error updating kubernetes config map: %s", err)
+		}
+		log.Printf("Updated config map '%s'.", configMapName)
+	}
+	return nil
+}
+
+//Destroy destroys the k8 config map created by a service
+func Destroy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) error {
+	configMapName := s.GetConfigMapName()
+	cClient := c.CoreV1().ConfigMaps(e.Name)
+	err := cClient.Delete(configMapName, &metav1.DeleteOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return fmt.Errorf("Error deleting kubernetes config map: %s", err)
+	}
+	log.Printf("Deleted config map '%s'.", configMapName)
+	return nil
+}
@@ -0,0 +1,23 @@
+package configmap
+
+import (
+	"bitbucket.org/okteto/okteto/backend/model"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func translate(s *model.Service) *apiv1.ConfigMap {
+	data := map[string]string{}
+	for name, c := range s.Configs {
+		data[name] = c.Content
+	}
+	return &apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: s.GetConfigMapName(),
+			Labels: map[string]string{
+				"app": s.Name,
+			},
+		},
+		Data: data,
+	}
+}
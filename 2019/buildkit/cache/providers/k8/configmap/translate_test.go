@@ -0,0 +1,27 @@
+package configmap
+
+import (
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+)
+
+func TestTranslate(t *testing.T) {
+	s := &model.Service{
+		Name: "test",
+		Configs: map[string]*model.Config{
+			"nginx": &model.Config{Name: "nginx", Content: "server {}"},
+			"app":   &model.Config{Name: "app", Content: "debug: true"},
+		},
+	}
+	cm := translate(s)
+	if cm.Name != "test-configs" {
+		t.Errorf("wrong name: %s", cm.Name)
+	}
+	if cm.Labels["app"] != "test" {
+		t.Errorf("wrong labels: %+v", cm.Labels)
+	}
+	if len(cm.Data) != 2 || cm.Data["nginx"] != "server {}" || cm.Data["app"] != "debug: true" {
+		t.Errorf("wrong data: %+v", cm.Data)
+	}
+}
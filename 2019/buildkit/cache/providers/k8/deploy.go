@@ -4,7 +4,9 @@ import (
 	logger "log"
 
 	"bitbucket.org/okteto/okteto/backend/model"
+	k8Autoscaler "bitbucket.org/okteto/okteto/backend/providers/k8/autoscaler"
 	"bitbucket.org/okteto/okteto/backend/providers/k8/client"
+	k8ConfigMap "bitbucket.org/okteto/okteto/backend/providers/k8/configmap"
 	k8Deployment "bitbucket.org/okteto/okteto/backend/providers/k8/deployment"
 	k8Ingress "bitbucket.org/okteto/okteto/backend/providers/k8/ingress"
 	"bitbucket.org/okteto/okteto/backend/providers/k8/namespace"
@@ -39,9 +41,29 @@ func Deploy(s *model.Service, e *model.Environment, log *logger.Logger) error {
 			}
 		}
 	}
+	if len(s.Configs) > 0 {
+		if err := k8ConfigMap.Deploy(s, e, c, log); err != nil {
+			return err
+		}
+	}
+	if !s.IsAutoscaled() {
+		if err := k8Autoscaler.Destroy(s, e, c, log); err != nil {
+			return err
+		}
+	}
 	if err := k8Deployment.Deploy(s, e, c, log); err != nil {
 		return err
 	}
+	if s.IsAutoscaled() {
+		if err := k8Autoscaler.Deploy(s, e, c, log); err != nil {
+			return err
+		}
+	}
+	if len(s.Configs) == 0 {
+		if err := k8ConfigMap.Destroy(s, e, c, log); err != nil {
+			return err
+		}
+	}
 	if len(s.GetPrivatePorts()) == 0 {
 		return nil
 	}
@@ -8,6 +8,8 @@ import (
 	logger "log"
 
 	"bitbucket.org/okteto/okteto/backend/model"
+	"bitbucket.org/okteto/okteto/backend/providers/k8/client"
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -32,7 +34,12 @@ func Deploy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log
 		log.Printf("Created deployment %s.", deploymentName)
 	} else {
 		log.Printf("Updating deployment '%s'...", deploymentName)
+		current := d.Spec.Replicas
 		d = translate(s, e)
+		if s.IsAutoscaled() && current != nil && *current >= int32(s.Autoscale.Min) && *current <= int32(s.Autoscale.Max) {
+			// keep the replica count set by the autoscaler
+			d.Spec.Replicas = current
+		}
 		_, err = dClient.Update(d)
 		if err != nil {
 			return fmt.Errorf("Error updating kubernetes deployment: %s", err)
@@ -48,7 +55,7 @@ func Deploy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log
 		if err != nil {
 			return fmt.Errorf("Error getting kubernetes deployment: %s", err)
 		}
-		if d.Status.ReadyReplicas == int32(s.Replicas) && d.Status.Replicas == int32(s.Replicas) && d.Status.UpdatedReplicas == int32(s.Replicas) {
+		if isReady(d) {
 			if d.Status.UnavailableReplicas == 0 {
 				log.Printf("kubernetes deployment '%s' is ready.", deploymentName)
 				return nil
@@ -58,6 +65,46 @@ func Deploy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log
 	return fmt.Errorf("kubernetes deployment not ready after 5 minutes")
 }
 
+// isReady compares the status of d against its current spec, since the replica count
+// can be changed by an autoscaler while the rollout is in progress
+func isReady(d *appsv1.Deployment) bool {
+	if d.Status.ObservedGeneration < d.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.ReadyReplicas == replicas && d.Status.Replicas == replicas && d.Status.UpdatedReplicas == replicas
+}
+
+//GetReplicaStatus returns the current replica count of the k8 deployment created by a service
+func GetReplicaStatus(s *model.Service, e *model.Environment) (*model.ReplicaStatus, error) {
+	if e.Provider.IsTestProvider() {
+		return nil, nil
+	}
+	c, err := client.Get(e.Provider)
+	if err != nil {
+		return nil, err
+	}
+	d, err := c.AppsV1().Deployments(e.Name).Get(s.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting kubernetes deployment: %s", err)
+	}
+	status := &model.ReplicaStatus{
+		Current: int(d.Status.Replicas),
+		Ready:   int(d.Status.ReadyReplicas),
+	}
+	if d.Spec.Replicas != nil {
+		status.Desired = int(*d.Spec.Replicas)
+	}
+	if s.IsAutoscaled() {
+		status.Min = s.Autoscale.Min
+		status.Max = s.Autoscale.Max
+	}
+	return status, nil
+}
+
 //Destroy destroys the k8 deployment created by a service
 func Destroy(s *model.Service, e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) error {
 	deploymentName := s.Name
@@ -1,23 +1,28 @@
 package deployment
 
 import (
-	"fmt"
-	"strconv"
+	"sort"
+	"strings"
 
 	"bitbucket.org/okteto/okteto/backend/model"
-	uuid "github.com/satori/go.uuid"
 	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const configsChecksumAnnotation = "okteto.com/configs-checksum"
+
 func translate(s *model.Service, e *model.Environment) *appsv1.Deployment {
 	deploymentName := s.Name
 	replicas := int32(s.Replicas)
+	if s.IsAutoscaled() {
+		replicas = int32(s.Autoscale.Min)
+	}
 	gracePeriod := int64(s.GracePeriod)
 	volumes := []apiv1.Volume{}
-	for _, v := range s.Volumes {
+	for _, vName := range getVolumeNames(s.Volumes) {
+		v := s.Volumes[vName]
 		if v.Persistent {
 			volumes = append(
 				volumes,
@@ -35,77 +40,45 @@ func translate(s *model.Service, e *model.Environment) *appsv1.Deployment {
 			volumes = append(volumes, apiv1.Volume{Name: v.Name})
 		}
 	}
+	for _, name := range getConfigNames(s.Configs) {
+		volumes = append(
+			volumes,
+			apiv1.Volume{
+				Name: name,
+				VolumeSource: apiv1.VolumeSource{
+					ConfigMap: &apiv1.ConfigMapVolumeSource{
+						LocalObjectReference: apiv1.LocalObjectReference{Name: s.GetConfigMapName()},
+						Items:                []apiv1.KeyToPath{apiv1.KeyToPath{Key: name, Path: name}},
+					},
+				},
+			},
+		)
+	}
 	containers := []apiv1.Container{}
-	for name, c := range s.Containers {
-		ports := []apiv1.ContainerPort{}
-		for _, p := range c.Ports {
-			portInt64, _ := strconv.ParseInt(p, 10, 32)
-			ports = append(ports, apiv1.ContainerPort{
-				Protocol:      apiv1.ProtocolTCP,
-				ContainerPort: int32(portInt64),
-				Name:          fmt.Sprintf("p%s", p),
-			})
-		}
-		envs := []apiv1.EnvVar{}
-		for _, e := range c.Environment {
-			envs = append(envs, apiv1.EnvVar{
-				Name:  e.Name,
-				Value: e.Value,
-			})
-		}
-		volumeMounts := []apiv1.VolumeMount{}
-		for name, mount := range c.Mounts {
-			volumeMounts = append(volumeMounts, apiv1.VolumeMount{
-				Name:      name,
-				MountPath: mount.Path,
-			})
-		}
-		command := []string{}
-		if c.Command != "" {
-			command = append(command, c.Command)
-		}
-
-		container := apiv1.Container{
-			Name:         name,
-			Image:        c.Image,
-			WorkingDir:   c.WorkingDir,
-			Ports:        ports,
-			VolumeMounts: volumeMounts,
-			Env:          envs,
-			Command:      command,
-			Args:         c.Arguments,
-		}
-		if c.Resources != nil {
-			container.Resources = apiv1.ResourceRequirements{}
-			if c.Resources.Limits != nil {
-				quantMemory, _ := resource.ParseQuantity(c.Resources.Limits.Memory)
-				quantCPU, _ := resource.ParseQuantity(c.Resources.Limits.CPU)
-				container.Resources.Limits = apiv1.ResourceList{
-					apiv1.ResourceMemory: quantMemory,
-					apiv1.ResourceCPU:    quantCPU,
-				}
-			}
-			if c.Resources.Requests != nil {
-				quantMemory, _ := resource.ParseQuantity(c.Resources.Requests.Memory)
-				quantCPU, _ := resource.ParseQuantity(c.Resources.Requests.CPU)
-				container.Resources.Requests = apiv1.ResourceList{
-					apiv1.ResourceMemory: quantMemory,
-					apiv1.ResourceCPU:    quantCPU,
-				}
-			}
-		}
-		containers = append(containers, container)
+	for _, name := range getContainerNames(s.Containers) {
+		containers = append(containers, translateContainer(name, s.Containers[name], s))
+	}
+	initContainers := []apiv1.Container{}
+	for _, c := range s.InitContainers {
+		initContainers = append(initContainers, translateContainer(c.Name, &c.Container, s))
 	}
 
+	// the pods are rolled when their template changes, e.g. when an image is resolved to a new digest or the content
+	// of a config changes, so repeated renders of the same manifest must be identical
 	deploymentLabels := map[string]string{
-		"app":         deploymentName,
-		"okteto-uuid": uuid.NewV4().String(),
+		"app": deploymentName,
 	}
 
 	for k, v := range s.Labels {
 		deploymentLabels[k] = v
 	}
 
+	// the checksum rolls the pods when the content of the configs changes
+	podAnnotations := map[string]string{}
+	if len(s.Configs) > 0 {
+		podAnnotations[configsChecksumAnnotation] = s.GetConfigsChecksum()
+	}
+
 	var revisionHistoryLimit int32
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -121,12 +94,18 @@ func translate(s *model.Service, e *model.Environment) *appsv1.Deployment {
 			RevisionHistoryLimit: &revisionHistoryLimit,
 			Template: apiv1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: deploymentLabels,
+					Labels:      deploymentLabels,
+					Annotations: podAnnotations,
 				},
 				Spec: apiv1.PodSpec{
 					TerminationGracePeriodSeconds: &gracePeriod,
+					InitContainers:                initContainers,
 					Containers:                    containers,
 					Volumes:                       volumes,
+					NodeSelector:                  s.NodeSelector,
+					Tolerations:                   translateTolerations(s),
+					Affinity:                      translateAffinity(s),
+					PriorityClassName:             s.PriorityClass,
 				},
 			},
 		},
@@ -145,3 +124,186 @@ func translate(s *model.Service, e *model.Environment) *appsv1.Deployment {
 	}
 	return deployment
 }
+
+func translateContainer(name string, c *model.Container, s *model.Service) apiv1.Container {
+	ports := []apiv1.ContainerPort{}
+	for _, p := range c.Ports {
+		port, err := model.ParsePort(p)
+		if err != nil {
+			// the manifests are validated before they are deployed
+			continue
+		}
+
+		ports = append(ports, apiv1.ContainerPort{
+			Protocol:      apiv1.Protocol(port.Protocol),
+			ContainerPort: port.Number,
+			Name:          port.GetName(),
+		})
+	}
+	envs := []apiv1.EnvVar{}
+	for _, e := range c.Environment {
+		envs = append(envs, apiv1.EnvVar{
+			Name:  e.Name,
+			Value: e.Value,
+		})
+	}
+	volumeMounts := []apiv1.VolumeMount{}
+	for _, vName := range getMountNames(c.Mounts) {
+		volumeMount := apiv1.VolumeMount{
+			Name:      vName,
+			MountPath: c.Mounts[vName].Path,
+		}
+		if _, ok := s.Configs[vName]; ok {
+			volumeMount.SubPath = vName
+			volumeMount.ReadOnly = true
+		}
+		volumeMounts = append(volumeMounts, volumeMount)
+	}
+	command := []string{}
+	if c.Command != "" {
+		command = append(command, c.Command)
+	}
+
+	container := apiv1.Container{
+		Name:         name,
+		Image:        c.Image,
+		WorkingDir:   c.WorkingDir,
+		Ports:        ports,
+		VolumeMounts: volumeMounts,
+		Env:          envs,
+		Command:      command,
+		Args:         c.Arguments,
+	}
+	if c.SecurityContext != nil {
+		container.SecurityContext = translateSecurityContext(c.SecurityContext)
+	}
+	if c.Resources != nil {
+		container.Resources = apiv1.ResourceRequirements{}
+		if c.Resources.Limits != nil {
+			quantMemory, _ := resource.ParseQuantity(c.Resources.Limits.Memory)
+			quantCPU, _ := resource.ParseQuantity(c.Resources.Limits.CPU)
+			container.Resources.Limits = apiv1.ResourceList{
+				apiv1.ResourceMemory: quantMemory,
+				apiv1.ResourceCPU:    quantCPU,
+			}
+		}
+		if c.Resources.Requests != nil {
+			quantMemory, _ := resource.ParseQuantity(c.Resources.Requests.Memory)
+			quantCPU, _ := resource.ParseQuantity(c.Resources.Requests.CPU)
+			container.Resources.Requests = apiv1.ResourceList{
+				apiv1.ResourceMemory: quantMemory,
+				apiv1.ResourceCPU:    quantCPU,
+			}
+		}
+	}
+	return container
+}
+
+func translateSecurityContext(sc *model.SecurityContext) *apiv1.SecurityContext {
+	securityContext := &apiv1.SecurityContext{
+		RunAsUser:                sc.RunAsUser,
+		RunAsGroup:               sc.RunAsGroup,
+		AllowPrivilegeEscalation: sc.AllowPrivilegeEscalation,
+	}
+	if sc.RunAsNonRoot {
+		securityContext.RunAsNonRoot = &sc.RunAsNonRoot
+	}
+	if sc.ReadOnlyRootFilesystem {
+		securityContext.ReadOnlyRootFilesystem = &sc.ReadOnlyRootFilesystem
+	}
+	if sc.Capabilities != nil {
+		securityContext.Capabilities = &apiv1.Capabilities{
+			Add:  translateCapabilities(sc.Capabilities.Add),
+			Drop: translateCapabilities(sc.Capabilities.Drop),
+		}
+	}
+	return securityContext
+}
+
+func translateCapabilities(capabilities []string) []apiv1.Capability {
+	result := []apiv1.Capability{}
+	for _, c := range capabilities {
+		result = append(result, apiv1.Capability(strings.TrimPrefix(strings.ToUpper(c), "CAP_")))
+	}
+	return result
+}
+
+func translateTolerations(s *model.Service) []apiv1.Toleration {
+	if len(s.Tolerations) == 0 {
+		return nil
+	}
+	tolerations := []apiv1.Toleration{}
+	for _, t := range s.Tolerations {
+		tolerations = append(tolerations, apiv1.Toleration{
+			Key:      t.Key,
+			Operator: apiv1.TolerationOperator(t.Operator),
+			Value:    t.Value,
+			Effect:   apiv1.TaintEffect(t.Effect),
+		})
+	}
+	return tolerations
+}
+
+func translateAffinity(s *model.Service) *apiv1.Affinity {
+	term := apiv1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app": s.Name,
+			},
+		},
+		TopologyKey: "kubernetes.io/hostname",
+	}
+	switch s.AntiAffinity {
+	case model.RequiredAntiAffinity:
+		return &apiv1.Affinity{
+			PodAntiAffinity: &apiv1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []apiv1.PodAffinityTerm{term},
+			},
+		}
+	case model.PreferredAntiAffinity:
+		return &apiv1.Affinity{
+			PodAntiAffinity: &apiv1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []apiv1.WeightedPodAffinityTerm{
+					apiv1.WeightedPodAffinityTerm{Weight: 100, PodAffinityTerm: term},
+				},
+			},
+		}
+	}
+	return nil
+}
+
+func getContainerNames(containers map[string]*model.Container) []string {
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getVolumeNames(volumes map[string]*model.Volume) []string {
+	names := make([]string, 0, len(volumes))
+	for name := range volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getConfigNames(configs map[string]*model.Config) []string {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getMountNames(mounts map[string]*model.Mount) []string {
+	names := make([]string, 0, len(mounts))
+	for name := range mounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,179 @@
+package deployment
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"bitbucket.org/okteto/okteto/backend/model"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestTranslateContainerOrder(t *testing.T) {
+	s := &model.Service{
+		Name:     "test",
+		Replicas: 1,
+		Volumes: map[string]*model.Volume{
+			"data":  &model.Volume{Name: "data"},
+			"cache": &model.Volume{Name: "cache"},
+		},
+		InitContainers: []*model.InitContainer{
+			&model.InitContainer{Name: "migrate", Container: model.Container{Image: "app", Command: "migrate"}},
+			&model.InitContainer{Name: "assets", Container: model.Container{Image: "app", Command: "fetch"}},
+		},
+		Containers: map[string]*model.Container{
+			"web":    &model.Container{Image: "app"},
+			"api":    &model.Container{Image: "app"},
+			"worker": &model.Container{Image: "app"},
+			"cache": &model.Container{
+				Image:  "redis",
+				Mounts: map[string]*model.Mount{"data": &model.Mount{Path: "/data"}, "cache": &model.Mount{Path: "/cache"}},
+			},
+		},
+	}
+	e := &model.Environment{Name: "env"}
+
+	for i := 0; i < 10; i++ {
+		spec := translate(s, e).Spec.Template.Spec
+		if names := getNames(spec.Containers); !reflect.DeepEqual(names, []string{"api", "cache", "web", "worker"}) {
+			t.Fatalf("wrong container order: %v", names)
+		}
+		if names := getNames(spec.InitContainers); !reflect.DeepEqual(names, []string{"migrate", "assets"}) {
+			t.Fatalf("wrong init container order: %v", names)
+		}
+		if spec.Volumes[0].Name != "cache" || spec.Volumes[1].Name != "data" {
+			t.Fatalf("wrong volume order: %+v", spec.Volumes)
+		}
+		if spec.Containers[1].VolumeMounts[0].Name != "cache" || spec.Containers[1].VolumeMounts[1].Name != "data" {
+			t.Fatalf("wrong mount order: %+v", spec.Containers[1].VolumeMounts)
+		}
+	}
+}
+
+func TestTranslateIsDeterministic(t *testing.T) {
+	s := &model.Service{
+		Name:     "test",
+		Replicas: 1,
+		Labels:   map[string]string{"team": "api"},
+		InitContainers: []*model.InitContainer{
+			&model.InitContainer{Name: "migrate", Container: model.Container{Image: "app"}},
+		},
+		Containers: map[string]*model.Container{
+			"web": &model.Container{Image: "app", Ports: []string{"8080"}},
+			"api": &model.Container{Image: "app"},
+		},
+		Configs: map[string]*model.Config{"nginx.conf": &model.Config{Name: "nginx.conf", Content: "server {}"}},
+	}
+	e := &model.Environment{Name: "env"}
+
+	first, err := json.Marshal(translate(s, e))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := json.Marshal(translate(s, e))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first, second) {
+		t.Errorf("the renders of the same service are different:\n%s\n%s", first, second)
+	}
+}
+
+func getNames(containers []apiv1.Container) []string {
+	names := []string{}
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestTranslateScheduling(t *testing.T) {
+	s := &model.Service{
+		Name:          "test",
+		Replicas:      2,
+		NodeSelector:  map[string]string{"pool": "spot"},
+		Tolerations:   []*model.Toleration{&model.Toleration{Key: "spot", Operator: "Exists", Effect: "NoSchedule"}},
+		PriorityClass: "low",
+		Containers: map[string]*model.Container{
+			"web": &model.Container{Image: "app"},
+		},
+	}
+	e := &model.Environment{Name: "env"}
+
+	spec := translate(s, e).Spec.Template.Spec
+	if spec.NodeSelector["pool"] != "spot" || spec.PriorityClassName != "low" {
+		t.Errorf("wrong scheduling: %+v", spec)
+	}
+	if len(spec.Tolerations) != 1 || spec.Tolerations[0].Operator != apiv1.TolerationOpExists || spec.Tolerations[0].Effect != apiv1.TaintEffectNoSchedule {
+		t.Errorf("wrong tolerations: %+v", spec.Tolerations)
+	}
+	if spec.Affinity != nil {
+		t.Errorf("unexpected affinity: %+v", spec.Affinity)
+	}
+
+	s.AntiAffinity = model.RequiredAntiAffinity
+	spec = translate(s, e).Spec.Template.Spec
+	terms := spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(terms) != 1 || terms[0].TopologyKey != "kubernetes.io/hostname" || terms[0].LabelSelector.MatchLabels["app"] != "test" {
+		t.Errorf("wrong required anti affinity: %+v", terms)
+	}
+
+	s.AntiAffinity = model.PreferredAntiAffinity
+	spec = translate(s, e).Spec.Template.Spec
+	if len(spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("wrong preferred anti affinity: %+v", spec.Affinity.PodAntiAffinity)
+	}
+}
+
+func TestTranslateSecurityContext(t *testing.T) {
+	user := int64(1000)
+	no := false
+	s := &model.Service{
+		Name:     "test",
+		Replicas: 1,
+		Containers: map[string]*model.Container{
+			"web": &model.Container{
+				Image: "app",
+				SecurityContext: &model.SecurityContext{
+					RunAsUser:                &user,
+					ReadOnlyRootFilesystem:   true,
+					AllowPrivilegeEscalation: &no,
+					Capabilities:             &model.Capabilities{Drop: []string{"all"}, Add: []string{"CAP_NET_BIND_SERVICE"}},
+				},
+			},
+			"sidecar": &model.Container{Image: "proxy"},
+		},
+	}
+	e := &model.Environment{Name: "env"}
+
+	containers := translate(s, e).Spec.Template.Spec.Containers
+	if containers[0].SecurityContext != nil {
+		t.Errorf("unexpected security context: %+v", containers[0].SecurityContext)
+	}
+
+	sc := containers[1].SecurityContext
+	if *sc.RunAsUser != 1000 || sc.RunAsNonRoot != nil || !*sc.ReadOnlyRootFilesystem || *sc.AllowPrivilegeEscalation {
+		t.Errorf("wrong security context: %+v", sc)
+	}
+	if sc.Capabilities.Drop[0] != "ALL" || sc.Capabilities.Add[0] != "NET_BIND_SERVICE" {
+		t.Errorf("wrong capabilities: %+v", sc.Capabilities)
+	}
+}
+
+func TestTranslateContainerPorts(t *testing.T) {
+	s := &model.Service{Name: "dns"}
+	c := &model.Container{Image: "coredns", Ports: []string{"53/udp", "53", "metrics:9153"}}
+
+	expected := []apiv1.ContainerPort{
+		apiv1.ContainerPort{Name: "p53-udp", Protocol: apiv1.ProtocolUDP, ContainerPort: 53},
+		apiv1.ContainerPort{Name: "p53", Protocol: apiv1.ProtocolTCP, ContainerPort: 53},
+		apiv1.ContainerPort{Name: "metrics", Protocol: apiv1.ProtocolTCP, ContainerPort: 9153},
+	}
+
+	if ports := translateContainer("coredns", c, s).Ports; !reflect.DeepEqual(ports, expected) {
+		t.Errorf("expected %+v, got %+v", expected, ports)
+	}
+}
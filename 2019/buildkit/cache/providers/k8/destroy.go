@@ -4,9 +4,12 @@ import (
 	logger "log"
 
 	"bitbucket.org/okteto/okteto/backend/model"
+	k8Autoscaler "bitbucket.org/okteto/okteto/backend/providers/k8/autoscaler"
 	"bitbucket.org/okteto/okteto/backend/providers/k8/client"
+	k8ConfigMap "bitbucket.org/okteto/okteto/backend/providers/k8/configmap"
 	k8Deployment "bitbucket.org/okteto/okteto/backend/providers/k8/deployment"
 	k8Ingress "bitbucket.org/okteto/okteto/backend/providers/k8/ingress"
+	"bitbucket.org/okteto/okteto/backend/providers/k8/namespace"
 	k8Service "bitbucket.org/okteto/okteto/backend/providers/k8/service"
 	k8Volume "bitbucket.org/okteto/okteto/backend/providers/k8/volume"
 )
@@ -26,9 +29,15 @@ func Destroy(s *model.Service, e *model.Environment, log *logger.Logger) error {
 	if err = k8Service.Destroy(s, e, c, log); err != nil {
 		return err
 	}
+	if err := k8Autoscaler.Destroy(s, e, c, log); err != nil {
+		return err
+	}
 	if err := k8Deployment.Destroy(s, e, c, log); err != nil {
 		return err
 	}
+	if err := k8ConfigMap.Destroy(s, e, c, log); err != nil {
+		return err
+	}
 	if s.Volumes == nil {
 		s.Volumes = map[string]*model.Volume{}
 	}
@@ -46,3 +55,15 @@ func Destroy(s *model.Service, e *model.Environment, log *logger.Logger) error {
 	}
 	return nil
 }
+
+//DestroyNamespace deletes the k8 namespace of an environment
+func DestroyNamespace(e *model.Environment, log *logger.Logger) error {
+	if e.Provider.IsTestProvider() {
+		return nil
+	}
+	c, err := client.Get(e.Provider)
+	if err != nil {
+		return err
+	}
+	return namespace.Destroy(e, c, log)
+}
@@ -1,8 +1,6 @@
 package ingress
 
 import (
-	"strconv"
-
 	"bitbucket.org/okteto/okteto/backend/model"
 	v1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -41,14 +39,18 @@ func translate(s *model.Service, e *model.Environment) *v1beta1.Ingress {
 		ingress.Spec.Rules = append(ingress.Spec.Rules, ir)
 		for _, i := range s.GetIngressRules(true) {
 			if i.GetIngressHostname(e) == hostname {
-				portNumber, _ := strconv.Atoi(i.Port)
+				port, err := model.ParsePort(i.Port)
+				if err != nil || port.Protocol != model.TCP {
+					continue
+				}
+
 				ir.IngressRuleValue.HTTP.Paths = append(
 					ir.IngressRuleValue.HTTP.Paths,
 					v1beta1.HTTPIngressPath{
 						Path: i.Path,
 						Backend: v1beta1.IngressBackend{
 							ServiceName: serviceName,
-							ServicePort: intstr.FromInt(portNumber),
+							ServicePort: intstr.FromInt(int(port.Number)),
 						},
 					},
 				)
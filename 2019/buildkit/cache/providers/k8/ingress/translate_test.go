@@ -267,3 +267,37 @@ func TestTranslate(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslateNamedAndUDPPorts(t *testing.T) {
+	service := &model.Service{
+		Name: "test",
+		Containers: map[string]*model.Container{
+			"api": &model.Container{
+				Ports: []string{"http:8080", "dns:53/udp"},
+			},
+		},
+	}
+	e := &model.Environment{
+		Name:        "environment-1",
+		ProjectName: "environment",
+		Provider: &model.Provider{
+			Ingress: &model.IngressController{
+				Domain: "test.okteto.net",
+			},
+		},
+	}
+
+	result := translate(service, e)
+	expected := []v1beta1.HTTPIngressPath{
+		v1beta1.HTTPIngressPath{
+			Path: "/",
+			Backend: v1beta1.IngressBackend{
+				ServiceName: "test",
+				ServicePort: intstr.FromInt(8080),
+			},
+		},
+	}
+	if len(result.Spec.Rules) != 1 || !reflect.DeepEqual(result.Spec.Rules[0].HTTP.Paths, expected) {
+		t.Errorf("ingressTranslate(): %+v, expected the paths: %+v", result.Spec.Rules, expected)
+	}
+}
@@ -20,7 +20,7 @@ func Create(e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) e
 		return fmt.Errorf("Error getting kubernetes namespace: %s", err)
 	}
 	if n.Name == "" {
-		n := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: e.Name}}
+		n := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: e.Name, Labels: e.Labels}}
 		_, err := c.Core().Namespaces().Create(n)
 		if err != nil {
 			return fmt.Errorf("Error creating kubernetes namespace: %s", err)
@@ -31,3 +31,32 @@ func Create(e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) e
 	}
 	return nil
 }
+
+//Destroy deletes the namespace of a given project with every resource in it. The namespace must have the labels of
+//the environment, so a namespace that wasn't created for it isn't deleted
+func Destroy(e *model.Environment, c *kubernetes.Clientset, log *logger.Logger) error {
+	log.Printf("Deleting namespace '%s'...", e.Name)
+	n, err := c.Core().Namespaces().Get(e.Name, metav1.GetOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			log.Printf("Namespace '%s' was already deleted.", e.Name)
+			return nil
+		}
+		return fmt.Errorf("Error getting kubernetes namespace: %s", err)
+	}
+	for k, v := range e.Labels {
+		if n.Labels[k] != v {
+			return fmt.Errorf("namespace '%s' doesn't have the label %s=%s, it wasn't deleted", e.Name, k, v)
+		}
+	}
+	err = c.Core().Namespaces().Delete(e.Name, &metav1.DeleteOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			log.Printf("Namespace '%s' was already deleted.", e.Name)
+			return nil
+		}
+		return fmt.Errorf("Error deleting kubernetes namespace: %s", err)
+	}
+	log.Printf("Deleted namespace '%s'.", e.Name)
+	return nil
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"fmt"
-	"strconv"
 
 	"bitbucket.org/okteto/okteto/backend/model"
 	apiv1 "k8s.io/api/core/v1"
@@ -21,7 +20,7 @@ func translate(s *model.Service, e *model.Environment) []*apiv1.Service {
 			Spec: apiv1.ServiceSpec{
 				Selector: map[string]string{"app": s.Name},
 				Type:     apiv1.ServiceTypeClusterIP,
-				Ports:    getK8Ports(s.GetPrivatePorts()),
+				Ports:    getK8Ports(s.GetServicePorts(s.GetPrivatePorts())),
 			},
 		},
 	)
@@ -37,7 +36,7 @@ func translate(s *model.Service, e *model.Environment) []*apiv1.Service {
 			Spec: apiv1.ServiceSpec{
 				Selector: map[string]string{"app": s.Name},
 				Type:     apiv1.ServiceTypeLoadBalancer,
-				Ports:    getK8Ports(s.GetLoadBalancerPorts()),
+				Ports:    getK8Ports(s.GetServicePorts(s.GetLoadBalancerPorts())),
 			},
 		},
 	)
@@ -48,16 +47,21 @@ func getLoadBalancerName(name string) string {
 	return fmt.Sprintf("%s-load-balancer", name)
 }
 
-func getK8Ports(ports []string) []apiv1.ServicePort {
+func getK8Ports(ports []*model.ServicePort) []apiv1.ServicePort {
 	result := []apiv1.ServicePort{}
 	for _, port := range ports {
-		portInt64, _ := strconv.ParseInt(port, 10, 32)
+		targetPort := intstr.FromInt(int(port.Number))
+		if port.TargetName {
+			targetPort = intstr.FromString(port.GetName())
+		}
+
 		result = append(
 			result,
 			apiv1.ServicePort{
-				Name:       fmt.Sprintf("p%s", port),
-				Port:       int32(portInt64),
-				TargetPort: intstr.IntOrString{StrVal: port},
+				Name:       port.GetName(),
+				Protocol:   apiv1.Protocol(port.Protocol),
+				Port:       port.Number,
+				TargetPort: targetPort,
 			})
 	}
 	return result
@@ -49,18 +49,21 @@ func TestTranslate(t *testing.T) {
 						Ports: []apiv1.ServicePort{
 							apiv1.ServicePort{
 								Name:       "p443",
+								Protocol:   apiv1.ProtocolTCP,
 								Port:       int32(443),
-								TargetPort: intstr.IntOrString{StrVal: "443"},
+								TargetPort: intstr.FromString("p443"),
 							},
 							apiv1.ServicePort{
 								Name:       "p8080",
+								Protocol:   apiv1.ProtocolTCP,
 								Port:       int32(8080),
-								TargetPort: intstr.IntOrString{StrVal: "8080"},
+								TargetPort: intstr.FromInt(8080),
 							},
 							apiv1.ServicePort{
 								Name:       "p80",
+								Protocol:   apiv1.ProtocolTCP,
 								Port:       int32(80),
-								TargetPort: intstr.IntOrString{StrVal: "80"},
+								TargetPort: intstr.FromInt(80),
 							},
 						},
 					},
@@ -75,8 +78,9 @@ func TestTranslate(t *testing.T) {
 						Ports: []apiv1.ServicePort{
 							apiv1.ServicePort{
 								Name:       "p443",
+								Protocol:   apiv1.ProtocolTCP,
 								Port:       int32(443),
-								TargetPort: intstr.IntOrString{StrVal: "443"},
+								TargetPort: intstr.FromString("p443"),
 							},
 						},
 					},
@@ -119,18 +123,71 @@ func TestTranslate(t *testing.T) {
 						Ports: []apiv1.ServicePort{
 							apiv1.ServicePort{
 								Name:       "p443",
+								Protocol:   apiv1.ProtocolTCP,
 								Port:       int32(443),
-								TargetPort: intstr.IntOrString{StrVal: "443"},
+								TargetPort: intstr.FromString("p443"),
 							},
 							apiv1.ServicePort{
 								Name:       "p8080",
+								Protocol:   apiv1.ProtocolTCP,
 								Port:       int32(8080),
-								TargetPort: intstr.IntOrString{StrVal: "8080"},
+								TargetPort: intstr.FromInt(8080),
 							},
 							apiv1.ServicePort{
 								Name:       "p80",
+								Protocol:   apiv1.ProtocolTCP,
 								Port:       int32(80),
-								TargetPort: intstr.IntOrString{StrVal: "80"},
+								TargetPort: intstr.FromInt(80),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "udp-and-named-ports",
+			service: model.Service{
+				Name: "dns",
+				Containers: map[string]*model.Container{
+					"coredns": &model.Container{
+						Ports:  []string{"53/udp", "53", "metrics:9153"},
+						Expose: []string{"9153"},
+					},
+				},
+			},
+			environment: model.Environment{
+				Provider: &model.Provider{
+					Ingress: &model.IngressController{
+						Domain: "example.com",
+					},
+				},
+			},
+			expected: []*apiv1.Service{
+				&apiv1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "dns",
+					},
+					Spec: apiv1.ServiceSpec{
+						Selector: map[string]string{"app": "dns"},
+						Type:     apiv1.ServiceTypeClusterIP,
+						Ports: []apiv1.ServicePort{
+							apiv1.ServicePort{
+								Name:       "p53-udp",
+								Protocol:   apiv1.ProtocolUDP,
+								Port:       int32(53),
+								TargetPort: intstr.FromString("p53-udp"),
+							},
+							apiv1.ServicePort{
+								Name:       "p53",
+								Protocol:   apiv1.ProtocolTCP,
+								Port:       int32(53),
+								TargetPort: intstr.FromString("p53"),
+							},
+							apiv1.ServicePort{
+								Name:       "metrics",
+								Protocol:   apiv1.ProtocolTCP,
+								Port:       int32(9153),
+								TargetPort: intstr.FromString("metrics"),
 							},
 						},
 					},
@@ -6,7 +6,7 @@ func TestMemoryStore(t *testing.T) {
 	db := NewMemoryStore()
 	defer db.Close()
 
-	for _, tbl := range []string{"services", "users", "activities", "activity_logs"} {
+	for _, tbl := range []string{"services", "users", "activities", "activity_logs", "activity_images", "previews"} {
 		if !db.HasTable(tbl) {
 			t.Errorf("%s wasn't created", tbl)
 		}
@@ -18,7 +18,7 @@ import (
 )
 
 const (
-	currentSchema = 10
+	currentSchema = 14
 )
 
 // InitSQLStore creates the tables and migrates if needed
@@ -46,10 +46,17 @@ func createTables(db *gorm.DB) error {
 		&model.User{},
 		&model.Activity{},
 		&model.ActivityLog{},
+		&model.ActivityImage{},
 		&model.Project{},
 		&model.ProjectACL{},
 		&model.GHRepoLink{},
-		&model.GHInstallation{})
+		&model.GHInstallation{},
+		&model.Preview{},
+		&model.GHDelivery{},
+		&model.GHLinkManifest{},
+		&model.ProjectWebhook{},
+		&model.WebhookDelivery{},
+		&model.EmailPreferences{})
 
 	if result.Error != nil {
 		return errors.Wrap(result.Error, "Failed to create the tables")